@@ -1,26 +1,226 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
 	"plotng/internal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
+func debugBundle(args []string) {
+	flags := flag.NewFlagSet("debug-bundle", flag.ExitOnError)
+	configFile := flags.String("config", "", "configuration file to include, without passwords")
+	host := flags.String("host", "localhost", "host server name, default: localhost")
+	port := flags.Int("port", 8484, "host server port number, default: 8484")
+	output := flags.String("o", fmt.Sprintf("plotng-debug-%s.tar.gz", time.Now().Format("20060102-150405")), "output file")
+	flags.Parse(args)
+	if err := internal.WriteDebugBundle(fmt.Sprintf("%s:%d", *host, *port), *configFile, *output); err != nil {
+		log.Fatalf("Failed to write debug bundle: %s", err)
+	}
+	fmt.Printf("Debug bundle written to %s\n", *output)
+}
+
+// intList parses a comma separated list of numbers.
+func intList(name string, value string) []int {
+	var list []int
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); len(field) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid -%s: %s", name, field)
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+func whatIf(args []string) {
+	flags := flag.NewFlagSet("what-if", flag.ExitOnError)
+	configFile := flags.String("config", "config.json", "configuration file to replay")
+	history := flags.String("history", "", "history file, the output of GET /history or history.json of a debug bundle, default: fetched from the server")
+	host := flags.String("host", "localhost", "host server name, default: localhost")
+	port := flags.Int("port", 8484, "host server port number, default: 8484")
+	parallel := flags.String("parallel", "", "NumberOfParallelPlots to try, comma separated, default: from the configuration")
+	stagger := flags.String("stagger", "", "StaggeringDelay in minutes to try, comma separated, default: from the configuration")
+	delay := flags.String("delay", "", "DelaysBetweenPlot in minutes to try, comma separated, default: from the configuration")
+	days := flags.Int("days", 7, "days to replay, default: 7")
+	flags.Parse(args)
+	request := &internal.WhatIfRequest{
+		ConfigPath:  *configFile,
+		HistoryPath: *history,
+		Host:        fmt.Sprintf("%s:%d", *host, *port),
+		Parallel:    intList("parallel", *parallel),
+		Stagger:     intList("stagger", *stagger),
+		Delay:       intList("delay", *delay),
+		Days:        *days,
+	}
+	log.SetOutput(ioutil.Discard) // the decisions of every replayed cycle
+	err := internal.WriteWhatIf(os.Stdout, request)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatalf("What-if failed: %s", err)
+	}
+}
+
+func status(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	host := flags.String("host", "localhost", "host server names, comma separated, with :port unless 8484, default: localhost")
+	plain := flags.Bool("plain", false, "plain text without colours, eg. for watch or to paste in a chat")
+	flags.Parse(args)
+	if err := internal.WriteStatus(os.Stdout, *host, !*plain); err != nil {
+		log.Fatalf("Status failed: %s", err)
+	}
+}
+
+func alertRules(args []string) {
+	flags := flag.NewFlagSet("alert-rules", flag.ExitOnError)
+	failureRate := flags.Float64("failure-rate", 0.2, "share of failed plots which alerts, default: 0.2")
+	window := flags.Duration("window", 6*time.Hour, "period of the failure rate, default: 6h")
+	tempFree := flags.Float64("temp-free", 10, "percentage of free space below which a temp directory alerts, default: 10")
+	destPlots := flags.Int("dest-plots", 5, "plots of room left in the dest directories of a host below which it alerts, default: 5")
+	noPlots := flags.Int("no-plots-hours", 12, "hours without a finished plot which alert, default: 12")
+	alertmanager := flags.String("alertmanager", "", "print an example Alertmanager configuration sending the alerts to this webhook URL instead")
+	flags.Parse(args)
+	var err error
+	if len(*alertmanager) > 0 {
+		err = internal.WriteAlertmanagerConfig(os.Stdout, *alertmanager)
+	} else {
+		if *failureRate <= 0 || *failureRate > 1 || *window < time.Minute || *tempFree <= 0 || *destPlots < 0 || *noPlots <= 0 {
+			log.Fatalf("Invalid threshold, see plotng alert-rules -h")
+		}
+		err = internal.WriteAlertRules(os.Stdout, internal.AlertRuleOptions{
+			FailureRate:  *failureRate,
+			Window:       *window,
+			TempFree:     *tempFree,
+			DestPlots:    *destPlots,
+			NoPlotsHours: *noPlots,
+		})
+	}
+	if err != nil {
+		log.Fatalf("Failed to write the alerting rules: %s", err)
+	}
+}
+
+func doctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configFile := flags.String("config", "config.json", "configuration file to check")
+	port := flags.Int("port", 8484, "port the server will listen on, default: 8484")
+	timeUrl := flags.String("time-url", "https://www.cloudflare.com", "HTTPS server whose time the clock is compared with, empty to skip")
+	flags.Parse(args)
+	if err := internal.RunDoctor(os.Stdout, internal.DoctorOptions{ConfigPath: *configFile, Port: *port, TimeUrl: *timeUrl}); err != nil {
+		os.Exit(1)
+	}
+}
+
+func service(args []string) {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		log.Fatalf("Usage: plotng service install|uninstall [options], see plotng service install -h")
+	}
+	flags := flag.NewFlagSet("service "+args[0], flag.ExitOnError)
+	name := flags.String("name", "plotng", "name of the service, or label of the launchd job, default: plotng")
+	configFile := flags.String("config", "config.json", "configuration file of the server, default: config.json")
+	port := flags.Int("port", 8484, "server port number, default: 8484")
+	user := flags.String("user", "", "account to run the server as, whose chia keys are used, default: LocalSystem on Windows, root or the current user on macOS")
+	password := flags.String("password", "", "password of -user, Windows only")
+	flags.Parse(args[1:])
+	if args[0] == "uninstall" {
+		if err := internal.UninstallService(*name); err != nil {
+			log.Fatalf("Failed to uninstall service %s: %s", *name, err)
+		}
+		fmt.Printf("Service %s uninstalled\n", *name)
+		return
+	}
+	options := internal.ServiceOptions{Name: *name, ConfigPath: *configFile, Port: *port, User: *user, Password: *password}
+	if err := internal.InstallService(options); err != nil {
+		log.Fatalf("Failed to install service %s: %s", *name, err)
+	}
+	fmt.Printf("Service %s installed and started, it logs to plotng.log next to the configuration file\n", *name)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "debug-bundle" {
+		debugBundle(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "what-if" {
+		whatIf(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		status(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "alert-rules" {
+		alertRules(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		service(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		doctor(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "simulate-plotter" {
+		if err := internal.SimulatePlotter(os.Args[2:]); err != nil {
+			log.Fatalf("Simulated plotter failed: %s", err)
+		}
+		return
+	}
 	configFile := flag.String("config", "", "configuration file")
 	ui := flag.Bool("ui", false, "launch UI client only, it will attempt to connect to server")
+	accessible := flag.Bool("accessible", false, "with -ui, print the changes as plain lines for screen readers instead of drawing the UI")
 	host := flag.String("host", "localhost", "host server name, default: localhost")
 	port := flag.Int("port", 8484, "host server port number, default: 8484")
+	setup := flag.Bool("setup", false, "run the setup wizard to write the configuration file given by -config")
+	simulate := flag.Bool("simulate", false, "plot with a fake plotter instead of chia, to try PlotNG without plotting hardware")
+	simulateSpeed := flag.Float64("simulate-speed", 60, "simulated seconds per second with -simulate, default: 60")
+	simulateFailures := flag.Float64("simulate-failures", 0, "percentage of simulated plots which fail, default: 0")
 
 	flag.Parse()
+	if *setup {
+		if len(*configFile) == 0 {
+			*configFile = "config.json"
+		}
+		if err := internal.RunSetupWizard(*configFile); err != nil {
+			log.Fatalf("Setup failed: %s", err)
+		}
+		return
+	}
 	if flag.Parsed() == false || (len(*configFile) == 0 && *ui == false) {
 		flag.Usage()
 		return
 	}
-	if *ui {
+	if *ui && *accessible {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		internal.Follow(ctx, os.Stdout, *host)
+	} else if *ui {
 		client := &internal.Client{}
 		client.ProcessLoop(*host)
 	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 		server := &internal.Server{}
-		server.ProcessLoop(*configFile, *port)
+		if *simulate {
+			if *simulateSpeed <= 0 {
+				log.Fatalf("Invalid -simulate-speed: %g", *simulateSpeed)
+			}
+			server.Simulate(*simulateSpeed, *simulateFailures)
+		}
+		internal.RunDaemon(ctx, *configFile, func(ctx context.Context) {
+			server.ProcessLoop(ctx, *configFile, *port)
+		})
 	}
 }
@@ -3,8 +3,10 @@ module plotng
 go 1.16
 
 require (
-	github.com/gdamore/tcell v1.4.0 // indirect
 	github.com/gdamore/tcell/v2 v2.3.1
+	github.com/mattn/go-runewidth v0.0.10
 	github.com/ricochet2200/go-disk-usage v0.0.0-20150921141558-f0d1b743428f
 	github.com/rivo/tview v0.0.0-20210312174852-ae9464cc3598
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/sys v0.0.0-20210309074719-68d13333faf2
 )
@@ -1,18 +1,17 @@
 package internal
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"plotng/internal/format"
 )
 
 const KB = uint64(1024)
@@ -21,14 +20,9 @@ const GB = KB * KB * KB
 const TB = KB * KB * KB * KB
 const PLOT_SIZE = 105 * GB
 
-const (
-	PlotRunning = iota
-	PlotError
-	PlotFinished
-	PlotKilled
-)
-
-type ActivePlot struct {
+// PlotStatus is what the UI and the API know about a plot.  The plot's goroutines change it with
+// ActivePlot.lock held, everybody else reads a copy taken by Snapshot.
+type PlotStatus struct {
 	PlotId          int64
 	StartTime       time.Time
 	EndTime         time.Time
@@ -37,6 +31,7 @@ type ActivePlot struct {
 	Fingerprint     string
 	FarmerPublicKey string
 	PoolPublicKey   string
+	PoolContract    string
 	Threads         int
 	PlotSize        int
 	Buffers         int
@@ -44,8 +39,8 @@ type ActivePlot struct {
 
 	Phase            string
 	Tail             []string
-	State            int
-	lock             sync.RWMutex
+	State            PlotState
+	StateHistory     []StateChange
 	Id               string
 	Progress         string
 	Phase1Time       time.Time
@@ -55,25 +50,88 @@ type ActivePlot struct {
 	UseTargetForTmp2 bool
 	BucketSize       int
 	SavePlotLogDir   string
-	process          *os.Process
+	Overdue          bool
+	Labels           []string
+	Profile          string
+	TransferRate     uint64
+	TransferredBytes uint64
+	TransferSize     uint64
+	IsolateWorkDir   bool
+	WorkDir          string
+	Subfolder        string
+	Plotter          string
+	LastError        string
+	Failure          FailureCategory
+	Suspended        bool
+	Audit            PlotAudit
+	DroppedLogLines  uint64
+	Revision         int64
+}
+
+type ActivePlot struct {
+	PlotStatus
+	lock           sync.RWMutex
+	cancel         context.CancelFunc
+	transfers      *transferManager
+	s3             *s3Credentials
+	chiaVersion    *chiaVersionRule
+	keychain       *keychain
+	copyGroup      string
+	maxCopies      int
+	maxRate        float64
+	writeSpeeds    *writeSpeeds
+	events         *eventBus
+	clock          Clock // of the state changes, the wall clock when nil
+	revisions      *revisionCounter
+	simulation     *simulation
+	fs             Filesystem
+	diskSpaceCheck bool
+	runAs          *plotUser   // user of the plotter, nil to keep the user of PlotNG
+	owner          *plotUser   // owner of the plots, work directories and subfolders
+	fileMode       os.FileMode // of the final plot, 0 for the umask
+	dirMode        os.FileMode // of the subfolders created in the dest directory, 0 for the umask
+	lastStep       time.Time
+	commandLine    string
+	exited         bool
+	exitCode       int
+	recentLog      []string
+}
+
+// Snapshot returns a copy of the plot's status which the plot's goroutines won't change.
+func (ap *ActivePlot) Snapshot() PlotStatus {
+	ap.lock.RLock()
+	defer ap.lock.RUnlock()
+	status := ap.PlotStatus
+	status.Tail = append([]string(nil), ap.Tail...)
+	status.StateHistory = append([]StateChange(nil), ap.StateHistory...)
+	status.Labels = append([]string(nil), ap.Labels...)
+	return status
+}
+
+// currentState returns the state of the plot, which kill can change at any time.
+func (ap *ActivePlot) currentState() PlotState {
+	ap.lock.RLock()
+	defer ap.lock.RUnlock()
+	return ap.State
 }
 
 // getPhaseTime returns the end time of a phase. phase 0 is the start time
 // of the entire plot, and phase 4 is the end time of the entire plot.
 // TODO: We can change the ActivePlot structure to be PhaseTime [5]time.Time,
-//       but that's a protocol change.
-func (ap *ActivePlot) getPhaseTime(phase int) time.Time {
+//
+//	but that's a protocol change.
+func (ps *PlotStatus) getPhaseTime(phase int) time.Time {
 	switch phase {
 	case 0:
-		return ap.StartTime
+		return ps.StartTime
 	case 1:
-		return ap.Phase1Time
+		return ps.Phase1Time
 	case 2:
-		return ap.Phase2Time
+		return ps.Phase2Time
 	case 3:
-		return ap.Phase3Time
+		return ps.Phase3Time
 	case 4:
-		return ap.EndTime
+		return ps.EndTime
 	default:
 		panic("request for invalid phase time")
 	}
@@ -81,8 +139,8 @@ func (ap *ActivePlot) getPhaseTime(phase int) time.Time {
 
 // getCurrentPhase returns the current phase, or a negative number to indicate an error.
 // TODO: We can also change this to be part of the structure, but that's also a protocol change.
-func (ap *ActivePlot) getCurrentPhase() int {
-	parts := strings.Split(ap.Phase, "/")
+func (ps *PlotStatus) getCurrentPhase() int {
+	parts := strings.Split(ps.Phase, "/")
 	if len(parts) != 2 {
 		return -1
 	} else if i, err := strconv.Atoi(parts[0]); err != nil {
@@ -94,51 +152,89 @@ func (ap *ActivePlot) getCurrentPhase() int {
 
 // getProgress returns the current progress, or a negative number to indicate an error
 // TODO: We can also change this to be part of the structure, but that's also a protocol change.
-func (ap *ActivePlot) getProgress() int {
-	if len(ap.Progress) == 0 {
+func (ps *PlotStatus) getProgress() int {
+	if len(ps.Progress) == 0 {
 		return -1
-	} else if i, err := strconv.Atoi(ap.Progress[:len(ap.Progress)-1]); err != nil {
+	} else if i, err := strconv.Atoi(ps.Progress[:len(ps.Progress)-1]); err != nil {
 		return -2
 	} else {
 		return i
 	}
 }
 
-func (ap *ActivePlot) Duration(currentTime time.Time) string {
-	return DurationString(currentTime.Sub(ap.StartTime))
+func (ps *PlotStatus) Duration(currentTime time.Time) string {
+	if ps.StartTime.IsZero() {
+		return format.Duration(0)
+	}
+	return format.Duration(currentTime.Sub(ps.StartTime))
 }
 
 func (ap *ActivePlot) String(showLog bool) string {
-	ap.lock.RLock()
-	state := "Unknown"
-	switch ap.State {
-	case PlotRunning:
-		state = "Running"
-	case PlotError:
-		state = "Errored"
-	case PlotFinished:
-		state = "Finished"
-	}
-	s := fmt.Sprintf("Plot [%s] - %s, Phase: %s %s, Start Time: %s, Duration: %s, Tmp Dir: %s, Dst Dir: %s\n", ap.Id, state, ap.Phase, ap.Progress, ap.StartTime.Format("2006-01-02 15:04:05"), ap.Duration(time.Now()), ap.PlotDir, ap.TargetDir)
+	status := ap.Snapshot()
+	s := fmt.Sprintf("Plot [%s] - %s, Phase: %s %s, Start Time: %s, Duration: %s, Tmp Dir: %s, Dst Dir: %s\n", status.Id, status.State, status.Phase, status.Progress, format.Time(status.StartTime), status.Duration(time.Now()), status.PlotDir, status.TargetDir)
 	if showLog {
-		for _, l := range ap.Tail {
+		for _, l := range status.Tail {
 			s += fmt.Sprintf("\t%s", l)
 		}
 	}
-	ap.lock.RUnlock()
 	return s
 }
 
-func (ap *ActivePlot) RunPlot() {
+// tempDir returns the directory the plot's temp files are written to.
+func (ap *ActivePlot) tempDir() string {
+	if len(ap.WorkDir) > 0 {
+		return ap.WorkDir
+	}
+	return ap.PlotDir
+}
+
+// RunPlot plots, copies and verifies the plot.  Cancelling ctx kills chia and aborts the copy,
+// ctx is cancelled when RunPlot returns so nothing started for the plot outlives it.
+func (ap *ActivePlot) RunPlot(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ap.lock.Lock()
+	ap.cancel = cancel
 	ap.StartTime = time.Now()
-	defer func() {
-		ap.EndTime = time.Now()
-	}()
+	ap.changed()
+	ap.lock.Unlock()
+	if !ap.setState(PlotSpaceCheck) {
+		return // killed while queued
+	}
+	if err := ap.checkSpace(); err != nil {
+		ap.fail("space check failed: %s", err)
+		return
+	}
+	if err := ap.checkKeys(); err != nil {
+		ap.fail("key check failed: %s", err)
+		return
+	}
+	if ap.IsolateWorkDir {
+		ap.lock.Lock()
+		ap.WorkDir = filepath.Join(ap.PlotDir, fmt.Sprintf("plotng-%d", ap.PlotId))
+		ap.changed()
+		ap.lock.Unlock()
+		if err := ap.filesystem().MkdirAll(ap.WorkDir, 0755); err != nil {
+			ap.fail("failed to create work directory: %s", err)
+			return
+		}
+		ap.runAs.chown(ap.WorkDir) // the plotter writes in it
+	}
+	// When we handle the transfer chia leaves the final plot in the temp directory, and we move it.
+	finalDir := ap.finalDir()
+	if ap.transfers != nil {
+		finalDir = ap.tempDir()
+	} else if len(ap.Subfolder) > 0 {
+		if err := ap.makeSubfolder(finalDir, ap.runAs); err != nil { // the plotter writes in it
+			ap.fail("failed to create plot subfolder: %s", err)
+			return
+		}
+	}
 	args := []string{
 		"plots", "create",
 		"-n1",
-		"-t" + ap.PlotDir,
-		"-d" + ap.TargetDir,
+		"-t" + ap.tempDir(),
+		"-d" + finalDir,
 	}
 	if len(ap.Fingerprint) > 0 {
 		args = append(args, "-a"+ap.Fingerprint)
@@ -149,6 +245,13 @@ func (ap *ActivePlot) RunPlot() {
 	if len(ap.PoolPublicKey) > 0 {
 		args = append(args, "-p"+ap.PoolPublicKey)
 	}
+	if len(ap.PoolContract) > 0 {
+		if ap.chiaVersion != nil && !ap.chiaVersion.poolContract {
+			log.Printf("Plot [%d] PoolContractAddress ignored, this chia version doesn't support pool contracts", ap.PlotId)
+		} else {
+			args = append(args, "-c"+ap.PoolContract)
+		}
+	}
 	if ap.Threads > 0 {
 		args = append(args, fmt.Sprintf("-r%d", ap.Threads))
 	}
@@ -183,114 +286,208 @@ func (ap *ActivePlot) RunPlot() {
 	if ap.DisableBitField {
 		args = append(args, "-e")
 	}
-	if ap.UseTargetForTmp2 {
+	if ap.UseTargetForTmp2 && ap.transfers == nil {
 		args = append(args, "-2"+ap.TargetDir)
 	}
 	if ap.BucketSize > 0 {
 		args = append(args, fmt.Sprintf("-u%d", ap.BucketSize))
 	}
 
-	cmd := exec.Command("chia", args...)
-	ap.State = PlotRunning
+	cmd := ap.plotterCommand(ctx, args)
+	ap.runAs.runAs(cmd)
+	if !ap.setState(PlotRunning) {
+		return
+	}
 	if stderr, err := cmd.StderrPipe(); err != nil {
-		ap.State = PlotError
-		log.Printf("Failed to start Plotting: %s", err)
+		ap.fail("failed to start plotting: %s", err)
 		return
 	} else {
-		go ap.processLogs(stderr)
+		go ap.processLogs(ctx, stderr)
 	}
 	if stdout, err := cmd.StdoutPipe(); err != nil {
-		ap.State = PlotError
-		log.Printf("Failed to start Plotting: %s", err)
+		ap.fail("failed to start plotting: %s", err)
 		return
 	} else {
-		go ap.processLogs(stdout)
+		go ap.processLogs(ctx, stdout)
 	}
 	//log.Println(cmd.String())
 
 	if err := cmd.Start(); err != nil {
-		log.Printf("Failed to start chia command: %s", err)
-		ap.State = PlotError
+		if ctx.Err() == nil {
+			ap.fail("failed to start chia command: %s", err)
+		}
 		return
 	} else {
-		ap.process = cmd.Process
+		ap.lock.Lock()
 		ap.Pid = cmd.Process.Pid
+		ap.commandLine = cmd.String()
+		ap.changed()
+		ap.lock.Unlock()
 		if err := cmd.Wait(); err != nil {
-			if ap.State != PlotKilled {
-				ap.State = PlotError
-				log.Printf("Plotting Exit with Error: %s", err)
+			if ctx.Err() == nil {
+				ap.plotterExited(err)
+				ap.fail("plotting exited with error: %s", err)
 			} else {
-				log.Printf("Plot [%s] Killed", ap.Id)
+				log.Printf("Plot [%d] Killed", ap.PlotId)
 			}
 			ap.cleanup()
 			return
 		}
 	}
-	ap.State = PlotFinished
-	return
-}
-
-func (ap *ActivePlot) processLogs(in io.ReadCloser) {
-	reader := bufio.NewReader(in)
-	var logFile *os.File
-	for {
-		if s, err := reader.ReadString('\n'); err != nil {
-			break
-		} else {
-			if strings.HasPrefix(s, "Starting phase ") {
-				ap.Phase = s[15:18]
-				switch ap.Phase {
-				case "2/4":
-					ap.Phase1Time = time.Now()
-				case "3/4":
-					ap.Phase2Time = time.Now()
-				case "4/4":
-					ap.Phase3Time = time.Now()
-				}
-			}
-			if strings.HasPrefix(s, "ID: ") {
-				ap.Id = strings.TrimSuffix(s[4:], "\n")
-				if len(ap.SavePlotLogDir) > 0 {
-					logFilePath := filepath.Join(ap.SavePlotLogDir, fmt.Sprintf("plotng_log_%s.txt", ap.Id))
-					logFile, err = os.Create(logFilePath)
-					if err != nil {
-						break
-					}
-					for _, l := range ap.Tail {
-						logFile.Write([]byte(l))
-					}
-				}
-			}
-			for phaseStr, progress := range progressTable {
-				if strings.Index(s, phaseStr) >= 0 {
-					ap.Progress = progress
-					break
-				}
-			}
-			ap.lock.Lock()
-			if logFile != nil {
-				logFile.Write([]byte(s))
-			}
-			ap.Tail = append(ap.Tail, s)
-			if len(ap.Tail) > 20 {
-				ap.Tail = ap.Tail[len(ap.Tail)-20:]
+	if ctx.Err() != nil {
+		return
+	}
+	if ap.transfers != nil {
+		if err := ap.moveFinalPlot(ctx); err != nil {
+			if ctx.Err() == nil {
+				ap.fail("failed to copy final plot, it is left in %s: %s", ap.tempDir(), err)
+			} else {
+				log.Printf("Plot [%d] Killed while copying, the final plot is left in %s", ap.PlotId, ap.tempDir())
 			}
-			ap.lock.Unlock()
+			return
 		}
 	}
+	ap.setState(PlotVerifying)
+	if err := ap.verifyFinalPlot(); err != nil {
+		ap.fail("verification failed: %s", err)
+		return
+	}
+	ap.setFinalPlotAccess()
+	if len(ap.WorkDir) > 0 {
+		ap.cleanup()
+	}
+	ap.setState(PlotFinished)
 	return
 }
 
+// finalDir returns the local directory the final plot goes to, the subfolder of its dest directory.
+func (ap *ActivePlot) finalDir() string {
+	return filepath.Join(ap.TargetDir, filepath.FromSlash(ap.Subfolder))
+}
+
+// checkSpace makes sure the temp and target directories are reachable, and with DiskSpaceCheck
+// that a local target still has room for the plot.
+func (ap *ActivePlot) checkSpace() error {
+	fs := ap.filesystem()
+	if err := probeDirectory(fs, ap.PlotDir); err != nil {
+		return err
+	}
+	if isRemoteTarget(ap.TargetDir) {
+		return nil
+	}
+	if err := probeDirectory(fs, ap.TargetDir); err != nil {
+		return err
+	}
+	if available := fs.Available(ap.TargetDir); ap.diskSpaceCheck && available < PLOT_SIZE {
+		return fmt.Errorf("only %s available in %s", format.Space(available), ap.TargetDir)
+	}
+	return nil
+}
+
+// expectedPlotSize is the size of an uncompressed plot of size k, scaled from the 101.4 GiB of a
+// k32 plot: every k doubles the number of entries, and entries grow with k.
+func expectedPlotSize(k int) uint64 {
+	k = plotK(k)
+	size := 101.4 * float64(GB) * float64(2*k+1) / 65
+	for ; k > 32; k-- {
+		size *= 2
+	}
+	for ; k < 32; k++ {
+		size /= 2
+	}
+	return uint64(size)
+}
+
+// minPlotSizeRatio is how much smaller than expected a plot may be, plot sizes vary slightly.
+const minPlotSizeRatio = 0.95
+
+// verifyFinalPlot checks that the plot file made it to a local target directory in one piece, a
+// plot cut short by a flaky drive is smaller than plots of its size ever are.
+func (ap *ActivePlot) verifyFinalPlot() error {
+	if isRemoteTarget(ap.TargetDir) {
+		return nil
+	}
+	id := ap.Snapshot().Id
+	if len(id) == 0 {
+		log.Printf("Plot [%d] has no plot ID, can't verify the plot file", ap.PlotId)
+		return nil
+	}
+	fs := ap.filesystem()
+	name, err := findFinalPlot(fs, ap.finalDir(), id)
+	if err != nil {
+		return err
+	}
+	stat, err := fs.Stat(filepath.Join(ap.finalDir(), name))
+	if err != nil {
+		return err
+	}
+	if stat.Size() == 0 {
+		return fmt.Errorf("%s is empty", name)
+	}
+	if ap.simulation != nil {
+		return nil // the fake plotter writes small files
+	}
+	if expected := expectedPlotSize(ap.PlotSize); uint64(stat.Size()) < uint64(float64(expected)*minPlotSizeRatio) {
+		return fmt.Errorf("%s is truncated, it has %d bytes where a k%d plot has about %s", name, stat.Size(), plotK(ap.PlotSize), format.Space(expected))
+	}
+	return nil
+}
+
+// plotK returns the k of a plot, PlotSize 0 means k32.
+func plotK(plotSize int) int {
+	if plotSize == 0 {
+		return 32
+	}
+	return plotSize
+}
+
+// checkAudit warns when the plotter reports different keys than the ones the plot was started with.
+func (ap *ActivePlot) checkAudit(audit PlotAudit) {
+	if len(audit.FarmerPublicKey) > 0 && len(ap.FarmerPublicKey) > 0 && !strings.EqualFold(strings.TrimPrefix(audit.FarmerPublicKey, "0x"), strings.TrimPrefix(ap.FarmerPublicKey, "0x")) {
+		log.Printf("WARNING: Plot [%s] uses farmer key %s, expected %s", ap.Id, audit.FarmerPublicKey, ap.FarmerPublicKey)
+	}
+	if len(audit.PoolPublicKey) > 0 && len(ap.PoolPublicKey) > 0 && !strings.EqualFold(strings.TrimPrefix(audit.PoolPublicKey, "0x"), strings.TrimPrefix(ap.PoolPublicKey, "0x")) {
+		log.Printf("WARNING: Plot [%s] uses pool key %s, expected %s", ap.Id, audit.PoolPublicKey, ap.PoolPublicKey)
+	}
+	if len(audit.PoolContract) > 0 && len(ap.PoolContract) > 0 && audit.PoolContract != ap.PoolContract {
+		log.Printf("WARNING: Plot [%s] uses pool contract %s, expected %s", ap.Id, audit.PoolContract, ap.PoolContract)
+	}
+}
+
+// kill cancels a plot which hasn't been copied yet, a plot being verified can't be cancelled.
+func (ap *ActivePlot) kill() {
+	if !ap.setState(PlotKilled) {
+		return
+	}
+	ap.lock.RLock()
+	cancel := ap.cancel
+	ap.lock.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 func (ap *ActivePlot) cleanup() {
-	if len(ap.Id) == 0 {
+	id := ap.Snapshot().Id
+	fs := ap.filesystem()
+	if len(ap.WorkDir) > 0 {
+		// Everything in the work directory belongs to this plot.
+		if err := fs.RemoveAll(ap.WorkDir); err == nil {
+			log.Printf("Directory: %s deleted\n", ap.WorkDir)
+		} else {
+			log.Printf("Failed to delete directory: %s\n", ap.WorkDir)
+		}
+		return
+	}
+	if len(id) == 0 {
 		return
 	}
-	if fileList, err := ioutil.ReadDir(ap.PlotDir); err == nil {
+	if fileList, err := fs.ReadDir(ap.PlotDir); err == nil {
 		for _, file := range fileList {
-			if strings.Index(file.Name(), ap.Id) >= 0 && strings.HasSuffix(file.Name(), ".tmp") {
+			if strings.Index(file.Name(), id) >= 0 && strings.HasSuffix(file.Name(), ".tmp") {
 				fullPath := fmt.Sprintf("%s%c%s", ap.PlotDir, os.PathSeparator, file.Name())
 
-				if err := os.Remove(fullPath); err == nil {
+				if err := fs.Remove(fullPath); err == nil {
 					log.Printf("File: %s deleted\n", fullPath)
 				} else {
 					log.Printf("Failed to delete file: %s\n", fullPath)
@@ -299,30 +496,3 @@ func (ap *ActivePlot) cleanup() {
 		}
 	}
 }
-
-/*
-Progress from Chia docs
-https://github.com/Chia-Network/chia-blockchain/wiki/Beginners-Guide#create-a-plot
-*/
-var progressTable = map[string]string{
-	"Computing table 1":          "1%",
-	"Computing table 2":          "6%",
-	"Computing table 3":          "12%",
-	"Computing table 4":          "20%",
-	"Computing table 5":          "28%",
-	"Computing table 6":          "36%",
-	"Computing table 7":          "42%",
-	"Backpropagating on table 7": "43%",
-	"Backpropagating on table 6": "48%",
-	"Backpropagating on table 5": "51%",
-	"Backpropagating on table 4": "55%",
-	"Backpropagating on table 3": "58%",
-	"Backpropagating on table 2": "61%",
-	"Compressing tables 1 and 2": "66%",
-	"Compressing tables 2 and 3": "73%",
-	"Compressing tables 3 and 4": "79%",
-	"Compressing tables 4 and 5": "85%",
-	"Compressing tables 5 and 6": "92%",
-	"Compressing tables 6 and 7": "98%",
-	"Write checkpoint tables":    "100%",
-}
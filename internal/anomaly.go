@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// minAnomalySamples is the number of finished plots a profile needs before its P95 is trusted.
+const minAnomalySamples = 5
+
+// plotProfile groups plots that are expected to take about the same time.
+func plotProfile(plot *PlotStatus) string {
+	plotSize := plot.PlotSize
+	if plotSize == 0 {
+		plotSize = 32
+	}
+	return fmt.Sprintf("k%d %s", plotSize, plot.PlotDir)
+}
+
+// durationP95 returns the 95th percentile duration of the finished plots of every profile.  The
+// caller must hold server.lock.
+func (server *Server) durationP95() map[string]time.Duration {
+	durations := map[string][]time.Duration{}
+	for _, plot := range server.archive {
+		status := plot.Snapshot()
+		if status.State == PlotFinished {
+			profile := plotProfile(&status)
+			durations[profile] = append(durations[profile], status.EndTime.Sub(status.StartTime))
+		}
+	}
+	p95 := map[string]time.Duration{}
+	for profile, list := range durations {
+		if len(list) < minAnomalySamples {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		p95[profile] = list[(len(list)*95+99)/100-1]
+	}
+	return p95
+}
+
+// checkOverduePlots flags active plots which have been running longer than the P95 of their
+// profile, which usually means the temp drive is degrading.
+func (server *Server) checkOverduePlots(now time.Time) {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	p95 := server.durationP95()
+	for _, plot := range server.active {
+		status := plot.Snapshot()
+		limit, ok := p95[plotProfile(&status)]
+		if !ok || status.Overdue || status.State != PlotRunning || status.StartTime.IsZero() {
+			continue
+		}
+		if elapsed := now.Sub(status.StartTime); elapsed > limit {
+			plot.lock.Lock()
+			plot.Overdue = true
+			plot.changed()
+			plot.lock.Unlock()
+			message := fmt.Sprintf("Plot [%s] in %s has been running for %s, longer than the P95 of %s", status.Id, status.PlotDir, format.Duration(elapsed), format.Duration(limit))
+			log.Print(message)
+			notify(server.config.CurrentConfig, "Slow plot", message)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The HTTP API is served under /v<major> for every major version the server speaks, and without
+// prefix as apiLegacyMajor for the clients from before versioning.  Every response gives the
+// version of the server in X-API-Version and the major versions it serves in X-API-Versions;
+// servers from before versioning send neither.
+const (
+	apiVersionHeader  = "X-API-Version"
+	apiVersionsHeader = "X-API-Versions"
+	apiMajor          = 1 // spoken by the client, the newest served
+	apiMinMajor       = 1 // the oldest served
+	apiLegacyMajor    = 1 // of the paths without prefix
+)
+
+// apiPrefix is the prefix of the paths of the version the client speaks.
+var apiPrefix = fmt.Sprintf("/v%d", apiMajor)
+
+// apiMajors returns the major versions the server serves, for X-API-Versions.
+func apiMajors() string {
+	var majors []string
+	for major := apiMinMajor; major <= apiMajor; major++ {
+		majors = append(majors, strconv.Itoa(major))
+	}
+	return strings.Join(majors, ", ")
+}
+
+// apiPath splits the version prefix off the path of a request and returns an error when the
+// server doesn't serve the version asked for.
+func apiPath(path string) (string, error) {
+	major := apiLegacyMajor
+	if strings.HasPrefix(path, "/v") {
+		end := len(path)
+		if i := strings.IndexByte(path[1:], '/'); i >= 0 {
+			end = i + 1
+		}
+		if n, err := strconv.Atoi(path[2:end]); err == nil {
+			major = n
+			path = "/" + strings.TrimPrefix(path[end:], "/")
+		}
+	}
+	switch {
+	case major > apiMajor:
+		return "", fmt.Errorf("API v%d is not supported, this server speaks v%s: upgrade the server", major, apiMajors())
+	case major < apiMinMajor:
+		return "", fmt.Errorf("API v%d is no longer supported, this server speaks v%s: upgrade the client", major, apiMajors())
+	}
+	return path, nil
+}
+
+// withPath returns a shallow copy of req for path, as http.StripPrefix does.
+func withPath(req *http.Request, path string) *http.Request {
+	stripped := new(http.Request)
+	*stripped = *req
+	stripped.URL = new(url.URL)
+	*stripped.URL = *req.URL
+	stripped.URL.Path = path
+	stripped.URL.RawPath = ""
+	return stripped
+}
+
+// apiHosts remembers the prefix of the API of every host a client talks to, learnt from the
+// first response: apiPrefix, or none for the servers from before versioning.  The ConfigToken of
+// a host, when known, is sent with every request.
+type apiHosts struct {
+	lock     sync.Mutex
+	prefixes map[string]string
+	tokens   map[string]string
+}
+
+// do sends a request to path on the API of host and checks that the server speaks the version of
+// the client.  A server from before versioning, which ignores the prefix, is asked again without.
+func (hosts *apiHosts) do(httpClient *http.Client, host string, method string, path string, body []byte) (*http.Response, error) {
+	hosts.lock.Lock()
+	prefix, known := hosts.prefixes[host]
+	token := hosts.tokens[host]
+	hosts.lock.Unlock()
+	if !known {
+		prefix = apiPrefix
+	}
+	resp, err := apiRequest(httpClient, "http://"+host+prefix+path, method, body, token)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Header.Get(apiVersionHeader)) == 0 {
+		prefix = ""
+	} else if err := checkApiVersions(host, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	hosts.lock.Lock()
+	if hosts.prefixes == nil {
+		hosts.prefixes = map[string]string{}
+	}
+	hosts.prefixes[host] = prefix
+	hosts.lock.Unlock()
+	if !known && len(prefix) == 0 {
+		resp.Body.Close()
+		return apiRequest(httpClient, "http://"+host+path, method, body, token)
+	}
+	return resp, nil
+}
+
+// apiRequest sends a request with an optional JSON body and bearer token.
+func apiRequest(httpClient *http.Client, target string, method string, body []byte, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return httpClient.Do(req)
+}
+
+// checkApiVersions returns an error saying which side to upgrade when the server answering resp
+// doesn't serve the version of the client.
+func checkApiVersions(host string, resp *http.Response) error {
+	for _, major := range strings.Split(resp.Header.Get(apiVersionsHeader), ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(major)); err == nil && n == apiMajor {
+			return nil
+		}
+	}
+	version := resp.Header.Get(apiVersionHeader)
+	if major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0]); err == nil && major < apiMajor {
+		return fmt.Errorf("%s speaks API v%s, older than v%d of this client: upgrade the server", host, version, apiMajor)
+	}
+	message, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%s speaks API v%s, not v%d of this client: upgrade the client (%s)", host, version, apiMajor, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// defaultBufferOffloadPercent is the fill level of a buffer directory at which it is drained.
+const defaultBufferOffloadPercent = 80
+
+// bufferOffload moves the plots from the buffer directories to the dest directories in the
+// background, one plot at a time, so the plotters only ever write to the fast local buffers.
+type bufferOffload struct {
+	// draining holds the buffers being drained, from reaching BufferOffloadPercent until no
+	// finished plot is left on them.
+	draining   map[string]bool
+	move       *RebalanceMove
+	nextTarget int
+}
+
+// bufferTarget returns the buffer directory with the most space left after the plots already
+// writing to it, or targetDir when no buffer has room for another plot.  Like the dest
+// directories, the room is only checked with DiskSpaceCheck.  The caller must hold server.lock.
+func (server *Server) bufferTarget(config *Config, targetDir string) string {
+	best, bestSpace := "", uint64(0)
+	for _, dir := range config.BufferDirectory {
+		if server.overlay.Disabled[dir] || server.offlineTargets[dir] {
+			continue
+		}
+		needed := uint64(server.countPlotsUsing(dir)+1) * PLOT_SIZE
+		space := server.getDiskSpaceAvailable(dir)
+		if !config.DiskSpaceCheck {
+			needed = 0
+		}
+		if space >= needed && (len(best) == 0 || space-needed > bestSpace) {
+			best, bestSpace = dir, space-needed
+		}
+	}
+	if len(best) == 0 {
+		if len(config.BufferDirectory) > 0 {
+			server.schedulerEvent("No buffer directory has room for a plot, writing to [%s]", targetDir)
+		}
+		return targetDir
+	}
+	return best
+}
+
+// bufferLevel returns how full the drive of a buffer directory is, in percent.
+func (server *Server) bufferLevel(dir string) float64 {
+	size := server.filesystem().Size(dir)
+	if size == 0 {
+		return 0
+	}
+	return float64(size-server.getDiskSpaceAvailable(dir)) / float64(size) * 100
+}
+
+// bufferedPlots returns the finished plots in a buffer directory and its subfolders, oldest first.
+// Plots still written or verified by an active plot are left out.  The caller must hold server.lock.
+func (server *Server) bufferedPlots(dir string) []plotFile {
+	inUse := map[string]bool{}
+	for _, plot := range server.active {
+		if status := plot.Snapshot(); status.TargetDir == dir && len(status.Id) > 0 {
+			inUse[status.Id] = true
+		}
+	}
+	var plots []plotFile
+	server.filesystem().Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".plot") || inUse[plotIdFromFileName(info.Name())] {
+			return nil
+		}
+		plots = append(plots, plotFile{path: path, size: uint64(info.Size()), modTime: info.ModTime(), subfolder: plotSubfolderOf(dir, path)})
+		return nil
+	})
+	sort.Slice(plots, func(i, j int) bool { return plots[i].modTime.Before(plots[j].modTime) })
+	return plots
+}
+
+// offloadTarget picks the next dest directory round robin for the plot of status, skipping the
+// ones for other keys, the disabled, offline and evacuated ones and the local ones without room
+// for the plot.  The caller must hold server.lock.
+func (server *Server) offloadTarget(config *Config, size uint64, status *PlotStatus) string {
+	targets := config.TargetDirectory
+	for i := 0; i < len(targets); i++ {
+		dir := targets[(server.offload.nextTarget+i)%len(targets)]
+		if !config.acceptsPlot(dir, status) {
+			continue
+		}
+		if _, evacuating := server.overlay.Evacuating[dir]; evacuating || server.overlay.Disabled[dir] || server.offlineTargets[dir] {
+			continue
+		}
+		if !isRemoteTarget(dir) && server.getDiskSpaceAvailable(dir) < size+GB {
+			continue
+		}
+		server.offload.nextTarget = (server.offload.nextTarget + i + 1) % len(targets)
+		return dir
+	}
+	return ""
+}
+
+// checkOffload starts draining the buffer directories which reached BufferOffloadPercent, and
+// starts moving their oldest plot unless a move is already running.
+func (server *Server) checkOffload(config *Config) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if len(config.BufferDirectory) == 0 {
+		server.offload = nil
+		return
+	}
+	if server.offload == nil {
+		server.offload = &bufferOffload{draining: map[string]bool{}}
+	}
+	if server.offload.move != nil {
+		return
+	}
+	threshold := config.BufferOffloadPercent
+	if threshold <= 0 {
+		threshold = defaultBufferOffloadPercent
+	}
+	for _, dir := range config.BufferDirectory {
+		if server.offload.draining[dir] {
+			continue
+		}
+		if level := server.bufferLevel(dir); level >= threshold && len(server.bufferedPlots(dir)) > 0 {
+			server.schedulerEvent("Buffer [%s] is %.0f%% full, offloading its plots", dir, level)
+			server.offload.draining[dir] = true
+		}
+	}
+	for _, dir := range config.BufferDirectory {
+		if !server.offload.draining[dir] {
+			continue
+		}
+		plots := server.bufferedPlots(dir)
+		if len(plots) == 0 {
+			server.schedulerEvent("Buffer [%s] is drained", dir)
+			delete(server.offload.draining, dir)
+			continue
+		}
+		// With DestinationKeys a plot may only go to the drives of its farm, the oldest plot
+		// which can go somewhere is moved.
+		var plot plotFile
+		var targetDir string
+		for _, plot = range plots {
+			if targetDir = server.offloadTarget(config, plot.size, server.bufferedPlotStatus(plot.path)); len(targetDir) > 0 {
+				break
+			}
+		}
+		if len(targetDir) == 0 {
+			server.schedulerEvent("Skipping offload of [%s], no dest directory has room for %s", dir, format.Space(plots[0].size))
+			return
+		}
+		move := &RebalanceMove{
+			Source:    plot.path,
+			TargetDir: targetDir,
+			Subfolder: plot.subfolder,
+			Size:      plot.size,
+			State:     MoveRunning,
+			plot: &ActivePlot{
+				PlotStatus: PlotStatus{
+					Id:        filepath.Base(plot.path),
+					TargetDir: targetDir,
+				},
+				transfers:   server.transfers,
+				writeSpeeds: server.writeSpeeds,
+				copyGroup:   copyGroup(config, targetDir),
+				maxCopies:   config.MaxConcurrentCopiesPerTarget,
+			},
+		}
+		server.offload.move = move
+		go server.runOffload(server.ctx, move)
+		return
+	}
+}
+
+// runOffload moves one plot out of its buffer directory.
+func (server *Server) runOffload(ctx context.Context, move *RebalanceMove) {
+	log.Printf("Offloading %s to %s", move.Source, move.TargetDir)
+	plot := move.plot
+	start := time.Now()
+	err := plot.transfers.acquire(ctx, plot.copyGroup, plot.maxCopies)
+	if err == nil {
+		err = plot.transferPlot(ctx, move.Source, move.TargetDir, move.name())
+		plot.transfers.release(plot.copyGroup)
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if err != nil {
+		move.State = MoveFailed
+		move.Error = err.Error()
+		server.schedulerEvent("Failed to offload %s to %s: %s", move.Source, move.TargetDir, err)
+	} else {
+		move.State = MoveDone
+		log.Printf("Offloaded %s to %s in %s", move.Source, move.TargetDir, format.Duration(time.Since(start)))
+	}
+	if server.offload != nil {
+		server.offload.move = nil
+	}
+}
+
+// offloadSnapshot returns the buffer directories, true for the ones being drained, and the plot
+// being moved out of them with its progress.  The caller must hold server.lock.
+func (server *Server) offloadSnapshot(config *Config) (buffers map[string]bool, move *RebalanceMove) {
+	if server.offload == nil || config == nil {
+		return nil, nil
+	}
+	buffers = map[string]bool{}
+	for _, dir := range config.BufferDirectory {
+		buffers[dir] = server.offload.draining[dir]
+	}
+	if server.offload.move != nil {
+		m := *server.offload.move
+		status := m.plot.Snapshot()
+		m.TransferredBytes = status.TransferredBytes
+		m.TransferRate = status.TransferRate
+		m.plot = nil
+		move = &m
+	}
+	return
+}
+
+// bufferString returns the suffix shown after a buffer directory, with the progress of the plot
+// being offloaded from it.
+func bufferString(msg *Msg, dir string) string {
+	if _, ok := msg.Buffers[dir]; !ok {
+		return ""
+	}
+	if move := msg.Offload; move != nil && filepath.Dir(move.Source) == dir && move.Size > 0 {
+		return fmt.Sprintf(" (buffer, offloading %d%%)", move.TransferredBytes*100/move.Size)
+	}
+	if msg.Buffers[dir] {
+		return " (buffer, draining)"
+	}
+	return " (buffer)"
+}
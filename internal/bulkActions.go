@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// BulkResult is the answer of the bulk actions: the number of plots killed, retried or cleared,
+// and the jobs queued to retry the failed plots.
+type BulkResult struct {
+	Count int
+	Jobs  []*PlotJob
+}
+
+// audit records a bulk action in the log and the events, with the address it was requested from.
+func (server *Server) audit(req *http.Request, format string, args ...interface{}) {
+	server.schedulerEvent("Audit: %s, requested by %s", fmt.Sprintf(format, args...), req.RemoteAddr)
+}
+
+// writeBulkResult returns result as JSON.
+func writeBulkResult(resp http.ResponseWriter, result *BulkResult) {
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(result)
+}
+
+// handleKillAll kills all active plots on POST /plots/kill.  The scheduler keeps starting new
+// plots as usual.
+func (server *Server) handleKillAll(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result := &BulkResult{}
+	server.lock.Lock()
+	for _, plot := range server.active {
+		plot.kill()
+		if plot.currentState() == PlotKilled {
+			result.Count++
+		}
+	}
+	total := len(server.active)
+	server.lock.Unlock()
+	server.audit(req, "killed %d of %d active plots", result.Count, total)
+	writeBulkResult(resp, result)
+}
+
+// handleRetryFailed queues a job on POST /plots/retry for every failed plot of the archive which
+// wasn't retried yet, with the directories, keys and parameters of the plot.
+func (server *Server) handleRetryFailed(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result := &BulkResult{}
+	server.lock.Lock()
+	if server.retried == nil {
+		server.retried = map[int64]bool{}
+	}
+	for _, plot := range server.archive {
+		status := plot.Snapshot()
+		if status.State != PlotError || server.retried[status.PlotId] {
+			continue
+		}
+		job := &PlotJob{
+			JobId:           server.nextJobId,
+			SubmitTime:      server.now(),
+			TempDir:         status.PlotDir,
+			TargetDir:       status.TargetDir,
+			PlotSize:        status.PlotSize,
+			Fingerprint:     status.Fingerprint,
+			FarmerPublicKey: status.FarmerPublicKey,
+			PoolPublicKey:   status.PoolPublicKey,
+			Threads:         status.Threads,
+			Buffers:         status.Buffers,
+			Labels:          status.Labels,
+		}
+		server.nextJobId++
+		server.queue = append(server.queue, job)
+		server.retried[status.PlotId] = true
+		result.Jobs = append(result.Jobs, job)
+		result.Count++
+	}
+	server.lock.Unlock()
+	server.audit(req, "queued %d failed plots again", result.Count)
+	writeBulkResult(resp, result)
+}
+
+// handleClearArchive removes the archived plots on DELETE /archive, filtered with from, to and
+// state like GET /history.
+func (server *Server) handleClearArchive(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "DELETE" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseHistoryQuery(req.URL.Query())
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := &BulkResult{}
+	server.lock.Lock()
+	var kept []*ActivePlot
+	for _, plot := range server.archive {
+		status := plot.Snapshot()
+		if !query.matches(&status) {
+			kept = append(kept, plot)
+			continue
+		}
+		server.forgetArchived(plot, &status)
+		result.Count++
+	}
+	server.archive = kept
+	server.lock.Unlock()
+	server.audit(req, "cleared %d archived plots", result.Count)
+	writeBulkResult(resp, result)
+}
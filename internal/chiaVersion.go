@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// chiaVersionRule describes the arguments and log format of a range of chia releases.
+type chiaVersionRule struct {
+	minVersion   [3]int // inclusive
+	maxVersion   [3]int // exclusive
+	logParser    string
+	poolContract bool // supports -c <pool contract address>
+}
+
+// chiaVersionRules lists the known chia releases, the last entry is used for unknown versions.
+var chiaVersionRules = []*chiaVersionRule{
+	{minVersion: [3]int{1, 0, 0}, maxVersion: [3]int{1, 2, 0}, logParser: "chia", poolContract: false},
+	{minVersion: [3]int{1, 2, 0}, maxVersion: [3]int{3, 0, 0}, logParser: "chia", poolContract: true},
+}
+
+var chiaVersionRegexp = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// parseChiaVersion extracts the release number from the output of "chia version", eg. "1.2.11.dev0".
+func parseChiaVersion(s string) ([3]int, error) {
+	var version [3]int
+	match := chiaVersionRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return version, fmt.Errorf("unrecognised chia version: %s", strings.TrimSpace(s))
+	}
+	for i := 0; i < 3; i++ {
+		version[i], _ = strconv.Atoi(match[i+1])
+	}
+	return version, nil
+}
+
+func compareVersion(a, b [3]int) int {
+	for i := 0; i < 3; i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+// findChiaVersionRule returns the rule covering version, or nil if the version is unknown.
+func findChiaVersionRule(version [3]int) *chiaVersionRule {
+	for _, rule := range chiaVersionRules {
+		if compareVersion(version, rule.minVersion) >= 0 && compareVersion(version, rule.maxVersion) < 0 {
+			return rule
+		}
+	}
+	return nil
+}
+
+// detectChiaVersion runs "chia version" and selects the matching rule, warning and falling back to
+// the newest known rule when the version is not recognised.
+func detectChiaVersion() *chiaVersionRule {
+	latest := chiaVersionRules[len(chiaVersionRules)-1]
+	out, err := exec.Command("chia", "version").Output()
+	if err != nil {
+		log.Printf("WARNING: failed to detect chia version, assuming a recent release: %s", err)
+		return latest
+	}
+	version, err := parseChiaVersion(string(out))
+	if err != nil {
+		log.Printf("WARNING: %s, assuming a recent release", err)
+		return latest
+	}
+	rule := findChiaVersionRule(version)
+	if rule == nil {
+		log.Printf("WARNING: chia version %d.%d.%d is not known to PlotNG, plot arguments and log parsing may not match", version[0], version[1], version[2])
+		return latest
+	}
+	log.Printf("Detected chia version %d.%d.%d", version[0], version[1], version[2])
+	return rule
+}
@@ -3,14 +3,20 @@ package internal
 import (
 	"encoding/gob"
 	"fmt"
+	"log"
 	"math"
 	"net/http"
+	"os"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 
+	"plotng/internal/format"
+	"plotng/internal/locale"
 	"plotng/internal/widget"
 )
 
@@ -22,12 +28,63 @@ type Client struct {
 	archivedPlotsTable *widget.SortedTable
 
 	logTextbox          *tview.TextView
+	heatmap             *widget.Heatmap
+	plotsSparkline      *widget.Sparkline
+	tempSparkline       *widget.Sparkline
+	statsTable          *widget.SortedTable
+	eventsTextbox       *tview.TextView
+	quotaTable          *widget.SortedTable
+	orphansTable        *widget.SortedTable
+	queueTable          *widget.SortedTable
+	distributionTable   *widget.SortedTable
+	rebalanceTable      *widget.SortedTable
+	rebalancePlans      map[string]*Rebalance
+	settingsForm        *tview.Form
+	settingsHost        string
+	compareTable        *widget.SortedTable
+	compare             compareHosts
+	statusBar           *tview.TextView
+	pages               *tview.Pages
+	mainPanel           *tview.Flex
+	dirPanel            *tview.Flex
+	layout              *ClientLayout
+	layoutPrefix        bool
+	width               int
+	height              int
+	compact             bool
+	panicked            interface{}
+	panicOnce           sync.Once
 	hosts               []string
 	msg                 map[string]*Msg
+	connections         map[string]*hostConnection
 	archivedTableActive bool
 	activeLogs          map[string][]string
+	activeChanges       *rowChanges
+	archivedChanges     *rowChanges
+	destChanges         *rowChanges
+	ring                bool
+	flashText           string
+	flashUntil          time.Time
+	renderText          *tview.TextView
+	renderShown         time.Time
+	renderFrames        int
+	frameRate           float64
+	frameStart          time.Time
+	frameTimes          durationSamples
+	frames              int
+	updateTimes         durationSamples
 	archivedLogs        map[string][]string
 	logPlotId           string
+	logFilter           logFilter
+	grouping            plotGrouping
+	filter              string
+	config              *ClientConfig
+	http                *http.Client
+	api                 apiHosts
+	readOnly            bool
+	done                chan struct{}
+	watchers            sync.WaitGroup
+	stopOnce            sync.Once
 }
 
 var httpClient = &http.Client{
@@ -35,6 +92,36 @@ var httpClient = &http.Client{
 }
 
 func (client *Client) ProcessLoop(hostList string) {
+	logPath := clientLogPath()
+	restoreLog := openClientLog(logPath)
+	defer restoreLog()
+	defer func() {
+		// tview restores the terminal before passing on a panic of the UI thread
+		if p := recover(); p != nil {
+			log.Printf("UI panic: %v\n%s", p, debug.Stack())
+			restoreLog()
+			panic(p)
+		}
+	}()
+	client.init(hostList)
+	client.setupUI()
+
+	client.spawn(client.processLoop)
+	client.spawn(client.stopOnSignal)
+	err := client.app.Run()
+	restoreLog()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the UI: %s\n", err)
+		return
+	}
+	client.saveState()
+	if client.crashed(logPath) {
+		os.Exit(2)
+	}
+}
+
+// init sets up the connections to the hosts and the client configuration, before the UI.
+func (client *Client) init(hostList string) {
 	for _, host := range strings.Split(hostList, ",") {
 		host = strings.TrimSpace(host)
 		if strings.Index(host, ":") < 0 {
@@ -43,41 +130,86 @@ func (client *Client) ProcessLoop(hostList string) {
 		client.hosts = append(client.hosts, host)
 	}
 	client.msg = map[string]*Msg{}
+	client.connections = map[string]*hostConnection{}
+	for _, host := range client.hosts {
+		client.connections[host] = &hostConnection{}
+	}
+	client.config = loadClientConfig(clientConfigPath())
+	client.config.apply()
+	client.api.tokens = client.config.ConfigTokens
+	client.rebalancePlans = map[string]*Rebalance{}
+	client.activeChanges = newRowChanges()
+	client.archivedChanges = newRowChanges()
+	client.destChanges = newRowChanges()
+	if client.http == nil {
+		client.http = httpClient
+	}
+	client.done = make(chan struct{})
+	client.watchers.Add(len(client.hosts) + 1)
 
 	gob.Register(Msg{})
-	gob.Register(ActivePlot{})
-
-	client.setupUI()
-
-	go client.processLoop()
-	client.app.Run()
+	gob.Register(PlotStatus{})
+	gob.Register(PlotJob{})
+	gob.Register(OrphanFile{})
+	gob.Register(DestinationSummary{})
+	gob.Register(Rebalance{})
+	gob.Register(RebalanceMove{})
 }
 
+// processLoop watches every host on its own goroutine, so an unreachable host doesn't delay the
+// others, keeps the ages of stale hosts in the status bar current, fades the highlighted rows and
+// flashes the status bar.
 func (client *Client) processLoop() {
-	client.checkServers()
-	ticker := time.NewTicker(30 * time.Second)
-	for range ticker.C {
-		client.checkServers()
+	defer client.watchers.Done()
+	for _, host := range client.hosts {
+		host := host
+		client.spawn(func() {
+			client.watchHost(host)
+		})
+	}
+	ticker := time.NewTicker(reconnectMinDelay)
+	defer ticker.Stop()
+	fade := time.NewTicker(time.Second)
+	defer fade.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			client.app.QueueUpdateDraw(func() {
+				client.drawStatusBar()
+				client.drawRenderStats()
+			})
+		case <-fade.C:
+			client.app.QueueUpdate(client.animate)
+		case <-client.done:
+			return
+		}
 	}
 }
 
-func (client *Client) checkServers() {
-	for _, host := range client.hosts {
-		client.checkServer(host)
-	}
+// stop ends the UI once the goroutines watching the hosts have returned, as their updates would
+// otherwise wait forever for the stopped application.  It must not be called on the tview thread.
+func (client *Client) stop() {
+	client.stopOnce.Do(func() {
+		close(client.done)
+		client.watchers.Wait()
+		client.app.Stop()
+	})
 }
 
-func (client *Client) getServerData(host string) (*Msg, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/", host), nil)
-	if err != nil {
-		return nil, err
+func (client *Client) getServerData(host string, since int64, epoch int64) (*Msg, error) {
+	path := "/"
+	if since > 0 {
+		path += fmt.Sprintf("?since=%d&epoch=%d", since, epoch)
 	}
-
-	if resp, err := httpClient.Do(req); err == nil {
+	start := time.Now()
+	if resp, err := client.api.do(client.http, host, "GET", path, nil); err == nil {
 		defer resp.Body.Close()
+		roundTrip := time.Since(start)
 		var msg Msg
-		decoder := gob.NewDecoder(resp.Body)
+		body := &countingReader{reader: resp.Body}
+		decoder := gob.NewDecoder(body)
 		if err := decoder.Decode(&msg); err == nil {
+			client.connections[host].observe(roundTrip, time.Since(start)-roundTrip, body.count)
 			return &msg, nil
 		} else {
 			return nil, fmt.Errorf("Failed to decode message: %w", err)
@@ -89,29 +221,44 @@ func (client *Client) getServerData(host string) (*Msg, error) {
 
 func (client *Client) checkServer(host string) {
 	// Retrieve data on the goroutine thread
-	msg, err := client.getServerData(host)
+	msg, changed, err := client.syncServerData(host)
 
 	// Modify UI state on the tview thread.
 	client.app.QueueUpdateDraw(func() {
+		start := time.Now()
+		defer func() { client.updateTimes.add(time.Since(start)) }()
+		client.drawStatusBar()
 		if err != nil {
-			client.logTextbox.SetText("Log (error) ")
-			client.logTextbox.SetText(err.Error())
+			client.logTextbox.SetTitle(paneTitle("Log (error)"))
+			client.logTextbox.SetText(client.connections[host].describe(host, time.Now()) + ": " + err.Error())
+			// Keep showing the last data of the host, greyed out until it answers again.
+			client.drawActivePlotsTable()
+			client.drawPlotDirsTable()
+			client.drawDestDirsTable()
 			return
 		}
 		client.msg[host] = msg
 		client.drawActivePlotsTable()
 		client.drawPlotDirsTable()
 		client.drawDestDirsTable()
-		client.drawArchivedPlotsTable()
-
-		log, ok := client.activeLogs[client.logPlotId]
-		if !ok {
-			log, ok = client.archivedLogs[client.logPlotId]
+		if changed {
+			client.drawArchivedPlotsTable()
+			client.drawHeatmap()
 		}
-		if ok {
-			client.logTextbox.SetTitle(fmt.Sprintf(" Log (%s) ", shortenPlotId(client.logPlotId)))
-			client.logTextbox.SetText(strings.Join(log, ""))
-			client.logTextbox.ScrollToEnd()
+		client.drawStatsTable()
+		client.drawSparklines()
+		client.drawEvents()
+		client.drawQuotaTable()
+		client.drawOrphansTable()
+		client.drawQueueTable()
+		client.drawDistributionTable()
+		client.drawRebalanceTable()
+		client.drawCompareTable()
+
+		_, active := client.activeLogs[client.logPlotId]
+		_, archived := client.archivedLogs[client.logPlotId]
+		if active || archived {
+			client.showPlotLog()
 		}
 	})
 }
@@ -120,6 +267,11 @@ func (client *Client) tabBetweenTables(event *tcell.EventKey) *tcell.EventKey {
 	if event.Key() != tcell.KeyTab {
 		return event
 	}
+	defer client.followZoom()
+	if client.activePlotsTable.HasFocus() && client.compact && len(client.layout.Zoom) == 0 {
+		client.app.SetFocus(client.logTextbox)
+		return nil
+	}
 	if client.activePlotsTable.HasFocus() {
 		client.plotDirsTable.SetFocus(client.app)
 		return nil
@@ -144,68 +296,356 @@ func (client *Client) tabBetweenTables(event *tcell.EventKey) *tcell.EventKey {
 }
 
 func (client *Client) setupUI() {
-	tview.Styles.PrimitiveBackgroundColor = tcell.ColorDefault
+	client.config.applyTheme()
 	client.activePlotsTable = widget.NewSortedTable()
 	client.activePlotsTable.SetSelectable(true)
 	client.activePlotsTable.SetBorder(true)
 	client.activePlotsTable.SetTitleAlign(tview.AlignLeft)
-	client.activePlotsTable.SetTitle(" Active Plots ")
+	client.activePlotsTable.SetTitle(paneTitle("Active Plots"))
 	client.activePlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.activePlotsTable.SetSelectionChangedFunc(client.selectActivePlot)
 	client.activePlotsTable.SetupFromType(activePlotsData{})
-	client.activePlotsTable.SetInputCapture(client.tabBetweenTables)
+	client.activePlotsTable.SetInputCapture(client.plotsKeys)
+	client.activePlotsTable.SetFilterFunc(client.labelFilter)
 
 	client.plotDirsTable = widget.NewSortedTable()
 	client.plotDirsTable.SetSelectable(true)
 	client.plotDirsTable.SetBorder(true)
 	client.plotDirsTable.SetTitleAlign(tview.AlignLeft)
-	client.plotDirsTable.SetTitle(" Plot Directories ")
+	client.plotDirsTable.SetTitle(paneTitle("Plot Directories"))
 	client.plotDirsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.plotDirsTable.SetupFromType(plotDirData{})
-	client.plotDirsTable.SetInputCapture(client.tabBetweenTables)
+	client.plotDirsTable.SetInputCapture(client.dirKeys)
 
 	client.destDirsTable = widget.NewSortedTable()
 	client.destDirsTable.SetSelectable(true)
 	client.destDirsTable.SetBorder(true)
 	client.destDirsTable.SetTitleAlign(tview.AlignLeft)
-	client.destDirsTable.SetTitle(" Dest Directories ")
+	client.destDirsTable.SetTitle(paneTitle("Dest Directories"))
 	client.destDirsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.destDirsTable.SetupFromType(destDirData{})
-	client.destDirsTable.SetInputCapture(client.tabBetweenTables)
+	client.destDirsTable.SetInputCapture(client.dirKeys)
 
 	client.archivedPlotsTable = widget.NewSortedTable()
 	client.archivedPlotsTable.SetSelectable(true)
 	client.archivedPlotsTable.SetBorder(true)
 	client.archivedPlotsTable.SetTitleAlign(tview.AlignLeft)
-	client.archivedPlotsTable.SetTitle(" Archived Plots ")
+	client.archivedPlotsTable.SetTitle(paneTitle("Archived Plots"))
 	client.archivedPlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.archivedPlotsTable.SetSelectionChangedFunc(client.selectArchivedPlot)
 	client.archivedPlotsTable.SetupFromType(archivedPlotData{})
-	client.archivedPlotsTable.SetInputCapture(client.tabBetweenTables)
+	client.archivedPlotsTable.SetInputCapture(client.plotsKeys)
+	client.archivedPlotsTable.SetFilterFunc(client.labelFilter)
 
 	client.logTextbox = tview.NewTextView()
-	client.logTextbox.SetBorder(true).SetTitle(" Log ").SetTitleAlign(tview.AlignLeft)
-	client.logTextbox.SetInputCapture(client.tabBetweenTables)
+	client.logTextbox.SetBorder(true).SetTitle(paneTitle("Log")).SetTitleAlign(tview.AlignLeft)
+	client.logTextbox.SetInputCapture(client.logKeys)
 
 	client.logTextbox.ScrollToEnd()
 
 	client.app = tview.NewApplication()
 
-	dirPanel := tview.NewFlex()
-	dirPanel.SetDirection(tview.FlexColumn)
-	dirPanel.AddItem(client.plotDirsTable, 0, 1, false)
-	dirPanel.AddItem(client.destDirsTable, 0, 1, false)
-
-	mainPanel := tview.NewFlex()
-	mainPanel.SetDirection(tview.FlexRow)
-	mainPanel.AddItem(client.activePlotsTable, 0, 1, true)
-	mainPanel.AddItem(dirPanel, 0, 1, false)
-	mainPanel.AddItem(client.archivedPlotsTable, 0, 1, false)
-	mainPanel.AddItem(client.logTextbox, 0, 1, false)
+	client.dirPanel = tview.NewFlex()
+	client.dirPanel.SetDirection(tview.FlexColumn)
+	client.mainPanel = tview.NewFlex()
+	client.mainPanel.SetDirection(tview.FlexRow)
+	client.layout = client.workspaceLayout()
+	client.applyLayout()
+
+	client.heatmap = widget.NewHeatmap()
+	client.heatmap.SetBorder(true)
+	client.heatmap.SetTitleAlign(tview.AlignLeft)
+	client.heatmap.SetTitle(paneTitle("Plots Completed per Hour"))
+
+	client.statsTable = widget.NewSortedTable()
+	client.statsTable.SetSelectable(true)
+	client.statsTable.SetBorder(true)
+	client.statsTable.SetTitleAlign(tview.AlignLeft)
+	client.statsTable.SetTitle(paneTitle("Statistics"))
+	client.statsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.statsTable.SetupFromType(statsData{})
+
+	client.eventsTextbox = tview.NewTextView()
+	client.eventsTextbox.SetBorder(true).SetTitle(paneTitle("Events")).SetTitleAlign(tview.AlignLeft)
+
+	client.plotsSparkline = widget.NewSparkline()
+	client.plotsSparkline.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	client.tempSparkline = widget.NewSparkline()
+	client.tempSparkline.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+
+	sparklinePanel := tview.NewFlex()
+	sparklinePanel.SetDirection(tview.FlexColumn)
+	sparklinePanel.AddItem(client.plotsSparkline, 0, 1, false)
+	sparklinePanel.AddItem(client.tempSparkline, 0, 1, false)
+
+	statsPanel := tview.NewFlex()
+	statsPanel.SetDirection(tview.FlexRow)
+	statsPanel.AddItem(sparklinePanel, 6, 0, false)
+	statsPanel.AddItem(client.statsTable, 0, 1, true)
+	statsPanel.AddItem(client.eventsTextbox, 0, 1, false)
+
+	client.quotaTable = widget.NewSortedTable()
+	client.quotaTable.SetSelectable(true)
+	client.quotaTable.SetBorder(true)
+	client.quotaTable.SetTitleAlign(tview.AlignLeft)
+	client.quotaTable.SetTitle(paneTitle("Plots per Key"))
+	client.quotaTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.quotaTable.SetupFromType(quotaData{})
+
+	client.orphansTable = widget.NewSortedTable()
+	client.orphansTable.SetSelectable(true)
+	client.orphansTable.SetBorder(true)
+	client.orphansTable.SetTitleAlign(tview.AlignLeft)
+	client.orphansTable.SetTitle(paneTitle("Orphaned Temp Files"))
+	client.orphansTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.orphansTable.SetupFromType(orphanData{})
+	client.orphansTable.SetInputCapture(client.orphansKeys)
+
+	client.queueTable = widget.NewSortedTable()
+	client.queueTable.SetSelectable(true)
+	client.queueTable.SetBorder(true)
+	client.queueTable.SetTitleAlign(tview.AlignLeft)
+	client.queueTable.SetTitle(paneTitle("Queued Jobs"))
+	client.queueTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.queueTable.SetupFromType(queueData{})
+	client.queueTable.SetInputCapture(client.queueKeys)
+
+	client.distributionTable = widget.NewSortedTable()
+	client.distributionTable.SetSelectable(true)
+	client.distributionTable.SetBorder(true)
+	client.distributionTable.SetTitleAlign(tview.AlignLeft)
+	client.distributionTable.SetTitle(paneTitle("Plot Distribution"))
+	client.distributionTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.distributionTable.SetupFromType(distributionData{})
+	client.distributionTable.SetInputCapture(client.distributionKeys)
+
+	client.rebalanceTable = widget.NewSortedTable()
+	client.rebalanceTable.SetSelectable(true)
+	client.rebalanceTable.SetBorder(true)
+	client.rebalanceTable.SetTitleAlign(tview.AlignLeft)
+	client.rebalanceTable.SetTitle(paneTitle("Rebalance"))
+	client.rebalanceTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.rebalanceTable.SetupFromType(rebalanceData{})
+
+	distributionPanel := tview.NewFlex()
+	distributionPanel.SetDirection(tview.FlexRow)
+	distributionPanel.AddItem(client.distributionTable, 0, 1, true)
+	distributionPanel.AddItem(client.rebalanceTable, 0, 1, false)
+
+	client.settingsForm = tview.NewForm()
+	client.settingsForm.SetBorder(true)
+	client.settingsForm.SetTitleAlign(tview.AlignLeft)
+	client.settingsForm.SetTitle(paneTitle("Settings"))
+
+	client.compareTable = widget.NewSortedTable()
+	client.compareTable.SetSelectable(true)
+	client.compareTable.SetBorder(true)
+	client.compareTable.SetTitleAlign(tview.AlignLeft)
+	client.compareTable.SetTitle(paneTitle("Compare"))
+	client.compareTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
+	client.compareTable.SetupFromType(compareData{})
+	client.compareTable.SetVisibleColumns("Item", "Host A", "Host B")
+	client.compareTable.SetSortOrder("Item", false)
+	client.compareTable.SetGroupFunc(compareGroup)
+	client.compareTable.SetInputCapture(client.compareKeys)
+
+	client.renderText = tview.NewTextView()
+	client.renderText.SetDynamicColors(true)
+	client.renderText.SetBorder(true).SetTitle(paneTitle("Rendering")).SetTitleAlign(tview.AlignLeft)
+
+	client.statusBar = tview.NewTextView()
+	client.statusBar.SetDynamicColors(true)
+
+	client.pages = tview.NewPages()
+	client.pages.AddPage("plots", client.mainPanel, true, true)
+	client.pages.AddPage("heatmap", client.heatmap, true, false)
+	client.pages.AddPage("stats", statsPanel, true, false)
+	client.pages.AddPage("quotas", client.quotaTable, true, false)
+	client.pages.AddPage("orphans", client.orphansTable, true, false)
+	client.pages.AddPage("settings", client.settingsForm, true, false)
+	client.pages.AddPage("distribution", distributionPanel, true, false)
+	client.pages.AddPage("queue", client.queueTable, true, false)
+	client.pages.AddPage("compare", client.compareTable, true, false)
+	client.pages.AddPage("render", client.renderText, true, false)
+
+	rootPanel := tview.NewFlex()
+	rootPanel.SetDirection(tview.FlexRow)
+	rootPanel.AddItem(client.pages, 0, 1, true)
+	rootPanel.AddItem(client.statusBar, 1, 0, false)
 
 	client.app = tview.NewApplication()
-	client.app.SetRoot(mainPanel, true)
+	client.app.SetRoot(rootPanel, true)
 	client.app.EnableMouse(true)
+	client.app.SetInputCapture(client.globalKeys)
+	client.app.SetMouseCapture(client.hoverHeaders)
+	client.app.SetBeforeDrawFunc(client.beforeDraw)
+	client.app.SetAfterDrawFunc(client.afterDraw)
+	client.showView("plots")
+	client.restoreState()
+}
+
+// clientViews lists the pages of the UI, in the order they are shown in the status bar.
+var clientViews = []struct {
+	key   tcell.Key
+	page  string
+	title string
+}{
+	{tcell.KeyF1, "plots", "Plots"},
+	{tcell.KeyF2, "heatmap", "Heatmap"},
+	{tcell.KeyF3, "stats", "Statistics"},
+	{tcell.KeyF4, "quotas", "Quotas"},
+	{tcell.KeyF5, "orphans", "Orphans"},
+	{tcell.KeyF6, "settings", "Settings"},
+	{tcell.KeyF7, "distribution", "Distribution"},
+	{tcell.KeyF8, "queue", "Queue"},
+	{tcell.KeyF9, "compare", "Compare"},
+}
+
+func (client *Client) globalKeys(event *tcell.EventKey) *tcell.EventKey {
+	if client.layoutPrefix {
+		client.layoutPrefix = false
+		client.layoutKeys(event)
+		client.drawStatusBar()
+		return nil
+	}
+	if page, _ := client.pages.GetFrontPage(); page == "plots" && event.Key() == tcell.KeyCtrlB {
+		client.layoutPrefix = true
+		client.drawStatusBar()
+		return nil
+	}
+	if client.readOnly {
+		switch event.Key() {
+		case tcell.KeyCtrlC:
+			go client.stop()
+			return nil
+		case tcell.KeyCtrlN, tcell.KeyCtrlR:
+			return nil
+		}
+	}
+	if event.Key() == tcell.KeyCtrlN {
+		client.showAddJobDialog()
+		return nil
+	}
+	if event.Key() == tcell.KeyCtrlF {
+		client.showFilterDialog()
+		return nil
+	}
+	if event.Key() == tcell.KeyCtrlR {
+		client.confirmResume()
+		return nil
+	}
+	if event.Key() == tcell.KeyRune && event.Rune() == 'A' {
+		// the aggregates of the column the focused table is sorted by
+		for _, t := range client.renderTables() {
+			if t.table.HasFocus() {
+				t.table.ToggleAggregates()
+				return nil
+			}
+		}
+	}
+	if event.Key() == tcell.KeyF12 {
+		client.showView("render")
+		client.drawRenderStats()
+		return nil
+	}
+	for _, view := range clientViews {
+		if event.Key() == view.key {
+			client.showView(view.page)
+			if view.page == "settings" {
+				host := client.settingsHost
+				if len(host) == 0 {
+					host = client.hosts[0]
+				}
+				client.loadSettings(host)
+			}
+			if view.page == "compare" {
+				client.showCompare()
+			}
+			return nil
+		}
+	}
+	return event
+}
+
+// hoverHeaders shows the aggregates of a column while the mouse is on its header, in the tables
+// drawn in the last frame.  The move is consumed when a tooltip changed, so the screen is redrawn.
+func (client *Client) hoverHeaders(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+	if action != tview.MouseMove {
+		return event, action
+	}
+	changed := false
+	x, y := event.Position()
+	for _, t := range client.renderTables() {
+		if !t.table.DrawStats().Time.Before(client.frameStart) && t.table.HoverHeader(x, y) {
+			changed = true
+		}
+	}
+	if changed {
+		return nil, action
+	}
+	return event, action
+}
+
+func (client *Client) showView(page string) {
+	client.pages.SwitchToPage(page)
+	client.drawStatusBar()
+}
+
+func (client *Client) drawStatusBar() {
+	page, _ := client.pages.GetFrontPage()
+	status := client.flashStatus(time.Now())
+	for idx, view := range clientViews {
+		if view.page == page {
+			status += fmt.Sprintf(" [black:white]F%d %s[-:-] ", idx+1, locale.T(view.title))
+		} else if client.compact {
+			status += fmt.Sprintf(" F%d ", idx+1)
+		} else {
+			status += fmt.Sprintf(" F%d %s ", idx+1, locale.T(view.title))
+		}
+	}
+	if page == "render" {
+		status += fmt.Sprintf(" [black:white]F12 %s[-:-] ", locale.T("Rendering"))
+	}
+	if client.readOnly {
+		status += fmt.Sprintf(" %s  ^F %s  ^C %s ", locale.T("Read-only"), locale.T("Filter"), locale.T("Quit"))
+	} else {
+		status += fmt.Sprintf(" ^N %s  ^F %s ", locale.T("Add Job"), locale.T("Filter"))
+	}
+	if page == "plots" {
+		if client.layoutPrefix {
+			status += fmt.Sprintf(" [black:yellow]%s[-:-] ", locale.T("Layout: +/- height  </> width  {/} move  z zoom  = reset  w workspace"))
+		} else {
+			status += fmt.Sprintf(" ^B %s ", locale.Sprintf("Layout: %s", tview.Escape(client.config.Workspace)))
+		}
+	}
+	status += fmt.Sprintf(" %s ", locale.Sprintf("Times in %s", format.Zone(time.Now())))
+	if len(client.filter) > 0 {
+		status += fmt.Sprintf(" [yellow]%s[-] ", locale.Sprintf("Label filter: %s", tview.Escape(client.filter)))
+	}
+	for _, unreachable := range client.connectionStatus(time.Now()) {
+		status += fmt.Sprintf(" [red]%s[-] ", tview.Escape(unreachable))
+	}
+	for _, maintenance := range client.maintenanceStatus(time.Now()) {
+		status += fmt.Sprintf(" [yellow]%s[-] ", tview.Escape(maintenance))
+	}
+	for _, host := range client.haltedHosts() {
+		status += fmt.Sprintf(" [white:red]%s[-:-] ", locale.Sprintf("HALTED %s: %s, ^R to resume", host, tview.Escape(client.msg[host].Halted.String())))
+	}
+	client.statusBar.SetText(status)
+}
+
+// plotLog returns the state changes of a plot followed by the end of its log.
+func plotLog(plot *PlotStatus, filter logFilter) []string {
+	var lines []string
+	for _, change := range plot.StateHistory {
+		lines = append(lines, fmt.Sprintf("[%s] %s\n", format.Time(change.Time), change.State))
+	}
+	for _, line := range plot.Tail {
+		if filter.shows(line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
 }
 
 func shortenPlotId(id string) string {
@@ -220,24 +660,79 @@ func shortenPlotId(id string) string {
 type activePlotsData struct {
 	Host      string        `header:"Host"`
 	PlotId    string        `header:"Plot ID"`
-	Status    int           `header:"Status"`
+	Status    PlotState     `header:"Status"`
 	Phase     int           `header:"Phase"    data-align:"right"`
-	Progress  int           `header:"Progress" data-align:"right"`
+	Progress  int           `header:"Progress" data-align:"right" data-unit:"percent"`
+	Threads   int           `header:"Threads"  data-align:"right"`
+	Transfer  uint64        `header:"Transfer" data-align:"right" data-unit:"rate"`
 	StartTime time.Time     `header:"Start Time"`
-	Duration  time.Duration `header:"Duration"`
+	Elapsed   time.Duration `header:"Elapsed"`
+	Remaining time.Duration `header:"ETA"`
 	PlotDir   string        `header:"Plot Dir"`
 	DestDir   string        `header:"Dest Dir"`
+	Labels    string        `header:"Labels"`
+
+	overdue      bool
+	stale        bool
+	suspended    bool
+	external     bool
+	transferred  uint64
+	transferSize uint64
+	changed      time.Time
+}
+
+// elapsedSince returns the time a plot has been running, 0 when its start is unknown or ahead of
+// the clock of the UI.
+func elapsedSince(start time.Time, now time.Time) time.Duration {
+	if start.IsZero() || start.After(now) {
+		return 0
+	}
+	return now.Sub(start)
+}
+
+// elapsedString is blank for a plot whose start is unknown, instead of the decades since year 1.
+func (apd *activePlotsData) elapsedString() string {
+	if apd.StartTime.IsZero() {
+		return ""
+	}
+	return format.Duration(apd.Elapsed)
+}
+
+func (apd *activePlotsData) TextColor() tcell.Color {
+	if apd.stale {
+		return tcell.ColorDarkGray
+	}
+	if apd.overdue {
+		return tcell.ColorOrange
+	}
+	if apd.external {
+		return highlightColor(tcell.ColorGray, apd.changed, time.Now())
+	}
+	return highlightColor(tview.Styles.PrimaryTextColor, apd.changed, time.Now())
 }
 
 func (apd *activePlotsData) Strings() []string {
-	status := "Unknown"
-	switch apd.Status {
-	case PlotRunning:
-		status = "Running"
-	case PlotError:
-		status = "Errored"
-	case PlotFinished:
-		status = "Finished"
+	if apd.external {
+		threads := ""
+		if apd.Threads > 0 {
+			threads = fmt.Sprintf("%d", apd.Threads)
+		}
+		return []string{apd.Host, apd.PlotId, locale.T("External"), "", "", threads, "", format.Time(apd.StartTime), apd.elapsedString(), "", apd.PlotDir, apd.DestDir, apd.Labels}
+	}
+	status := locale.T(apd.Status.String())
+	if apd.suspended {
+		status += " " + locale.T("(suspended)")
+	}
+	remaining := ""
+	if apd.Remaining > 0 {
+		remaining = format.Duration(apd.Remaining)
+	}
+	transfer := ""
+	if apd.Transfer > 0 {
+		transfer = format.Rate(apd.Transfer)
+		if apd.transferSize > 0 {
+			transfer = fmt.Sprintf("%d%% %s", apd.transferred*100/apd.transferSize, transfer)
+		}
 	}
 	return []string{
 		apd.Host,
@@ -245,30 +740,85 @@ func (apd *activePlotsData) Strings() []string {
 		status,
 		fmt.Sprintf("%d/4", apd.Phase),
 		fmt.Sprintf("%d%%", apd.Progress),
-		apd.StartTime.Format("2006-01-02 15:04:05"),
-		DurationString(apd.Duration),
+		fmt.Sprintf("%d", apd.Threads),
+		transfer,
+		format.Time(apd.StartTime),
+		apd.elapsedString(),
+		remaining,
 		apd.PlotDir,
 		apd.DestDir,
+		apd.Labels,
 	}
 }
 
-func (client *Client) makeActivePlotsData(host string, p *ActivePlot) *activePlotsData {
+func (client *Client) makeActivePlotsData(host string, p *PlotStatus) *activePlotsData {
 	apd := &activePlotsData{}
 	apd.Host = host
 	apd.PlotId = p.Id
 	apd.Status = p.State
 	apd.Phase = p.getCurrentPhase()
 	apd.Progress = p.getProgress()
+	apd.Threads = plotThreads(p.Threads)
+	apd.Transfer = p.TransferRate
+	apd.transferred = p.TransferredBytes
+	apd.transferSize = p.TransferSize
 	apd.StartTime = p.getPhaseTime(0)
-	apd.Duration = time.Since(apd.StartTime)
+	apd.Elapsed = elapsedSince(apd.StartTime, time.Now())
+	if apd.Progress > 0 && apd.Progress < 100 && apd.Elapsed > 0 {
+		// Assumes the rest of the plot goes at the pace so far.
+		apd.Remaining = apd.Elapsed * time.Duration(100-apd.Progress) / time.Duration(apd.Progress)
+	}
 	apd.PlotDir = p.PlotDir
 	apd.DestDir = p.TargetDir
+	apd.Labels = strings.Join(p.Labels, ", ")
+	apd.overdue = p.Overdue
+	apd.suspended = p.Suspended
+	apd.stale = client.connections[host].stale()
 	return apd
 }
 
+// makeExternalPlotData shows a plotter PlotNG didn't start, with the details in the log panel.
+func (client *Client) makeExternalPlotData(host string, p *ExternalPlot) (*activePlotsData, []string) {
+	apd := &activePlotsData{
+		Host:      host,
+		PlotId:    shortenPlotId(p.Id),
+		Threads:   p.Threads,
+		StartTime: p.StartTime,
+		Elapsed:   elapsedSince(p.StartTime, time.Now()),
+		PlotDir:   p.TempDir,
+		DestDir:   p.TargetDir,
+		Labels:    p.Plotter,
+		external:  true,
+		stale:     client.connections[host].stale(),
+	}
+	if len(apd.PlotId) == 0 {
+		apd.PlotId = fmt.Sprintf("PID %d", p.Pid)
+	}
+	details := []string{
+		fmt.Sprintf("%s plotter started outside PlotNG, read-only\n", p.Plotter),
+		fmt.Sprintf("PID: %d\n", p.Pid),
+		fmt.Sprintf("Plot ID: %s\n", p.Id),
+		fmt.Sprintf("k: %d\n", p.PlotSize),
+		fmt.Sprintf("Temp dir: %s\n", p.TempDir),
+	}
+	if len(p.Temp2Dir) > 0 {
+		details = append(details, fmt.Sprintf("Temp2 dir: %s\n", p.Temp2Dir))
+	}
+	details = append(details, fmt.Sprintf("Dest dir: %s\n", p.TargetDir))
+	for _, file := range p.TempFiles {
+		details = append(details, fmt.Sprintf("Open: %s\n", file))
+	}
+	return apd, details
+}
+
 func (client *Client) drawActivePlotsTable() {
 	activePlotsCount := 0
+	externalCount := 0
+	queuedJobsCount := 0
+	threads := ThreadPlan{}
 	client.activeLogs = make(map[string][]string)
+	now := time.Now()
+	client.activeChanges.begin()
 
 	keysToRemove := make(map[string]struct{})
 	for _, key := range client.activePlotsTable.Keys() {
@@ -278,29 +828,83 @@ func (client *Client) drawActivePlotsTable() {
 	for host, msg := range client.msg {
 		for _, plot := range msg.Actives {
 			delete(keysToRemove, plot.Id)
-			client.activeLogs[plot.Id] = plot.Tail
-			client.activePlotsTable.SetRowData(plot.Id, client.makeActivePlotsData(host, plot))
+			client.activeLogs[plot.Id] = plotLog(plot, client.logFilter)
+			apd := client.makeActivePlotsData(host, plot)
+			apd.changed = client.activeChanges.update(host, plot.Id, fmt.Sprintf("%s %d", plot.State, apd.Phase), now)
+			client.activePlotsTable.SetRowData(plot.Id, apd)
 			activePlotsCount++
 		}
+		for _, plot := range msg.External {
+			key := fmt.Sprintf("external:%s:%d", host, plot.Pid)
+			apd, details := client.makeExternalPlotData(host, plot)
+			delete(keysToRemove, key)
+			client.activeLogs[key] = details
+			apd.changed = client.activeChanges.update(host, key, "external", now)
+			client.activePlotsTable.SetRowData(key, apd)
+			externalCount++
+		}
+		queuedJobsCount += len(msg.Queued)
+		threads.Cores += msg.Threads.Cores
+		threads.Used += msg.Threads.Used
+		if msg.Threads.Limit > 0 {
+			threads.Limit += msg.Threads.Limit
+		} else {
+			threads.Limit += msg.Threads.Cores
+		}
 	}
 
 	for key, _ := range keysToRemove {
 		client.activePlotsTable.ClearRowData(key)
 	}
+	client.activeChanges.end(client.hostsShown(), now)
 
-	client.activePlotsTable.SetTitle(fmt.Sprintf(" Active Plots [%d] ", activePlotsCount))
+	count := fmt.Sprintf("%d", activePlotsCount)
+	if queuedJobsCount > 0 {
+		count = fmt.Sprintf("%d (%s)", activePlotsCount, locale.Sprintf("%d queued", queuedJobsCount))
+	}
+	if externalCount > 0 {
+		count += " " + locale.Sprintf("+%d external", externalCount)
+	}
+	if threads.Cores > 0 {
+		client.activePlotsTable.SetTitle(fmt.Sprintf(" %s [%s] %s [%d/%d] %s", locale.T("Active Plots"), count, locale.T("Threads"), threads.Used, threads.Limit, client.groupingTitle()))
+	} else {
+		client.activePlotsTable.SetTitle(fmt.Sprintf(" %s [%s] %s", locale.T("Active Plots"), count, client.groupingTitle()))
+	}
 }
 
 func (client *Client) selectActivePlot(key string) {
 	client.logPlotId = key
 	client.archivedPlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse | tcell.AttrDim))
 	client.activePlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse | tcell.AttrBold))
-	client.logTextbox.SetTitle(fmt.Sprintf(" Log (%s) ", shortenPlotId(client.logPlotId)))
-	if log, found := client.activeLogs[key]; found {
-		client.logTextbox.SetText(strings.Join(log, ""))
-		client.logTextbox.ScrollToEnd()
-	} else {
-		client.logTextbox.SetText("")
+	client.showPlotLog()
+}
+
+// evacuationString returns the suffix shown after a directory whose drive is being evacuated, or
+// after a temp directory being trimmed, or drained with the number of plots still using it.
+func evacuationString(msg *Msg, dir string) string {
+	if msg.Trimming[dir] {
+		return " (trimming)"
+	}
+	if idle, draining := msg.Draining[dir]; draining {
+		if idle {
+			return " (drained)"
+		}
+		count := 0
+		for _, plot := range msg.Actives {
+			if plot.PlotDir == dir {
+				count++
+			}
+		}
+		return fmt.Sprintf(" (draining, %d active)", count)
+	}
+	safe, evacuating := msg.Evacuating[dir]
+	switch {
+	case !evacuating:
+		return ""
+	case safe:
+		return " (safe to remove)"
+	default:
+		return " (evacuating)"
 	}
 }
 
@@ -309,26 +913,63 @@ func (client *Client) selectActivePlot(key string) {
 type plotDirData struct {
 	Host           string        `header:"Host"`
 	PlotDir        string        `header:"Directory"`
-	AvailableBytes uint64        `header:"Available Space" data-align:"right"`
+	AvailableBytes uint64        `header:"Available Space" data-align:"right" data-unit:"bytes"`
 	AvgPhase1      time.Duration `header:"Avg Phase 1" data-align:"right"`
 	AvgPhase2      time.Duration `header:"Avg Phase 2" data-align:"right"`
 	AvgPhase3      time.Duration `header:"Avg Phase 3" data-align:"right"`
 	AvgPhase4      time.Duration `header:"Avg Phase 4" data-align:"right"`
 	Count          int           `header:"Count" data-align:"right"`
 	Failed         int           `header:"Failed" data-align:"right"`
+	Written        uint64        `header:"Written" data-align:"right" data-unit:"bytes"`
+
+	disabled   bool
+	stale      bool
+	evacuation string
+	wear       *TempWear
+}
+
+func (pdd *plotDirData) TextColor() tcell.Color {
+	if pdd.stale {
+		return tcell.ColorDarkGray
+	}
+	if pdd.disabled {
+		return tcell.ColorGray
+	}
+	if pdd.wear != nil {
+		switch pdd.wear.alertLevel() {
+		case wearAlertExceeded:
+			return tcell.ColorRed
+		case wearAlertApproaching:
+			return tcell.ColorYellow
+		}
+	}
+	return tview.Styles.PrimaryTextColor
+}
+
+// writtenString returns the bytes written to the drive, with the share of its budget.
+func (pdd *plotDirData) writtenString() string {
+	if pdd.wear == nil || pdd.Written == 0 {
+		return ""
+	}
+	written := format.Float(float64(pdd.Written)/1e12, 1) + " TB"
+	if pdd.wear.Budget > 0 {
+		written += fmt.Sprintf(" %.0f%%", pdd.wear.Percent())
+	}
+	return written
 }
 
 func (pdd *plotDirData) Strings() []string {
 	return []string{
 		pdd.Host,
-		pdd.PlotDir,
-		SpaceString(pdd.AvailableBytes),
-		DurationString(pdd.AvgPhase1),
-		DurationString(pdd.AvgPhase2),
-		DurationString(pdd.AvgPhase3),
-		DurationString(pdd.AvgPhase4),
+		pdd.PlotDir + pdd.evacuation,
+		format.Space(pdd.AvailableBytes),
+		format.Duration(pdd.AvgPhase1),
+		format.Duration(pdd.AvgPhase2),
+		format.Duration(pdd.AvgPhase3),
+		format.Duration(pdd.AvgPhase4),
 		fmt.Sprintf("%d", pdd.Count),
 		fmt.Sprintf("%d", pdd.Failed),
+		pdd.writtenString(),
 	}
 }
 
@@ -336,12 +977,20 @@ func (client *Client) makePlotDirsData() map[string]*plotDirData {
 	plotDirs := make(map[string]*plotDirData)
 
 	for host, msg := range client.msg {
+		stale := client.connections[host].stale()
 		for plotDir, plotSpace := range msg.TempDirs {
-			plotDirs[host+"||"+plotDir] = &plotDirData{
+			pdd := &plotDirData{
 				Host:           host,
 				PlotDir:        plotDir,
 				AvailableBytes: plotSpace,
+				disabled:       msg.Disabled[plotDir],
+				stale:          stale,
+				evacuation:     evacuationString(msg, plotDir),
+			}
+			if wear, ok := msg.TempWear[plotDir]; ok {
+				pdd.Written, pdd.wear = wear.Written(), wear
 			}
+			plotDirs[host+"||"+plotDir] = pdd
 		}
 
 		for _, plot := range msg.Archived {
@@ -352,6 +1001,7 @@ func (client *Client) makePlotDirsData() map[string]*plotDirData {
 					Host:           host,
 					PlotDir:        plot.PlotDir,
 					AvailableBytes: math.MaxUint64,
+					stale:          stale,
 				}
 				plotDirs[host+"||"+plot.PlotDir] = pdd
 			}
@@ -396,7 +1046,7 @@ func (client *Client) drawPlotDirsTable() {
 		client.plotDirsTable.ClearRowData(key)
 	}
 
-	client.plotDirsTable.SetTitle(fmt.Sprintf(" Plot Directories [%d] ", len(plotDirs)))
+	client.plotDirsTable.SetTitle(fmt.Sprintf(" %s [%d] ", locale.T("Plot Directories"), len(plotDirs)))
 }
 
 // Dest Directories
@@ -404,18 +1054,43 @@ func (client *Client) drawPlotDirsTable() {
 type destDirData struct {
 	Host           string        `header:"Host"`
 	DestDir        string        `header:"Directory"`
-	AvailableBytes uint64        `header:"Available Space" data-align:"right"`
+	AvailableBytes uint64        `header:"Available Space" data-align:"right" data-unit:"bytes"`
+	WriteSpeed     uint64        `header:"Write Speed" data-align:"right" data-unit:"rate"`
 	AvgPlotTime    time.Duration `header:"Avg Plot Time" data-align:"right"`
 	Count          int           `header:"Count" data-align:"right"`
 	Failed         int           `header:"Failed" data-align:"right"`
+
+	offline    bool
+	disabled   bool
+	stale      bool
+	evacuation string
+	buffer     string
+}
+
+func (ddd *destDirData) TextColor() tcell.Color {
+	if ddd.stale {
+		return tcell.ColorDarkGray
+	}
+	if ddd.offline {
+		return tcell.ColorRed
+	}
+	if ddd.disabled {
+		return tcell.ColorGray
+	}
+	return tview.Styles.PrimaryTextColor
 }
 
 func (ddd *destDirData) Strings() []string {
+	writeSpeed := ""
+	if ddd.WriteSpeed > 0 {
+		writeSpeed = format.Rate(ddd.WriteSpeed)
+	}
 	return []string{
 		ddd.Host,
-		ddd.DestDir,
-		SpaceString(ddd.AvailableBytes),
-		DurationString(ddd.AvgPlotTime),
+		ddd.DestDir + ddd.evacuation + ddd.buffer,
+		format.Space(ddd.AvailableBytes),
+		writeSpeed,
+		format.Duration(ddd.AvgPlotTime),
 		fmt.Sprintf("%d", ddd.Count),
 		fmt.Sprintf("%d", ddd.Failed),
 	}
@@ -425,11 +1100,18 @@ func (client *Client) makeDestDirsData() map[string]*destDirData {
 	destDirs := make(map[string]*destDirData)
 
 	for host, msg := range client.msg {
+		stale := client.connections[host].stale()
 		for destDir, plotSpace := range msg.TargetDirs {
 			destDirs[host+"||"+destDir] = &destDirData{
 				Host:           host,
 				DestDir:        destDir,
 				AvailableBytes: plotSpace,
+				WriteSpeed:     uint64(msg.WriteSpeeds[destDir]),
+				offline:        msg.Offline[destDir],
+				disabled:       msg.Disabled[destDir],
+				stale:          stale,
+				evacuation:     evacuationString(msg, destDir),
+				buffer:         bufferString(msg, destDir),
 			}
 		}
 
@@ -441,6 +1123,7 @@ func (client *Client) makeDestDirsData() map[string]*destDirData {
 					Host:           host,
 					DestDir:        plot.TargetDir,
 					AvailableBytes: math.MaxUint64,
+					stale:          stale,
 				}
 				destDirs[host+"||"+plot.TargetDir] = ddd
 			}
@@ -470,56 +1153,68 @@ func (client *Client) drawDestDirsTable() {
 		keysToRemove[key] = struct{}{}
 	}
 
+	now := time.Now()
+	client.destChanges.begin()
 	for key, ddd := range destDirs {
 		delete(keysToRemove, key)
 		client.destDirsTable.SetRowData(key, ddd)
+		state := "online"
+		if ddd.offline {
+			state = "offline"
+		}
+		if changed := client.destChanges.update(ddd.Host, key, state, now); ddd.offline && changed.Equal(now) {
+			client.alertDiskOffline(ddd.Host, ddd.DestDir)
+		}
 	}
 
 	for key, _ := range keysToRemove {
 		client.destDirsTable.ClearRowData(key)
 	}
+	client.destChanges.end(client.hostsShown(), now)
 
-	client.destDirsTable.SetTitle(fmt.Sprintf(" Dest Directories [%d] ", len(destDirs)))
+	client.destDirsTable.SetTitle(fmt.Sprintf(" %s [%d] ", locale.T("Dest Directories"), len(destDirs)))
 }
 
 // Archived plots
 
 type archivedPlotData struct {
-	Host      string        `header:"Host"`
-	PlotId    string        `header:"Plot Id"`
-	Status    int           `header:"Status"`
-	Phase     int           `header:"Phase" data-align:"right"`
-	StartTime time.Time     `header:"Start Time"`
-	EndTime   time.Time     `header:"End Time"`
-	Duration  time.Duration `header:"Duration"`
-	PlotDir   string        `header:"Plot Dir"`
-	DestDir   string        `header:"Dest Dir"`
+	Host      string          `header:"Host"`
+	PlotId    string          `header:"Plot Id"`
+	Status    PlotState       `header:"Status"`
+	Phase     int             `header:"Phase" data-align:"right"`
+	StartTime time.Time       `header:"Start Time"`
+	EndTime   time.Time       `header:"End Time"`
+	Duration  time.Duration   `header:"Duration"`
+	PlotDir   string          `header:"Plot Dir"`
+	DestDir   string          `header:"Dest Dir"`
+	Labels    string          `header:"Labels"`
+	Failure   FailureCategory `header:"Failure"`
+
+	changed time.Time
+}
+
+func (apd *archivedPlotData) TextColor() tcell.Color {
+	return highlightColor(tview.Styles.PrimaryTextColor, apd.changed, time.Now())
 }
 
 func (apd *archivedPlotData) Strings() []string {
-	status := "Unknown"
-	switch apd.Status {
-	case PlotRunning:
-		status = "Running"
-	case PlotError:
-		status = "Errored"
-	case PlotFinished:
-		status = "Finished"
-	}
+	status := locale.T(apd.Status.String())
 	return []string{
 		apd.Host,
 		shortenPlotId(apd.PlotId),
 		status,
 		fmt.Sprintf("%d/4", apd.Phase),
-		apd.StartTime.Format("2006-01-02 15:04:05"),
-		apd.EndTime.Format("2006-01-02 15:04:05"),
-		DurationString(apd.Duration),
+		format.Time(apd.StartTime),
+		format.Time(apd.EndTime),
+		format.Duration(apd.Duration),
 		apd.PlotDir,
 		apd.DestDir,
+		apd.Labels,
+		locale.T(apd.Failure.String()),
 	}
 }
 
-func (client *Client) makeArchivedPlotData(host string, p *ActivePlot) *archivedPlotData {
+func (client *Client) makeArchivedPlotData(host string, p *PlotStatus) *archivedPlotData {
 	apd := &archivedPlotData{}
 	apd.Host = host
 	apd.PlotId = p.Id
@@ -530,6 +1225,8 @@ func (client *Client) makeArchivedPlotData(host string, p *ActivePlot) *archived
 	apd.Duration = apd.EndTime.Sub(apd.StartTime)
 	apd.PlotDir = p.PlotDir
 	apd.DestDir = p.TargetDir
+	apd.Labels = strings.Join(p.Labels, ", ")
+	apd.Failure = p.Failure
 	return apd
 }
 
@@ -537,6 +1234,8 @@ func (client *Client) drawArchivedPlotsTable() {
 	archivedPlotsSuccess := 0
 	archivedPlotsFailed := 0
 	client.archivedLogs = make(map[string][]string)
+	now := time.Now()
+	client.archivedChanges.begin()
 
 	keysToRemove := make(map[string]struct{})
 	for _, key := range client.archivedPlotsTable.Keys() {
@@ -546,8 +1245,13 @@ func (client *Client) drawArchivedPlotsTable() {
 	for host, msg := range client.msg {
 		for _, plot := range msg.Archived {
 			delete(keysToRemove, plot.Id)
-			client.archivedLogs[plot.Id] = plot.Tail
-			client.archivedPlotsTable.SetRowData(plot.Id, client.makeArchivedPlotData(host, plot))
+			client.archivedLogs[plot.Id] = plotLog(plot, client.logFilter)
+			apd := client.makeArchivedPlotData(host, plot)
+			apd.changed = client.archivedChanges.update(host, plot.Id, plot.State.String(), now)
+			if plot.State == PlotError && apd.changed.Equal(now) {
+				client.alertPlotFailed(host, plot)
+			}
+			client.archivedPlotsTable.SetRowData(plot.Id, apd)
 			switch plot.State {
 			case PlotFinished:
 				archivedPlotsSuccess++
@@ -562,11 +1266,12 @@ func (client *Client) drawArchivedPlotsTable() {
 	for key, _ := range keysToRemove {
 		client.archivedPlotsTable.ClearRowData(key)
 	}
+	client.archivedChanges.end(client.hostsShown(), now)
 
 	if archivedPlotsFailed > 0 {
-		client.archivedPlotsTable.SetTitle(fmt.Sprintf(" Archived Plots [%d (%d failed)] ", archivedPlotsSuccess, archivedPlotsFailed))
+		client.archivedPlotsTable.SetTitle(fmt.Sprintf(" %s [%d (%s)] ", locale.T("Archived Plots"), archivedPlotsSuccess, locale.Sprintf("%d failed", archivedPlotsFailed)))
 	} else {
-		client.archivedPlotsTable.SetTitle(fmt.Sprintf(" Archived Plots [%d] ", archivedPlotsSuccess))
+		client.archivedPlotsTable.SetTitle(fmt.Sprintf(" %s [%d] ", locale.T("Archived Plots"), archivedPlotsSuccess))
 	}
 }
 
@@ -574,11 +1279,5 @@ func (client *Client) selectArchivedPlot(key string) {
 	client.logPlotId = key
 	client.activePlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse | tcell.AttrDim))
 	client.archivedPlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse | tcell.AttrBold))
-	client.logTextbox.SetTitle(fmt.Sprintf(" Log (%s) ", shortenPlotId(client.logPlotId)))
-	if log, found := client.archivedLogs[key]; found {
-		client.logTextbox.SetText(strings.Join(log, ""))
-		client.logTextbox.ScrollToEnd()
-	} else {
-		client.logTextbox.SetText("")
-	}
+	client.showPlotLog()
 }
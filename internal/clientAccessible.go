@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// summaryInterval is how often the accessible mode reads out the progress of every active plot,
+// between the lines of the changes.
+const summaryInterval = 10 * time.Minute
+
+// followedHost is what the accessible mode last said about a host, to only tell what changed.
+type followedHost struct {
+	seen        bool
+	unreachable bool
+	halted      bool
+	plots       map[int64]followedPlot
+	archived    map[int64]bool
+	offline     map[string]bool
+}
+
+type followedPlot struct {
+	state PlotState
+	phase int
+}
+
+// Follow prints the progress of the plotting on the hosts as plain lines for screen readers, one
+// line per change: plots starting, changing phase and ending, dest directories going offline and
+// hosts becoming unreachable or halted, with a summary of the active plots every summaryInterval.
+// Nothing is ever redrawn or coloured, so every line can be read out as it comes.  It runs until
+// ctx is done.
+func Follow(ctx context.Context, w io.Writer, hostList string) {
+	client := &Client{}
+	client.init(hostList)
+	followed := map[string]*followedHost{}
+	for _, host := range client.hosts {
+		followed[host] = &followedHost{}
+	}
+
+	fmt.Fprintf(w, "PlotNG following %s, a summary every %s.\n", strings.Join(client.hosts, ", "), spokenDuration(summaryInterval))
+	nextSummary := time.Now().Add(summaryInterval)
+	for {
+		next := nextSummary
+		for _, host := range client.hosts {
+			conn := client.connections[host]
+			if !time.Now().Before(conn.due()) {
+				msg, _, err := client.syncServerData(host)
+				client.followHost(w, host, followed[host], msg, err)
+			}
+			if due := conn.due(); due.Before(next) {
+				next = due
+			}
+		}
+		if !time.Now().Before(nextSummary) {
+			client.followSummary(w)
+			nextSummary = time.Now().Add(summaryInterval)
+			continue
+		}
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// followHost prints what changed on host since its last fetch.  The first fetch of a host only
+// prints how many plots it has.
+func (client *Client) followHost(w io.Writer, host string, fh *followedHost, msg *Msg, err error) {
+	say := func(text string, args ...interface{}) {
+		fmt.Fprintf(w, "%s %s: %s.\n", format.Time(time.Now()), host, fmt.Sprintf(text, args...))
+	}
+	if err != nil {
+		if !fh.unreachable {
+			say("unreachable, %s", err)
+			fh.unreachable = true
+		}
+		return
+	}
+	if fh.unreachable {
+		say("reachable again")
+		fh.unreachable = false
+	}
+	client.msg[host] = msg
+
+	plots := map[int64]followedPlot{}
+	for _, plot := range msg.Actives {
+		plots[plot.PlotId] = followedPlot{state: plot.State, phase: plot.getCurrentPhase()}
+	}
+	archived := map[int64]bool{}
+	for _, plot := range msg.Archived {
+		archived[plot.PlotId] = true
+	}
+	offline := map[string]bool{}
+	for dir, isOffline := range msg.Offline {
+		if isOffline {
+			offline[dir] = true
+		}
+	}
+
+	if !fh.seen {
+		say("%s, %d queued, %d archived", spokenCount(len(msg.Actives), "active plot"), len(msg.Queued), len(msg.Archived))
+		for _, dir := range sortedDirs(offline) {
+			say("dest directory %s is offline", dir)
+		}
+	} else {
+		for _, plot := range msg.Actives {
+			previous, known := fh.plots[plot.PlotId]
+			current := plots[plot.PlotId]
+			switch {
+			case !known:
+				say("%s started in %s for %s", spokenPlot(plot), plot.PlotDir, plot.TargetDir)
+			case current.phase != previous.phase && current.phase > 0:
+				say("%s in phase %d of 4", spokenPlot(plot), current.phase)
+			case current.state != previous.state:
+				say("%s is %s", spokenPlot(plot), strings.ToLower(current.state.String()))
+			}
+		}
+		for _, plot := range msg.Archived {
+			if fh.archived[plot.PlotId] {
+				continue
+			}
+			switch plot.State {
+			case PlotFinished:
+				say("%s finished in %s", spokenPlot(plot), spokenDuration(plot.EndTime.Sub(plot.StartTime)))
+			case PlotError:
+				if plot.Failure != FailureNone {
+					say("%s failed, %s", spokenPlot(plot), strings.ToLower(plot.Failure.String()))
+				} else {
+					say("%s failed", spokenPlot(plot))
+				}
+			default:
+				say("%s %s", spokenPlot(plot), strings.ToLower(plot.State.String()))
+			}
+		}
+		for _, dir := range sortedDirs(offline) {
+			if !fh.offline[dir] {
+				say("dest directory %s went offline", dir)
+			}
+		}
+		for _, dir := range sortedDirs(fh.offline) {
+			if !offline[dir] {
+				say("dest directory %s is back online", dir)
+			}
+		}
+	}
+
+	if msg.Halted != nil && !fh.halted {
+		say("halted, %s", msg.Halted.String())
+	} else if msg.Halted == nil && fh.halted {
+		say("plotting resumed")
+	}
+	fh.halted = msg.Halted != nil
+	fh.plots = plots
+	fh.archived = archived
+	fh.offline = offline
+	fh.seen = true
+}
+
+// followSummary prints the progress of every active plot.
+func (client *Client) followSummary(w io.Writer) {
+	now := time.Now()
+	for _, host := range client.hosts {
+		msg := client.msg[host]
+		if msg == nil {
+			continue
+		}
+		if len(msg.Actives) == 0 {
+			fmt.Fprintf(w, "%s %s: no active plots.\n", format.Time(now), host)
+			continue
+		}
+		var parts []string
+		for _, plot := range msg.Actives {
+			apd := client.makeActivePlotsData(host, plot)
+			part := fmt.Sprintf("%s %s", spokenPlot(plot), strings.ToLower(plot.State.String()))
+			if apd.Phase > 0 {
+				part += fmt.Sprintf(", phase %d of 4", apd.Phase)
+			}
+			if apd.Progress >= 0 {
+				part += fmt.Sprintf(", %d percent", apd.Progress)
+			}
+			if apd.Remaining > 0 {
+				part += fmt.Sprintf(", about %s left", spokenDuration(apd.Remaining))
+			}
+			parts = append(parts, part)
+		}
+		fmt.Fprintf(w, "%s %s: %s. %s.\n", format.Time(now), host, spokenCount(len(msg.Actives), "active plot"), strings.Join(parts, ". "))
+	}
+}
+
+// spokenPlot names a plot by the start of its ID, which is easier to listen to than the whole
+// ID, or as a new plot before the plotter gave it an ID.
+func spokenPlot(plot *PlotStatus) string {
+	if len(plot.Id) >= 8 {
+		return "plot " + plot.Id[:8]
+	}
+	return "new plot"
+}
+
+// spokenDuration writes a duration in words, to the minute.
+func spokenDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d / time.Hour)
+	minutes := int((d % time.Hour) / time.Minute)
+	switch {
+	case hours == 0:
+		return spokenCount(minutes, "minute")
+	case minutes == 0:
+		return spokenCount(hours, "hour")
+	}
+	return spokenCount(hours, "hour") + " " + spokenCount(minutes, "minute")
+}
+
+// spokenCount writes a number of things, in the singular for one.
+func spokenCount(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// sortedDirs returns the directories of a set in order.
+func sortedDirs(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/locale"
+)
+
+// The events which can ring the bell, the keys of ClientConfig.Bell.
+const (
+	bellPlotFailed  = "plot failed"
+	bellDiskOffline = "disk offline"
+)
+
+// flashDuration is how long the status bar flashes after an event.
+const flashDuration = 6 * time.Second
+
+// bellModes are the values of ClientConfig.Bell: whether to ring the terminal bell, flash the
+// status bar or both.
+var bellModes = map[string]struct{ ring, flash bool }{
+	"":      {},
+	"off":   {},
+	"bell":  {ring: true},
+	"flash": {flash: true},
+	"both":  {ring: true, flash: true},
+}
+
+// checkBell logs the events of ClientConfig.Bell which are unknown or have an unknown mode.
+func (cc *ClientConfig) checkBell() {
+	for event, mode := range cc.Bell {
+		if event != bellPlotFailed && event != bellDiskOffline {
+			log.Printf("Unknown bell event [%s], expecting %q or %q", event, bellPlotFailed, bellDiskOffline)
+		} else if _, ok := bellModes[mode]; !ok {
+			log.Printf("Unknown bell mode [%s] for %s, expecting bell, flash, both or off", mode, event)
+		}
+	}
+}
+
+// alert rings the bell and flashes the status bar with text, as configured for event.  It runs on
+// the tview thread; the bell rings after the next draw, which has the screen.
+func (client *Client) alert(event string, text string) {
+	mode := bellModes[client.config.Bell[event]]
+	if mode.ring {
+		client.ring = true
+	}
+	if mode.flash {
+		client.flashText = text
+		client.flashUntil = time.Now().Add(flashDuration)
+		client.drawStatusBar()
+	}
+}
+
+// ringBell rings the terminal bell after a draw, if an event asked for it.
+func (client *Client) ringBell(screen tcell.Screen) {
+	if client.ring {
+		client.ring = false
+		screen.Beep()
+	}
+}
+
+// flashStatus returns the alert shown at the front of the status bar while it flashes, blinking
+// every other second.
+func (client *Client) flashStatus(now time.Time) string {
+	if !now.Before(client.flashUntil) {
+		return ""
+	}
+	if now.Unix()%2 == 0 {
+		return fmt.Sprintf(" [white:red]%s[-:-] ", tview.Escape(client.flashText))
+	}
+	return fmt.Sprintf(" [red]%s[-] ", tview.Escape(client.flashText))
+}
+
+// flashing redraws the status bar while it flashes and returns whether the screen needs a draw,
+// including the second after the flash to clear it.
+func (client *Client) flashing(now time.Time) bool {
+	if client.flashUntil.IsZero() {
+		return false
+	}
+	if !now.Before(client.flashUntil) {
+		client.flashUntil = time.Time{}
+	}
+	client.drawStatusBar()
+	return true
+}
+
+// alertPlotFailed alerts about a plot which just ended as Errored.
+func (client *Client) alertPlotFailed(host string, plot *PlotStatus) {
+	text := locale.Sprintf("Plot %s failed on %s", shortenPlotId(plot.Id), host)
+	if plot.Failure != FailureNone {
+		text += ": " + locale.T(plot.Failure.String())
+	}
+	client.alert(bellPlotFailed, text)
+}
+
+// alertDiskOffline alerts about a dest directory which just went offline.
+func (client *Client) alertDiskOffline(host string, dir string) {
+	client.alert(bellDiskOffline, locale.Sprintf("%s is offline on %s", dir, host))
+}
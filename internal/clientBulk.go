@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gdamore/tcell/v2"
+
+	"plotng/internal/locale"
+)
+
+// plotsKeys runs the bulk actions on the host of the selected plot: K kills its active plots, R
+// queues its failed plots again and X clears its archive.  In the active plots, G cycles through
+// the groupings and Enter collapses or expands the selected group.
+func (client *Client) plotsKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEnter && client.activePlotsTable.HasFocus() && client.activePlotsTable.ToggleGroup() {
+		return nil
+	}
+	if event.Key() != tcell.KeyRune {
+		return client.tabBetweenTables(event)
+	}
+	var text, method, path, done string
+	host := client.selectedPlotHost()
+	switch event.Rune() {
+	case 'G':
+		if client.activePlotsTable.HasFocus() {
+			client.cyclePlotGrouping()
+			return nil
+		}
+		return event
+	case 'K':
+		count := 0
+		if msg, ok := client.msg[host]; ok {
+			count = len(msg.Actives)
+		}
+		text = locale.Sprintf("Kill all %d active plots on %s?", count, host)
+		method, path, done = "POST", "/plots/kill", "Killed %d plots on %s"
+	case 'R':
+		text = locale.Sprintf("Queue the failed plots of %s again?", host)
+		method, path, done = "POST", "/plots/retry", "Queued %d failed plots again on %s"
+	case 'X':
+		text = locale.Sprintf("Clear the archived plots of %s?", host)
+		method, path, done = "DELETE", "/archive", "Cleared %d archived plots on %s"
+	default:
+		return event
+	}
+	if len(host) == 0 {
+		return nil
+	}
+	client.confirm(text, func() {
+		client.spawn(func() {
+			client.bulkAction(host, method, path, done)
+		})
+	})
+	return nil
+}
+
+// selectedPlotHost returns the host of the plot selected in the focused plots table, or the only
+// host.
+func (client *Client) selectedPlotHost() string {
+	if client.activePlotsTable.HasFocus() {
+		if data, ok := client.activePlotsTable.RowData(client.activePlotsTable.GetSelection()).(*activePlotsData); ok {
+			return data.Host
+		}
+	} else if data, ok := client.archivedPlotsTable.RowData(client.archivedPlotsTable.GetSelection()).(*archivedPlotData); ok {
+		return data.Host
+	}
+	if len(client.hosts) == 1 {
+		return client.hosts[0]
+	}
+	return ""
+}
+
+// bulkAction runs a bulk action on host and shows the number of plots it affected, formatted
+// with done, in the log box.
+func (client *Client) bulkAction(host string, method string, path string, done string) {
+	var result BulkResult
+	resp, err := client.api.do(client.http, host, method, path, nil)
+	if err == nil {
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		if err != nil {
+			client.logTextbox.SetText(locale.Sprintf("Bulk action on %s failed: %s", host, err))
+		} else {
+			client.logTextbox.SetText(locale.Sprintf(done, result.Count, host))
+		}
+	})
+	client.checkServer(host)
+}
@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+	"plotng/internal/widget"
+)
+
+// The sections of the comparison of two hosts.
+const (
+	compareConfig      = "Configuration"
+	comparePerformance = "Performance"
+	compareFailures    = "Failures"
+)
+
+// compareData is a setting, statistic or failure category of two hosts side by side, the rows
+// which differ are yellow.
+type compareData struct {
+	Section string `header:"Section"`
+	Item    string `header:"Item"`
+	HostA   string `header:"Host A" data-align:"right"`
+	HostB   string `header:"Host B" data-align:"right"`
+
+	differs bool
+}
+
+func (cd *compareData) Strings() []string {
+	return []string{cd.Section, cd.Item, cd.HostA, cd.HostB}
+}
+
+func (cd *compareData) TextColor() tcell.Color {
+	if cd.differs {
+		return tcell.ColorYellow
+	}
+	return tcell.ColorWhite
+}
+
+// compareHosts are the hosts being compared and their effective configurations, fetched when
+// the view is shown or a host is changed.
+type compareHosts struct {
+	a, b    string
+	configs map[string]*Config
+	errors  map[string]error // of fetching the configurations
+	all     bool             // show the settings which are the same too
+}
+
+// showCompare shows the comparison view, comparing the first two hosts the first time.
+func (client *Client) showCompare() {
+	if len(client.compare.a) == 0 {
+		client.compare.a = client.hosts[0]
+		client.compare.b = client.hosts[0]
+		if len(client.hosts) > 1 {
+			client.compare.b = client.hosts[1]
+		}
+	}
+	client.loadCompareConfigs()
+	client.drawCompareTable()
+}
+
+// loadCompareConfigs fetches the configurations of the compared hosts.
+func (client *Client) loadCompareConfigs() {
+	for _, host := range []string{client.compare.a, client.compare.b} {
+		host := host
+		client.spawn(func() {
+			config, err := client.getServerConfig(host)
+			client.app.QueueUpdateDraw(func() {
+				if client.compare.configs == nil {
+					client.compare.configs = map[string]*Config{}
+					client.compare.errors = map[string]error{}
+				}
+				client.compare.errors[host] = err
+				if err == nil {
+					client.compare.configs[host] = config
+				}
+				client.drawCompareTable()
+			})
+		})
+	}
+}
+
+// nextCompareHost switches a compared host to the next host.
+func (client *Client) nextCompareHost(host *string) {
+	for i, h := range client.hosts {
+		if h == *host {
+			*host = client.hosts[(i+1)%len(client.hosts)]
+			break
+		}
+	}
+	client.loadCompareConfigs()
+	client.drawCompareTable()
+}
+
+// compareKeys handles the keys of the comparison: a and b change the compared hosts, d shows or
+// hides the settings which are the same and Enter collapses or expands a section.
+func (client *Client) compareKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEnter && client.compareTable.ToggleGroup() {
+		return nil
+	}
+	if event.Key() != tcell.KeyRune {
+		return event
+	}
+	switch event.Rune() {
+	case 'a':
+		client.nextCompareHost(&client.compare.a)
+	case 'b':
+		client.nextCompareHost(&client.compare.b)
+	case 'd':
+		client.compare.all = !client.compare.all
+		client.drawCompareTable()
+	default:
+		return event
+	}
+	return nil
+}
+
+// compareStats returns the statistics of a host: the plot counts and rates of the statistics
+// view, and the average time of every phase of its finished plots.
+func (client *Client) compareStats(host string, now time.Time) map[string]string {
+	stats := map[string]string{}
+	msg, ok := client.msg[host]
+	if !ok {
+		return stats
+	}
+	sd := &statsData{Host: host}
+	for _, plot := range msg.Archived {
+		sd.add(plot)
+	}
+	for _, plot := range msg.Actives {
+		sd.addActive(plot, now)
+	}
+	sd.finish(now, msg.Downtime)
+	stats["Active Plots"] = fmt.Sprint(len(msg.Actives))
+	stats["Started"] = fmt.Sprint(sd.Started)
+	stats["Plots"] = fmt.Sprint(sd.Count)
+	stats["Failed"] = fmt.Sprint(sd.Failed)
+	stats["Failure Rate"] = format.Percent(sd.FailureRate, 1)
+	stats["Avg Plot Time"] = format.Duration(sd.AvgPlotTime)
+	stats["Plots/Day"] = format.Float(sd.PlotsPerDay, 2)
+	var phases [4]time.Duration
+	finished := 0
+	for _, plot := range msg.Archived {
+		if plot.State != PlotFinished {
+			continue
+		}
+		finished++
+		for phase := 1; phase <= 4; phase++ {
+			phases[phase-1] += plot.getPhaseTime(phase).Sub(plot.getPhaseTime(phase - 1))
+		}
+	}
+	for phase := 1; phase <= 4; phase++ {
+		name := fmt.Sprintf("Avg Phase %d", phase)
+		if phase == 4 {
+			name = "Avg Phase 4 + Copy"
+		}
+		if finished > 0 {
+			stats[name] = format.Duration(phases[phase-1] / time.Duration(finished))
+		} else {
+			stats[name] = "-"
+		}
+	}
+	return stats
+}
+
+// compareFailureCounts counts the failed plots of a host by failure category.
+func (client *Client) compareFailureCounts(host string) map[string]int {
+	counts := map[string]int{}
+	if msg, ok := client.msg[host]; ok {
+		for _, plot := range msg.Archived {
+			if plot.State == PlotError || plot.State == PlotKilled {
+				counts[plot.Failure.String()]++
+			}
+		}
+	}
+	return counts
+}
+
+// compareConfigValues returns the settings of a configuration in JSON, by name.
+func compareConfigValues(config *Config) map[string]string {
+	values := map[string]string{}
+	if config == nil {
+		return values
+	}
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		data, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			continue
+		}
+		values[v.Type().Field(i).Name] = string(data)
+	}
+	return values
+}
+
+// drawCompareTable lays out the configurations, statistics and failure categories of the two
+// compared hosts.  The settings which are the same on both hosts are left out unless all is set.
+func (client *Client) drawCompareTable() {
+	if len(client.compare.a) == 0 {
+		return
+	}
+	a, b := client.compare.a, client.compare.b
+	rows := map[string]*compareData{}
+	add := func(section string, item string, valueA string, valueB string) {
+		rows[section+"||"+item] = &compareData{Section: section, Item: item, HostA: valueA, HostB: valueB, differs: valueA != valueB}
+	}
+
+	configA, configB := compareConfigValues(client.compare.configs[a]), compareConfigValues(client.compare.configs[b])
+	same := 0
+	for _, name := range compareConfigNames() {
+		valueA, valueB := configA[name], configB[name]
+		if valueA == valueB && !client.compare.all {
+			same++
+			continue
+		}
+		add(compareConfig, name, valueA, valueB)
+	}
+
+	now := time.Now()
+	statsA, statsB := client.compareStats(a, now), client.compareStats(b, now)
+	for name := range statsA {
+		add(comparePerformance, locale.T(name), statsA[name], statsB[name])
+	}
+
+	failuresA, failuresB := client.compareFailureCounts(a), client.compareFailureCounts(b)
+	for _, counts := range []map[string]int{failuresA, failuresB} {
+		for category := range counts {
+			add(compareFailures, locale.T(category), fmt.Sprint(failuresA[category]), fmt.Sprint(failuresB[category]))
+		}
+	}
+
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.compareTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+	for key, row := range rows {
+		delete(keysToRemove, key)
+		client.compareTable.SetRowData(key, row)
+	}
+	for key := range keysToRemove {
+		client.compareTable.ClearRowData(key)
+	}
+
+	title := locale.Sprintf("Compare: A %s, B %s", a, b)
+	if err := client.compare.errors[a]; err != nil {
+		title += fmt.Sprintf(" - %s: %s", a, err)
+	} else if err := client.compare.errors[b]; err != nil {
+		title += fmt.Sprintf(" - %s: %s", b, err)
+	} else if client.compare.configs[a] == nil || client.compare.configs[b] == nil {
+		title += " - " + locale.T("loading")
+	} else if !client.compare.all {
+		title += " - " + locale.Sprintf("%d settings the same, d to show", same)
+	}
+	client.compareTable.SetTitle(fmt.Sprintf(" %s  a/b %s ", tview.Escape(title), locale.T("change host")))
+}
+
+// compareConfigNames returns the names of the settings.
+func compareConfigNames() []string {
+	var names []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, t.Field(i).Name)
+	}
+	return names
+}
+
+// compareGroup puts a row under the heading of its section.
+func compareGroup(key string, data widget.SortableRow) string {
+	if cd, ok := data.(*compareData); ok {
+		return locale.T(cd.Section)
+	}
+	return ""
+}
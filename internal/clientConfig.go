@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/widget"
+)
+
+// ClientConfig holds the preferences of the UI client, read from plotng/client.json in the user's
+// configuration directory (~/.config on Linux).
+type ClientConfig struct {
+	Clock            string // "24h" (default) or "12h"
+	DecimalSeparator string // "." (default) or eg. ","
+	TimeZone         string // IANA name eg. "Europe/Paris", "UTC" or "" for the local time zone
+	Theme            string // "" for the colours of the terminal or "light"
+	CompactWidth     int    // terminal width below which the UI is compact, 0 for 120 and -1 for never
+	Graphs           string // "braille" (default) or "ascii" for terminals without a braille font
+	Language         string // "" for the language of LANG, "en", "zh-TW" or "zh-CN"
+
+	// Bell by event, "plot failed" or "disk offline": "bell", "flash" (the status bar), "both"
+	// or "off" (default).
+	Bell map[string]string
+
+	// ConfigToken of the servers by host, eg. "farm2:8484", to change their settings, plots and
+	// directories from another machine.
+	ConfigTokens map[string]string
+
+	// The state of the UI, written back when the UI exits and restored when it starts.
+	View      string                // page shown, eg. "plots"
+	Focus     string                // table of the plots page with the focus
+	Host      string                // host of the settings page
+	Filter    string                // label filter
+	LogFilter int                   // lines shown in the log view
+	Group     string                // grouping of the active plots: "state", "host", "tempDir" or ""
+	Sort      map[string]ClientSort // sort order by table
+
+	// The layouts of the plots page by workspace name, changed with the Ctrl-B keys.
+	Workspace  string                   // workspace in use
+	Workspaces map[string]*ClientLayout // layout by workspace
+}
+
+// ClientSort is the sort order of a table, by the header of its column.
+type ClientSort struct {
+	Column  string
+	Reverse bool
+}
+
+func clientConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "plotng", "client.json")
+}
+
+// loadClientConfig reads the client preferences, a missing file leaves the defaults.
+func loadClientConfig(path string) *ClientConfig {
+	config := &ClientConfig{}
+	if len(path) == 0 {
+		return config
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read client config [%s]: %s", path, err)
+		}
+		return config
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		log.Printf("Failed to process client config [%s], check your config file for mistake: %s", path, err)
+	}
+	return config
+}
+
+// save writes the preferences and the state of the UI.
+func (cc *ClientConfig) save(path string) error {
+	if len(path) == 0 {
+		return os.ErrNotExist
+	}
+	data, err := json.MarshalIndent(cc, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// apply makes the formatting layer use the client preferences.
+func (cc *ClientConfig) apply() {
+	var location *time.Location
+	if len(cc.TimeZone) > 0 {
+		var err error
+		if location, err = time.LoadLocation(cc.TimeZone); err != nil {
+			log.Printf("Unknown time zone [%s], using the local time zone: %s", cc.TimeZone, err)
+		}
+	}
+	format.SetOptions(format.Options{
+		Clock12h:         cc.Clock == "12h",
+		DecimalSeparator: cc.DecimalSeparator,
+		Location:         location,
+	})
+	cc.applyLanguage()
+	cc.checkBell()
+}
+
+// applyTheme sets the colours of the UI, before the widgets are created.
+func (cc *ClientConfig) applyTheme() {
+	tview.Styles.PrimitiveBackgroundColor = tcell.ColorDefault
+	switch cc.Theme {
+	case "":
+	case "light":
+		tview.Styles.PrimaryTextColor = tcell.ColorBlack
+		tview.Styles.SecondaryTextColor = tcell.ColorNavy
+		tview.Styles.TertiaryTextColor = tcell.ColorGreen
+		tview.Styles.BorderColor = tcell.ColorBlack
+		tview.Styles.TitleColor = tcell.ColorBlack
+		tview.Styles.GraphicsColor = tcell.ColorBlack
+	default:
+		log.Printf("Unknown theme [%s], using the colours of the terminal", cc.Theme)
+	}
+}
+
+// sortedTables returns the tables whose sort order is saved, by name.
+func (client *Client) sortedTables() map[string]*widget.SortedTable {
+	return map[string]*widget.SortedTable{
+		"active":       client.activePlotsTable,
+		"plotDirs":     client.plotDirsTable,
+		"destDirs":     client.destDirsTable,
+		"archived":     client.archivedPlotsTable,
+		"stats":        client.statsTable,
+		"quotas":       client.quotaTable,
+		"orphans":      client.orphansTable,
+		"queue":        client.queueTable,
+		"distribution": client.distributionTable,
+		"rebalance":    client.rebalanceTable,
+	}
+}
+
+// plotsFocus returns the focusable panes of the plots page, by name.
+func (client *Client) plotsFocus() map[string]tview.Primitive {
+	return map[string]tview.Primitive{
+		"active":   client.activePlotsTable,
+		"plotDirs": client.plotDirsTable,
+		"destDirs": client.destDirsTable,
+		"archived": client.archivedPlotsTable,
+		"log":      client.logTextbox,
+	}
+}
+
+// restoreState brings the UI back to the state saved when it last exited.
+func (client *Client) restoreState() {
+	cc := client.config
+	for name, order := range cc.Sort {
+		if table, ok := client.sortedTables()[name]; ok {
+			table.SetSortOrder(order.Column, order.Reverse)
+		}
+	}
+	client.filter = cc.Filter
+	client.setPlotGrouping(plotGrouping(cc.Group))
+	if filter := logFilter(cc.LogFilter); filter >= logFilterAll && filter <= logFilterBoundaries {
+		client.logFilter = filter
+	}
+	for _, host := range client.hosts {
+		if host == cc.Host {
+			client.settingsHost = host
+		}
+	}
+	for _, view := range clientViews {
+		if view.page == cc.View && view.page != "settings" {
+			client.showView(view.page)
+		}
+	}
+	if pane, ok := client.plotsFocus()[cc.Focus]; ok {
+		client.app.SetFocus(pane)
+		client.followZoom()
+	}
+}
+
+// saveState writes the state of the UI to the client configuration.
+func (client *Client) saveState() {
+	cc := client.config
+	cc.View, _ = client.pages.GetFrontPage()
+	cc.Focus = ""
+	for name, pane := range client.plotsFocus() {
+		if pane.HasFocus() {
+			cc.Focus = name
+		}
+	}
+	cc.Host = client.settingsHost
+	cc.Filter = client.filter
+	cc.LogFilter = int(client.logFilter)
+	cc.Group = string(client.grouping)
+	cc.Sort = map[string]ClientSort{}
+	for name, table := range client.sortedTables() {
+		column, reverse := table.SortOrder()
+		cc.Sort[name] = ClientSort{Column: column, Reverse: reverse}
+	}
+	if err := cc.save(clientConfigPath()); err != nil {
+		log.Printf("Failed to save the client config: %s", err)
+	}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"plotng/internal/locale"
+)
+
+const (
+	refreshInterval   = 30 * time.Second
+	reconnectMinDelay = 5 * time.Second
+	reconnectMaxDelay = 5 * time.Minute
+)
+
+// hostConnection is what the client knows about reaching a host.  When the host doesn't answer,
+// the retries are spaced out exponentially up to reconnectMaxDelay, and its data is kept but shown
+// as stale until it answers again.
+type hostConnection struct {
+	fetch  sync.Mutex // held while fetching, so fetches don't interleave, guards synced
+	synced *Msg
+
+	lock      sync.Mutex
+	lastSeen  time.Time
+	failures  int
+	nextCheck time.Time
+	lastError error
+
+	roundTrips durationSamples
+	decodes    durationSamples
+	size       uint64
+}
+
+func (hc *hostConnection) succeeded(now time.Time) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	hc.lastSeen = now
+	hc.failures = 0
+	hc.nextCheck = now.Add(refreshInterval)
+	hc.lastError = nil
+}
+
+func (hc *hostConnection) failed(now time.Time, err error) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	hc.failures++
+	delay := reconnectMinDelay
+	for i := 1; i < hc.failures && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > reconnectMaxDelay {
+		delay = reconnectMaxDelay
+	}
+	hc.nextCheck = now.Add(delay)
+	hc.lastError = err
+}
+
+func (hc *hostConnection) due() time.Time {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	return hc.nextCheck
+}
+
+// stale reports whether the last attempt to reach the host failed.
+func (hc *hostConnection) stale() bool {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	return hc.failures > 0
+}
+
+// describe says when the host was last seen and when it is tried again, for a stale host.
+func (hc *hostConnection) describe(host string, now time.Time) string {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	seen := locale.T("never seen")
+	if !hc.lastSeen.IsZero() {
+		seen = locale.Sprintf("last seen %s ago", now.Sub(hc.lastSeen).Round(time.Second))
+	}
+	return locale.Sprintf("%s unreachable, %s, retrying in %s", host, seen, hc.nextCheck.Sub(now).Round(time.Second))
+}
+
+// watchHost fetches the data of host every refreshInterval, or with a growing delay while it is
+// unreachable.  Fetches made after an action move the next one later.
+func (client *Client) watchHost(host string) {
+	defer client.watchers.Done()
+	conn := client.connections[host]
+	for {
+		if wait := time.Until(conn.due()); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-client.done:
+				return
+			}
+			continue
+		}
+		client.checkServer(host)
+	}
+}
+
+// connectionStatus describes the unreachable hosts for the status bar.
+func (client *Client) connectionStatus(now time.Time) []string {
+	var status []string
+	for _, host := range client.hosts {
+		if conn := client.connections[host]; conn.stale() {
+			status = append(status, conn.describe(host, now))
+		}
+	}
+	return status
+}
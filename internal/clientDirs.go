@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/locale"
+)
+
+// dirKeys handles the keys of the plot and destination directory tables: d toggles the selected
+// directory, e evacuates its drive, i drains a temp directory and a adds a drive.  The other keys
+// move between the tables.
+func (client *Client) dirKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune {
+		return client.tabBetweenTables(event)
+	}
+	key := client.plotDirsTable.GetSelection()
+	if client.destDirsTable.HasFocus() {
+		key = client.destDirsTable.GetSelection()
+	}
+	host, path := "", ""
+	if parts := strings.SplitN(key, "||", 2); len(parts) == 2 {
+		host, path = parts[0], parts[1]
+	}
+	msg, found := client.msg[host]
+
+	switch event.Rune() {
+	case 'd':
+		if !found {
+			return nil
+		}
+		enabled := msg.Disabled[path]
+		text := locale.Sprintf("Disable %s on %s?", path, host)
+		if enabled {
+			text = locale.Sprintf("Enable %s on %s?", path, host)
+		}
+		client.confirm(text, func() {
+			client.spawn(func() {
+				client.toggleDir(host, path, enabled)
+			})
+		})
+	case 'e':
+		if !found {
+			return nil
+		}
+		client.confirm(locale.Sprintf("Stop using %s on %s and wait for its plots to finish before removing the drive?", path, host), func() {
+			client.spawn(func() {
+				client.postDrive(host, "evacuate", url.Values{"path": {path}},
+					locale.Sprintf("Evacuating %s on %s, it is safe to remove when shown as such", path, host))
+			})
+		})
+	case 'i':
+		if !found || !client.plotDirsTable.HasFocus() {
+			return nil
+		}
+		client.confirm(locale.Sprintf("Stop starting plots in %s on %s and notify once its plots are done?", path, host), func() {
+			client.spawn(func() {
+				client.postDrive(host, "drain", url.Values{"path": {path}},
+					locale.Sprintf("Draining %s on %s, press d to enable it again once trimmed", path, host))
+			})
+		})
+	case 'a':
+		client.showAddDriveDialog(host, client.plotDirsTable.HasFocus())
+	default:
+		return event
+	}
+	return nil
+}
+
+func (client *Client) toggleDir(host string, path string, enabled bool) {
+	target := fmt.Sprintf("/dirs?path=%s&enabled=%t", url.QueryEscape(path), enabled)
+	resp, err := client.api.do(client.http, host, "PUT", target, nil)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		switch {
+		case err != nil:
+			client.logTextbox.SetText(locale.Sprintf("Failed to change %s on %s: %s", path, host, err))
+		case enabled:
+			client.logTextbox.SetText(locale.Sprintf("Enabled %s on %s", path, host))
+		default:
+			client.logTextbox.SetText(locale.Sprintf("Disabled %s on %s", path, host))
+		}
+	})
+	client.checkServer(host)
+}
+
+func (client *Client) showAddDriveDialog(host string, temp bool) {
+	if client.pages.HasPage("addDrive") {
+		return
+	}
+	hostIndex := 0
+	for idx, h := range client.hosts {
+		if h == host {
+			hostIndex = idx
+		}
+	}
+	kindIndex := 0
+	if temp {
+		kindIndex = 1
+	}
+	form := tview.NewForm()
+	form.AddDropDown("Host", client.hosts, hostIndex, nil)
+	form.AddDropDown("Type", []string{"Dest Dir", "Temp Dir"}, kindIndex, nil)
+	form.AddInputField("Path", "", 40, nil, nil)
+
+	closeDialog := func() {
+		client.pages.RemovePage("addDrive")
+		client.app.SetFocus(client.pages)
+	}
+	form.AddButton("Add", func() {
+		_, host := form.GetFormItemByLabel("Host").(*tview.DropDown).GetCurrentOption()
+		kind, _ := form.GetFormItemByLabel("Type").(*tview.DropDown).GetCurrentOption()
+		path := strings.TrimSpace(form.GetFormItemByLabel("Path").(*tview.InputField).GetText())
+		closeDialog()
+		client.spawn(func() {
+			client.postDrive(host, "add", url.Values{"path": {path}, "temp": {fmt.Sprintf("%t", kind == 1)}},
+				locale.Sprintf("Added %s on %s", path, host))
+		})
+	})
+	form.AddButton("Cancel", closeDialog)
+	form.SetCancelFunc(closeDialog)
+	form.SetBorder(true).SetTitle(paneTitle("Add Drive")).SetTitleAlign(tview.AlignLeft)
+
+	client.pages.AddPage("addDrive", modal(form, 60, 11), true, true)
+	client.app.SetFocus(form)
+}
+
+// postDrive runs one of the /drives actions on host and shows done or the error in the log box.
+func (client *Client) postDrive(host string, action string, params url.Values, done string) {
+	resp, err := client.api.do(client.http, host, "POST", fmt.Sprintf("/drives/%s?%s", action, params.Encode()), nil)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			body, _ := ioutil.ReadAll(resp.Body)
+			err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+		}
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		if err != nil {
+			client.logTextbox.SetText(locale.Sprintf("Failed to %s drive on %s: %s", action, host, err))
+		} else {
+			client.logTextbox.SetText(done)
+		}
+	})
+	client.checkServer(host)
+}
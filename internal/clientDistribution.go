@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// Plot distribution
+
+type distributionData struct {
+	Host        string    `header:"Host"`
+	TargetDir   string    `header:"Dest Dir"`
+	Plots       int       `header:"Plots" data-align:"right"`
+	Bytes       uint64    `header:"Size" data-align:"right" data-unit:"bytes"`
+	Created     int       `header:"By PlotNG" data-align:"right"`
+	PreExisting int       `header:"Pre-existing" data-align:"right"`
+	ScanTime    time.Time `header:"Scanned"`
+
+	err string
+}
+
+func (dd *distributionData) TextColor() tcell.Color {
+	if len(dd.err) > 0 {
+		return tcell.ColorRed
+	}
+	return tview.Styles.PrimaryTextColor
+}
+
+func (dd *distributionData) Strings() []string {
+	return []string{
+		dd.Host,
+		dd.TargetDir,
+		fmt.Sprintf("%d", dd.Plots),
+		format.TiBytes(dd.Bytes),
+		fmt.Sprintf("%d", dd.Created),
+		fmt.Sprintf("%d", dd.PreExisting),
+		format.Time(dd.ScanTime),
+	}
+}
+
+func (client *Client) drawDistributionTable() {
+	var plots int
+	var bytes uint64
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.distributionTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+
+	for host, msg := range client.msg {
+		for _, summary := range msg.Distribution {
+			key := host + "||" + summary.TargetDir
+			delete(keysToRemove, key)
+			client.distributionTable.SetRowData(key, &distributionData{
+				Host:        host,
+				TargetDir:   summary.TargetDir,
+				Plots:       summary.Plots,
+				Bytes:       summary.Bytes,
+				Created:     summary.Created,
+				PreExisting: summary.Plots - summary.Created,
+				ScanTime:    summary.ScanTime,
+				err:         summary.Error,
+			})
+			plots += summary.Plots
+			bytes += summary.Bytes
+		}
+	}
+
+	for key := range keysToRemove {
+		client.distributionTable.ClearRowData(key)
+	}
+
+	client.distributionTable.SetTitle(fmt.Sprintf(" %s [%s, %s] ", locale.T("Plot Distribution"), locale.Sprintf("%d plots", plots), format.TiBytes(bytes)))
+}
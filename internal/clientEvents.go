@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// eventTimeLength is the length of the time stamp the server puts in front of every event.
+const eventTimeLength = len("2006-01-02 15:04:05")
+
+// makeEventLines merges the recent events of all hosts in time order, naming the host when
+// there is more than one.
+func (client *Client) makeEventLines() []string {
+	var lines []string
+	for host, msg := range client.msg {
+		for _, event := range msg.Events {
+			if len(event) < eventTimeLength {
+				continue
+			}
+			if len(client.hosts) > 1 {
+				event = event[:eventTimeLength] + " [" + host + "]" + event[eventTimeLength:]
+			}
+			lines = append(lines, event)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i][:eventTimeLength] < lines[j][:eventTimeLength]
+	})
+	return lines
+}
+
+func (client *Client) drawEvents() {
+	client.eventsTextbox.SetText(strings.Join(client.makeEventLines(), "\n"))
+	client.eventsTextbox.ScrollToEnd()
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/widget"
+)
+
+// labelFilter hides the plots which don't have a label containing the current filter text.
+func (client *Client) labelFilter(key string, data widget.SortableRow) bool {
+	if len(client.filter) == 0 {
+		return true
+	}
+	labels := ""
+	switch row := data.(type) {
+	case *activePlotsData:
+		labels = row.Labels
+	case *archivedPlotData:
+		labels = row.Labels
+	}
+	return strings.Contains(strings.ToLower(labels), strings.ToLower(client.filter))
+}
+
+func (client *Client) showFilterDialog() {
+	if client.pages.HasPage("filter") {
+		return
+	}
+	input := tview.NewInputField().SetLabel("Label ").SetText(client.filter)
+	input.SetBorder(true).SetTitle(paneTitle("Filter Plots")).SetTitleAlign(tview.AlignLeft)
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			client.filter = strings.TrimSpace(input.GetText())
+		}
+		client.pages.RemovePage("filter")
+		front, _ := client.pages.GetFrontPage()
+		client.showView(front)
+		client.app.SetFocus(client.pages)
+	})
+	client.pages.AddPage("filter", modal(input, 50, 3), true, true)
+	client.app.SetFocus(input)
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+
+	"plotng/internal/locale"
+	"plotng/internal/widget"
+)
+
+// plotGrouping picks the sections the active plots are shown in, to scan many concurrent plots.
+type plotGrouping string
+
+const (
+	groupNone    plotGrouping = ""
+	groupState   plotGrouping = "state"
+	groupHost    plotGrouping = "host"
+	groupTempDir plotGrouping = "tempDir"
+)
+
+// plotGroupings are the groupings in the order the G key cycles through them.
+var plotGroupings = []plotGrouping{groupNone, groupState, groupHost, groupTempDir}
+
+func (grouping plotGrouping) String() string {
+	switch grouping {
+	case groupState:
+		return "state"
+	case groupHost:
+		return "host"
+	case groupTempDir:
+		return "temp dir"
+	}
+	return ""
+}
+
+// setPlotGrouping groups the active plots table, unknown groupings show it flat.
+func (client *Client) setPlotGrouping(grouping plotGrouping) {
+	client.grouping = groupNone
+	client.activePlotsTable.SetGroupFunc(nil)
+	for _, known := range plotGroupings {
+		if grouping == known && grouping != groupNone {
+			client.grouping = grouping
+			client.activePlotsTable.SetGroupFunc(client.plotGroup)
+		}
+	}
+}
+
+// cyclePlotGrouping switches the active plots table to the next grouping.
+func (client *Client) cyclePlotGrouping() {
+	next := groupNone
+	for i, grouping := range plotGroupings {
+		if grouping == client.grouping {
+			next = plotGroupings[(i+1)%len(plotGroupings)]
+		}
+	}
+	client.setPlotGrouping(next)
+	client.drawActivePlotsTable()
+}
+
+// plotGroup returns the section of an active plot.  The temp directories are prefixed with their
+// host when there are several, as the same path on two hosts is two drives.
+func (client *Client) plotGroup(key string, data widget.SortableRow) string {
+	apd, ok := data.(*activePlotsData)
+	if !ok {
+		return ""
+	}
+	switch client.grouping {
+	case groupState:
+		return apd.Strings()[2]
+	case groupHost:
+		return apd.Host
+	case groupTempDir:
+		if len(client.hosts) > 1 {
+			return apd.Host + ":" + apd.PlotDir
+		}
+		return apd.PlotDir
+	}
+	return ""
+}
+
+// groupingTitle returns the suffix of the title of the active plots table naming its grouping.
+func (client *Client) groupingTitle() string {
+	if client.grouping == groupNone {
+		return ""
+	}
+	return fmt.Sprintf("[%s] ", locale.Sprintf("by %s", locale.T(client.grouping.String())))
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"plotng/internal/locale"
+)
+
+// haltedHosts returns the hosts which stopped starting new plots after repeated failures.
+func (client *Client) haltedHosts() []string {
+	var hosts []string
+	for host, msg := range client.msg {
+		if msg.Halted != nil {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// confirmResume asks to resume plotting on the halted hosts.
+func (client *Client) confirmResume() {
+	hosts := client.haltedHosts()
+	if len(hosts) == 0 {
+		return
+	}
+	client.confirm(locale.Sprintf("Resume plotting on %s?", strings.Join(hosts, ", ")), func() {
+		for _, host := range hosts {
+			host := host
+			client.spawn(func() {
+				client.resume(host)
+			})
+		}
+	})
+}
+
+func (client *Client) resume(host string) {
+	resp, err := client.api.do(client.http, host, "DELETE", "/halt", nil)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		if err != nil {
+			client.logTextbox.SetText(locale.Sprintf("Failed to resume plotting on %s: %s", host, err))
+		} else {
+			client.logTextbox.SetText(locale.Sprintf("Resumed plotting on %s", host))
+		}
+	})
+	client.checkServer(host)
+}
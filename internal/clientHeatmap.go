@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// heatmapDays is how many days of archived plots are shown in the heatmap view.
+const heatmapDays = 28
+
+// makeHeatmapData buckets the finished plots of all hosts by the day and hour they completed.
+// Row 0 is the oldest day, the last row is today.
+func (client *Client) makeHeatmapData(now time.Time) (rowLabels []string, colLabels []string, values [][]int) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	firstDay := today.AddDate(0, 0, -(heatmapDays - 1))
+
+	for hour := 0; hour < 24; hour++ {
+		colLabels = append(colLabels, fmt.Sprintf("%02d", hour))
+	}
+	for day := 0; day < heatmapDays; day++ {
+		rowLabels = append(rowLabels, firstDay.AddDate(0, 0, day).Format("Mon 01-02"))
+		values = append(values, make([]int, 24))
+	}
+
+	for _, msg := range client.msg {
+		for _, plot := range msg.Archived {
+			if plot.State != PlotFinished {
+				continue
+			}
+			endTime := plot.getPhaseTime(4).In(now.Location())
+			endDay := time.Date(endTime.Year(), endTime.Month(), endTime.Day(), 0, 0, 0, 0, now.Location())
+			if endDay.Before(firstDay) || endDay.After(today) {
+				continue
+			}
+			day := int(endDay.Sub(firstDay).Hours()+12) / 24 // round to survive DST changes
+			values[day][endTime.Hour()]++
+		}
+	}
+	return
+}
+
+func (client *Client) drawHeatmap() {
+	rowLabels, colLabels, values := client.makeHeatmapData(format.In(time.Now()))
+	client.heatmap.SetData(rowLabels, colLabels, values)
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// highlightDuration is how long a row which appeared or changed state is highlighted, fading back
+// to its own colour, so changes stand out on tables which otherwise look the same from one update
+// to the next.
+const highlightDuration = 10 * time.Second
+
+// rowChanges tracks the state of the rows of a table to know when each one appeared or changed.
+// The rows of a host are recorded without being highlighted until the host was drawn once, so the
+// whole table doesn't light up when the UI starts or a host is added.
+type rowChanges struct {
+	states  map[string]string
+	changed map[string]time.Time
+	shown   map[string]struct{}
+	seen    map[string]bool
+}
+
+func newRowChanges() *rowChanges {
+	return &rowChanges{
+		states:  map[string]string{},
+		changed: map[string]time.Time{},
+		seen:    map[string]bool{},
+	}
+}
+
+// begin starts a draw of the table.
+func (rc *rowChanges) begin() {
+	rc.shown = map[string]struct{}{}
+}
+
+// update records the state of the row key of host and returns when it appeared or last changed
+// state, the zero time when that isn't recent.
+func (rc *rowChanges) update(host string, key string, state string, now time.Time) time.Time {
+	rc.shown[key] = struct{}{}
+	previous, known := rc.states[key]
+	rc.states[key] = state
+	if rc.seen[host] && (!known || previous != state) {
+		rc.changed[key] = now
+	}
+	return rc.changed[key]
+}
+
+// end finishes a draw of the table with the hosts drawn, forgetting the rows which are gone and
+// the highlights which faded out.
+func (rc *rowChanges) end(hosts []string, now time.Time) {
+	for key := range rc.states {
+		if _, ok := rc.shown[key]; !ok {
+			delete(rc.states, key)
+			delete(rc.changed, key)
+		}
+	}
+	for key, changed := range rc.changed {
+		if now.Sub(changed) >= highlightDuration {
+			delete(rc.changed, key)
+		}
+	}
+	for _, host := range hosts {
+		rc.seen[host] = true
+	}
+}
+
+// fading returns whether a row is still highlighted.
+func (rc *rowChanges) fading(now time.Time) bool {
+	for _, changed := range rc.changed {
+		if now.Sub(changed) < highlightDuration {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightColor returns the colour of a row with the given colour which appeared or changed at
+// changed, going from the secondary colour of the theme back to color over highlightDuration.
+func highlightColor(color tcell.Color, changed time.Time, now time.Time) tcell.Color {
+	if changed.IsZero() {
+		return color
+	}
+	fraction := float64(now.Sub(changed)) / float64(highlightDuration)
+	if fraction >= 1 {
+		return color
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+	from := tview.Styles.SecondaryTextColor
+	r1, g1, b1 := from.RGB()
+	r2, g2, b2 := color.RGB()
+	if r1 < 0 || r2 < 0 {
+		// Without RGB values, eg. the default colour of the terminal, there is nothing to blend
+		if fraction < 0.5 {
+			return from
+		}
+		return color
+	}
+	blend := func(a, b int32) int32 {
+		return a + int32(float64(b-a)*fraction)
+	}
+	return tcell.NewRGBColor(blend(r1, r2), blend(g1, g2), blend(b1, b2))
+}
+
+// hostsShown returns the hosts which sent their plots.
+func (client *Client) hostsShown() []string {
+	hosts := make([]string, 0, len(client.msg))
+	for host := range client.msg {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// highlighting returns whether rows are highlighted, and the screen needs a draw every second as
+// the tables otherwise only change when a host sends an update.
+func (client *Client) highlighting(now time.Time) bool {
+	return client.activeChanges.fading(now) || client.archivedChanges.fading(now)
+}
+
+// animate repaints the screen while rows are highlighted or the status bar flashes.  It runs on
+// the tview thread.
+func (client *Client) animate() {
+	now := time.Now()
+	highlighting := client.highlighting(now)
+	if client.flashing(now) || highlighting {
+		client.app.ForceDraw()
+	}
+}
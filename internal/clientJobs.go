@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"plotng/internal/locale"
+)
+
+// modal centers p in a box of the given size on top of the current page.
+func modal(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (client *Client) showAddJobDialog() {
+	if client.pages.HasPage("addJob") {
+		return
+	}
+	form := tview.NewForm()
+	tempDirs := tview.NewDropDown().SetLabel("Temp Dir")
+	destDirs := tview.NewDropDown().SetLabel("Dest Dir")
+	var tempOptions, destOptions []string
+	host := ""
+	selectHost := func(option string, index int) {
+		host = option
+		tempOptions, destOptions = nil, nil
+		if msg, ok := client.msg[host]; ok {
+			tempOptions = sortedKeys(msg.TempDirs)
+			destOptions = sortedKeys(msg.TargetDirs)
+		}
+		tempDirs.SetOptions(tempOptions, nil).SetCurrentOption(0)
+		destDirs.SetOptions(destOptions, nil).SetCurrentOption(0)
+	}
+
+	form.AddDropDown("Host", client.hosts, 0, selectHost)
+	form.AddFormItem(tempDirs)
+	form.AddFormItem(destDirs)
+	form.AddInputField("K Size", "32", 4, tview.InputFieldInteger, nil)
+	form.AddInputField("Fingerprint", "", 20, nil, nil)
+	form.AddInputField("Farmer Public Key", "", 40, nil, nil)
+	form.AddInputField("Pool Public Key", "", 40, nil, nil)
+	form.AddInputField("Labels", "", 40, nil, nil)
+
+	closeDialog := func() {
+		client.pages.RemovePage("addJob")
+		client.app.SetFocus(client.pages)
+	}
+	form.AddButton("Submit", func() {
+		job := &PlotJob{
+			Fingerprint:     form.GetFormItemByLabel("Fingerprint").(*tview.InputField).GetText(),
+			FarmerPublicKey: form.GetFormItemByLabel("Farmer Public Key").(*tview.InputField).GetText(),
+			PoolPublicKey:   form.GetFormItemByLabel("Pool Public Key").(*tview.InputField).GetText(),
+		}
+		for _, label := range strings.Split(form.GetFormItemByLabel("Labels").(*tview.InputField).GetText(), ",") {
+			if label = strings.TrimSpace(label); len(label) > 0 {
+				job.Labels = append(job.Labels, label)
+			}
+		}
+		job.PlotSize, _ = strconv.Atoi(form.GetFormItemByLabel("K Size").(*tview.InputField).GetText())
+		if _, option := tempDirs.GetCurrentOption(); len(option) > 0 {
+			job.TempDir = option
+		}
+		if _, option := destDirs.GetCurrentOption(); len(option) > 0 {
+			job.TargetDir = option
+		}
+		closeDialog()
+		client.spawn(func() {
+			client.submitJob(host, job)
+		})
+	})
+	form.AddButton("Cancel", closeDialog)
+	form.SetCancelFunc(closeDialog)
+	form.SetBorder(true).SetTitle(paneTitle("Add Job")).SetTitleAlign(tview.AlignLeft)
+
+	client.pages.AddPage("addJob", modal(form, 72, 21), true, true)
+	client.app.SetFocus(form)
+}
+
+func (client *Client) submitJob(host string, job *PlotJob) {
+	data, err := json.Marshal(job)
+	if err == nil {
+		var resp *http.Response
+		resp, err = client.api.do(client.http, host, "POST", "/jobs", data)
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				body, _ := ioutil.ReadAll(resp.Body)
+				err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+			}
+		}
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		if err != nil {
+			client.logTextbox.SetText(locale.Sprintf("Failed to submit job to %s: %s", host, err))
+		} else {
+			client.logTextbox.SetText(locale.Sprintf("Job queued on %s: %s -> %s", host, job.TempDir, job.TargetDir))
+		}
+	})
+	client.checkServer(host)
+}
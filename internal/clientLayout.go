@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// layoutPanes are the rows of the plots page, "dirs" holds the plot and dest directories side by
+// side.
+var layoutPanes = []string{"active", "dirs", "archived", "log"}
+
+// maxPaneSize is the largest share of the page a pane can be given, against 1 for the smallest.
+const maxPaneSize = 10
+
+// defaultWorkspace is the workspace used until another one is picked.
+const defaultWorkspace = "default"
+
+// defaultCompactWidth is the terminal width, in columns, below which the UI is compact.
+const defaultCompactWidth = 120
+
+// compactColumns are the columns of the active plots kept when the UI is compact.
+var compactColumns = []string{"Plot ID", "Phase", "Progress", "ETA"}
+
+// ClientLayout is the arrangement of the panes of the plots page: the order of the rows from the
+// top, their share of the height, the share of the width of the plot and dest directories, and
+// the pane filling the page on its own when zoomed.
+type ClientLayout struct {
+	Rows    []string
+	Heights []int
+	Widths  [2]int
+	Zoom    string
+}
+
+func defaultLayout() *ClientLayout {
+	return &ClientLayout{
+		Rows:    append([]string(nil), layoutPanes...),
+		Heights: []int{1, 1, 1, 1},
+		Widths:  [2]int{5, 5},
+	}
+}
+
+// valid reports whether the layout has every pane once with a usable size, a layout edited by
+// hand or saved by another version may not.
+func (layout *ClientLayout) valid() bool {
+	if layout == nil || len(layout.Rows) != len(layoutPanes) || len(layout.Heights) != len(layout.Rows) {
+		return false
+	}
+	seen := map[string]bool{}
+	for i, pane := range layout.Rows {
+		if seen[pane] || layout.Heights[i] < 1 || layout.Heights[i] > maxPaneSize {
+			return false
+		}
+		seen[pane] = true
+	}
+	for _, pane := range layoutPanes {
+		if !seen[pane] {
+			return false
+		}
+	}
+	return layout.Widths[0] >= 1 && layout.Widths[0] <= maxPaneSize && layout.Widths[1] >= 1 && layout.Widths[1] <= maxPaneSize
+}
+
+func (layout *ClientLayout) copy() *ClientLayout {
+	copied := *layout
+	copied.Rows = append([]string(nil), layout.Rows...)
+	copied.Heights = append([]int(nil), layout.Heights...)
+	return &copied
+}
+
+func (layout *ClientLayout) index(pane string) int {
+	for i, row := range layout.Rows {
+		if row == pane {
+			return i
+		}
+	}
+	return -1
+}
+
+// workspaceLayout returns the layout saved for the current workspace, or the default one.
+func (client *Client) workspaceLayout() *ClientLayout {
+	if len(client.config.Workspace) == 0 {
+		client.config.Workspace = defaultWorkspace
+	}
+	if layout := client.config.Workspaces[client.config.Workspace]; layout.valid() {
+		return layout.copy()
+	}
+	return defaultLayout()
+}
+
+// focusedPane returns the row of the plots page holding the focus.
+func (client *Client) focusedPane() string {
+	switch {
+	case client.activePlotsTable.HasFocus():
+		return "active"
+	case client.plotDirsTable.HasFocus(), client.destDirsTable.HasFocus():
+		return "dirs"
+	case client.archivedPlotsTable.HasFocus():
+		return "archived"
+	case client.logTextbox.HasFocus():
+		return "log"
+	}
+	return ""
+}
+
+func (client *Client) panePrimitive(pane string) tview.Primitive {
+	switch pane {
+	case "active":
+		return client.activePlotsTable
+	case "dirs":
+		return client.dirPanel
+	case "archived":
+		return client.archivedPlotsTable
+	}
+	return client.logTextbox
+}
+
+// applyLayout arranges the plots page after the layout and keeps it for the workspace.
+func (client *Client) applyLayout() {
+	layout := client.layout
+	focus := client.app.GetFocus()
+	client.dirPanel.Clear()
+	client.dirPanel.AddItem(client.plotDirsTable, 0, layout.Widths[0], true)
+	client.dirPanel.AddItem(client.destDirsTable, 0, layout.Widths[1], false)
+	client.mainPanel.Clear()
+	top := true // the page gives the focus to the top pane when switched to
+	for i, pane := range layout.Rows {
+		if len(layout.Zoom) == 0 && client.compact && (pane == "dirs" || pane == "archived") {
+			continue
+		}
+		if len(layout.Zoom) == 0 || layout.Zoom == pane {
+			client.mainPanel.AddItem(client.panePrimitive(pane), 0, layout.Heights[i], top)
+			top = false
+		}
+	}
+	if focus != nil {
+		client.app.SetFocus(focus)
+	}
+	if pane := client.focusedPane(); len(layout.Zoom) == 0 && client.compact && (pane == "dirs" || pane == "archived") {
+		client.activePlotsTable.SetFocus(client.app)
+	}
+	if client.config.Workspaces == nil {
+		client.config.Workspaces = map[string]*ClientLayout{}
+	}
+	client.config.Workspaces[client.config.Workspace] = layout.copy()
+}
+
+// layoutKeys handles the key pressed after Ctrl-B on the plots page, like the commands of tmux:
+// + and - change the height of the focused pane, < and > the width of the plot directories
+// against the dest directories, { and } move the pane up and down, z zooms it, = restores the
+// default layout and w switches to another workspace.
+func (client *Client) layoutKeys(event *tcell.EventKey) {
+	layout := client.layout
+	pane := client.focusedPane()
+	row := layout.index(pane)
+	switch event.Rune() {
+	case '+':
+		if row >= 0 && layout.Heights[row] < maxPaneSize {
+			layout.Heights[row]++
+		}
+	case '-':
+		if row >= 0 && layout.Heights[row] > 1 {
+			layout.Heights[row]--
+		}
+	case '>':
+		if layout.Widths[0] < maxPaneSize && layout.Widths[1] > 1 {
+			layout.Widths[0]++
+			layout.Widths[1]--
+		}
+	case '<':
+		if layout.Widths[0] > 1 && layout.Widths[1] < maxPaneSize {
+			layout.Widths[0]--
+			layout.Widths[1]++
+		}
+	case '{':
+		if row > 0 {
+			layout.Rows[row-1], layout.Rows[row] = layout.Rows[row], layout.Rows[row-1]
+			layout.Heights[row-1], layout.Heights[row] = layout.Heights[row], layout.Heights[row-1]
+		}
+	case '}':
+		if row >= 0 && row < len(layout.Rows)-1 {
+			layout.Rows[row+1], layout.Rows[row] = layout.Rows[row], layout.Rows[row+1]
+			layout.Heights[row+1], layout.Heights[row] = layout.Heights[row], layout.Heights[row+1]
+		}
+	case 'z':
+		if len(layout.Zoom) > 0 {
+			layout.Zoom = ""
+		} else {
+			layout.Zoom = pane
+		}
+	case '=':
+		client.layout = defaultLayout()
+	case 'w':
+		client.showWorkspaceDialog()
+		return
+	default:
+		return
+	}
+	client.applyLayout()
+}
+
+// showWorkspaceDialog asks for the workspace to switch to, a new name starts from the current
+// layout.
+func (client *Client) showWorkspaceDialog() {
+	if client.pages.HasPage("workspace") {
+		return
+	}
+	focus := client.app.GetFocus()
+	var names []string
+	for name := range client.config.Workspaces {
+		names = append(names, name)
+	}
+	input := tview.NewInputField().SetLabel("Name ").SetText(client.config.Workspace)
+	input.SetAutocompleteFunc(func(text string) []string {
+		var matches []string
+		for _, name := range names {
+			if strings.HasPrefix(name, text) {
+				matches = append(matches, name)
+			}
+		}
+		return matches
+	})
+	input.SetBorder(true).SetTitle(paneTitle("Workspace")).SetTitleAlign(tview.AlignLeft)
+	input.SetDoneFunc(func(key tcell.Key) {
+		client.pages.RemovePage("workspace")
+		if name := strings.TrimSpace(input.GetText()); key == tcell.KeyEnter && len(name) > 0 && name != client.config.Workspace {
+			client.config.Workspace = name
+			if layout := client.config.Workspaces[name]; layout.valid() {
+				client.layout = layout.copy()
+			}
+			client.applyLayout()
+		}
+		client.showView("plots")
+		client.app.SetFocus(focus)
+	})
+	client.pages.AddPage("workspace", modal(input, 50, 3), true, true)
+	client.app.SetFocus(input)
+}
+
+// followZoom zooms on the pane which got the focus, when a pane is zoomed.
+func (client *Client) followZoom() {
+	if pane := client.focusedPane(); len(client.layout.Zoom) > 0 && len(pane) > 0 && pane != client.layout.Zoom {
+		client.layout.Zoom = pane
+		client.applyLayout()
+	}
+}
+
+// compactWidth returns whether the UI is compact on a terminal width columns wide.
+func (client *Client) compactWidth(width int) bool {
+	threshold := client.config.CompactWidth
+	if threshold == 0 {
+		threshold = defaultCompactWidth
+	}
+	return threshold > 0 && width < threshold
+}
+
+// setCompact only shows the essential columns of the active plots, and hides the directories and
+// archived plots unless zoomed on them, so rows don't wrap on an 80 column terminal.
+func (client *Client) setCompact(compact bool) {
+	if compact == client.compact {
+		return
+	}
+	client.compact = compact
+	if compact {
+		columns := compactColumns
+		if len(client.hosts) > 1 {
+			columns = append([]string{"Host"}, columns...)
+		}
+		client.activePlotsTable.SetVisibleColumns(columns...)
+	} else {
+		client.activePlotsTable.SetVisibleColumns()
+	}
+	client.applyLayout()
+	client.drawStatusBar()
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+
+	"plotng/internal/locale"
+)
+
+// applyLanguage picks the language of the UI strings from the Language preference, or from the
+// environment (LC_ALL, LC_MESSAGES or LANG) when it is empty.
+func (cc *ClientConfig) applyLanguage() {
+	lang, known := locale.Resolve(cc.Language)
+	if len(cc.Language) == 0 {
+		lang, _ = locale.Resolve(locale.Environment())
+	} else if !known {
+		log.Printf("Unknown language [%s], using English, the languages are en, %s", cc.Language, strings.Join(locale.Languages(), ", "))
+	}
+	locale.SetLanguage(lang)
+
+	// With a CJK locale, the characters of ambiguous width like the box drawing ones count as two
+	// columns, which breaks the borders on most terminals.  RUNEWIDTH_EASTASIAN=1 keeps them wide.
+	if len(os.Getenv("RUNEWIDTH_EASTASIAN")) == 0 {
+		runewidth.EastAsianWidth = false
+		runewidth.DefaultCondition.EastAsianWidth = false
+	}
+}
+
+// paneTitle returns the translated title of a pane, with the spaces which keep it off the border.
+func paneTitle(title string) string {
+	return " " + locale.T(title) + " "
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+
+	"plotng/internal/locale"
+)
+
+// logFilter picks the plot log lines shown in the log view, the state changes are always shown.
+type logFilter int
+
+const (
+	logFilterAll logFilter = iota
+	logFilterProblems
+	logFilterBoundaries
+)
+
+var (
+	logProblemRegexp  = regexp.MustCompile(`(?i)warn|error|fail|exception|caught|dropped`)
+	logBoundaryRegexp = regexp.MustCompile(`Starting phase|Time for phase|Total time|Copy time|Computing table|Backpropagating on table|Compressing tables|Write checkpoint tables|Renamed final file`)
+)
+
+func (filter logFilter) String() string {
+	switch filter {
+	case logFilterProblems:
+		return "warnings/errors"
+	case logFilterBoundaries:
+		return "phases/tables"
+	}
+	return ""
+}
+
+// shows reports whether a line of the plotter's output passes the filter.
+func (filter logFilter) shows(line string) bool {
+	switch filter {
+	case logFilterProblems:
+		return logProblemRegexp.MatchString(line)
+	case logFilterBoundaries:
+		return logBoundaryRegexp.MatchString(line)
+	}
+	return true
+}
+
+// logTitle returns the title of the log view for the selected plot.
+func (client *Client) logTitle() string {
+	if client.logFilter == logFilterAll {
+		return fmt.Sprintf(" %s (%s) ", locale.T("Log"), shortenPlotId(client.logPlotId))
+	}
+	return fmt.Sprintf(" %s (%s) [%s] ", locale.T("Log"), shortenPlotId(client.logPlotId), locale.T(client.logFilter.String()))
+}
+
+// showPlotLog shows the log of the selected plot, active or archived.
+func (client *Client) showPlotLog() {
+	client.logTextbox.SetTitle(client.logTitle())
+	log, found := client.activeLogs[client.logPlotId]
+	if !found {
+		log, found = client.archivedLogs[client.logPlotId]
+	}
+	if found {
+		client.logTextbox.SetText(strings.Join(log, ""))
+		client.logTextbox.ScrollToEnd()
+	} else {
+		client.logTextbox.SetText("")
+	}
+}
+
+// logKeys toggles the log filters: w shows only warnings and errors, b only the phase and table
+// boundaries, pressing the key again shows everything.
+func (client *Client) logKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune {
+		return client.tabBetweenTables(event)
+	}
+	filter := logFilterAll
+	switch event.Rune() {
+	case 'w':
+		filter = logFilterProblems
+	case 'b':
+		filter = logFilterBoundaries
+	default:
+		return event
+	}
+	if client.logFilter == filter {
+		filter = logFilterAll
+	}
+	client.logFilter = filter
+	client.drawActivePlotsTable()
+	client.drawArchivedPlotsTable()
+	client.showPlotLog()
+	return nil
+}
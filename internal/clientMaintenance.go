@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"time"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// maintenanceCountdown is how long before a maintenance window the status bar starts counting down.
+const maintenanceCountdown = 24 * time.Hour
+
+// maintenanceStatus describes the maintenance windows of the hosts which are in one or about to
+// start one.
+func (client *Client) maintenanceStatus(now time.Time) []string {
+	var status []string
+	for _, host := range client.hosts {
+		msg, ok := client.msg[host]
+		if !ok || msg.Maintenance.Start.IsZero() {
+			continue
+		}
+		m := msg.Maintenance
+		switch {
+		case m.Active:
+			status = append(status, locale.Sprintf("%s: maintenance [%s] until %s", host, m.Name, format.ShortTime(m.End)))
+		case m.Start.Sub(now) < maintenanceCountdown:
+			status = append(status, locale.Sprintf("%s: maintenance [%s] in %s", host, m.Name, format.Duration(m.Start.Sub(now))))
+		}
+	}
+	return status
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// Orphaned temp files
+
+type orphanData struct {
+	Host    string    `header:"Host"`
+	TempDir string    `header:"Temp Dir"`
+	Name    string    `header:"File"`
+	Size    uint64    `header:"Size" data-align:"right" data-unit:"bytes"`
+	ModTime time.Time `header:"Modified"`
+
+	path string
+}
+
+func (od *orphanData) Strings() []string {
+	return []string{
+		od.Host,
+		od.TempDir,
+		od.Name,
+		format.GiBytes(od.Size),
+		format.Time(od.ModTime),
+	}
+}
+
+func (client *Client) drawOrphansTable() {
+	var total uint64
+	count := 0
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.orphansTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+
+	for host, msg := range client.msg {
+		for _, orphan := range msg.Orphans {
+			key := host + "||" + orphan.Path
+			delete(keysToRemove, key)
+			name := filepath.Base(orphan.Path)
+			if !orphan.reclaimable() {
+				name += " (" + locale.Sprintf("%d finished plots to recover", len(orphan.Plots)) + ")"
+			}
+			client.orphansTable.SetRowData(key, &orphanData{
+				Host:    host,
+				TempDir: orphan.TempDir,
+				Name:    name,
+				Size:    orphan.Size,
+				ModTime: orphan.ModTime,
+				path:    orphan.Path,
+			})
+			if orphan.reclaimable() {
+				total += orphan.Size
+				count++
+			}
+		}
+	}
+
+	for key := range keysToRemove {
+		client.orphansTable.ClearRowData(key)
+	}
+
+	client.orphansTable.SetTitle(fmt.Sprintf(" %s [%d, %s] (%s) ", locale.T("Orphaned Temp Files"), count, locale.Sprintf("%s reclaimable", format.GiBytes(total)), locale.T("r: reclaim selected, R: reclaim all on host")))
+}
+
+func (client *Client) orphansKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune || (event.Rune() != 'r' && event.Rune() != 'R') {
+		return event
+	}
+	key := client.orphansTable.GetSelection()
+	parts := strings.SplitN(key, "||", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	host, path := parts[0], parts[1]
+	text := locale.Sprintf("Delete %s on %s?", path, host)
+	if event.Rune() == 'R' {
+		path = ""
+		text = locale.Sprintf("Delete all orphaned temp files on %s?", host)
+	}
+	client.confirm(text, func() {
+		client.spawn(func() {
+			client.reclaimOrphans(host, path)
+		})
+	})
+	return nil
+}
+
+// confirm shows a yes/no dialog and calls yes if the user agrees.
+func (client *Client) confirm(text string, yes func()) {
+	if client.readOnly {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		client.logTextbox.SetText(locale.T("This session is read-only."))
+		return
+	}
+	dialog := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{locale.T("Yes"), locale.T("No")}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			client.pages.RemovePage("confirm")
+			client.app.SetFocus(client.pages)
+			if buttonIndex == 0 {
+				yes()
+			}
+		})
+	client.pages.AddPage("confirm", dialog, true, true)
+	client.app.SetFocus(dialog)
+}
+
+func (client *Client) reclaimOrphans(host string, path string) {
+	target := "/orphans"
+	if len(path) > 0 {
+		target += "?path=" + url.QueryEscape(path)
+	}
+	var result struct {
+		Reclaimed uint64
+	}
+	resp, err := client.api.do(client.http, host, "DELETE", target, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		if err != nil {
+			client.logTextbox.SetText(locale.Sprintf("Failed to reclaim orphaned files on %s: %s", host, err))
+		} else {
+			client.logTextbox.SetText(locale.Sprintf("Reclaimed %s on %s", format.Space(result.Reclaimed), host))
+		}
+	})
+	client.checkServer(host)
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// Queued jobs
+
+type queueData struct {
+	Position   int       `header:"#" data-align:"right"`
+	Host       string    `header:"Host"`
+	JobId      int64     `header:"Job" data-align:"right"`
+	Status     string    `header:"Status"`
+	SubmitTime time.Time `header:"Submitted"`
+	TempDir    string    `header:"Temp Dir"`
+	TargetDir  string    `header:"Dest Dir"`
+	PlotSize   int       `header:"K" data-align:"right"`
+	Labels     string    `header:"Labels"`
+
+	held bool
+}
+
+func (qd *queueData) TextColor() tcell.Color {
+	if qd.held {
+		return tcell.ColorDarkGray
+	}
+	return tview.Styles.PrimaryTextColor
+}
+
+func (qd *queueData) Strings() []string {
+	size := ""
+	if qd.PlotSize > 0 {
+		size = fmt.Sprintf("%d", qd.PlotSize)
+	}
+	return []string{
+		fmt.Sprintf("%d", qd.Position),
+		qd.Host,
+		fmt.Sprintf("%d", qd.JobId),
+		qd.Status,
+		format.Time(qd.SubmitTime),
+		qd.TempDir,
+		qd.TargetDir,
+		size,
+		qd.Labels,
+	}
+}
+
+func (client *Client) drawQueueTable() {
+	count, held := 0, 0
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.queueTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+
+	for host, msg := range client.msg {
+		for i, job := range msg.Queued {
+			key := fmt.Sprintf("%s||%d", host, job.JobId)
+			delete(keysToRemove, key)
+			status := "Queued"
+			if job.Held {
+				status = "Held"
+				held++
+			}
+			client.queueTable.SetRowData(key, &queueData{
+				Position:   i + 1,
+				Host:       host,
+				JobId:      job.JobId,
+				Status:     status,
+				SubmitTime: job.SubmitTime,
+				TempDir:    job.TempDir,
+				TargetDir:  job.TargetDir,
+				PlotSize:   job.PlotSize,
+				Labels:     strings.Join(job.Labels, ", "),
+				held:       job.Held,
+			})
+			count++
+		}
+	}
+
+	for key := range keysToRemove {
+		client.queueTable.ClearRowData(key)
+	}
+
+	client.queueTable.SetTitle(fmt.Sprintf(" %s [%d (%s)] (%s) ", locale.T("Queued Jobs"), count, locale.Sprintf("%d held", held), locale.T("u/d: move up/down, t: top, h: hold/release, c: cancel")))
+}
+
+func (client *Client) queueKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune {
+		return event
+	}
+	key := client.queueTable.GetSelection()
+	parts := strings.SplitN(key, "||", 2)
+	if len(parts) != 2 {
+		return event
+	}
+	host := parts[0]
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return event
+	}
+	action := ""
+	switch event.Rune() {
+	case 'u':
+		action = "up"
+	case 'd':
+		action = "down"
+	case 't':
+		action = "top"
+	case 'h':
+		action = "hold"
+		for _, job := range client.msg[host].Queued {
+			if job.JobId == id && job.Held {
+				action = "release"
+			}
+		}
+	case 'c':
+		client.confirm(locale.Sprintf("Cancel job %d on %s?", id, host), func() {
+			client.spawn(func() {
+				client.changeJob(host, id, "cancel")
+			})
+		})
+		return nil
+	default:
+		return event
+	}
+	client.spawn(func() {
+		client.changeJob(host, id, action)
+	})
+	return nil
+}
+
+func (client *Client) changeJob(host string, id int64, action string) {
+	resp, err := client.api.do(client.http, host, "PUT", fmt.Sprintf("/jobs?id=%d&action=%s", id, action), nil)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+	}
+	if err != nil {
+		client.app.QueueUpdateDraw(func() {
+			client.logTextbox.SetTitle(paneTitle("Log"))
+			client.logTextbox.SetText(locale.Sprintf("Failed to %s job %d on %s: %s", action, id, host, err))
+		})
+	}
+	client.checkServer(host)
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"fmt"
+)
+
+// Quotas
+
+type quotaData struct {
+	Host      string `header:"Host"`
+	Customer  string `header:"Key"`
+	Completed int    `header:"Completed" data-align:"right"`
+	Active    int    `header:"Active" data-align:"right"`
+	Failed    int    `header:"Failed" data-align:"right"`
+	Quota     int    `header:"Quota" data-align:"right"`
+	Remaining int    `header:"Remaining" data-align:"right"`
+
+	hasQuota bool
+}
+
+func (qd *quotaData) Strings() []string {
+	quota, remaining := "-", "-"
+	if qd.hasQuota {
+		quota = fmt.Sprintf("%d", qd.Quota)
+		remaining = fmt.Sprintf("%d", qd.Remaining)
+	}
+	customer := qd.Customer
+	if len(customer) == 0 {
+		customer = "(default)"
+	} else if len(customer) > 20 {
+		customer = shortenPlotId(customer)
+	}
+	return []string{
+		qd.Host,
+		customer,
+		fmt.Sprintf("%d", qd.Completed),
+		fmt.Sprintf("%d", qd.Active),
+		fmt.Sprintf("%d", qd.Failed),
+		quota,
+		remaining,
+	}
+}
+
+// makeQuotaData breaks down the plots of every host by the key they were made for.
+func (client *Client) makeQuotaData() map[string]*quotaData {
+	quotas := make(map[string]*quotaData)
+	get := func(host, customer string) *quotaData {
+		qd, ok := quotas[host+"||"+customer]
+		if !ok {
+			qd = &quotaData{Host: host, Customer: customer}
+			quotas[host+"||"+customer] = qd
+		}
+		return qd
+	}
+
+	for host, msg := range client.msg {
+		for customer, quota := range msg.Quotas {
+			qd := get(host, customer)
+			qd.Quota = quota
+			qd.hasQuota = true
+		}
+		for _, plot := range msg.Actives {
+			get(host, plot.customer()).Active++
+		}
+		for customer, finished := range msg.QuotaFinished {
+			get(host, customer).Completed = finished
+		}
+		for _, plot := range msg.Archived {
+			switch plot.State {
+			case PlotFinished:
+				if msg.QuotaFinished == nil { // older servers
+					get(host, plot.customer()).Completed++
+				}
+			case PlotError, PlotKilled:
+				get(host, plot.customer()).Failed++
+			}
+		}
+	}
+
+	for _, qd := range quotas {
+		if qd.hasQuota {
+			qd.Remaining = qd.Quota - qd.Completed - qd.Active
+			if qd.Remaining < 0 {
+				qd.Remaining = 0
+			}
+		}
+	}
+	return quotas
+}
+
+func (client *Client) drawQuotaTable() {
+	quotas := client.makeQuotaData()
+
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.quotaTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+
+	for key, qd := range quotas {
+		delete(keysToRemove, key)
+		client.quotaTable.SetRowData(key, qd)
+	}
+
+	for key := range keysToRemove {
+		client.quotaTable.ClearRowData(key)
+	}
+}
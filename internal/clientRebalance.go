@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// Rebalance moves
+
+type rebalanceData struct {
+	Host      string `header:"Host"`
+	Plot      string `header:"Plot"`
+	SourceDir string `header:"From"`
+	TargetDir string `header:"To"`
+	Size      uint64 `header:"Size" data-align:"right" data-unit:"bytes"`
+	State     string `header:"State"`
+	Progress  string `header:"Progress" data-align:"right"`
+
+	err string
+}
+
+func (rd *rebalanceData) TextColor() tcell.Color {
+	switch rd.State {
+	case MoveFailed:
+		return tcell.ColorRed
+	case MoveDone:
+		return tcell.ColorGreen
+	}
+	return tview.Styles.PrimaryTextColor
+}
+
+func (rd *rebalanceData) Strings() []string {
+	state := rd.State
+	if len(rd.err) > 0 {
+		state += ": " + rd.err
+	}
+	return []string{
+		rd.Host,
+		rd.Plot,
+		rd.SourceDir,
+		rd.TargetDir,
+		format.GiBytes(rd.Size),
+		state,
+		rd.Progress,
+	}
+}
+
+// currentRebalance returns the rebalance shown for host, the last dry run unless a rebalance
+// started after it.
+func (client *Client) currentRebalance(host string) *Rebalance {
+	var rebalance *Rebalance
+	if msg, ok := client.msg[host]; ok {
+		rebalance = msg.Rebalance
+	}
+	if plan, ok := client.rebalancePlans[host]; ok && (rebalance == nil || plan.StartTime.After(rebalance.StartTime)) {
+		rebalance = plan
+	}
+	return rebalance
+}
+
+func (client *Client) drawRebalanceTable() {
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.rebalanceTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+
+	count := 0
+	for _, host := range client.hosts {
+		rebalance := client.currentRebalance(host)
+		if rebalance == nil {
+			continue
+		}
+		for _, move := range rebalance.Moves {
+			key := host + "||" + move.Source
+			delete(keysToRemove, key)
+			progress := ""
+			if move.State == MoveRunning && move.Size > 0 {
+				progress = fmt.Sprintf("%d%% %s", move.TransferredBytes*100/move.Size, format.Rate(move.TransferRate))
+			}
+			client.rebalanceTable.SetRowData(key, &rebalanceData{
+				Host:      host,
+				Plot:      filepath.Base(move.Source),
+				SourceDir: filepath.Dir(move.Source),
+				TargetDir: move.TargetDir,
+				Size:      move.Size,
+				State:     move.State,
+				Progress:  progress,
+				err:       move.Error,
+			})
+			count++
+		}
+	}
+
+	for key := range keysToRemove {
+		client.rebalanceTable.ClearRowData(key)
+	}
+
+	client.rebalanceTable.SetTitle(fmt.Sprintf(" %s [%s] (%s) ", locale.T("Rebalance"), locale.Sprintf("%d moves", count), locale.T("p: plan, b: rebalance, c: cancel")))
+}
+
+// distributionKeys plans (p), starts (b) or cancels (c) a rebalance on the host of the selected
+// dest directory.
+func (client *Client) distributionKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune {
+		return event
+	}
+	parts := strings.SplitN(client.distributionTable.GetSelection(), "||", 2)
+	if len(parts) != 2 {
+		return event
+	}
+	host := parts[0]
+	switch event.Rune() {
+	case 'p':
+		client.spawn(func() {
+			client.requestRebalance(host, "POST", &RebalanceRequest{DryRun: true})
+		})
+	case 'b':
+		client.confirm(locale.Sprintf("Move plots between the dest directories of %s to even out their fill levels?", host), func() {
+			client.spawn(func() {
+				client.requestRebalance(host, "POST", &RebalanceRequest{})
+			})
+		})
+	case 'c':
+		client.spawn(func() {
+			client.requestRebalance(host, "DELETE", nil)
+		})
+	default:
+		return event
+	}
+	return nil
+}
+
+func (client *Client) requestRebalance(host string, method string, request *RebalanceRequest) {
+	var body []byte
+	if request != nil {
+		body, _ = json.Marshal(request)
+	}
+	var rebalance *Rebalance
+	resp, err := client.api.do(client.http, host, method, "/rebalance", body)
+	if err == nil {
+		if resp.StatusCode != http.StatusOK {
+			data, _ := ioutil.ReadAll(resp.Body)
+			err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&rebalance)
+		}
+		resp.Body.Close()
+	}
+	client.app.QueueUpdateDraw(func() {
+		client.logTextbox.SetTitle(paneTitle("Log"))
+		switch {
+		case err != nil:
+			client.logTextbox.SetText(locale.Sprintf("Rebalance on %s failed: %s", host, err))
+		case rebalance == nil:
+			client.logTextbox.SetText(locale.Sprintf("No rebalance on %s", host))
+		case rebalance.DryRun:
+			client.rebalancePlans[host] = rebalance
+			client.logTextbox.SetText(locale.Sprintf("Rebalancing %s would move %d plots", host, len(rebalance.Moves)))
+		default:
+			delete(client.rebalancePlans, host)
+			client.logTextbox.SetText(locale.Sprintf("Rebalance on %s: %d moves", host, len(rebalance.Moves)))
+		}
+		client.drawRebalanceTable()
+	})
+	client.checkServer(host)
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+	"plotng/internal/widget"
+)
+
+// renderSamples is the number of recent frames, updates and fetches the rendering statistics are
+// computed from.
+const renderSamples = 100
+
+// durationSamples keeps the last renderSamples durations of something the UI does repeatedly.
+type durationSamples struct {
+	values [renderSamples]time.Duration
+	next   int
+	count  int
+}
+
+func (ds *durationSamples) add(d time.Duration) {
+	ds.values[ds.next] = d
+	ds.next = (ds.next + 1) % renderSamples
+	if ds.count < renderSamples {
+		ds.count++
+	}
+}
+
+// String gives the last, mean, 95th percentile and maximum duration.
+func (ds durationSamples) String() string {
+	if ds.count == 0 {
+		return "-"
+	}
+	sorted := append([]time.Duration{}, ds.values[:ds.count]...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	last := ds.values[(ds.next+renderSamples-1)%renderSamples]
+	return fmt.Sprintf("%8s %8s %8s %8s", renderDuration(last), renderDuration(total/time.Duration(ds.count)),
+		renderDuration(sorted[(ds.count*95-1)/100]), renderDuration(sorted[ds.count-1]))
+}
+
+// renderDuration rounds d to a precision readable at a glance.
+func renderDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	count  uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += uint64(n)
+	return n, err
+}
+
+// observe records how long fetching the data of the host took: the round trip until the response
+// arrived, then the transfer and decoding of its size in bytes.
+func (hc *hostConnection) observe(roundTrip time.Duration, decode time.Duration, size uint64) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	hc.roundTrips.add(roundTrip)
+	hc.decodes.add(decode)
+	hc.size = size
+}
+
+// beforeDraw notes when a frame starts.
+func (client *Client) beforeDraw(screen tcell.Screen) bool {
+	client.frameStart = time.Now()
+	return false
+}
+
+// measureFrame records the time the frame took to draw, before it is shown on the terminal.
+func (client *Client) measureFrame() {
+	client.frameTimes.add(time.Since(client.frameStart))
+	client.frames++
+}
+
+// renderTables returns the tables of the UI with the titles of their panes.
+func (client *Client) renderTables() []struct {
+	title string
+	table *widget.SortedTable
+} {
+	return []struct {
+		title string
+		table *widget.SortedTable
+	}{
+		{"Active Plots", client.activePlotsTable},
+		{"Plot Directories", client.plotDirsTable},
+		{"Dest Directories", client.destDirsTable},
+		{"Archived Plots", client.archivedPlotsTable},
+		{"Statistics", client.statsTable},
+		{"Plots per Key", client.quotaTable},
+		{"Orphaned Temp Files", client.orphansTable},
+		{"Queued Jobs", client.queueTable},
+		{"Plot Distribution", client.distributionTable},
+		{"Rebalance", client.rebalanceTable},
+		{"Compare", client.compareTable},
+	}
+}
+
+// drawRenderStats shows how long the UI takes to draw frames, to apply the data of the servers to
+// the tables and to fetch it, when the rendering page is shown.
+func (client *Client) drawRenderStats() {
+	if page, _ := client.pages.GetFrontPage(); page != "render" {
+		return
+	}
+	now := time.Now()
+	var b strings.Builder
+	if elapsed := now.Sub(client.renderShown); client.renderShown.IsZero() || elapsed >= time.Second {
+		if !client.renderShown.IsZero() {
+			client.frameRate = float64(client.frames-client.renderFrames) / elapsed.Seconds()
+		}
+		client.renderShown, client.renderFrames = now, client.frames
+	}
+	fmt.Fprintf(&b, "%s %s %s %s %s\n", renderLabel(""), renderCell("Last", 8), renderCell("Mean", 8), renderCell("95%", 8), renderCell("Max", 8))
+	fmt.Fprintf(&b, "%s %s  %s\n", renderLabel("Frame"), client.frameTimes, locale.Sprintf("%d frames, %.1f/s", client.frames, client.frameRate))
+	fmt.Fprintf(&b, "%s %s\n", renderLabel("Update"), client.updateTimes)
+
+	fmt.Fprintf(&b, "\n%s %s %s %s\n", renderLabel("Table"), renderCell("Rows", 8), renderCell("Rebuild", 10), renderCell("Draw", 10))
+	for _, t := range client.renderTables() {
+		stats := t.table.DrawStats()
+		if stats.Time.IsZero() {
+			continue
+		}
+		line := fmt.Sprintf("%s %8d %10s %10s", renderLabel(t.title), stats.Rows, renderDuration(stats.Redraw), renderDuration(stats.Draw))
+		if stats.Time.Before(client.frameStart) {
+			line = "[gray]" + line + "[-]" // not drawn in the last frame
+		}
+		b.WriteString(line + "\n")
+	}
+
+	fmt.Fprintf(&b, "\n%s %s %s %s %s\n", renderLabel("Host"), renderCell("Last", 8), renderCell("Mean", 8), renderCell("95%", 8), renderCell("Max", 8))
+	for _, host := range client.hosts {
+		conn := client.connections[host]
+		conn.lock.Lock()
+		roundTrips, decodes, size := conn.roundTrips, conn.decodes, conn.size
+		conn.lock.Unlock()
+		fmt.Fprintf(&b, "%-24s %s  %s\n", tview.Escape(host), roundTrips, locale.T("round trip"))
+		fmt.Fprintf(&b, "%s %s  %s\n", renderLabel(""), decodes, locale.Sprintf("transfer and decoding, %s", format.Float(float64(size)/1024, 1)+" KiB"))
+	}
+	client.renderText.SetText(b.String())
+}
+
+// renderLabel returns the translation of the label of a line, padded to the first column.
+func renderLabel(label string) string {
+	return runewidth.FillRight(locale.T(label), 24)
+}
+
+// renderCell returns the translation of a heading, aligned right in width columns.
+func renderCell(heading string, width int) string {
+	return runewidth.FillLeft(locale.T(heading), width)
+}
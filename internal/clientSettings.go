@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/rivo/tview"
+
+	"plotng/internal/locale"
+)
+
+func (client *Client) getServerConfig(host string) (*Config, error) {
+	resp, err := client.api.do(client.http, host, "GET", "/config", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var config Config
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("Failed to decode config: %w", err)
+	}
+	return &config, nil
+}
+
+func (client *Client) putServerConfig(host string, config *Config) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	resp, err := client.api.do(client.http, host, "PUT", "/config", data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// loadSettings fetches the configuration of host and shows it in the settings form.
+func (client *Client) loadSettings(host string) {
+	client.settingsHost = host
+	client.settingsForm.SetTitle(fmt.Sprintf(" %s (%s) - %s ", locale.T("Settings"), host, locale.T("loading")))
+	client.spawn(func() {
+		config, err := client.getServerConfig(host)
+		client.app.QueueUpdateDraw(func() {
+			if host != client.settingsHost {
+				return
+			}
+			if err != nil {
+				client.settingsForm.SetTitle(fmt.Sprintf(" %s (%s) - %s ", locale.T("Settings"), host, err))
+				return
+			}
+			client.fillSettingsForm(host, config)
+		})
+	})
+}
+
+func (client *Client) fillSettingsForm(host string, config *Config) {
+	form := client.settingsForm
+	form.Clear(true)
+	hostIndex := 0
+	for idx, h := range client.hosts {
+		if h == host {
+			hostIndex = idx
+		}
+	}
+	form.AddDropDown("Host", client.hosts, hostIndex, func(option string, index int) {
+		if option != client.settingsHost {
+			client.loadSettings(option)
+		}
+	})
+
+	intFields := []struct {
+		label string
+		value *int
+	}{
+		{"Parallel Plots", &config.NumberOfParallelPlots},
+		{"Target Plots Per Day", &config.TargetPlotsPerDay},
+		{"Staggering Delay (mins)", &config.StaggeringDelay},
+		{"Delays Between Plot (mins)", &config.DelaysBetweenPlot},
+		{"Max Active Plot Per Temp", &config.MaxActivePlotPerTemp},
+		{"Max Active Plot Per Target", &config.MaxActivePlotPerTarget},
+		{"Max Active Plot Per Phase1", &config.MaxActivePlotPerPhase1},
+		{"Threads", &config.Threads},
+		{"Buffers", &config.Buffers},
+	}
+	for _, field := range intFields {
+		form.AddInputField(field.label, strconv.Itoa(*field.value), 6, tview.InputFieldInteger, nil)
+	}
+	tempChecks := make([]*tview.Checkbox, len(config.TempDirectory))
+	for idx, dir := range config.TempDirectory {
+		tempChecks[idx] = tview.NewCheckbox().SetLabel("Temp " + dir).SetChecked(true)
+		form.AddFormItem(tempChecks[idx])
+	}
+	targetChecks := make([]*tview.Checkbox, len(config.TargetDirectory))
+	for idx, dir := range config.TargetDirectory {
+		targetChecks[idx] = tview.NewCheckbox().SetLabel("Target " + dir).SetChecked(true)
+		form.AddFormItem(targetChecks[idx])
+	}
+
+	form.AddButton("Save", func() {
+		for _, field := range intFields {
+			value, err := strconv.Atoi(form.GetFormItemByLabel(field.label).(*tview.InputField).GetText())
+			if err != nil {
+				form.SetTitle(fmt.Sprintf(" %s (%s) - %s ", locale.T("Settings"), host, locale.Sprintf("invalid %s", field.label)))
+				return
+			}
+			*field.value = value
+		}
+		newConfig := *config
+		newConfig.TempDirectory = nil
+		for idx, dir := range config.TempDirectory {
+			if tempChecks[idx].IsChecked() {
+				newConfig.TempDirectory = append(newConfig.TempDirectory, dir)
+			}
+		}
+		newConfig.TargetDirectory = nil
+		for idx, dir := range config.TargetDirectory {
+			if targetChecks[idx].IsChecked() {
+				newConfig.TargetDirectory = append(newConfig.TargetDirectory, dir)
+			}
+		}
+		form.SetTitle(fmt.Sprintf(" %s (%s) - %s ", locale.T("Settings"), host, locale.T("saving")))
+		client.spawn(func() {
+			err := client.putServerConfig(host, &newConfig)
+			client.app.QueueUpdateDraw(func() {
+				if err != nil {
+					form.SetTitle(fmt.Sprintf(" %s (%s) - %s ", locale.T("Settings"), host, err))
+				} else {
+					client.fillSettingsForm(host, &newConfig)
+					form.SetTitle(fmt.Sprintf(" %s (%s) - %s ", locale.T("Settings"), host, locale.T("saved")))
+				}
+			})
+		})
+	})
+	form.AddButton("Reload", func() {
+		client.loadSettings(host)
+	})
+	form.SetTitle(fmt.Sprintf(" %s (%s) ", locale.T("Settings"), host))
+}
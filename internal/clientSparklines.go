@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// sparklineDays is how many days of finished plots are shown in the plots/day graph.
+const sparklineDays = 14
+
+// makePlotsPerDay counts the finished plots of all hosts by the day they completed, oldest first
+// and today last.
+func (client *Client) makePlotsPerDay(now time.Time) []float64 {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	firstDay := today.AddDate(0, 0, -(sparklineDays - 1))
+	values := make([]float64, sparklineDays)
+	for _, msg := range client.msg {
+		for _, plot := range msg.Archived {
+			if plot.State != PlotFinished {
+				continue
+			}
+			endTime := plot.getPhaseTime(4).In(now.Location())
+			endDay := time.Date(endTime.Year(), endTime.Month(), endTime.Day(), 0, 0, 0, 0, now.Location())
+			if endDay.Before(firstDay) || endDay.After(today) {
+				continue
+			}
+			values[int(endDay.Sub(firstDay).Hours()+12)/24]++ // round to survive DST changes
+		}
+	}
+	return values
+}
+
+// makeTempUsage averages the temp disk usage of all hosts over every tempUsageInterval of the last
+// tempUsageWindow, oldest first.  Intervals without a sample are -1.
+func (client *Client) makeTempUsage(now time.Time) []float64 {
+	buckets := int(tempUsageWindow / tempUsageInterval)
+	sums := make([]float64, buckets)
+	counts := make([]int, buckets)
+	start := now.Add(-tempUsageWindow)
+	for _, msg := range client.msg {
+		for _, sample := range msg.TempUsage {
+			if bucket := int(sample.Time.Sub(start) / tempUsageInterval); bucket >= 0 && bucket < buckets {
+				sums[bucket] += sample.Percent
+				counts[bucket]++
+			}
+		}
+	}
+	values := make([]float64, buckets)
+	for i := range values {
+		values[i] = -1
+		if counts[i] > 0 {
+			values[i] = sums[i] / float64(counts[i])
+		}
+	}
+	return values
+}
+
+func (client *Client) drawSparklines() {
+	now := format.In(time.Now())
+	ascii := client.config.Graphs == "ascii"
+
+	plotsPerDay := client.makePlotsPerDay(now)
+	total := 0.0
+	for _, count := range plotsPerDay[:sparklineDays-1] {
+		total += count
+	}
+	client.plotsSparkline.SetASCII(ascii).SetData(plotsPerDay, 0)
+	client.plotsSparkline.SetTitle(fmt.Sprintf(" %s [%s] ", locale.Sprintf("Plots/Day, %d Days", sparklineDays), locale.Sprintf("today %d, avg %s", int(plotsPerDay[sparklineDays-1]), format.Float(total/(sparklineDays-1), 1))))
+
+	tempUsage := client.makeTempUsage(now)
+	client.tempSparkline.SetASCII(ascii).SetData(tempUsage, 100)
+	title := fmt.Sprintf(" %s ", locale.Sprintf("Temp Disk Usage, %d Hours", int(tempUsageWindow.Hours())))
+	for i := len(tempUsage) - 1; i >= 0; i-- {
+		if tempUsage[i] >= 0 {
+			title += fmt.Sprintf("[%s] ", locale.Sprintf("now %s", format.Percent(tempUsage[i], 0)))
+			break
+		}
+	}
+	client.tempSparkline.SetTitle(title)
+}
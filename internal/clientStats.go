@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// Statistics comparison
+
+type statsData struct {
+	Group       string        `header:"Group"`
+	Host        string        `header:"Host"`
+	Name        string        `header:"Name"`
+	Started     int           `header:"Started" data-align:"right"`
+	Count       int           `header:"Plots" data-align:"right"`
+	Failed      int           `header:"Failed" data-align:"right"`
+	FailureRate float64       `header:"Failure Rate" data-align:"right" data-unit:"percent"`
+	AvgPlotTime time.Duration `header:"Avg Plot Time" data-align:"right"`
+	PlotsPerDay float64       `header:"Plots/Day" data-align:"right"`
+
+	firstStart time.Time
+	busy       busyPeriods
+}
+
+func (sd *statsData) Strings() []string {
+	return []string{
+		sd.Group,
+		sd.Host,
+		sd.Name,
+		fmt.Sprintf("%d", sd.Started),
+		fmt.Sprintf("%d", sd.Count),
+		fmt.Sprintf("%d", sd.Failed),
+		format.Percent(sd.FailureRate, 1),
+		format.Duration(sd.AvgPlotTime),
+		format.Float(sd.PlotsPerDay, 2),
+	}
+}
+
+func (sd *statsData) add(plot *PlotStatus) {
+	sd.Started++
+	sd.busy.add(plot.getPhaseTime(0), plot.EndTime)
+	switch plot.State {
+	case PlotFinished:
+		sd.AvgPlotTime += plot.getPhaseTime(4).Sub(plot.getPhaseTime(0))
+		sd.Count++
+	case PlotError, PlotKilled:
+		sd.Failed++
+	default:
+		return
+	}
+	if sd.firstStart.IsZero() || plot.getPhaseTime(0).Before(sd.firstStart) {
+		sd.firstStart = plot.getPhaseTime(0)
+	}
+}
+
+// addActive counts the time an active plot has been running as plotting time.
+func (sd *statsData) addActive(plot *PlotStatus, now time.Time) {
+	sd.Started++
+	sd.busy.add(plot.getPhaseTime(0), now)
+}
+
+// finish computes the averages.  Plots/day leaves out the time plotting was paused while none of
+// the group's plots were running, so maintenance doesn't drag it down.
+func (sd *statsData) finish(now time.Time, downtime []Downtime) {
+	if sd.Count > 0 {
+		sd.AvgPlotTime /= time.Duration(sd.Count)
+	}
+	if sd.Count+sd.Failed > 0 {
+		sd.FailureRate = float64(sd.Failed) * 100 / float64(sd.Count+sd.Failed)
+	}
+	plotting := now.Sub(sd.firstStart) - idleDowntime(downtime, sd.busy, sd.firstStart, now)
+	if days := plotting.Hours() / 24; sd.Count > 0 && days > 0 {
+		sd.PlotsPerDay = float64(sd.Count) / days
+	}
+}
+
+// makeStatsData groups the archived plots of all hosts by temp directory, destination directory,
+// profile and host.  Keys are prefixed with the group so a directory and a host never collide.
+func (client *Client) makeStatsData(now time.Time) map[string]*statsData {
+	stats := make(map[string]*statsData)
+	get := func(group, host, name string) *statsData {
+		key := group + "||" + host + "||" + name
+		sd, ok := stats[key]
+		if !ok {
+			sd = &statsData{Group: group, Host: host, Name: name}
+			stats[key] = sd
+		}
+		return sd
+	}
+
+	for host, msg := range client.msg {
+		for _, plot := range msg.Archived {
+			get("Host", host, "").add(plot)
+			get("Temp Dir", host, plot.PlotDir).add(plot)
+			get("Dest Dir", host, plot.TargetDir).add(plot)
+			if len(plot.Profile) > 0 {
+				get("Profile", host, plot.Profile).add(plot)
+			}
+		}
+	}
+	// Active plots only count as started, but they keep their groups busy during a pause.
+	for host, msg := range client.msg {
+		for _, plot := range msg.Actives {
+			groups := []*statsData{get("Host", host, ""), get("Temp Dir", host, plot.PlotDir), get("Dest Dir", host, plot.TargetDir)}
+			if len(plot.Profile) > 0 {
+				groups = append(groups, get("Profile", host, plot.Profile))
+			}
+			for _, sd := range groups {
+				sd.addActive(plot, now)
+			}
+		}
+	}
+
+	for _, sd := range stats {
+		var downtime []Downtime
+		if msg, ok := client.msg[sd.Host]; ok {
+			downtime = msg.Downtime
+		}
+		sd.finish(now, downtime)
+	}
+	return stats
+}
+
+func (client *Client) drawStatsTable() {
+	stats := client.makeStatsData(time.Now())
+
+	keysToRemove := make(map[string]struct{})
+	for _, key := range client.statsTable.Keys() {
+		keysToRemove[key] = struct{}{}
+	}
+
+	for key, sd := range stats {
+		delete(keysToRemove, key)
+		client.statsTable.SetRowData(key, sd)
+	}
+
+	for key := range keysToRemove {
+		client.statsTable.ClearRowData(key)
+	}
+}
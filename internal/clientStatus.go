@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+	"plotng/internal/widget"
+)
+
+// WriteStatus writes the tables of the plots page of the UI as text, for watch(1) or to paste in a
+// chat: the active plots and the plot and dest directories of every host, with the sort order and
+// label filter the UI was left with.  With colors, the rows keep the colours of the UI.  Hosts
+// which don't answer are listed at the end, and make it return an error.
+func WriteStatus(w io.Writer, hostList string, colors bool) error {
+	client := &Client{}
+	client.init(hostList)
+	client.setupUI()
+
+	var failed []string
+	for _, host := range client.hosts {
+		msg, err := client.getServerData(host, 0, 0)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", host, err))
+			continue
+		}
+		client.msg[host] = msg
+	}
+	client.drawActivePlotsTable()
+	client.drawPlotDirsTable()
+	client.drawDestDirsTable()
+
+	fmt.Fprintf(w, "PlotNG status at %s\n", format.Time(time.Now()))
+	if len(client.filter) > 0 {
+		fmt.Fprintf(w, "Label filter: %s\n", client.filter)
+	}
+	for _, host := range client.haltedHosts() {
+		fmt.Fprintf(w, "HALTED %s: %s\n", host, client.msg[host].Halted.String())
+	}
+	for _, table := range []*widget.SortedTable{client.activePlotsTable, client.plotDirsTable, client.destDirsTable} {
+		fmt.Fprintln(w)
+		if err := table.WriteText(w, colors); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(w, "\nUnreachable: %s\n", strings.Join(failed, ", "))
+		return fmt.Errorf("%d of %d hosts unreachable", len(failed), len(client.hosts))
+	}
+	return nil
+}
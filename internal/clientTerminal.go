@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// clientLogPath returns the file the UI writes its log to, as anything written to the terminal
+// would end up in the middle of the UI.
+func clientLogPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "plotng", "client.log")
+}
+
+// openClientLog sends the log to the log file of the UI and returns a function sending it back to
+// stderr, which can be called more than once.  The log is discarded when the file can't be opened.
+func openClientLog(path string) (restore func()) {
+	var output io.Writer = ioutil.Discard
+	var file *os.File
+	if len(path) > 0 {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			file, _ = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		}
+	}
+	if file != nil {
+		output = file
+	}
+	log.SetOutput(output)
+	return func() {
+		log.SetOutput(os.Stderr)
+		if file != nil {
+			file.Close()
+			file = nil
+		}
+	}
+}
+
+// spawn runs f on its own goroutine, stopping the UI cleanly if it panics.
+func (client *Client) spawn(f func()) {
+	go func() {
+		defer client.recoverPanic()
+		f()
+	}()
+}
+
+// recoverPanic logs a panic of a goroutine of the UI with its stack and stops the UI, so the
+// terminal is restored instead of being left in raw mode on the alternate screen.
+func (client *Client) recoverPanic() {
+	if p := recover(); p != nil {
+		log.Printf("UI panic: %v\n%s", p, debug.Stack())
+		client.panicOnce.Do(func() { client.panicked = p })
+		client.stop()
+	}
+}
+
+// stopOnSignal stops the UI when the terminal goes away or the process is asked to end, as the
+// default handling would exit with the terminal still in raw mode.
+func (client *Client) stopOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	select {
+	case sig := <-signals:
+		log.Printf("Received %s, stopping the UI", sig)
+		client.stop()
+	case <-client.done:
+	}
+}
+
+// afterDraw runs after every draw with the application locked.
+func (client *Client) afterDraw(screen tcell.Screen) {
+	client.measureFrame()
+	client.checkSize(screen)
+	client.ringBell(screen)
+}
+
+// checkSize repaints the whole terminal after it was resized, as terminals which reflow their
+// content on resize leave remnants the incremental updates wouldn't clear.  It runs after every
+// draw with the application locked, so switching to the compact layout is queued.
+func (client *Client) checkSize(screen tcell.Screen) {
+	width, height := screen.Size()
+	if width == client.width && height == client.height {
+		return
+	}
+	if client.width != 0 {
+		screen.Sync()
+	}
+	if width != client.width {
+		compact := client.compactWidth(width)
+		client.spawn(func() {
+			client.app.QueueUpdateDraw(func() { client.setCompact(compact) })
+		})
+	}
+	client.width, client.height = width, height
+}
+
+// crashed reports a panic of the UI on stderr once the terminal is restored.
+func (client *Client) crashed(logPath string) bool {
+	if client.panicked == nil {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "PlotNG UI crashed: %v\n", client.panicked)
+	if len(logPath) > 0 {
+		fmt.Fprintf(os.Stderr, "The stack trace is in %s\n", logPath)
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the scheduler the time.  The server uses the wall clock, the scheduler harness a
+// VirtualClock so schedules can be checked without waiting.
+type Clock interface {
+	Now() time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// VirtualClock is a Clock which only moves when told to.
+type VirtualClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+func (vc *VirtualClock) Now() time.Time {
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+	return vc.now
+}
+
+// Advance moves the clock forward by d.
+func (vc *VirtualClock) Advance(d time.Duration) {
+	vc.lock.Lock()
+	vc.now = vc.now.Add(d)
+	vc.lock.Unlock()
+}
+
+// now returns the time of the server's clock.
+func (server *Server) now() time.Time {
+	if server.clock == nil {
+		return time.Now()
+	}
+	return server.clock.Now()
+}
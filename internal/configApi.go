@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// configAuthorized reports whether req may change anything through the API, the configuration
+// included: always from the host itself, from another host only with the ConfigToken given as a
+// bearer token.
+func (config *Config) configAuthorized(req *http.Request) bool {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return true
+		}
+	}
+	auth := req.Header.Get("Authorization")
+	if len(config.ConfigToken) == 0 || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(config.ConfigToken)) == 1
+}
+
+// apiAuthorized reports whether req may change anything, by the ConfigToken of the current
+// configuration.
+func (server *Server) apiAuthorized(req *http.Request) bool {
+	server.config.Lock.RLock()
+	current := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	if current == nil {
+		current = &Config{}
+	}
+	return current.configAuthorized(req)
+}
+
+// handleConfig returns the current configuration as JSON on GET, and validates, saves and applies
+// a new configuration on PUT.
+func (server *Server) handleConfig(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		server.config.Lock.RLock()
+		config := server.config.CurrentConfig
+		server.config.Lock.RUnlock()
+		if config == nil {
+			http.Error(resp, "No configuration loaded", http.StatusNotFound)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(config.redacted())
+	case "PUT":
+		var config Config
+		if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+			http.Error(resp, fmt.Sprintf("Failed to decode config: %s", err), http.StatusBadRequest)
+			return
+		}
+		server.config.Lock.RLock()
+		current := server.config.CurrentConfig
+		server.config.Lock.RUnlock()
+		if current == nil {
+			current = &Config{}
+		}
+		if err := current.checkSandboxChange(&config); err != nil {
+			http.Error(resp, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := server.config.SaveConfig(&config); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ConfigDiff is what changed between two configurations: the temp and dest directories added and
+// removed, and the names of the other settings which changed.
+type ConfigDiff struct {
+	TempAdded     []string
+	TempRemoved   []string
+	TargetAdded   []string
+	TargetRemoved []string
+	Changed       []string
+}
+
+// diffConfigs compares the configuration being replaced with the new one.
+func diffConfigs(old *Config, new *Config) *ConfigDiff {
+	diff := &ConfigDiff{}
+	diff.TempAdded, diff.TempRemoved = diffDirs(old.TempDirectory, new.TempDirectory)
+	diff.TargetAdded, diff.TargetRemoved = diffDirs(old.TargetDirectory, new.TargetDirectory)
+	oldValue, newValue := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		if name == "TempDirectory" || name == "TargetDirectory" {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	return diff
+}
+
+// diffDirs returns the directories of new which are not in old, and those of old which are not in
+// new.
+func diffDirs(old []string, new []string) (added []string, removed []string) {
+	inOld, inNew := map[string]bool{}, map[string]bool{}
+	for _, dir := range old {
+		inOld[dir] = true
+	}
+	for _, dir := range new {
+		inNew[dir] = true
+		if !inOld[dir] {
+			added = append(added, dir)
+		}
+	}
+	for _, dir := range old {
+		if !inNew[dir] {
+			removed = append(removed, dir)
+		}
+	}
+	return added, removed
+}
+
+// changed reports whether one of the settings named changed.
+func (diff *ConfigDiff) changed(names ...string) bool {
+	for _, changed := range diff.Changed {
+		for _, name := range names {
+			if changed == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyConfig switches the server to a new configuration, loaded from the file or saved through
+// the API, without disturbing the work in flight: the plots on removed directories keep running
+// until they finish, added directories are used from the next plot on, and the schedule only
+// starts again when the scheduler or the profiles changed.  The offline targets are probed again.
+// The diff is reported in the event log.  The caller must hold server.lock.
+func (server *Server) applyConfig(old *Config, config *Config) {
+	server.offlineTargets = map[string]bool{}
+	if old == nil {
+		return
+	}
+	diff := diffConfigs(old, config)
+	if diff.changed("Scheduler", "Profiles") {
+		server.scheduler = nil
+		server.profiles = nil
+	}
+	for _, change := range []struct {
+		what    string
+		dirs    []string
+		temp    bool
+		removed bool
+	}{
+		{"temp directories added", diff.TempAdded, true, false},
+		{"temp directories removed", diff.TempRemoved, true, true},
+		{"dest directories added", diff.TargetAdded, false, false},
+		{"dest directories removed", diff.TargetRemoved, false, true},
+	} {
+		if len(change.dirs) == 0 {
+			continue
+		}
+		var dirs []string
+		for _, dir := range change.dirs {
+			if running := server.activeIn(dir, change.temp); change.removed && running > 0 {
+				dir = fmt.Sprintf("%s (%d plots left to finish)", dir, running)
+			}
+			dirs = append(dirs, dir)
+		}
+		server.schedulerEvent("Configuration applied, %s: %s", change.what, strings.Join(dirs, ", "))
+	}
+	if len(diff.Changed) > 0 {
+		server.schedulerEvent("Configuration applied, settings changed: %s", strings.Join(diff.Changed, ", "))
+	}
+}
+
+// activeIn returns the number of active plots using dir as temp directory, or as dest directory.
+// The caller must hold server.lock.
+func (server *Server) activeIn(dir string, temp bool) (count int) {
+	for _, plot := range server.active {
+		status := plot.Snapshot()
+		if (temp && status.PlotDir == dir) || (!temp && status.TargetDir == dir) {
+			count++
+		}
+	}
+	return
+}
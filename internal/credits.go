@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+)
+
+// PlotCredits is the token bucket an external orchestrator fills to control how many plots this
+// plotter may start, e.g. to share a farm-wide rate between machines.  With PlotCredits set in the
+// configuration, every plot started takes a credit and no plot starts without one.  Credits are
+// granted through POST /credits and refill at RatePerHour, neither above Capacity when it is set.
+// It is kept next to the configuration file so a restart doesn't reset it.
+type PlotCredits struct {
+	Enabled     bool // PlotCredits of the configuration
+	Credits     float64
+	Capacity    float64 // 0 for no limit
+	RatePerHour float64
+	Granted     float64 // since the bucket was created
+	Consumed    int
+	Updated     time.Time // of the last refill
+	LastGrant   time.Time
+	LastConsume time.Time
+
+	waiting bool   // a scheduling cycle waited for a credit
+	path    string // of the file kept, none in the scheduler harness
+}
+
+// CreditGrant adds Grant credits, and changes the capacity and the refill rate when given.
+type CreditGrant struct {
+	Grant       float64
+	Capacity    *float64
+	RatePerHour *float64
+}
+
+func creditsPath(configPath string) string {
+	return configPath + ".credits"
+}
+
+func loadPlotCredits(path string) *PlotCredits {
+	credits := &PlotCredits{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read plot credits %s: %s", path, err)
+		}
+		return credits
+	}
+	if err := json.Unmarshal(data, credits); err != nil {
+		log.Printf("Failed to parse plot credits %s: %s", path, err)
+	}
+	return credits
+}
+
+func (credits *PlotCredits) save() error {
+	if len(credits.path) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(credits, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(credits.path, data, 0644)
+}
+
+// refill adds the credits earned at RatePerHour since the last refill.
+func (credits *PlotCredits) refill(now time.Time) {
+	if !credits.Updated.IsZero() && now.After(credits.Updated) && credits.RatePerHour > 0 {
+		credits.add(credits.RatePerHour * now.Sub(credits.Updated).Hours())
+	}
+	credits.Updated = now
+}
+
+// add adds credits up to the capacity.
+func (credits *PlotCredits) add(amount float64) {
+	credits.Credits += amount
+	credits.Granted += amount
+	if credits.Capacity > 0 && credits.Credits > credits.Capacity {
+		credits.Credits = credits.Capacity
+	}
+}
+
+// plotCreditAvailable reports whether a plot may start, always true without PlotCredits.  The
+// first cycle waiting for a credit is reported in the event log.  The caller must hold
+// server.lock.
+func (server *Server) plotCreditAvailable(config *Config, now time.Time) bool {
+	credits := server.credits
+	credits.Enabled = config.PlotCredits
+	if !config.PlotCredits {
+		return true
+	}
+	credits.refill(now)
+	if credits.Credits >= 1 {
+		credits.waiting = false
+		return true
+	}
+	if !credits.waiting {
+		server.schedulerEvent("Waiting for plot credits, %.2f left", credits.Credits)
+		credits.waiting = true
+	}
+	return false
+}
+
+// consumePlotCredit takes the credit of a plot which started.  The caller must hold server.lock.
+func (server *Server) consumePlotCredit(config *Config, now time.Time) {
+	if !config.PlotCredits {
+		return
+	}
+	credits := server.credits
+	credits.Credits--
+	credits.Consumed++
+	credits.LastConsume = now
+	if err := credits.save(); err != nil {
+		log.Printf("Failed to save plot credits: %s", err)
+	}
+}
+
+// handleCredits returns the plot credits on GET, grants credits or changes the refill rate and
+// capacity with the CreditGrant posted as JSON, and takes all remaining credits back on DELETE.
+func (server *Server) handleCredits(resp http.ResponseWriter, req *http.Request) {
+	now := server.now()
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	credits := server.credits
+	credits.refill(now)
+	switch req.Method {
+	case "GET":
+	case "POST":
+		var grant CreditGrant
+		if err := json.NewDecoder(req.Body).Decode(&grant); err != nil {
+			http.Error(resp, fmt.Sprintf("Invalid grant: %s", err), http.StatusBadRequest)
+			return
+		}
+		if grant.Grant < 0 || math.IsNaN(grant.Grant) || (grant.Capacity != nil && *grant.Capacity < 0) || (grant.RatePerHour != nil && *grant.RatePerHour < 0) {
+			http.Error(resp, "Grant, Capacity and RatePerHour can't be negative", http.StatusBadRequest)
+			return
+		}
+		if grant.Capacity != nil {
+			credits.Capacity = *grant.Capacity
+		}
+		if grant.RatePerHour != nil {
+			credits.RatePerHour = *grant.RatePerHour
+		}
+		credits.add(grant.Grant)
+		if grant.Grant > 0 {
+			credits.LastGrant = now
+		}
+		server.schedulerEvent("Plot credits granted: %g, %.2f available, %g per hour", grant.Grant, credits.Credits, credits.RatePerHour)
+	case "DELETE":
+		credits.Credits = 0
+		credits.RatePerHour = 0
+		server.schedulerEvent("Plot credits revoked")
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Method != "GET" {
+		if err := credits.save(); err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(credits)
+}
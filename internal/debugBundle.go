@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lineBuffer keeps the last lines written to it, to capture the log and the scheduler events.
+type lineBuffer struct {
+	lock    sync.Mutex
+	lines   []string
+	max     int
+	partial string
+}
+
+func newLineBuffer(max int) *lineBuffer {
+	return &lineBuffer{max: max}
+}
+
+func (lb *lineBuffer) add(line string) {
+	if lb == nil {
+		return
+	}
+	lb.lock.Lock()
+	lb.lines = append(lb.lines, line)
+	if len(lb.lines) > lb.max {
+		lb.lines = lb.lines[len(lb.lines)-lb.max:]
+	}
+	lb.lock.Unlock()
+}
+
+func (lb *lineBuffer) Write(p []byte) (int, error) {
+	lb.lock.Lock()
+	text := lb.partial + string(p)
+	lines := strings.Split(text, "\n")
+	lb.partial = lines[len(lines)-1]
+	lb.lock.Unlock()
+	for _, line := range lines[:len(lines)-1] {
+		lb.add(line)
+	}
+	return len(p), nil
+}
+
+func (lb *lineBuffer) Lines() []string {
+	return lb.Last(0)
+}
+
+// Last returns the last n lines, or all lines if n is 0.
+func (lb *lineBuffer) Last(n int) []string {
+	if lb == nil {
+		return nil
+	}
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	lines := lb.lines
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string{}, lines...)
+}
+
+// schedulerEvent logs why the scheduler started, skipped or paused a plot, and keeps it for the
+// debug bundle.
+func (server *Server) schedulerEvent(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Print(message)
+	server.events.add(server.now().Format("2006-01-02 15:04:05 ") + message)
+}
+
+// DebugState is the state of the daemon collected for a bug report.
+type DebugState struct {
+	Time       time.Time
+	GoVersion  string
+	Config     *Config
+	Overlay    *dirOverlay
+	Offline    map[string]bool
+	Logs       []string
+	Events     []string
+	Actives    []*PlotStatus
+	Archived   []*PlotStatus
+	Queued     []*PlotJob
+	Orphans    []*OrphanFile
+	Rebalance  *Rebalance
+	Goroutines int
+}
+
+// handleDebugState returns the DebugState of the server as JSON, without passwords and redacted
+// as configured.
+func (server *Server) handleDebugState(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	redact := server.redactor()
+	state := DebugState{
+		Time:       time.Now(),
+		GoVersion:  runtime.Version(),
+		Logs:       redact.lines(server.logs.Lines()),
+		Events:     redact.lines(server.events.Lines()),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	server.config.Lock.RLock()
+	if server.config.CurrentConfig != nil {
+		state.Config = redact.config(server.config.CurrentConfig.redacted())
+	}
+	server.config.Lock.RUnlock()
+	server.lock.RLock()
+	state.Overlay = server.overlay
+	state.Offline = server.offlineTargets
+	state.Actives, state.Archived = server.plotSnapshots()
+	for _, plot := range append(state.Actives, state.Archived...) {
+		redact.plot(plot)
+	}
+	state.Queued = server.queue
+	state.Orphans = server.orphans
+	state.Rebalance = server.rebalance.snapshot()
+	data, err := json.MarshalIndent(state, "", "  ")
+	server.lock.RUnlock()
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(data)
+}
+
+// WriteDebugBundle writes a tarball with the state of the server at host to output.  The local
+// configuration file is included too, without passwords, so there is something to look at when
+// the server doesn't answer.
+func WriteDebugBundle(host string, configPath string, output string) error {
+	files := map[string][]byte{}
+	var fetchErr error
+	if resp, err := (&apiHosts{}).do(httpClient, host, "GET", "/debug/state", nil); err != nil {
+		fetchErr = err
+	} else {
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		var state DebugState
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &state)
+		}
+		if err != nil {
+			fetchErr = err
+		} else {
+			files["state.json"] = data
+			files["logs.txt"] = []byte(strings.Join(state.Logs, "\n") + "\n")
+			files["events.txt"] = []byte(strings.Join(state.Events, "\n") + "\n")
+			files["history.json"], _ = json.MarshalIndent(state.Archived, "", "  ")
+		}
+	}
+	if fetchErr != nil {
+		log.Printf("Failed to get the state of %s: %s", host, fetchErr)
+		files["error.txt"] = []byte(fmt.Sprintf("Failed to get the state of %s: %s\n", host, fetchErr))
+	}
+	if len(configPath) > 0 {
+		var config Config
+		data, err := ioutil.ReadFile(configPath)
+		if err == nil {
+			err = json.Unmarshal(data, &config)
+		}
+		if err != nil {
+			files["config-error.txt"] = []byte(fmt.Sprintf("Failed to read %s: %s\n", configPath, err))
+		} else {
+			files["config.json"], _ = json.MarshalIndent(newRedactor(&config).config(config.redacted()), "", "  ")
+		}
+	}
+	if len(files) == 1 && fetchErr != nil {
+		return fetchErr
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	prefix := strings.TrimSuffix(strings.TrimSuffix(output, ".gz"), ".tar")
+	if idx := strings.LastIndex(prefix, string(os.PathSeparator)); idx >= 0 {
+		prefix = prefix[idx+1:]
+	}
+	for _, name := range []string{"error.txt", "state.json", "logs.txt", "events.txt", "history.json", "config.json", "config-error.txt"} {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		header := &tar.Header{
+			Name:    prefix + "/" + name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
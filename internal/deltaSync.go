@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// revisionCounter numbers the changes to the plots, so clients can ask for the plots changed
+// since the revision they last saw instead of all of them.
+type revisionCounter struct {
+	value int64
+}
+
+func (rc *revisionCounter) next() int64 {
+	if rc == nil {
+		return 0
+	}
+	return atomic.AddInt64(&rc.value, 1)
+}
+
+func (rc *revisionCounter) current() int64 {
+	if rc == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&rc.value)
+}
+
+// changed gives the plot a new revision, it must be called with ap.lock held after changing the
+// plot's status.
+func (ap *ActivePlot) changed() {
+	ap.Revision = ap.revisions.next()
+}
+
+// parseDeltaQuery returns the revision and epoch of the plots a client already has, from the since
+// and epoch parameters of GET /.  Without them the client gets all plots.
+func parseDeltaQuery(values url.Values) (since int64, epoch int64) {
+	since, _ = strconv.ParseInt(values.Get("since"), 10, 64)
+	epoch, _ = strconv.ParseInt(values.Get("epoch"), 10, 64)
+	return
+}
+
+// plotDeltas fills the plots of msg, only those changed after since when the client's epoch is the
+// server's, and the IDs the client needs to drop the plots which left the active list.  The revision
+// is read before the snapshots so a change racing with them is sent again next time rather than
+// missed.  The caller must hold server.lock.
+func (server *Server) plotDeltas(msg *Msg, since int64, epoch int64) {
+	msg.Epoch = server.startTime.UnixNano()
+	msg.Revision = server.revisions.current()
+	msg.Actives, msg.Archived = server.plotSnapshots()
+	for id := range server.active {
+		msg.ActiveIds = append(msg.ActiveIds, id)
+	}
+	msg.ArchivedCount = len(server.archive)
+	if since <= 0 || epoch != msg.Epoch || since > msg.Revision {
+		return
+	}
+	msg.Since = since
+	msg.Actives = changedSince(msg.Actives, since)
+	msg.Archived = changedSince(msg.Archived, since)
+}
+
+func changedSince(plots []*PlotStatus, since int64) (changed []*PlotStatus) {
+	for _, plot := range plots {
+		if plot.Revision > since {
+			changed = append(changed, plot)
+		}
+	}
+	return
+}
+
+// mergeMsg applies a delta from the server to the cached message of the host.  It returns nil when
+// the delta doesn't fit the cache, and everything has to be fetched again.
+func mergeMsg(cached *Msg, delta *Msg) *Msg {
+	if delta.Since == 0 {
+		return delta
+	}
+	if cached == nil || cached.Epoch != delta.Epoch || cached.Revision != delta.Since {
+		return nil
+	}
+	merged := *delta
+
+	plots := map[int64]*PlotStatus{}
+	for _, plot := range cached.Actives {
+		plots[plot.PlotId] = plot
+	}
+	for _, plot := range delta.Actives {
+		plots[plot.PlotId] = plot
+	}
+	merged.Actives = nil
+	for _, id := range delta.ActiveIds {
+		plot, ok := plots[id]
+		if !ok {
+			return nil
+		}
+		merged.Actives = append(merged.Actives, plot)
+	}
+
+	updated := map[int64]bool{}
+	for _, plot := range delta.Archived {
+		updated[plot.PlotId] = true
+	}
+	merged.Archived = nil
+	for _, plot := range cached.Archived {
+		if !updated[plot.PlotId] {
+			merged.Archived = append(merged.Archived, plot)
+		}
+	}
+	merged.Archived = append(merged.Archived, delta.Archived...)
+	if len(merged.Archived) != delta.ArchivedCount {
+		return nil
+	}
+	return &merged
+}
+
+// syncServerData fetches the plots of host changed since the last sync and merges them into the
+// cached message, falling back to fetching everything when the server restarted, the delta
+// doesn't fit or the host was unreachable.  It reports whether any plot changed.
+func (client *Client) syncServerData(host string) (*Msg, bool, error) {
+	conn := client.connections[host]
+	conn.fetch.Lock()
+	defer conn.fetch.Unlock()
+	msg, changed, err := conn.sync(func(since int64, epoch int64) (*Msg, error) {
+		return client.getServerData(host, since, epoch)
+	})
+	if err != nil {
+		conn.synced = nil
+		conn.failed(time.Now(), err)
+		return nil, false, err
+	}
+	conn.succeeded(time.Now())
+	return msg, changed, nil
+}
+
+// sync merges the changes returned by get into the cached message, conn.fetch must be held.
+func (hc *hostConnection) sync(get func(since int64, epoch int64) (*Msg, error)) (*Msg, bool, error) {
+	cached := hc.synced
+	var msg *Msg
+	if cached != nil {
+		delta, err := get(cached.Revision, cached.Epoch)
+		if err != nil {
+			return nil, false, err
+		}
+		msg = mergeMsg(cached, delta)
+	}
+	if msg == nil {
+		full, err := get(0, 0)
+		if err != nil {
+			return nil, false, err
+		}
+		if full.Since != 0 {
+			return nil, false, fmt.Errorf("Server sent a delta when asked for everything")
+		}
+		msg = full
+	}
+	hc.synced = msg
+	return msg, cached == nil || cached.Epoch != msg.Epoch || cached.Revision != msg.Revision, nil
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DestinationKeys are the keys of the plots made for a dest directory, so that one machine can
+// plot for several farms with every plot landing on the drives of its farm.  Empty keys fall back
+// to the configuration, or the profile.
+type DestinationKeys struct {
+	Fingerprint         string
+	FarmerPublicKey     string
+	PoolPublicKey       string
+	PoolContractAddress string
+}
+
+// forTarget returns config with the keys of DestinationKeys for targetDir, or config itself when
+// targetDir has no keys of its own.
+func (config *Config) forTarget(targetDir string) *Config {
+	keys, ok := config.DestinationKeys[targetDir]
+	if !ok {
+		return config
+	}
+	c := *config
+	if len(keys.Fingerprint) > 0 || len(keys.FarmerPublicKey) > 0 || len(keys.PoolPublicKey) > 0 {
+		c.Fingerprint = keys.Fingerprint
+		c.FarmerPublicKey = keys.FarmerPublicKey
+		c.PoolPublicKey = keys.PoolPublicKey
+	}
+	if len(keys.PoolContractAddress) > 0 {
+		c.PoolContractAddress = keys.PoolContractAddress
+	}
+	return &c
+}
+
+// acceptsPlot reports whether a plot made with the keys of status may be moved to targetDir, which
+// is when targetDir would get plots with the same keys.  Without DestinationKeys any plot may, and
+// a plot whose keys aren't known, nil status, may go to the dest directories without keys of their own.
+func (config *Config) acceptsPlot(targetDir string, status *PlotStatus) bool {
+	if len(config.DestinationKeys) == 0 {
+		return true
+	}
+	if status == nil {
+		_, ok := config.DestinationKeys[targetDir]
+		return !ok
+	}
+	c := config.forTarget(targetDir)
+	return c.Fingerprint == status.Fingerprint && c.FarmerPublicKey == status.FarmerPublicKey &&
+		c.PoolPublicKey == status.PoolPublicKey && c.PoolContractAddress == status.PoolContract
+}
+
+// validateDestinationKeys checks that every dest directory of DestinationKeys has keys.
+func validateDestinationKeys(config *Config) error {
+	for dir, keys := range config.DestinationKeys {
+		if len(strings.TrimSpace(keys.Fingerprint+keys.FarmerPublicKey+keys.PoolPublicKey+keys.PoolContractAddress)) == 0 {
+			return fmt.Errorf("DestinationKeys for %s has no keys", dir)
+		}
+	}
+	return nil
+}
+
+// bufferedPlotStatus returns the archived plot which left the plot file at path in a buffer
+// directory, to know its keys, or nil when it isn't archived.  The caller must hold server.lock.
+func (server *Server) bufferedPlotStatus(path string) *PlotStatus {
+	id := plotIdFromFileName(filepath.Base(path))
+	for i := len(server.archive) - 1; i >= 0 && len(id) > 0; i-- {
+		if status := server.archive[i].Snapshot(); status.Id == id {
+			return &status
+		}
+	}
+	return nil
+}
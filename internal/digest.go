@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// digestDue reports whether a digest covering the period since last should be sent at now.
+// Daily digests go out on the first cycle after midnight, weekly digests on the first cycle of Monday.
+func digestDue(period string, last time.Time, now time.Time) bool {
+	if last.Year() == now.Year() && last.YearDay() == now.YearDay() {
+		return false
+	}
+	switch strings.ToLower(period) {
+	case "daily":
+		return true
+	case "weekly":
+		return now.Weekday() == time.Monday
+	}
+	return false
+}
+
+func (server *Server) sendDigestIfDue(now time.Time) {
+	config := server.config.CurrentConfig
+	if config == nil || len(config.EmailDigest) == 0 || len(config.EmailTo) == 0 {
+		return
+	}
+	if server.lastDigest.IsZero() {
+		server.lastDigest = now
+		return
+	}
+	if !digestDue(config.EmailDigest, server.lastDigest, now) {
+		return
+	}
+	body := server.makeDigest(config, server.lastDigest, now)
+	server.lastDigest = now
+	subject := fmt.Sprintf("PlotNG %s report - %s", strings.ToLower(config.EmailDigest), now.Format("2006-01-02"))
+	go func() {
+		if err := sendEmail(config, subject, body); err != nil {
+			log.Printf("Failed to send email digest: %s", err)
+		} else {
+			log.Printf("Email digest sent to %s", strings.Join(config.EmailTo, ", "))
+		}
+	}()
+}
+
+// makeDigest summarises the plots which ended between from and to, and the remaining disk space.
+func (server *Server) makeDigest(config *Config, from time.Time, to time.Time) string {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	var buf bytes.Buffer
+	var finished, failed int
+	var total, phase1 time.Duration
+	for _, plot := range server.archive {
+		status := plot.Snapshot()
+		if status.EndTime.Before(from) || !status.EndTime.Before(to) {
+			continue
+		}
+		switch status.State {
+		case PlotFinished:
+			finished++
+			total += status.EndTime.Sub(status.StartTime)
+			phase1 += status.Phase1Time.Sub(status.StartTime)
+		case PlotError, PlotKilled:
+			failed++
+		}
+	}
+
+	fmt.Fprintf(&buf, "Report from %s to %s\n\n", format.ShortTime(from), format.ShortTime(to))
+	fmt.Fprintf(&buf, "Plots completed: %d\n", finished)
+	fmt.Fprintf(&buf, "Plots failed:    %d\n", failed)
+	if finished > 0 {
+		fmt.Fprintf(&buf, "Avg plot time:   %s\n", format.Duration(total/time.Duration(finished)))
+		fmt.Fprintf(&buf, "Avg phase 1:     %s\n", format.Duration(phase1/time.Duration(finished)))
+	}
+	fmt.Fprintf(&buf, "Active plots:    %d\n\n", len(server.active))
+
+	fmt.Fprintf(&buf, "Temp directories:\n")
+	for _, dir := range config.TempDirectory {
+		fmt.Fprintf(&buf, "  %s: %s available\n", dir, format.Space(server.getDiskSpaceAvailable(dir)))
+	}
+	fmt.Fprintf(&buf, "Target directories:\n")
+	for _, dir := range config.TargetDirectory {
+		fmt.Fprintf(&buf, "  %s: %s available\n", dir, format.Space(server.getDiskSpaceAvailable(dir)))
+	}
+	return buf.String()
+}
+
+func sendEmail(config *Config, subject string, body string) error {
+	var auth smtp.Auth
+	if len(config.SmtpUsername) > 0 {
+		host, _, err := net.SplitHostPort(config.SmtpServer)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", config.SmtpUsername, config.SmtpPassword, host)
+	}
+	from := config.EmailFrom
+	if len(from) == 0 {
+		from = config.SmtpUsername
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(config.EmailTo, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(config.SmtpServer, auth, from, config.EmailTo, msg.Bytes())
+}
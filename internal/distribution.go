@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// distributionScanCycles is how many cycles pass between two scans of the destination directories.
+const distributionScanCycles = 30
+
+// DestinationSummary counts the plots found on a destination directory.  Created are the plots
+// written by a plot archived on this server, the others were there before or came from elsewhere.
+type DestinationSummary struct {
+	TargetDir    string
+	Plots        int
+	Bytes        uint64
+	Created      int
+	CreatedBytes uint64
+	ScanTime     time.Time
+	Error        string
+}
+
+// scanDistribution walks the local destination directories looking for *.plot files.
+func (server *Server) scanDistribution(config *Config, now time.Time) {
+	server.lock.RLock()
+	created := map[string]bool{}
+	for id := range server.clearedIds {
+		created[id] = true
+	}
+	for _, plot := range server.archive {
+		if plot.State == PlotFinished && len(plot.Id) > 0 {
+			created[plot.Id] = true
+		}
+	}
+	server.lock.RUnlock()
+
+	var distribution []*DestinationSummary
+	for _, dir := range config.TargetDirectory {
+		if isRemoteTarget(dir) {
+			continue
+		}
+		summary := &DestinationSummary{TargetDir: dir, ScanTime: now}
+		err := server.filesystem().Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".plot") {
+				return nil
+			}
+			summary.Plots++
+			summary.Bytes += uint64(info.Size())
+			if created[plotIdFromFileName(info.Name())] {
+				summary.Created++
+				summary.CreatedBytes += uint64(info.Size())
+			}
+			return nil
+		})
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		distribution = append(distribution, summary)
+	}
+
+	server.lock.Lock()
+	server.distribution = distribution
+	server.lock.Unlock()
+}
+
+// plotIdFromFileName extracts the plot ID from a name like plot-k32-2021-05-01-10-20-<id>.plot.
+func plotIdFromFileName(name string) string {
+	name = strings.TrimSuffix(name, ".plot")
+	if idx := strings.LastIndex(name, "-"); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// handleDistribution returns the result of the last scan of the destination directories as JSON.
+func (server *Server) handleDistribution(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server.lock.RLock()
+	data, err := json.Marshal(server.distribution)
+	server.lock.RUnlock()
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(data)
+}
@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// k32TempSpace is the temp space a k32 plot needs at its peak with chia, it doubles with every k.
+const k32TempSpace = 239 * GB
+
+// maxClockSkew is how far the clock may be from the time server before the doctor fails it.
+const maxClockSkew = time.Minute
+
+// doctorResult is the outcome of a check of the doctor.
+type doctorResult int
+
+const (
+	doctorPass doctorResult = iota
+	doctorWarn
+	doctorFail
+)
+
+func (result doctorResult) String() string {
+	switch result {
+	case doctorPass:
+		return "PASS"
+	case doctorWarn:
+		return "WARN"
+	}
+	return "FAIL"
+}
+
+// doctorReport prints the checks of the doctor as they run, and counts the failures.
+type doctorReport struct {
+	w        io.Writer
+	failures int
+	warnings int
+}
+
+func (report *doctorReport) add(result doctorResult, check string, detail string, args ...interface{}) {
+	switch result {
+	case doctorFail:
+		report.failures++
+	case doctorWarn:
+		report.warnings++
+	}
+	fmt.Fprintf(report.w, "[%s] %-12s %s\n", result, check, fmt.Sprintf(detail, args...))
+}
+
+// DoctorOptions are what the doctor checks besides the configuration.
+type DoctorOptions struct {
+	ConfigPath string
+	Port       int    // of the API
+	TimeUrl    string // HTTPS server whose Date header the clock is compared with, empty to skip
+}
+
+// RunDoctor checks that PlotNG can plot with the configuration before the first plot is
+// attempted: the chia binary, the keys, the permissions and free space of the directories, the
+// clock and the API port.  It prints a PASS, WARN or FAIL line per check and returns an error
+// when a check failed.
+func RunDoctor(w io.Writer, options DoctorOptions) error {
+	report := &doctorReport{w: w}
+	config := doctorConfig(report, options.ConfigPath)
+	doctorChia(report)
+	if config != nil {
+		doctorKeys(report, config)
+		doctorDirectories(report, config)
+	}
+	doctorClock(report, options.TimeUrl)
+	doctorPort(report, options.Port)
+	fmt.Fprintf(w, "\n%d failed, %d warnings\n", report.failures, report.warnings)
+	if report.failures > 0 {
+		return fmt.Errorf("%d checks failed", report.failures)
+	}
+	return nil
+}
+
+// doctorConfig loads and validates the configuration file.
+func doctorConfig(report *doctorReport, path string) *Config {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		report.add(doctorFail, "config", "%s", err)
+		return nil
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		report.add(doctorFail, "config", "%s: %s", path, err)
+		return nil
+	}
+	if err := config.Validate(); err != nil {
+		report.add(doctorFail, "config", "%s: %s", path, err)
+		return &config
+	}
+	if len(config.TempDirectory) == 0 || len(config.TargetDirectory) == 0 {
+		report.add(doctorFail, "config", "%s has no TempDirectory or TargetDirectory", path)
+		return &config
+	}
+	report.add(doctorPass, "config", "%s, %d temp and %d dest directories", path, len(config.TempDirectory), len(config.TargetDirectory))
+	return &config
+}
+
+// doctorChia finds the chia binary and checks that its version is known.
+func doctorChia(report *doctorReport) {
+	path, err := exec.LookPath("chia")
+	if err != nil {
+		report.add(doctorFail, "chia", "chia not found in the PATH, activate the chia environment first: %s", err)
+		return
+	}
+	out, err := exec.Command(path, "version").Output()
+	if err != nil {
+		report.add(doctorFail, "chia", "%s version failed: %s", path, err)
+		return
+	}
+	version, err := parseChiaVersion(string(out))
+	if err != nil {
+		report.add(doctorWarn, "chia", "%s: %s", path, err)
+		return
+	}
+	if findChiaVersionRule(version) == nil {
+		report.add(doctorWarn, "chia", "%s version %d.%d.%d is not known to PlotNG, plot arguments and log parsing may not match", path, version[0], version[1], version[2])
+		return
+	}
+	report.add(doctorPass, "chia", "%s version %d.%d.%d", path, version[0], version[1], version[2])
+}
+
+// doctorKeys checks the keys the plots are made with, like checkKeys does before every plot.
+func doctorKeys(report *doctorReport, config *Config) {
+	plot := &ActivePlot{PlotStatus: PlotStatus{
+		Fingerprint:     config.Fingerprint,
+		FarmerPublicKey: config.FarmerPublicKey,
+		PoolPublicKey:   config.PoolPublicKey,
+		PoolContract:    config.PoolContractAddress,
+	}}
+	plot.keychain = &keychain{}
+	if err := plot.checkKeys(); err != nil {
+		report.add(doctorFail, "keys", "%s", err)
+		return
+	}
+	switch {
+	case len(config.Fingerprint) > 0:
+		report.add(doctorPass, "keys", "fingerprint %s found in the chia keychain", config.Fingerprint)
+	case len(config.FarmerPublicKey) > 0 && (len(config.PoolPublicKey) > 0 || len(config.PoolContractAddress) > 0):
+		report.add(doctorPass, "keys", "farmer and pool keys set in the configuration")
+	default:
+		report.add(doctorPass, "keys", "plotting with the first key of the chia keychain")
+	}
+}
+
+// doctorDirectories checks that the local temp, dest and buffer directories exist, can be written
+// and have room for a plot.
+func doctorDirectories(report *doctorReport, config *Config) {
+	k := config.PlotSize
+	if k == 0 {
+		k = 32
+	}
+	tempSpace, plotSize := uint64(k32TempSpace), uint64(PLOT_SIZE)
+	if k >= 32 {
+		tempSpace, plotSize = tempSpace<<uint(k-32), plotSize<<uint(k-32)
+	} else {
+		tempSpace, plotSize = tempSpace>>uint(32-k), plotSize>>uint(32-k)
+	}
+	fs := localFilesystem{}
+	for _, dirs := range []struct {
+		kind  string
+		list  []string
+		space uint64
+	}{
+		{"temp", config.TempDirectory, tempSpace},
+		{"dest", config.TargetDirectory, plotSize},
+		{"buffer", config.BufferDirectory, plotSize},
+	} {
+		for _, dir := range dirs.list {
+			check := dirs.kind + " dir"
+			if isRemoteTarget(dir) {
+				report.add(doctorWarn, check, "%s is remote, not checked", dir)
+				continue
+			}
+			if err := probeDirectory(fs, dir); err != nil {
+				report.add(doctorFail, check, "%s", err)
+				continue
+			}
+			file, err := ioutil.TempFile(dir, ".plotng-doctor-")
+			if err != nil {
+				report.add(doctorFail, check, "%s is not writable: %s", dir, err)
+				continue
+			}
+			file.Close()
+			os.Remove(file.Name())
+			if available := fs.Available(dir); available < dirs.space {
+				report.add(doctorFail, check, "%s has %s available, a k%d plot needs %s", dir, format.Space(available), k, format.Space(dirs.space))
+			} else {
+				report.add(doctorPass, check, "%s writable, %s available, room for %d k%d plots", dir, format.Space(available), available/dirs.space, k)
+			}
+		}
+	}
+	if len(config.SavePlotLogDir) > 0 {
+		if err := os.MkdirAll(config.SavePlotLogDir, 0755); err != nil {
+			report.add(doctorFail, "log dir", "%s", err)
+		} else if file, err := ioutil.TempFile(config.SavePlotLogDir, ".plotng-doctor-"); err != nil {
+			report.add(doctorFail, "log dir", "%s is not writable: %s", config.SavePlotLogDir, err)
+		} else {
+			file.Close()
+			os.Remove(file.Name())
+			report.add(doctorPass, "log dir", "%s writable", filepath.Clean(config.SavePlotLogDir))
+		}
+	}
+}
+
+// doctorClock compares the clock with the Date header of an HTTPS server.  Plotting works with a
+// wrong clock, but the plot times, the schedules and the farmer on the same machine don't.
+func doctorClock(report *doctorReport, url string) {
+	if len(url) == 0 {
+		report.add(doctorWarn, "clock", "not checked, %s", format.Time(time.Now()))
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	before := time.Now()
+	resp, err := client.Head(url)
+	if err != nil {
+		report.add(doctorWarn, "clock", "can't reach %s to check the clock: %s", url, err)
+		return
+	}
+	resp.Body.Close()
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		report.add(doctorWarn, "clock", "%s has no Date header", url)
+		return
+	}
+	local := before.Add(time.Since(before) / 2)
+	skew := local.Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		report.add(doctorFail, "clock", "%s off from %s, synchronise it with NTP", format.Duration(skew.Round(time.Second)), url)
+		return
+	}
+	report.add(doctorPass, "clock", "within %s of %s", maxClockSkew, strings.TrimPrefix(url, "https://"))
+}
+
+// doctorPort checks that the API port is free, or already used by a PlotNG server.
+func doctorPort(report *doctorReport, port int) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err == nil {
+		listener.Close()
+		report.add(doctorPass, "api port", "%d is free", port)
+		return
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	var api apiHosts // under the version prefix, or without for servers from before versioning
+	if resp, err := api.do(client, fmt.Sprintf("localhost:%d", port), "GET", "/openapi.json", nil); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			report.add(doctorWarn, "api port", "%d is used by a PlotNG server already running", port)
+			return
+		}
+	}
+	report.add(doctorFail, "api port", "%d is in use by another program: %s", port, err)
+}
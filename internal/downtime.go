@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"plotng/internal/format"
+)
+
+const (
+	// maxDowntimes is the number of paused periods kept for the statistics.
+	maxDowntimes = 1000
+	// minServerDowntime is the gap since the server was last seen running which counts as
+	// downtime, a few scheduling cycles.
+	minServerDowntime = 3 * time.Minute
+)
+
+// Downtime is a period in which plotting was paused, because of the UPS, the temperature, low memory
+// or the electricity price, or the server wasn't running.  End is zero while it lasts.
+type Downtime struct {
+	Start time.Time
+	End   time.Time
+}
+
+// downtimeFile keeps the paused periods next to the configuration file, with the time of the last
+// scheduling cycle so that the time the server was down counts as downtime too.
+type downtimeFile struct {
+	LastSeen time.Time
+	Downtime []Downtime
+}
+
+func downtimePath(configPath string) string {
+	return configPath + ".downtime"
+}
+
+// loadDowntime returns the paused periods saved at path, followed by the time since the server
+// was last seen running when it is longer than minServerDowntime.
+func loadDowntime(path string, now time.Time) []Downtime {
+	var file downtimeFile
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read downtime %s: %s", path, err)
+		}
+		return nil
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Failed to parse downtime %s: %s", path, err)
+		return nil
+	}
+	downtime := file.Downtime
+	if last := len(downtime) - 1; last >= 0 && downtime[last].End.IsZero() {
+		downtime[last].End = file.LastSeen // paused until the server stopped
+	}
+	if !file.LastSeen.IsZero() && now.Sub(file.LastSeen) > minServerDowntime {
+		log.Printf("Server was down for %s since %s", format.Duration(now.Sub(file.LastSeen)), format.Time(file.LastSeen))
+		downtime = append(downtime, Downtime{Start: file.LastSeen, End: now})
+	}
+	if len(downtime) > maxDowntimes {
+		downtime = downtime[len(downtime)-maxDowntimes:]
+	}
+	return downtime
+}
+
+// saveDowntime writes the paused periods with now as the last time the server was seen running.
+func (server *Server) saveDowntime(now time.Time) {
+	server.lock.RLock()
+	data, err := json.MarshalIndent(downtimeFile{LastSeen: now, Downtime: server.downtime}, "", "  ")
+	server.lock.RUnlock()
+	if err == nil {
+		err = ioutil.WriteFile(downtimePath(server.config.ConfigPath), data, 0644)
+	}
+	if err != nil {
+		log.Printf("Failed to save downtime: %s", err)
+	}
+}
+
+// recordPause opens or closes the current paused period.  The caller must hold server.lock.
+func (server *Server) recordPause(paused bool, now time.Time) {
+	open := server.pausedNow()
+	switch {
+	case paused && !open:
+		server.downtime = append(server.downtime, Downtime{Start: now})
+		if len(server.downtime) > maxDowntimes {
+			server.downtime = append([]Downtime(nil), server.downtime[len(server.downtime)-maxDowntimes:]...)
+		}
+	case !paused && open:
+		server.downtime[len(server.downtime)-1].End = now
+	}
+}
+
+// pausedNow returns whether plotting was paused at the last scheduling cycle.  The caller must
+// hold server.lock.
+func (server *Server) pausedNow() bool {
+	return len(server.downtime) > 0 && server.downtime[len(server.downtime)-1].End.IsZero()
+}
+
+// busyPeriods collects the periods in which plots were running and merges the overlapping ones.
+type busyPeriods []Downtime
+
+func (bp *busyPeriods) add(start time.Time, end time.Time) {
+	if !start.IsZero() && end.After(start) {
+		*bp = append(*bp, Downtime{Start: start, End: end})
+	}
+}
+
+func (bp busyPeriods) merged() busyPeriods {
+	sort.Slice(bp, func(i, j int) bool { return bp[i].Start.Before(bp[j].Start) })
+	var merged busyPeriods
+	for _, period := range bp {
+		if last := len(merged) - 1; last >= 0 && !period.Start.After(merged[last].End) {
+			if period.End.After(merged[last].End) {
+				merged[last].End = period.End
+			}
+			continue
+		}
+		merged = append(merged, period)
+	}
+	return merged
+}
+
+// idleDowntime returns how long plotting was paused between from and to while none of the busy
+// periods had a plot running.  Paused periods with plots still running count as plotting time.
+func idleDowntime(downtime []Downtime, busy busyPeriods, from time.Time, to time.Time) (idle time.Duration) {
+	busy = busy.merged()
+	for _, d := range downtime {
+		start, end := d.Start, d.End
+		if end.IsZero() || end.After(to) {
+			end = to
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			continue
+		}
+		idle += end.Sub(start)
+		for _, b := range busy {
+			if b.End.After(start) && b.Start.Before(end) {
+				idle -= minTime(b.End, end).Sub(maxTime(b.Start, start))
+			}
+		}
+	}
+	return
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
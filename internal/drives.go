@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"plotng/internal/format"
+)
+
+// DriveStatus reports the progress of a drive being evacuated, or of a temp directory being
+// drained with the plots still using it.
+type DriveStatus struct {
+	Path        string
+	ActivePlots int
+	Safe        bool
+	Draining    bool
+	Plots       []int64
+}
+
+// countPlotsUsing returns the number of active plots which still read or write dir, including
+// plots waiting for or busy with their final copy.
+func (server *Server) countPlotsUsing(dir string) (count int) {
+	for _, plot := range server.active {
+		if plot.PlotDir == dir || plot.TargetDir == dir {
+			count++
+		}
+	}
+	return
+}
+
+// checkEvacuations reports every evacuated drive which no active plot uses any more as safe to
+// remove.
+func (server *Server) checkEvacuations(config *Config) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	for dir, safe := range server.overlay.Evacuating {
+		if safe || server.countPlotsUsing(dir) > 0 {
+			continue
+		}
+		server.overlay.Evacuating[dir] = true
+		if err := server.overlay.save(overlayPath(server.config.ConfigPath)); err != nil {
+			log.Printf("Failed to save overlay: %s", err)
+		}
+		log.Printf("Drive [%s] is safe to remove", dir)
+		notify(config, "Drive safe to remove", fmt.Sprintf("All plots using [%s] are done, the drive can be removed", dir))
+	}
+	for dir, idle := range server.overlay.Draining {
+		if idle || server.countPlotsUsing(dir) > 0 {
+			continue
+		}
+		server.overlay.Draining[dir] = true
+		if err := server.overlay.save(overlayPath(server.config.ConfigPath)); err != nil {
+			log.Printf("Failed to save overlay: %s", err)
+		}
+		server.schedulerEvent("Temp directory [%s] is drained", dir)
+		notify(config, "Temp directory drained", fmt.Sprintf("All plots using [%s] are done, it can be trimmed or erased.  Enable it again to plot on it.", dir))
+	}
+}
+
+func (server *Server) driveStatus(dir string) DriveStatus {
+	status := DriveStatus{Path: dir}
+	_, status.Draining = server.overlay.Draining[dir]
+	for _, plot := range server.active {
+		if plot.PlotDir == dir || plot.TargetDir == dir {
+			status.ActivePlots++
+			status.Plots = append(status.Plots, plot.PlotId)
+		}
+	}
+	sort.Slice(status.Plots, func(i, j int) bool { return status.Plots[i] < status.Plots[j] })
+	status.Safe = status.ActivePlots == 0
+	return status
+}
+
+// isTempDir reports whether dir is one of the temp directories of config.
+func isTempDir(config *Config, dir string) bool {
+	for _, temp := range config.TempDirectory {
+		if temp == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDrive checks that a newly mounted drive is a writable directory with room for a plot.
+func (server *Server) validateDrive(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("%s is not an absolute path", dir)
+	}
+	if err := probeDirectory(server.filesystem(), dir); err != nil {
+		return err
+	}
+	fs := server.filesystem()
+	probe := filepath.Join(dir, fmt.Sprintf(".plotng-probe-%d", server.now().UnixNano()))
+	file, err := fs.Create(probe)
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	file.Close()
+	fs.Remove(probe)
+	if space := server.getDiskSpaceAvailable(dir); space < PLOT_SIZE {
+		return fmt.Errorf("%s has only %s available", dir, format.Space(space))
+	}
+	return nil
+}
+
+// handleDrives lists the drives being evacuated and the temp directories being drained on GET
+// /drives.  POST /drives/evacuate stops scheduling on a drive until its plots are done, POST
+// /drives/drain does the same for a temp directory which is used again once enabled, POST
+// /drives/add validates a new drive and adds it to the temp (temp=true) or target directories.
+func (server *Server) handleDrives(resp http.ResponseWriter, req *http.Request) {
+	path := req.URL.Query().Get("path")
+	if len(path) > 0 {
+		if err := server.checkPath(path); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case req.URL.Path == "/drives" && req.Method == "GET":
+		var status []DriveStatus
+		server.lock.RLock()
+		for dir := range server.overlay.Evacuating {
+			status = append(status, server.driveStatus(dir))
+		}
+		for dir := range server.overlay.Draining {
+			status = append(status, server.driveStatus(dir))
+		}
+		server.lock.RUnlock()
+		sort.Slice(status, func(i, j int) bool { return status[i].Path < status[j].Path })
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(status)
+	case req.URL.Path == "/drives/evacuate" && req.Method == "POST":
+		if len(path) == 0 {
+			http.Error(resp, "path parameter is required", http.StatusBadRequest)
+			return
+		}
+		server.lock.Lock()
+		server.overlay.Disabled[path] = true
+		server.overlay.Evacuating[path] = false
+		err := server.overlay.save(overlayPath(server.config.ConfigPath))
+		status := server.driveStatus(path)
+		server.lock.Unlock()
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Evacuating drive [%s], %d active plots", path, status.ActivePlots)
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(status)
+	case req.URL.Path == "/drives/drain" && req.Method == "POST":
+		server.config.Lock.RLock()
+		temp := server.config.CurrentConfig != nil && isTempDir(server.config.CurrentConfig, path)
+		server.config.Lock.RUnlock()
+		if !temp {
+			http.Error(resp, fmt.Sprintf("%s is not a temp directory", path), http.StatusBadRequest)
+			return
+		}
+		server.lock.Lock()
+		server.overlay.Disabled[path] = true
+		server.overlay.Draining[path] = false
+		err := server.overlay.save(overlayPath(server.config.ConfigPath))
+		status := server.driveStatus(path)
+		server.lock.Unlock()
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		server.schedulerEvent("Draining temp directory [%s], %d active plots", path, status.ActivePlots)
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(status)
+	case req.URL.Path == "/drives/add" && req.Method == "POST":
+		temp, _ := strconv.ParseBool(req.URL.Query().Get("temp"))
+		if len(path) == 0 {
+			http.Error(resp, "path parameter is required", http.StatusBadRequest)
+			return
+		}
+		if err := server.validateDrive(path); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := server.enrollDrive(path, temp); err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// enrollDrive adds dir to the configuration unless it is already there, e.g. when a replacement
+// drive is mounted at the same place, and puts it back into the rotation.
+func (server *Server) enrollDrive(dir string, temp bool) error {
+	server.config.Lock.RLock()
+	current := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	if current == nil {
+		return fmt.Errorf("No configuration loaded")
+	}
+	dirs := current.TargetDirectory
+	if temp {
+		dirs = current.TempDirectory
+	}
+	found := false
+	for _, d := range dirs {
+		if d == dir {
+			found = true
+		}
+	}
+	if !found {
+		newConfig := *current
+		if temp {
+			newConfig.TempDirectory = append(append([]string{}, dirs...), dir)
+		} else {
+			newConfig.TargetDirectory = append(append([]string{}, dirs...), dir)
+		}
+		if err := server.config.SaveConfig(&newConfig); err != nil {
+			return err
+		}
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	delete(server.overlay.Disabled, dir)
+	delete(server.overlay.Evacuating, dir)
+	delete(server.offlineTargets, dir)
+	log.Printf("Drive [%s] added", dir)
+	return server.overlay.save(overlayPath(server.config.ConfigPath))
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// electricityPriceRefresh is how long a fetched electricity price is reused before asking the provider again.
+const electricityPriceRefresh = 15 * time.Minute
+
+// electricityPriceRetry is the first delay before fetching the price again after a failure.
+const electricityPriceRetry = time.Minute
+
+// fetchElectricityPrice retrieves the JSON document at url and extracts the number found at field,
+// a dot separated path where numeric elements index into arrays, eg. "data.0.price".
+func fetchElectricityPrice(url string, field string) (float64, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var doc interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return 0, fmt.Errorf("failed to decode price: %w", err)
+	}
+	if len(field) > 0 {
+		for _, part := range strings.Split(field, ".") {
+			switch v := doc.(type) {
+			case map[string]interface{}:
+				doc = v[part]
+			case []interface{}:
+				idx, err := strconv.Atoi(part)
+				if err != nil || idx < 0 || idx >= len(v) {
+					return 0, fmt.Errorf("invalid index [%s] in price field", part)
+				}
+				doc = v[idx]
+			default:
+				return 0, fmt.Errorf("price field [%s] not found", field)
+			}
+		}
+	}
+	switch v := doc.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("price field [%s] is not a number", field)
+}
+
+// checkElectricityPrice fetches the electricity price when the last one is older than
+// electricityPriceRefresh.  It is called without server.lock or the lock of the configuration, a
+// slow provider doesn't block the API.  After a failure the last known price is kept and the next attempt waits twice as long as
+// the previous one, up to electricityPriceRefresh.
+func (server *Server) checkElectricityPrice(config *Config, now time.Time) {
+	if len(config.ElectricityPriceUrl) == 0 {
+		server.priceTime = time.Time{}
+		server.priceRetry = time.Time{}
+		server.priceBackoff = 0
+		return
+	}
+	if now.Sub(server.priceTime) < electricityPriceRefresh || now.Before(server.priceRetry) {
+		return
+	}
+	price, err := fetchElectricityPrice(config.ElectricityPriceUrl, config.ElectricityPriceField)
+	if err != nil {
+		server.priceBackoff *= 2
+		if server.priceBackoff < electricityPriceRetry {
+			server.priceBackoff = electricityPriceRetry
+		} else if server.priceBackoff > electricityPriceRefresh {
+			server.priceBackoff = electricityPriceRefresh
+		}
+		server.priceRetry = now.Add(server.priceBackoff)
+		log.Printf("Failed to fetch electricity price, retrying in %s: %s", server.priceBackoff, err)
+		return
+	}
+	server.price = price
+	server.priceTime = now
+	server.priceRetry = time.Time{}
+	server.priceBackoff = 0
+}
+
+// electricityTooExpensive reports whether new plots should wait for a cheaper electricity price,
+// judged by the last price fetched by checkElectricityPrice.  Until a price could be retrieved
+// plotting carries on, so a provider outage doesn't stop the plotter.
+func (server *Server) electricityTooExpensive(config *Config, now time.Time) bool {
+	if len(config.ElectricityPriceUrl) == 0 || server.priceTime.IsZero() {
+		return false
+	}
+	if server.price > config.MaxElectricityPrice {
+		server.schedulerEvent("Skipping, electricity price %g is above %g", server.price, config.MaxElectricityPrice)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	EventPlotStarted  EventType = "plot started"
+	EventPhaseChanged EventType = "phase changed"
+	EventStateChanged EventType = "state changed"
+	EventPlotFinished EventType = "plot finished"
+	EventDiskOffline  EventType = "disk offline"
+)
+
+// Event is something that happened in the daemon, published on the event bus.  Plot events carry
+// the plot, state changes From and To, phase changes the Phase and disk events the Dir.
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Plot  *ActivePlot
+	From  PlotState
+	To    PlotState
+	Phase string
+	Dir   string
+	Error string
+}
+
+// String describes the event for the event list of the UI.
+func (event Event) String() string {
+	switch event.Type {
+	case EventPlotStarted:
+		return fmt.Sprintf("Plot [%d] started %s -> %s", event.Plot.PlotId, event.Plot.PlotDir, event.Plot.TargetDir)
+	case EventPhaseChanged:
+		return fmt.Sprintf("Plot [%d] entered phase %s", event.Plot.PlotId, event.Phase)
+	case EventStateChanged, EventPlotFinished:
+		return fmt.Sprintf("Plot [%d] %s", event.Plot.PlotId, strings.ToLower(event.To.String()))
+	case EventDiskOffline:
+		return fmt.Sprintf("Target [%s] is offline: %s", event.Dir, event.Error)
+	}
+	return string(event.Type)
+}
+
+// eventQueueSize is the number of events queued beyond which the informational ones are dropped.
+const eventQueueSize = 100
+
+// eventBus delivers events to its subscribers in order, on its own goroutine so a subscriber can
+// take server.lock whatever lock the publisher holds.  Publishing never blocks, the queue grows
+// as needed.  Beyond eventQueueSize events, e.g. while the publisher holds the lock a subscriber
+// waits for, phase changes and the state changes to a non final state are dropped and counted,
+// the other events are always delivered.
+type eventBus struct {
+	lock        sync.Mutex
+	queued      *sync.Cond // nil for a bus delivering in the publisher's goroutine
+	queue       []Event
+	subscribers []eventSubscriber
+	dropped     uint64 // atomic
+}
+
+type eventSubscriber struct {
+	types   []EventType
+	handler func(Event)
+}
+
+func (es *eventSubscriber) wants(eventType EventType) bool {
+	if len(es.types) == 0 {
+		return true
+	}
+	for _, t := range es.types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newEventBus() *eventBus {
+	bus := &eventBus{}
+	bus.queued = sync.NewCond(&bus.lock)
+	go bus.run()
+	return bus
+}
+
+// run delivers the queued events, forever.
+func (bus *eventBus) run() {
+	for {
+		bus.lock.Lock()
+		for len(bus.queue) == 0 {
+			bus.queued.Wait()
+		}
+		event := bus.queue[0]
+		bus.queue[0] = Event{}
+		bus.queue = bus.queue[1:]
+		bus.lock.Unlock()
+		bus.deliver(event)
+	}
+}
+
+// informational reports whether the event may be dropped, no subscriber keeps count of it.
+func (event Event) informational() bool {
+	return event.Type == EventPhaseChanged || (event.Type == EventStateChanged && !event.To.Final())
+}
+
+// deliver hands event to the subscribers which want it.
+func (bus *eventBus) deliver(event Event) {
+	for _, subscriber := range bus.subscribers {
+		if subscriber.wants(event.Type) {
+			subscriber.handler(event)
+		}
+	}
+}
+
+// newSyncEventBus returns a bus which delivers the events in the goroutine publishing them, so the
+// runs of the scheduler harness are deterministic.  Its subscribers must not take a lock the
+// publishers hold.
+func newSyncEventBus() *eventBus {
+	return &eventBus{}
+}
+
+// subscribe adds a handler for the given event types, or all events when none are given.  All
+// subscribers must be added before the first event is published.
+func (bus *eventBus) subscribe(handler func(Event), types ...EventType) {
+	bus.subscribers = append(bus.subscribers, eventSubscriber{types: types, handler: handler})
+}
+
+// publish queues event for the subscribers, it is safe to call on a nil bus.
+func (bus *eventBus) publish(event Event) {
+	if bus == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if bus.queued == nil {
+		bus.deliver(event)
+		return
+	}
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	if len(bus.queue) >= eventQueueSize && event.informational() {
+		if atomic.AddUint64(&bus.dropped, 1) == 1 {
+			log.Printf("Event queue full, dropping phase and state changes: %s", event)
+		}
+		return
+	}
+	bus.queue = append(bus.queue, event)
+	bus.queued.Signal()
+}
+
+// droppedEvents returns the number of informational events dropped because the queue was full.
+func (bus *eventBus) droppedEvents() uint64 {
+	if bus == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&bus.dropped)
+}
+
+// recordEvent keeps the events for the UI and the debug bundle.
+func (server *Server) recordEvent(event Event) {
+	if event.Type == EventStateChanged && event.To.Final() {
+		return // reported as EventPlotFinished
+	}
+	server.schedulerEvent("%s", event)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// eventLogTime is the time format of chia's debug.log.
+const eventLogTime = "2006-01-02T15:04:05.000"
+
+// eventLogLevel returns the level of an event in the event log: failures and offline disks are
+// errors, killed plots warnings and everything else information.
+func eventLogLevel(event Event) string {
+	switch {
+	case event.Type == EventDiskOffline:
+		return "ERROR"
+	case event.Type == EventPlotFinished && event.To == PlotError:
+		return "ERROR"
+	case event.Type == EventPlotFinished && event.To == PlotKilled:
+		return "WARNING"
+	}
+	return "INFO"
+}
+
+// eventLogLine formats an event like a line of chia's debug.log, eg.
+// "2021-06-01T10:12:45.120 plotng plotng.plotter: ERROR    Plot [1622541165] error: ...",
+// so the monitors reading chia's logs, chiadog among them, can follow it too.
+func eventLogLine(event Event) string {
+	message := event.String()
+	if event.Type == EventPlotFinished && event.To == PlotError {
+		if status := event.Plot.Snapshot(); len(status.LastError) > 0 {
+			message += ": " + status.LastError
+		}
+	}
+	message = strings.Replace(message, "\n", " ", -1)
+	return fmt.Sprintf("%s plotng plotng.plotter: %-8s %s\n", event.Time.Format(eventLogTime), eventLogLevel(event), message)
+}
+
+// writeEventLog appends the events to EventLogFile.  The file is opened for every event, so it
+// can be rotated without restarting PlotNG.
+func (server *Server) writeEventLog(event Event) {
+	if event.Type == EventStateChanged && event.To.Final() {
+		return // reported as EventPlotFinished
+	}
+	server.config.Lock.RLock()
+	config := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	if config == nil || len(config.EventLogFile) == 0 {
+		return
+	}
+	file, err := os.OpenFile(config.EventLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = file.WriteString(eventLogLine(event))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to write the event log %s: %s", config.EventLogFile, err)
+	}
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the unit of the process start times in /proc, USER_HZ is 100 on all Linux
+// architectures PlotNG runs on.
+const clockTicks = 100
+
+// externalPlotFile matches the temp files of chia and MadMax, and gives the plot ID.
+var externalPlotFile = regexp.MustCompile(`plot-k\d+-[\d-]+-([0-9a-f]{64})\.plot`)
+
+// ExternalPlot is a plotter process which PlotNG didn't start, eg. started by hand or by another
+// plot manager.  It is shown read-only with the active plots.
+type ExternalPlot struct {
+	Pid       int
+	Plotter   string
+	Id        string
+	StartTime time.Time
+	TempDir   string
+	Temp2Dir  string
+	TargetDir string
+	PlotSize  int
+	Threads   int
+	TempFiles []string
+}
+
+// externalPlotOptions maps the options of chia plots create and of MadMax to the fields of
+// ExternalPlot.
+var externalPlotOptions = map[string]string{
+	"-t": "temp", "--tmp_dir": "temp", "--tmpdir": "temp",
+	"-2": "temp2", "--tmp2_dir": "temp2", "--tmpdir2": "temp2",
+	"-d": "final", "--final_dir": "final", "--finaldir": "final",
+	"-k": "size", "--size": "size",
+	"-r": "threads", "--num_threads": "threads", "--threads": "threads",
+}
+
+// parsePlotterCommand returns the plot of a command line if it is a plotter, chia plots create
+// or MadMax's chia_plot.
+func parsePlotterCommand(args []string) *ExternalPlot {
+	plot := &ExternalPlot{}
+	start := -1
+	for i, arg := range args {
+		if arg == "plots" && i+1 < len(args) && args[i+1] == "create" && strings.Contains(strings.Join(args[:i], " "), "chia") {
+			plot.Plotter = "chia"
+			start = i + 2
+			break
+		}
+		if base := filepath.Base(arg); i == 0 && strings.HasPrefix(base, "chia_plot") {
+			plot.Plotter = "madmax"
+			start = 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	plot.PlotSize = 32
+	for i := start; i < len(args); i++ {
+		name, value := args[i], ""
+		if eq := strings.Index(name, "="); strings.HasPrefix(name, "--") && eq > 0 {
+			name, value = name[:eq], name[eq+1:]
+		} else if _, ok := externalPlotOptions[name]; ok && i+1 < len(args) {
+			value = args[i+1]
+			i++
+		} else if len(name) > 2 && !strings.HasPrefix(name, "--") {
+			name, value = name[:2], name[2:] // -t/tmp
+		}
+		switch externalPlotOptions[name] {
+		case "temp":
+			plot.TempDir = filepath.Clean(value)
+		case "temp2":
+			plot.Temp2Dir = filepath.Clean(value)
+		case "final":
+			plot.TargetDir = filepath.Clean(value)
+		case "size":
+			if k, err := strconv.Atoi(value); err == nil {
+				plot.PlotSize = k
+			}
+		case "threads":
+			if threads, err := strconv.Atoi(value); err == nil {
+				plot.Threads = threads
+			}
+		}
+	}
+	return plot
+}
+
+// bootTime returns when the system started, from /proc/stat.
+func bootTime() (time.Time, error) {
+	data, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "btime" {
+			seconds, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, os.ErrNotExist
+}
+
+// processStat returns the parent and the start time of a process, from /proc/<pid>/stat.
+func processStat(pid string, boot time.Time) (ppid int, start time.Time) {
+	data, err := ioutil.ReadFile(filepath.Join("/proc", pid, "stat"))
+	if err != nil {
+		return
+	}
+	// The command name in parentheses may contain spaces, the fields are counted after it.
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 20 {
+		return
+	}
+	ppid, _ = strconv.Atoi(fields[1])
+	if ticks, err := strconv.ParseInt(fields[19], 10, 64); err == nil {
+		start = boot.Add(time.Duration(ticks) * time.Second / clockTicks)
+	}
+	return
+}
+
+// findExternalPlots lists the plotter processes which aren't one of the own plots nor started by
+// PlotNG, Linux only.  The plot ID comes from the temp files the plotter has open.
+func findExternalPlots(own map[int]bool) []*ExternalPlot {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	boot, err := bootTime()
+	if err != nil {
+		return nil
+	}
+	var plots []*ExternalPlot
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || own[pid] || pid == os.Getpid() {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		plot := parsePlotterCommand(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"))
+		if plot == nil {
+			continue
+		}
+		ppid, start := processStat(entry.Name(), boot)
+		if ppid == os.Getpid() {
+			continue
+		}
+		plot.Pid = pid
+		plot.StartTime = start
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, _ := ioutil.ReadDir(fdDir)
+		for _, fd := range fds {
+			path, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			if match := externalPlotFile.FindStringSubmatch(filepath.Base(path)); match != nil {
+				plot.Id = match[1]
+				plot.TempFiles = append(plot.TempFiles, path)
+			}
+		}
+		plots = append(plots, plot)
+	}
+	return plots
+}
+
+// scanExternalPlots updates the plotter processes PlotNG didn't start.
+func (server *Server) scanExternalPlots() {
+	own := map[int]bool{}
+	server.lock.RLock()
+	for _, plot := range server.active {
+		if pid := plot.Snapshot().Pid; pid > 0 {
+			own[pid] = true
+		}
+	}
+	server.lock.RUnlock()
+	external := findExternalPlots(own)
+	server.lock.Lock()
+	server.external = external
+	server.lock.Unlock()
+}
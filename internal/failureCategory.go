@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FailureCategory is why a plot failed, as far as it can be told from the error, the exit code of
+// the plotter and its log.  The values are sent to the UI, new categories are added at the end.
+type FailureCategory int
+
+const (
+	FailureNone FailureCategory = iota
+	FailureOther
+	FailureDiskFull
+	FailureOutOfMemory
+	FailurePlotterMissing
+	FailureInvalidKey
+	FailureCopy
+	FailureVerification
+)
+
+// String returns the name of a failure category shown to the user.
+func (category FailureCategory) String() string {
+	switch category {
+	case FailureNone:
+		return ""
+	case FailureDiskFull:
+		return "Disk full"
+	case FailureOutOfMemory:
+		return "Out of memory"
+	case FailurePlotterMissing:
+		return "Plotter missing"
+	case FailureInvalidKey:
+		return "Invalid key"
+	case FailureCopy:
+		return "Copy failed"
+	case FailureVerification:
+		return "Verification failed"
+	}
+	return "Other"
+}
+
+// failurePatterns are checked in order against the error and the last log lines.
+var failurePatterns = []struct {
+	category FailureCategory
+	pattern  *regexp.Regexp
+}{
+	{FailurePlotterMissing, regexp.MustCompile(`executable file not found|command not found`)},
+	{FailureDiskFull, regexp.MustCompile(`(?i)no space left on device|not enough space|disk full|disk quota exceeded`)},
+	{FailureOutOfMemory, regexp.MustCompile(`(?i)bad_alloc|MemoryError|out of memory|cannot allocate memory|signal: killed`)},
+	{FailureInvalidKey, regexp.MustCompile(`(?i)invalid (farmer |pool )?(public )?key|invalid pool contract|key.* not found|fingerprint.* not found|no keys (present|found)|bech32|not a valid (key|address)`)},
+	{FailureCopy, regexp.MustCompile(`^failed to copy final plot`)},
+	{FailureVerification, regexp.MustCompile(`^verification failed`)},
+}
+
+// classifyFailure picks the category of a failure from its error message, the exit code of the
+// plotter and the last lines of its log.  A plotter killed by SIGKILL without PlotNG asking for it
+// was most likely killed by the OOM killer.
+func classifyFailure(message string, exited bool, exitCode int, log []string) FailureCategory {
+	text := message + "\n" + strings.Join(log, "")
+	for _, p := range failurePatterns {
+		if p.pattern.MatchString(message) {
+			return p.category
+		}
+	}
+	for _, p := range failurePatterns {
+		if p.pattern.MatchString(text) {
+			return p.category
+		}
+	}
+	if exited && exitCode == 137 {
+		return FailureOutOfMemory
+	}
+	return FailureOther
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"plotng/internal/format"
+)
+
+// failureReportLines is the number of log lines kept for the failure report.
+const failureReportLines = 50
+
+// plotterExited records the exit code of the plotter, -1 when it was killed by a signal.
+func (ap *ActivePlot) plotterExited(err error) {
+	code := -1
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	}
+	ap.lock.Lock()
+	ap.exited = true
+	ap.exitCode = code
+	ap.lock.Unlock()
+}
+
+// failureReport returns the text of an issue about the failed plot: what failed, the command line,
+// the exit code, the last log lines and the state of its directories.  It is redacted like the
+// logs sent to the UI.
+func (ap *ActivePlot) failureReport(from PlotState, r *redactor) string {
+	ap.lock.RLock()
+	status := ap.PlotStatus
+	commandLine := ap.commandLine
+	exited, exitCode := ap.exited, ap.exitCode
+	recentLog := append([]string(nil), ap.recentLog...)
+	ap.lock.RUnlock()
+	r = r.forPlot(&status)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Plot failed while %s\n\n", strings.ToLower(from.String()))
+	fmt.Fprintf(&b, "%s\n\n", r.line(status.LastError))
+	fmt.Fprintf(&b, "- Plot: %s (%d)\n", status.Id, status.PlotId)
+	fmt.Fprintf(&b, "- Started: %s, failed: %s\n", format.Time(status.StartTime), format.Time(status.EndTime))
+	fmt.Fprintf(&b, "- Phase: %s %s\n", status.Phase, status.Progress)
+	fmt.Fprintf(&b, "- Plotter: %s, OS: %s/%s, %d CPUs, %s\n", status.Plotter, runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.Version())
+	switch {
+	case exited && exitCode >= 0:
+		fmt.Fprintf(&b, "- Exit code: %d\n", exitCode)
+	case exited:
+		fmt.Fprintf(&b, "- Exit code: none, killed by a signal\n")
+	}
+	fmt.Fprintf(&b, "\n### Command line\n\n```\n%s\n```\n", r.line(commandLine))
+	fmt.Fprintf(&b, "\n### Last %d log lines\n\n```\n%s```\n", len(recentLog), strings.Join(r.lines(recentLog), ""))
+	fmt.Fprintf(&b, "\n### Disk state\n\n")
+	fs := ap.filesystem()
+	for _, dir := range []string{status.PlotDir, status.TargetDir} {
+		if isRemoteTarget(dir) {
+			fmt.Fprintf(&b, "- %s: remote\n", r.line(dir))
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %s available\n", r.line(dir), format.Space(fs.Available(dir)))
+	}
+	tempDir := ap.tempDir()
+	files, err := fs.ReadDir(tempDir)
+	if err != nil {
+		fmt.Fprintf(&b, "- %s: %s\n", r.line(tempDir), r.line(err.Error()))
+	}
+	for _, file := range files {
+		if len(status.WorkDir) > 0 || (len(status.Id) > 0 && strings.Contains(file.Name(), status.Id)) {
+			fmt.Fprintf(&b, "- %s: %s\n", r.line(filepath.Join(tempDir, file.Name())), format.GiBytes(uint64(file.Size())))
+		}
+	}
+	return b.String()
+}
+
+// writeFailureReport saves the failure report of a failed plot next to its log in SavePlotLogDir.
+func (server *Server) writeFailureReport(event Event) {
+	if event.To != PlotError || len(event.Plot.SavePlotLogDir) == 0 {
+		return
+	}
+	plot := event.Plot
+	id := plot.Snapshot().Id
+	if len(id) == 0 {
+		id = fmt.Sprintf("%d", plot.PlotId)
+	}
+	path := filepath.Join(plot.SavePlotLogDir, fmt.Sprintf("plotng_failure_%s.md", id))
+	if err := ioutil.WriteFile(path, []byte(plot.failureReport(event.From, server.redactor())), 0644); err != nil {
+		log.Printf("Failed to write failure report %s: %s", path, err)
+		return
+	}
+	log.Printf("Plot [%d] failure report written to %s", plot.PlotId, path)
+}
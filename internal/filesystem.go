@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ricochet2200/go-disk-usage/du"
+)
+
+// Filesystem is what PlotNG does with the temp and dest directories besides plotting and copying:
+// checking space, scanning for plots and temp files and cleaning up.  The local filesystem is used
+// unless another one is plugged in with SetFilesystem, e.g. one which knows about quotas or a
+// network filesystem reporting its space differently.
+type Filesystem interface {
+	DiskUsage
+	Size(dir string) uint64
+	Stat(path string) (os.FileInfo, error)
+	ReadDir(dir string) ([]os.FileInfo, error)
+	Walk(root string, fn filepath.WalkFunc) error
+	MkdirAll(path string, perm os.FileMode) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	Rename(oldpath string, newpath string) error
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+}
+
+// DiskUsage tells how much space is available in a directory.
+type DiskUsage interface {
+	Available(dir string) uint64
+}
+
+// StaticDiskUsage reports fixed amounts of space, directories not listed are empty.
+type StaticDiskUsage struct {
+	lock  sync.Mutex
+	space map[string]uint64
+}
+
+func NewStaticDiskUsage(space map[string]uint64) *StaticDiskUsage {
+	sdu := &StaticDiskUsage{space: map[string]uint64{}}
+	for dir, available := range space {
+		sdu.space[dir] = available
+	}
+	return sdu
+}
+
+func (sdu *StaticDiskUsage) Available(dir string) uint64 {
+	sdu.lock.Lock()
+	defer sdu.lock.Unlock()
+	return sdu.space[dir]
+}
+
+// Set changes the space available in dir.
+func (sdu *StaticDiskUsage) Set(dir string, available uint64) {
+	sdu.lock.Lock()
+	sdu.space[dir] = available
+	sdu.lock.Unlock()
+}
+
+// Use takes size bytes from dir, as a finished plot does.
+func (sdu *StaticDiskUsage) Use(dir string, size uint64) {
+	sdu.lock.Lock()
+	if sdu.space[dir] > size {
+		sdu.space[dir] -= size
+	} else {
+		sdu.space[dir] = 0
+	}
+	sdu.lock.Unlock()
+}
+
+// localFilesystem uses the os package and go-disk-usage, remote targets have unlimited space.
+type localFilesystem struct{}
+
+func (localFilesystem) Available(dir string) uint64 {
+	if isRemoteTarget(dir) {
+		return math.MaxUint64
+	}
+	return du.NewDiskUsage(dir).Available()
+}
+
+func (localFilesystem) Size(dir string) uint64 {
+	if isRemoteTarget(dir) {
+		return math.MaxUint64
+	}
+	return du.NewDiskUsage(dir).Size()
+}
+
+func (localFilesystem) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (localFilesystem) ReadDir(dir string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dir)
+}
+
+func (localFilesystem) Walk(root string, fn filepath.WalkFunc) error {
+	return filepath.Walk(root, fn)
+}
+
+func (localFilesystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (localFilesystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (localFilesystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (localFilesystem) Rename(oldpath string, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (localFilesystem) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// Create returns an *os.File, which copyFile syncs before closing.
+func (localFilesystem) Create(path string) (io.WriteCloser, error) {
+	return os.Create(path)
+}
+
+// diskUsageOverride is a filesystem reporting the space of another DiskUsage.
+type diskUsageOverride struct {
+	Filesystem
+	usage DiskUsage
+}
+
+func (duo *diskUsageOverride) Available(dir string) uint64 {
+	return duo.usage.Available(dir)
+}
+
+// SetFilesystem plugs in another filesystem, it must be called before ProcessLoop.
+func (server *Server) SetFilesystem(fs Filesystem) {
+	server.fs = fs
+}
+
+func (server *Server) filesystem() Filesystem {
+	if server.fs == nil {
+		return localFilesystem{}
+	}
+	return server.fs
+}
+
+func (ap *ActivePlot) filesystem() Filesystem {
+	if ap.fs == nil {
+		return localFilesystem{}
+	}
+	return ap.fs
+}
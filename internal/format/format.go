@@ -0,0 +1,136 @@
+// Package format turns durations, sizes, rates and timestamps into the strings shown to the user,
+// so the UI, the API and the exports all agree on units and locale.
+package format
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GiB = uint64(1) << 30
+	TiB = uint64(1) << 40
+	MiB = uint64(1) << 20
+)
+
+// Options selects the clock, decimal separator and the time zone timestamps are shown in, nil
+// means the local time zone.
+type Options struct {
+	Clock12h         bool
+	DecimalSeparator string
+	Location         *time.Location
+}
+
+var (
+	lock    sync.RWMutex
+	options = Options{DecimalSeparator: "."}
+)
+
+// SetOptions changes how values are formatted from now on.
+func SetOptions(o Options) {
+	if len(o.DecimalSeparator) == 0 {
+		o.DecimalSeparator = "."
+	}
+	lock.Lock()
+	options = o
+	lock.Unlock()
+}
+
+func current() Options {
+	lock.RLock()
+	defer lock.RUnlock()
+	return options
+}
+
+// Float formats f with prec decimals using the decimal separator.
+func Float(f float64, prec int) string {
+	s := fmt.Sprintf("%0.*f", prec, f)
+	if sep := current().DecimalSeparator; sep != "." {
+		s = strings.Replace(s, ".", sep, 1)
+	}
+	return s
+}
+
+// Duration formats d as hours:minutes:seconds, hours go beyond 24.
+func Duration(d time.Duration) string {
+	hour := d / time.Hour
+	d = d - hour*time.Hour
+	mins := d / time.Minute
+	d = d - mins*time.Minute
+	secs := d / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", hour, mins, secs)
+}
+
+// Space formats free space in whole GiB, or TiB above 1000 GiB.  MaxUint64 means unknown.
+func Space(s uint64) string {
+	if s == math.MaxUint64 {
+		return "???"
+	}
+	if s > 1000*GiB {
+		return Float(float64(s)/float64(TiB), 2) + " TiB"
+	}
+	return fmt.Sprintf("%d GiB", s/GiB)
+}
+
+// GiBytes formats a file size in GiB with one decimal.
+func GiBytes(s uint64) string {
+	return Float(float64(s)/float64(GiB), 1) + " GiB"
+}
+
+// TiBytes formats a total size in TiB with two decimals.
+func TiBytes(s uint64) string {
+	return Float(float64(s)/float64(TiB), 2) + " TiB"
+}
+
+// Rate formats a transfer rate given in bytes/s.
+func Rate(bytesPerSecond uint64) string {
+	return Float(float64(bytesPerSecond)/float64(MiB), 1) + " MB/s"
+}
+
+// Percent formats f, already multiplied by 100, with prec decimals.
+func Percent(f float64, prec int) string {
+	return Float(f, prec) + "%"
+}
+
+// Location returns the time zone timestamps are shown in.
+func Location() *time.Location {
+	if loc := current().Location; loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// In converts t to the time zone timestamps are shown in.  Times received from a server keep the
+// server's zone otherwise.
+func In(t time.Time) time.Time {
+	return t.In(Location())
+}
+
+// Zone returns the abbreviation and UTC offset of the display time zone at t, eg. "CEST +02:00".
+func Zone(t time.Time) string {
+	return In(t).Format("MST -07:00")
+}
+
+// Time formats a timestamp with the date and a 24h or 12h clock.
+func Time(t time.Time) string {
+	if current().Clock12h {
+		return In(t).Format("2006-01-02 03:04:05 PM")
+	}
+	return In(t).Format("2006-01-02 15:04:05")
+}
+
+// ShortTime formats a timestamp without seconds.
+func ShortTime(t time.Time) string {
+	if current().Clock12h {
+		return In(t).Format("2006-01-02 03:04 PM")
+	}
+	return In(t).Format("2006-01-02 15:04")
+}
+
+// Date formats the day of a timestamp.
+func Date(t time.Time) string {
+	return In(t).Format("2006-01-02")
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// failureStreak counts the consecutive failed plots with the same failure category.
+type failureStreak struct {
+	category FailureCategory
+	count    int
+}
+
+// Halt is why a plotter stopped starting new plots, sent to the UI until plotting is resumed.
+// Reason is set when plotting was paused by hand rather than after repeated failures.
+type Halt struct {
+	Category  FailureCategory
+	Count     int
+	LastError string
+	Reason    string
+	Time      time.Time
+}
+
+func (halt *Halt) String() string {
+	if len(halt.Reason) > 0 {
+		return halt.Reason
+	}
+	return fmt.Sprintf("%d plots failed in a row (%s): %s", halt.Count, halt.Category, halt.LastError)
+}
+
+// trackFailures halts plotting once HaltAfterFailures plots in a row failed for the same reason,
+// e.g. a missing key or plotter, instead of failing every plot of the queue.  A finished plot
+// ends the streak, killed plots don't count.
+func (server *Server) trackFailures(event Event) {
+	if event.To == PlotKilled {
+		return
+	}
+	server.config.Lock.RLock()
+	config := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	status := event.Plot.Snapshot()
+
+	server.lock.Lock()
+	if event.To == PlotFinished {
+		server.streak = failureStreak{}
+		server.lock.Unlock()
+		return
+	}
+	if server.streak.category != status.Failure {
+		server.streak = failureStreak{category: status.Failure}
+	}
+	server.streak.count++
+	var halt *Halt
+	if config != nil && config.HaltAfterFailures > 0 && server.streak.count >= config.HaltAfterFailures && server.halted == nil {
+		halt = &Halt{Category: status.Failure, Count: server.streak.count, LastError: status.LastError, Time: server.now()}
+		server.halted = halt
+	}
+	server.lock.Unlock()
+
+	if halt != nil {
+		server.schedulerEvent("Halting new plots, %s", halt)
+		notify(config, "Plotting halted", fmt.Sprintf("No new plots are started until plotting is resumed, %s", halt))
+	}
+}
+
+// pause stops starting new plots until plotting is resumed, like a halt after failures.  It
+// returns false if plotting was already halted.
+func (server *Server) pause(reason string) bool {
+	server.lock.Lock()
+	paused := server.halted == nil
+	if paused {
+		server.halted = &Halt{Reason: reason, Time: server.now()}
+	}
+	server.lock.Unlock()
+	if paused {
+		server.schedulerEvent("Halting new plots, %s", reason)
+	}
+	return paused
+}
+
+// resume starts new plots again after a halt, it returns false if plotting wasn't halted.
+func (server *Server) resume() bool {
+	server.lock.Lock()
+	resumed := server.halted != nil
+	server.halted = nil
+	server.streak = failureStreak{}
+	server.lock.Unlock()
+	if resumed {
+		log.Printf("Plotting resumed")
+		server.schedulerEvent("Resuming new plots after a halt")
+	}
+	return resumed
+}
+
+// handleHalt returns why plotting is halted, null when it isn't, and resumes it on DELETE.
+func (server *Server) handleHalt(resp http.ResponseWriter, req *http.Request) {
+	redact := server.redactor()
+	switch req.Method {
+	case "GET":
+	case "DELETE":
+		server.resume()
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server.lock.RLock()
+	var halt *Halt
+	if server.halted != nil {
+		h := *server.halted
+		h.LastError = redact.line(h.LastError)
+		halt = &h
+	}
+	server.lock.RUnlock()
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(halt)
+}
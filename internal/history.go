@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyQuery selects a page of the archived plots, in the order they finished.
+type historyQuery struct {
+	limit  int
+	offset int
+	cursor int64
+	from   time.Time
+	to     time.Time
+	states map[PlotState]bool
+}
+
+// parseHistoryTime accepts an RFC 3339 time or a date in the server's time zone.
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+func parseHistoryQuery(values url.Values) (*historyQuery, error) {
+	query := &historyQuery{}
+	var err error
+	if v := values.Get("limit"); len(v) > 0 {
+		if query.limit, err = strconv.Atoi(v); err != nil || query.limit < 0 {
+			return nil, fmt.Errorf("invalid limit: %s", v)
+		}
+	}
+	if v := values.Get("offset"); len(v) > 0 {
+		if query.offset, err = strconv.Atoi(v); err != nil || query.offset < 0 {
+			return nil, fmt.Errorf("invalid offset: %s", v)
+		}
+	}
+	if v := values.Get("cursor"); len(v) > 0 {
+		if query.cursor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid cursor: %s", v)
+		}
+	}
+	if v := values.Get("from"); len(v) > 0 {
+		if query.from, err = parseHistoryTime(v); err != nil {
+			return nil, fmt.Errorf("invalid from: %s", v)
+		}
+	}
+	if v := values.Get("to"); len(v) > 0 {
+		if query.to, err = parseHistoryTime(v); err != nil {
+			return nil, fmt.Errorf("invalid to: %s", v)
+		}
+	}
+	if v := values.Get("state"); len(v) > 0 {
+		query.states = map[PlotState]bool{}
+		for _, name := range strings.Split(v, ",") {
+			state, ok := parsePlotState(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("invalid state: %s", name)
+			}
+			query.states[state] = true
+		}
+	}
+	return query, nil
+}
+
+// parsePlotState accepts the name of a final state, as shown in the UI, or its number.
+func parsePlotState(name string) (PlotState, bool) {
+	if n, err := strconv.Atoi(name); err == nil {
+		return PlotState(n), PlotState(n).Final()
+	}
+	for _, state := range []PlotState{PlotFinished, PlotError, PlotKilled} {
+		if strings.EqualFold(name, state.String()) || strings.EqualFold(name, strings.TrimSuffix(state.String(), "ed")) {
+			return state, true
+		}
+	}
+	return 0, false
+}
+
+func (query *historyQuery) matches(plot *PlotStatus) bool {
+	if !query.from.IsZero() && plot.EndTime.Before(query.from) {
+		return false
+	}
+	if !query.to.IsZero() && !plot.EndTime.Before(query.to) {
+		return false
+	}
+	return query.states == nil || query.states[plot.State]
+}
+
+// page returns the plots after the cursor and offset matching the filters, at most limit of them
+// when a limit is given, the total number of matching plots and the cursor of the next page.
+func (query *historyQuery) page(archived []*PlotStatus) (page []*PlotStatus, total int, next int64) {
+	start := 0
+	if query.cursor != 0 {
+		for i, plot := range archived {
+			if plot.PlotId == query.cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	skipped := 0
+	for _, plot := range archived[start:] {
+		if !query.matches(plot) {
+			continue
+		}
+		total++
+		if skipped < query.offset {
+			skipped++
+			continue
+		}
+		if query.limit > 0 && len(page) >= query.limit {
+			next = page[len(page)-1].PlotId
+			continue
+		}
+		page = append(page, plot)
+	}
+	return
+}
+
+// handleHistory returns the archived plots as JSON, including the keys and memo reported by the
+// plotter for auditing.  The plots can be filtered with from and to, on the time they ended, and
+// state, and paged with limit and offset or cursor.  X-Total-Count gives the number of matching
+// plots after the cursor and X-Next-Cursor the cursor of the next page.
+func (server *Server) handleHistory(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseHistoryQuery(req.URL.Query())
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redact := server.redactor()
+	server.lock.RLock()
+	_, archived := server.plotSnapshots()
+	server.lock.RUnlock()
+	page, total, next := query.page(archived)
+	for _, plot := range page {
+		redact.plotLog(plot)
+	}
+	if page == nil {
+		page = []*PlotStatus{}
+	}
+	data, err := json.Marshal(page)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if next != 0 {
+		resp.Header().Set("X-Next-Cursor", strconv.FormatInt(next, 10))
+	}
+	resp.Write(data)
+}
@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the idle mode settings.
+const (
+	defaultIdleMinutes     = 10
+	defaultIdleMaxCpu      = 25
+	defaultIdleMaxDiskBusy = 20
+)
+
+// idleSample is a reading of the CPU and disk counters of Linux.  ioTicks are the milliseconds
+// each device, by "major:minor", spent doing I/O.
+type idleSample struct {
+	time     time.Time
+	cpuBusy  uint64
+	cpuTotal uint64
+	ioTicks  map[string]uint64
+}
+
+// idleMonitor tracks how long the machine has been idle enough for an extra plot.
+type idleMonitor struct {
+	last      *idleSample
+	idleSince time.Time
+	failed    bool
+}
+
+// readCpuTimes returns the busy and total CPU time from the Linux /proc/stat, waiting for I/O
+// counts as idle.
+func readCpuTimes() (busy uint64, total uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		for i, field := range fields[1:] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid cpu time: %s", field)
+			}
+			total += value
+			if i != 3 && i != 4 { // idle and iowait
+				busy += value
+			}
+		}
+		return busy, total, nil
+	}
+	return 0, 0, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// readIoTicks returns the milliseconds every device spent doing I/O from the Linux /proc/diskstats.
+func readIoTicks() (map[string]uint64, error) {
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ticks := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[12], 10, 64); err == nil {
+			ticks[fields[0]+":"+fields[1]] = value
+		}
+	}
+	return ticks, nil
+}
+
+// mountDevices returns the device, as "major:minor", of every mount point from the Linux
+// /proc/self/mountinfo.
+func mountDevices() (map[string]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	devices := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 5 {
+			devices[fields[4]] = fields[2]
+		}
+	}
+	return devices, nil
+}
+
+// deviceOf returns the device of the mount point holding dir.
+func deviceOf(devices map[string]string, dir string) string {
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		if device, ok := devices[dir]; ok {
+			return device
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
+
+func readIdleSample(now time.Time) (*idleSample, error) {
+	busy, total, err := readCpuTimes()
+	if err != nil {
+		return nil, err
+	}
+	ticks, err := readIoTicks()
+	if err != nil {
+		return nil, err
+	}
+	return &idleSample{time: now, cpuBusy: busy, cpuTotal: total, ioTicks: ticks}, nil
+}
+
+// utilization returns the CPU usage and the highest usage of the devices of dirs, in percent,
+// between the samples.
+func utilization(from *idleSample, to *idleSample, devices map[string]string, dirs []string) (cpu float64, disk float64) {
+	if to.cpuTotal > from.cpuTotal {
+		cpu = float64(to.cpuBusy-from.cpuBusy) * 100 / float64(to.cpuTotal-from.cpuTotal)
+	}
+	elapsed := to.time.Sub(from.time).Milliseconds()
+	if elapsed <= 0 {
+		return
+	}
+	for _, dir := range dirs {
+		device := deviceOf(devices, dir)
+		before, ok := from.ioTicks[device]
+		after, ok2 := to.ioTicks[device]
+		if !ok || !ok2 || after < before {
+			continue
+		}
+		if busy := float64(after-before) * 100 / float64(elapsed); busy > disk {
+			disk = busy
+		}
+	}
+	return
+}
+
+// idleExtraPlots returns how many plots may run above NumberOfParallelPlots: up to IdleExtraPlots
+// once the CPU and the temp drives have been below IdleMaxCpu and IdleMaxDiskBusy for IdleMinutes.
+// It is called every scheduling cycle, the usage is measured between cycles.  The caller must
+// hold server.lock.
+func (server *Server) idleExtraPlots(config *Config, now time.Time) int {
+	monitor := &server.idle
+	if config.IdleExtraPlots <= 0 {
+		monitor.last = nil
+		monitor.idleSince = time.Time{}
+		return 0
+	}
+	sample, err := readIdleSample(now)
+	if err != nil {
+		if !monitor.failed {
+			log.Printf("Failed to read the CPU and disk usage, IdleExtraPlots is ignored: %s", err)
+			monitor.failed = true
+		}
+		return 0
+	}
+	monitor.failed = false
+	last := monitor.last
+	monitor.last = sample
+	if last == nil {
+		return 0
+	}
+	devices, err := mountDevices()
+	if err != nil {
+		devices = map[string]string{}
+	}
+	cpu, disk := utilization(last, sample, devices, config.TempDirectory)
+	maxCpu, maxDisk, minutes := config.IdleMaxCpu, config.IdleMaxDiskBusy, config.IdleMinutes
+	if maxCpu <= 0 {
+		maxCpu = defaultIdleMaxCpu
+	}
+	if maxDisk <= 0 {
+		maxDisk = defaultIdleMaxDiskBusy
+	}
+	if minutes <= 0 {
+		minutes = defaultIdleMinutes
+	}
+	if cpu > maxCpu || disk > maxDisk {
+		if !monitor.idleSince.IsZero() {
+			server.schedulerEvent("Machine busy, CPU %.0f%%, temp drives %.0f%%, no extra plots", cpu, disk)
+		}
+		monitor.idleSince = time.Time{}
+		return 0
+	}
+	if monitor.idleSince.IsZero() {
+		monitor.idleSince = last.time
+	}
+	if now.Sub(monitor.idleSince) < time.Duration(minutes)*time.Minute {
+		return 0
+	}
+	return config.IdleExtraPlots
+}
+
+// startedExtraPlot makes the machine prove it is still idle before the next extra plot.
+func (server *Server) startedExtraPlot(now time.Time) {
+	server.schedulerEvent("Machine idle for %d minutes, started an extra plot", int(now.Sub(server.idle.idleSince).Minutes()))
+	server.idle.idleSince = now
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// janitorCycles is how many cycles pass between two prunings of the archive and the plot logs.
+const janitorCycles = 60
+
+// forgetArchived drops what the server keeps about an archived plot being removed from the
+// archive.  A finished plot still counts towards the quotas and as created by PlotNG in the
+// distribution.  The caller holds the lock.
+func (server *Server) forgetArchived(plot *ActivePlot, status *PlotStatus) {
+	if server.clearedIds == nil {
+		server.clearedIds = map[string]bool{}
+	}
+	if status.State == PlotFinished {
+		if len(status.Id) > 0 {
+			server.clearedIds[status.Id] = true
+		}
+	}
+	delete(server.retried, status.PlotId)
+}
+
+// runJanitor applies the retention settings, so that a server running for months doesn't keep
+// every plot it ever made in memory and every log on disk.
+func (server *Server) runJanitor(config *Config, now time.Time) {
+	if pruned := server.pruneArchive(config, now); pruned > 0 {
+		server.schedulerEvent("Janitor: removed %d plots from the archive", pruned)
+	}
+	if removed := server.pruneLogs(config, now); removed > 0 {
+		server.schedulerEvent("Janitor: deleted %d plot logs and failure reports from %s", removed, config.SavePlotLogDir)
+	}
+}
+
+// pruneArchive removes the archived plots which ended more than HistoryRetentionDays ago, then the
+// oldest ones beyond the last HistoryRetentionCount, and returns how many were removed.
+func (server *Server) pruneArchive(config *Config, now time.Time) (pruned int) {
+	if config.HistoryRetentionDays == 0 && config.HistoryRetentionCount == 0 {
+		return 0
+	}
+	cutoff := now.AddDate(0, 0, -config.HistoryRetentionDays)
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	var kept []*ActivePlot
+	for i, plot := range server.archive {
+		status := plot.Snapshot()
+		expired := config.HistoryRetentionDays > 0 && !status.EndTime.IsZero() && status.EndTime.Before(cutoff)
+		excess := config.HistoryRetentionCount > 0 && len(server.archive)-i > config.HistoryRetentionCount
+		if !expired && !excess {
+			kept = append(kept, plot)
+			continue
+		}
+		server.forgetArchived(plot, &status)
+		pruned++
+	}
+	server.archive = kept
+	return
+}
+
+// pruneLogs deletes the plot logs and failure reports in SavePlotLogDir which weren't modified for
+// PlotLogRetentionDays, and returns how many were deleted.
+func (server *Server) pruneLogs(config *Config, now time.Time) (removed int) {
+	if config.PlotLogRetentionDays == 0 || len(config.SavePlotLogDir) == 0 {
+		return 0
+	}
+	fs := server.filesystem()
+	entries, err := fs.ReadDir(config.SavePlotLogDir)
+	if err != nil {
+		server.schedulerEvent("Janitor: failed to read %s: %s", config.SavePlotLogDir, err)
+		return 0
+	}
+	cutoff := now.AddDate(0, 0, -config.PlotLogRetentionDays)
+	var failed []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !entry.ModTime().Before(cutoff) || !isPlotLogName(name) {
+			continue
+		}
+		if err := fs.Remove(filepath.Join(config.SavePlotLogDir, name)); err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s)", name, err))
+			continue
+		}
+		removed++
+	}
+	if len(failed) > 0 {
+		server.schedulerEvent("Janitor: failed to delete %s", strings.Join(failed, ", "))
+	}
+	return
+}
+
+// isPlotLogName tells whether name is a plot log or a failure report saved by PlotNG.
+func isPlotLogName(name string) bool {
+	return strings.HasPrefix(name, "plotng_log_") && strings.HasSuffix(name, ".txt") ||
+		strings.HasPrefix(name, "plotng_failure_") && strings.HasSuffix(name, ".md")
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PlotJob is a one-off plot submitted through the API.  Queued jobs are started in queue order ahead
+// of the plots scheduled from the configuration file, one per cycle, held jobs are skipped.  Zero
+// values fall back to the configuration.
+type PlotJob struct {
+	JobId           int64
+	SubmitTime      time.Time
+	Held            bool
+	TempDir         string
+	TargetDir       string
+	PlotSize        int
+	Fingerprint     string
+	FarmerPublicKey string
+	PoolPublicKey   string
+	Threads         int
+	Buffers         int
+	Labels          []string
+}
+
+func (job *PlotJob) validate() error {
+	if len(job.TempDir) == 0 {
+		return fmt.Errorf("missing TempDir")
+	}
+	if len(job.TargetDir) == 0 {
+		return fmt.Errorf("missing TargetDir")
+	}
+	if job.PlotSize != 0 && (job.PlotSize < 25 || job.PlotSize > 35) {
+		return fmt.Errorf("invalid PlotSize: %d", job.PlotSize)
+	}
+	return nil
+}
+
+// newActivePlot creates the plot for the job, using config for everything the job leaves out.
+func (job *PlotJob) newActivePlot(config *Config) *ActivePlot {
+	plot := newActivePlot(config.forTarget(job.TargetDir), job.TempDir, job.TargetDir)
+	if job.PlotSize > 0 {
+		plot.PlotSize = job.PlotSize
+	}
+	if len(job.Fingerprint) > 0 || len(job.FarmerPublicKey) > 0 || len(job.PoolPublicKey) > 0 {
+		plot.Fingerprint = job.Fingerprint
+		plot.FarmerPublicKey = job.FarmerPublicKey
+		plot.PoolPublicKey = job.PoolPublicKey
+	}
+	if job.Threads > 0 {
+		plot.Threads = job.Threads
+	}
+	if job.Buffers > 0 {
+		plot.Buffers = job.Buffers
+	}
+	if len(job.Labels) > 0 {
+		plot.Labels = job.Labels
+	}
+	return plot
+}
+
+// startQueuedJob takes job off the queue and starts it, unless the quota of its customer is
+// fulfilled.  It reports whether the plot started.  The caller must hold server.lock.
+func (server *Server) startQueuedJob(config *Config, job *PlotJob) bool {
+	for i, queued := range server.queue {
+		if queued.JobId == job.JobId {
+			server.queue = append(server.queue[:i:i], server.queue[i+1:]...)
+			break
+		}
+	}
+	plot := job.newActivePlot(config)
+	if server.quotaFulfilled(config, plot.customer()) {
+		log.Printf("Dropping queued job %d, quota fulfilled", job.JobId)
+		return false
+	}
+	log.Printf("Starting queued job %d: %s -> %s", job.JobId, job.TempDir, job.TargetDir)
+	server.fitThreads(config, plot)
+	server.startPlot(config, plot)
+	return true
+}
+
+// changeJob moves, holds, releases or cancels a queued job.  The caller must hold server.lock.
+func (server *Server) changeJob(id int64, action string) error {
+	index := -1
+	for i, job := range server.queue {
+		if job.JobId == id {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("no queued job %d", id)
+	}
+	job := server.queue[index]
+	switch action {
+	case "up":
+		if index > 0 {
+			server.queue[index-1], server.queue[index] = job, server.queue[index-1]
+		}
+	case "down":
+		if index < len(server.queue)-1 {
+			server.queue[index+1], server.queue[index] = job, server.queue[index+1]
+		}
+	case "top":
+		copy(server.queue[1:index+1], server.queue[:index])
+		server.queue[0] = job
+	case "hold":
+		job.Held = true
+	case "release":
+		job.Held = false
+	case "cancel":
+		server.queue = append(server.queue[:index:index], server.queue[index+1:]...)
+	default:
+		return fmt.Errorf("unknown action: %s", action)
+	}
+	log.Printf("Job %d: %s", id, action)
+	return nil
+}
+
+// handleJobs lists the queued jobs on GET, queues a new job, given as JSON, on POST and changes
+// the job given by the id parameter on PUT, see changeJob for the actions.
+func (server *Server) handleJobs(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "PUT":
+		id, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(resp, "id and action parameters are required", http.StatusBadRequest)
+			return
+		}
+		server.lock.Lock()
+		err = server.changeJob(id, req.URL.Query().Get("action"))
+		server.lock.Unlock()
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fallthrough
+	case "GET":
+		server.lock.RLock()
+		data, err := json.Marshal(server.queue)
+		server.lock.RUnlock()
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(data)
+	case "POST":
+		var job PlotJob
+		if err := json.NewDecoder(req.Body).Decode(&job); err != nil {
+			http.Error(resp, fmt.Sprintf("Failed to decode job: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := job.validate(); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, dir := range []string{job.TempDir, job.TargetDir} {
+			if err := server.checkPath(dir); err != nil {
+				http.Error(resp, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		server.lock.Lock()
+		job.JobId = server.nextJobId
+		job.SubmitTime = time.Now()
+		server.nextJobId++
+		server.queue = append(server.queue, &job)
+		server.lock.Unlock()
+		log.Printf("Job %d queued: %s -> %s", job.JobId, job.TempDir, job.TargetDir)
+		resp.Header().Set("Content-Type", "application/json")
+		resp.WriteHeader(http.StatusCreated)
+		json.NewEncoder(resp).Encode(&job)
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keychainCacheTime is how long the keys listed by "chia keys show" are trusted.
+	keychainCacheTime = 5 * time.Minute
+	// keychainTimeout stops "chia keys show" waiting for a keyring passphrase.
+	keychainTimeout = 30 * time.Second
+)
+
+var keychainFingerprint = regexp.MustCompile(`(?m)^\s*Fingerprint:\s*(\d+)`)
+
+// keychain caches the keys of the chia keychain, so that the keys of every plot are checked
+// before it starts without running chia each time.
+type keychain struct {
+	lock   sync.Mutex
+	listed time.Time
+	keys   []string
+}
+
+// fingerprints returns the fingerprints of the keys of the keychain, listing them again when the
+// cached ones are too old.  Failures aren't cached.
+func (kc *keychain) fingerprints() ([]string, error) {
+	kc.lock.Lock()
+	defer kc.lock.Unlock()
+	if time.Since(kc.listed) < keychainCacheTime {
+		return kc.keys, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keychainTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "chia", "keys", "show").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("can't list the keys with chia keys show: %s %s", err, strings.TrimSpace(string(out)))
+	}
+	kc.keys = parseChiaFingerprints(string(out))
+	kc.listed = time.Now()
+	return kc.keys, nil
+}
+
+// parseChiaFingerprints extracts the fingerprints from the output of "chia keys show".
+func parseChiaFingerprints(out string) (fingerprints []string) {
+	for _, match := range keychainFingerprint.FindAllStringSubmatch(out, -1) {
+		fingerprints = append(fingerprints, match[1])
+	}
+	return
+}
+
+// checkPublicKey checks that key is the hex of a 48 byte BLS public key.
+func checkPublicKey(name string, key string) error {
+	decoded, err := hex.DecodeString(strings.TrimPrefix(key, "0x"))
+	if err != nil || len(decoded) != 48 {
+		return fmt.Errorf("invalid %s %s, expected 96 hex digits", name, key)
+	}
+	return nil
+}
+
+// checkKeys makes sure the plotter will find the keys of the plot, so that a plot with a missing
+// key fails before it starts instead of minutes in.  Without a fingerprint, chia plots for the
+// first key of the keychain unless it is given the farmer key and a pool key or contract.
+func (ap *ActivePlot) checkKeys() error {
+	if len(ap.FarmerPublicKey) > 0 {
+		if err := checkPublicKey("farmer public key", ap.FarmerPublicKey); err != nil {
+			return err
+		}
+	}
+	if len(ap.PoolPublicKey) > 0 {
+		if err := checkPublicKey("pool public key", ap.PoolPublicKey); err != nil {
+			return err
+		}
+	}
+	if ap.keychain == nil {
+		return nil
+	}
+	needsKeychain := len(ap.Fingerprint) > 0 || len(ap.FarmerPublicKey) == 0 || (len(ap.PoolPublicKey) == 0 && len(ap.PoolContract) == 0)
+	if !needsKeychain {
+		return nil
+	}
+	fingerprints, err := ap.keychain.fingerprints()
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(ap.Fingerprint) > 0:
+		for _, fingerprint := range fingerprints {
+			if fingerprint == ap.Fingerprint {
+				return nil
+			}
+		}
+		if len(fingerprints) == 0 {
+			return fmt.Errorf("fingerprint %s not found, no keys found in the chia keychain", ap.Fingerprint)
+		}
+		return fmt.Errorf("fingerprint %s not found in the chia keychain, which has %s", ap.Fingerprint, strings.Join(fingerprints, ", "))
+	case len(fingerprints) == 0 && len(ap.FarmerPublicKey) > 0:
+		return fmt.Errorf("no keys found in the chia keychain for the pool key, set PoolPublicKey or PoolContractAddress")
+	case len(fingerprints) == 0:
+		return fmt.Errorf("no keys found in the chia keychain, add one with chia keys add or set the keys of the plots")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package locale translates the strings of the UI.  The English strings are the keys of the
+// catalogs, so the code reads as before and a string missing from a catalog is shown in English.
+// To add a language, add a catalog to catalogs and its names to Resolve.
+package locale
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// catalogs holds the translations by language.
+var catalogs = map[string]map[string]string{
+	"zh-TW": zhTW,
+	"zh-CN": zhCN,
+}
+
+var (
+	lock     sync.RWMutex
+	language string
+	catalog  map[string]string
+)
+
+// Languages returns the languages with a catalog.
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Resolve returns the catalog for a language setting like "zh-TW" or a POSIX locale like
+// "zh_TW.UTF-8", "" for English, and whether the language is known.  Chinese defaults to
+// simplified characters, except for Taiwan, Hong Kong and Macau.
+func Resolve(lang string) (string, bool) {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	lang = strings.ReplaceAll(lang, "_", "-")
+	switch {
+	case lang == "", lang == "c", lang == "posix", lang == "en", strings.HasPrefix(lang, "en-"):
+		return "", true
+	case lang == "zh-tw", lang == "zh-hk", lang == "zh-mo", strings.HasPrefix(lang, "zh-hant"):
+		return "zh-TW", true
+	case lang == "zh", strings.HasPrefix(lang, "zh-"):
+		return "zh-CN", true
+	}
+	return "", false
+}
+
+// Environment returns the language of the messages from the environment, as the C library
+// picks it: LC_ALL, then LC_MESSAGES, then LANG.
+func Environment() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// SetLanguage translates the strings into lang from now on, as returned by Resolve.
+func SetLanguage(lang string) {
+	lock.Lock()
+	defer lock.Unlock()
+	language = lang
+	catalog = catalogs[lang]
+}
+
+// Language returns the language the strings are translated into, "" for English.
+func Language() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return language
+}
+
+// T returns the translation of text, or text when it isn't translated.
+func T(text string) string {
+	lock.RLock()
+	defer lock.RUnlock()
+	if translated, ok := catalog[text]; ok {
+		return translated
+	}
+	return text
+}
+
+// Sprintf formats the translation of the format string.
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
@@ -0,0 +1,216 @@
+package locale
+
+// zhCN is the catalog in simplified Chinese.
+var zhCN = map[string]string{
+	// Views and status bar
+	"Plots":        "绘图",
+	"Heatmap":      "热图",
+	"Statistics":   "统计",
+	"Quotas":       "配额",
+	"Orphans":      "孤立文件",
+	"Settings":     "设置",
+	"Distribution": "分布",
+	"Queue":        "队列",
+	"Compare":      "比较",
+	"Read-only":    "只读",
+	"Filter":       "筛选",
+	"Quit":         "退出",
+	"Add Job":      "添加任务",
+	"Layout: %s":   "布局：%s",
+	"Layout: +/- height  </> width  {/} move  z zoom  = reset  w workspace": "布局：+/- 高度  </> 宽度  {/} 移动  z 放大  = 重置  w 工作区",
+	"Times in %s":                        "时间为 %s",
+	"Label filter: %s":                   "标签筛选：%s",
+	"HALTED %s: %s, ^R to resume":        "已停止 %s：%s，^R 恢复",
+	"%s unreachable, %s, retrying in %s": "%s 无法连接，%s，%s 后重试",
+	"last seen %s ago":                   "%s 前最后连接",
+	"never seen":                         "从未连接",
+	"%s: maintenance [%s] until %s":      "%s：维护 [%s] 直到 %s",
+	"%s: maintenance [%s] in %s":         "%s：维护 [%s] 于 %s 后",
+	"Plot %s failed on %s":               "%[2]s 上的绘图 %[1]s 失败",
+	"%s is offline on %s":                "%[2]s 上的 %[1]s 已离线",
+
+	// Panes
+	"Active Plots":                 "进行中的绘图",
+	"Threads":                      "线程",
+	"%d queued":                    "%d 个排队中",
+	"+%d external":                 "+%d 个外部",
+	"Plot Directories":             "绘图目录",
+	"Dest Directories":             "目标目录",
+	"Archived Plots":               "已归档绘图",
+	"%d failed":                    "%d 个失败",
+	"Log":                          "日志",
+	"Log (error)":                  "日志（错误）",
+	"warnings/errors":              "警告/错误",
+	"phases/tables":                "阶段/表",
+	"by %s":                        "按%s",
+	"state":                        "状态",
+	"host":                         "主机",
+	"temp dir":                     "临时目录",
+	"Plots Completed per Hour":     "每小时完成的绘图",
+	"Plots/Day, %d Days":           "每日绘图，%d 天",
+	"today %d, avg %s":             "今天 %d，平均 %s",
+	"Temp Disk Usage, %d Hours":    "临时磁盘使用率，%d 小时",
+	"now %s":                       "当前 %s",
+	"Events":                       "事件",
+	"Plots per Key":                "每个密钥的绘图",
+	"Orphaned Temp Files":          "孤立临时文件",
+	"%d finished plots to recover": "%d 个完成的图待恢复",
+	"%s reclaimable":               "可回收 %s",
+	"r: reclaim selected, R: reclaim all on host": "r：回收所选，R：回收主机上全部",
+	"Queued Jobs": "排队任务",
+	"%d held":     "%d 个保留",
+	"u/d: move up/down, t: top, h: hold/release, c: cancel": "u/d：上移/下移，t：置顶，h：保留/释放，c：取消",
+	"Plot Distribution":                "绘图分布",
+	"%d plots":                         "%d 个绘图",
+	"Rebalance":                        "重新平衡",
+	"%d moves":                         "%d 次移动",
+	"p: plan, b: rebalance, c: cancel": "p：规划，b：重新平衡，c：取消",
+	"loading":                          "加载中",
+	"saving":                           "保存中",
+	"saved":                            "已保存",
+	"invalid %s":                       "无效的 %s",
+	"Add Drive":                        "添加磁盘",
+	"Filter Plots":                     "筛选绘图",
+	"Workspace":                        "工作区",
+	"Rendering":                        "渲染",
+	"Last":                             "最近",
+	"Mean":                             "平均",
+	"Max":                              "最大",
+	"Distinct":                         "不同值",
+	"Min":                              "最小",
+	"Total":                            "总计",
+	"Earliest":                         "最早",
+	"Latest":                           "最晚",
+	"Frame":                            "帧",
+	"Update":                           "更新",
+	"%d frames, %.1f/s":                "%d 帧，%.1f/秒",
+	"Table":                            "表格",
+	"Rows":                             "行数",
+	"Compare: A %s, B %s":              "比较：A %s，B %s",
+	"%d settings the same, d to show":  "%d 项设置相同，按 d 显示",
+	"change host":                      "切换主机",
+	"Configuration":                    "配置",
+	"Performance":                      "性能",
+	"Failures":                         "失败",
+	"Avg Phase 4 + Copy":               "平均阶段 4 + 复制",
+	"Rebuild":                          "重建",
+	"Draw":                             "绘制",
+	"round trip":                       "往返",
+	"transfer and decoding, %s":        "传输与解码，%s",
+
+	// Dialogs and messages
+	"Yes":                                   "是",
+	"No":                                    "否",
+	"This session is read-only.":            "此会话为只读。",
+	"Resume plotting on %s?":                "恢复 %s 上的绘图？",
+	"Resumed plotting on %s":                "已恢复 %s 上的绘图",
+	"Failed to resume plotting on %s: %s":   "无法恢复 %s 上的绘图：%s",
+	"Delete %s on %s?":                      "删除 %[2]s 上的 %[1]s？",
+	"Delete all orphaned temp files on %s?": "删除 %s 上所有孤立临时文件？",
+	"Reclaimed %s on %s":                    "已回收 %[2]s 上的 %[1]s",
+	"Failed to reclaim orphaned files on %s: %s": "无法回收 %s 上的孤立文件：%s",
+	"Cancel job %d on %s?":                       "取消 %[2]s 上的任务 %[1]d？",
+	"Failed to %s job %d on %s: %s":              "无法 %s %[3]s 上的任务 %[2]d：%[4]s",
+	"Job queued on %s: %s -> %s":                 "任务已加入 %s 的队列：%s -> %s",
+	"Failed to submit job to %s: %s":             "无法提交任务到 %s：%s",
+	"Move plots between the dest directories of %s to even out their fill levels?": "在 %s 的目标目录之间移动绘图，使使用量平均？",
+	"Rebalance on %s failed: %s":         "%s 的重新平衡失败：%s",
+	"No rebalance on %s":                 "%s 无需重新平衡",
+	"Rebalancing %s would move %d plots": "重新平衡 %s 将移动 %d 个绘图",
+	"Rebalance on %s: %d moves":          "%s 的重新平衡：%d 次移动",
+	"Disable %s on %s?":                  "停用 %[2]s 上的 %[1]s？",
+	"Enable %s on %s?":                   "启用 %[2]s 上的 %[1]s？",
+	"Disabled %s on %s":                  "已停用 %[2]s 上的 %[1]s",
+	"Enabled %s on %s":                   "已启用 %[2]s 上的 %[1]s",
+	"Failed to change %s on %s: %s":      "无法更改 %[2]s 上的 %[1]s：%[3]s",
+	"Stop using %s on %s and wait for its plots to finish before removing the drive?": "停止使用 %[2]s 上的 %[1]s，并在移除磁盘前等待其绘图完成？",
+	"Evacuating %s on %s, it is safe to remove when shown as such":                    "正在清空 %[2]s 上的 %[1]s，显示可移除时即可安全移除",
+	"Stop starting plots in %s on %s and notify once its plots are done?":             "停止在 %[2]s 上的 %[1]s 启动绘图，并在其绘图完成时通知？",
+	"Draining %s on %s, press d to enable it again once trimmed":                      "正在排空 %[2]s 上的 %[1]s，修整后按 d 重新启用",
+	"Added %s on %s":                      "已在 %[2]s 上添加 %[1]s",
+	"Failed to %s drive on %s: %s":        "无法 %s %[2]s 上的磁盘：%[3]s",
+	"Kill all %d active plots on %s?":     "终止 %[2]s 上全部 %[1]d 个进行中的绘图？",
+	"Queue the failed plots of %s again?": "将 %s 上失败的绘图重新加入队列？",
+	"Clear the archived plots of %s?":     "清除 %s 上的已归档绘图？",
+	"Killed %d plots on %s":               "已终止 %[2]s 上的 %[1]d 个绘图",
+	"Queued %d failed plots again on %s":  "已将 %[2]s 上 %[1]d 个失败的绘图重新加入队列",
+	"Cleared %d archived plots on %s":     "已清除 %[2]s 上 %[1]d 个已归档绘图",
+	"Bulk action on %s failed: %s":        "%s 上的批量操作失败：%s",
+
+	// Plot states and failures
+	"Queued":              "排队中",
+	"Checking space":      "检查空间",
+	"Running":             "运行中",
+	"Waiting to copy":     "等待复制",
+	"Copying":             "复制中",
+	"Verifying":           "验证中",
+	"Errored":             "错误",
+	"Finished":            "完成",
+	"Killed":              "已终止",
+	"External":            "外部",
+	"(suspended)":         "（已暂停）",
+	"Disk full":           "磁盘已满",
+	"Out of memory":       "内存不足",
+	"Plotter missing":     "找不到绘图程序",
+	"Invalid key":         "密钥无效",
+	"Copy failed":         "复制失败",
+	"Verification failed": "验证失败",
+	"Other":               "其他",
+
+	// Column headers
+	"#":               "#",
+	"Active":          "进行中",
+	"Available Space": "可用空间",
+	"Avg Phase 1":     "平均阶段 1",
+	"Avg Phase 2":     "平均阶段 2",
+	"Avg Phase 3":     "平均阶段 3",
+	"Avg Phase 4":     "平均阶段 4",
+	"Section":         "区段",
+	"Item":            "项目",
+	"Host A":          "主机 A",
+	"Host B":          "主机 B",
+	"Avg Plot Time":   "平均绘图时间",
+	"By PlotNG":       "由 PlotNG",
+	"Completed":       "已完成",
+	"Count":           "数量",
+	"Dest Dir":        "目标目录",
+	"Directory":       "目录",
+	"Duration":        "耗时",
+	"ETA":             "剩余时间",
+	"Elapsed":         "已用时间",
+	"End Time":        "结束时间",
+	"Failed":          "失败",
+	"Failure Rate":    "失败率",
+	"Failure":         "失败原因",
+	"File":            "文件",
+	"From":            "来源",
+	"Group":           "分组",
+	"Host":            "主机",
+	"Job":             "任务",
+	"Key":             "密钥",
+	"Labels":          "标签",
+	"Modified":        "修改时间",
+	"Name":            "名称",
+	"Phase":           "阶段",
+	"Plot Dir":        "绘图目录",
+	"Plot ID":         "绘图 ID",
+	"Plot Id":         "绘图 ID",
+	"Plot":            "绘图",
+	"Plots/Day":       "每日绘图",
+	"Pre-existing":    "既有",
+	"Progress":        "进度",
+	"Quota":           "配额",
+	"Remaining":       "剩余",
+	"Scanned":         "扫描时间",
+	"Size":            "大小",
+	"Start Time":      "开始时间",
+	"Started":         "已开始",
+	"State":           "状态",
+	"Status":          "状态",
+	"Submitted":       "提交时间",
+	"Temp Dir":        "临时目录",
+	"To":              "目标",
+	"Transfer":        "传输",
+	"Write Speed":     "写入速度",
+	"Written":         "已写入",
+}
@@ -0,0 +1,216 @@
+package locale
+
+// zhTW is the catalog in traditional Chinese.
+var zhTW = map[string]string{
+	// Views and status bar
+	"Plots":        "繪圖",
+	"Heatmap":      "熱圖",
+	"Statistics":   "統計",
+	"Quotas":       "配額",
+	"Orphans":      "孤立檔案",
+	"Settings":     "設定",
+	"Distribution": "分佈",
+	"Queue":        "佇列",
+	"Compare":      "比較",
+	"Read-only":    "唯讀",
+	"Filter":       "篩選",
+	"Quit":         "離開",
+	"Add Job":      "新增工作",
+	"Layout: %s":   "版面：%s",
+	"Layout: +/- height  </> width  {/} move  z zoom  = reset  w workspace": "版面：+/- 高度  </> 寬度  {/} 移動  z 放大  = 重設  w 工作區",
+	"Times in %s":                        "時間為 %s",
+	"Label filter: %s":                   "標籤篩選：%s",
+	"HALTED %s: %s, ^R to resume":        "已停止 %s：%s，^R 恢復",
+	"%s unreachable, %s, retrying in %s": "%s 無法連線，%s，%s 後重試",
+	"last seen %s ago":                   "%s 前最後連線",
+	"never seen":                         "從未連線",
+	"%s: maintenance [%s] until %s":      "%s：維護 [%s] 直到 %s",
+	"%s: maintenance [%s] in %s":         "%s：維護 [%s] 於 %s 後",
+	"Plot %s failed on %s":               "%[2]s 上的繪圖 %[1]s 失敗",
+	"%s is offline on %s":                "%[2]s 上的 %[1]s 已離線",
+
+	// Panes
+	"Active Plots":                 "進行中的繪圖",
+	"Threads":                      "執行緒",
+	"%d queued":                    "%d 個排隊中",
+	"+%d external":                 "+%d 個外部",
+	"Plot Directories":             "繪圖目錄",
+	"Dest Directories":             "目標目錄",
+	"Archived Plots":               "已封存繪圖",
+	"%d failed":                    "%d 個失敗",
+	"Log":                          "日誌",
+	"Log (error)":                  "日誌（錯誤）",
+	"warnings/errors":              "警告/錯誤",
+	"phases/tables":                "階段/表格",
+	"by %s":                        "依%s",
+	"state":                        "狀態",
+	"host":                         "主機",
+	"temp dir":                     "暫存目錄",
+	"Plots Completed per Hour":     "每小時完成的繪圖",
+	"Plots/Day, %d Days":           "每日繪圖，%d 天",
+	"today %d, avg %s":             "今天 %d，平均 %s",
+	"Temp Disk Usage, %d Hours":    "暫存磁碟使用率，%d 小時",
+	"now %s":                       "目前 %s",
+	"Events":                       "事件",
+	"Plots per Key":                "每個金鑰的繪圖",
+	"Orphaned Temp Files":          "孤立暫存檔",
+	"%d finished plots to recover": "%d 個完成的圖待恢復",
+	"%s reclaimable":               "可回收 %s",
+	"r: reclaim selected, R: reclaim all on host": "r：回收所選，R：回收主機上全部",
+	"Queued Jobs": "排隊工作",
+	"%d held":     "%d 個保留",
+	"u/d: move up/down, t: top, h: hold/release, c: cancel": "u/d：上移/下移，t：置頂，h：保留/釋放，c：取消",
+	"Plot Distribution":                "繪圖分佈",
+	"%d plots":                         "%d 個繪圖",
+	"Rebalance":                        "重新平衡",
+	"%d moves":                         "%d 次移動",
+	"p: plan, b: rebalance, c: cancel": "p：規劃，b：重新平衡，c：取消",
+	"loading":                          "載入中",
+	"saving":                           "儲存中",
+	"saved":                            "已儲存",
+	"invalid %s":                       "無效的 %s",
+	"Add Drive":                        "新增磁碟",
+	"Filter Plots":                     "篩選繪圖",
+	"Workspace":                        "工作區",
+	"Rendering":                        "繪製",
+	"Last":                             "最近",
+	"Mean":                             "平均",
+	"Max":                              "最大",
+	"Distinct":                         "不同值",
+	"Min":                              "最小",
+	"Total":                            "總計",
+	"Earliest":                         "最早",
+	"Latest":                           "最晚",
+	"Frame":                            "畫面",
+	"Update":                           "更新",
+	"%d frames, %.1f/s":                "%d 個畫面，%.1f/秒",
+	"Table":                            "表格",
+	"Rows":                             "列數",
+	"Compare: A %s, B %s":              "比較：A %s，B %s",
+	"%d settings the same, d to show":  "%d 項設定相同，按 d 顯示",
+	"change host":                      "切換主機",
+	"Configuration":                    "設定",
+	"Performance":                      "效能",
+	"Failures":                         "失敗",
+	"Avg Phase 4 + Copy":               "平均階段 4 + 複製",
+	"Rebuild":                          "重建",
+	"Draw":                             "繪製",
+	"round trip":                       "往返",
+	"transfer and decoding, %s":        "傳輸與解碼，%s",
+
+	// Dialogs and messages
+	"Yes":                                   "是",
+	"No":                                    "否",
+	"This session is read-only.":            "此連線為唯讀。",
+	"Resume plotting on %s?":                "恢復 %s 上的繪圖？",
+	"Resumed plotting on %s":                "已恢復 %s 上的繪圖",
+	"Failed to resume plotting on %s: %s":   "無法恢復 %s 上的繪圖：%s",
+	"Delete %s on %s?":                      "刪除 %[2]s 上的 %[1]s？",
+	"Delete all orphaned temp files on %s?": "刪除 %s 上所有孤立暫存檔？",
+	"Reclaimed %s on %s":                    "已回收 %[2]s 上的 %[1]s",
+	"Failed to reclaim orphaned files on %s: %s": "無法回收 %s 上的孤立檔案：%s",
+	"Cancel job %d on %s?":                       "取消 %[2]s 上的工作 %[1]d？",
+	"Failed to %s job %d on %s: %s":              "無法 %s %[3]s 上的工作 %[2]d：%[4]s",
+	"Job queued on %s: %s -> %s":                 "工作已排入 %s：%s -> %s",
+	"Failed to submit job to %s: %s":             "無法提交工作到 %s：%s",
+	"Move plots between the dest directories of %s to even out their fill levels?": "在 %s 的目標目錄之間移動繪圖，使使用量平均？",
+	"Rebalance on %s failed: %s":         "%s 的重新平衡失敗：%s",
+	"No rebalance on %s":                 "%s 不需重新平衡",
+	"Rebalancing %s would move %d plots": "重新平衡 %s 將移動 %d 個繪圖",
+	"Rebalance on %s: %d moves":          "%s 的重新平衡：%d 次移動",
+	"Disable %s on %s?":                  "停用 %[2]s 上的 %[1]s？",
+	"Enable %s on %s?":                   "啟用 %[2]s 上的 %[1]s？",
+	"Disabled %s on %s":                  "已停用 %[2]s 上的 %[1]s",
+	"Enabled %s on %s":                   "已啟用 %[2]s 上的 %[1]s",
+	"Failed to change %s on %s: %s":      "無法變更 %[2]s 上的 %[1]s：%[3]s",
+	"Stop using %s on %s and wait for its plots to finish before removing the drive?": "停止使用 %[2]s 上的 %[1]s，並在移除磁碟前等待其繪圖完成？",
+	"Evacuating %s on %s, it is safe to remove when shown as such":                    "正在清空 %[2]s 上的 %[1]s，顯示可移除時即可安全移除",
+	"Stop starting plots in %s on %s and notify once its plots are done?":             "停止在 %[2]s 上的 %[1]s 啟動繪圖，並在其繪圖完成時通知？",
+	"Draining %s on %s, press d to enable it again once trimmed":                      "正在排空 %[2]s 上的 %[1]s，修整後按 d 重新啟用",
+	"Added %s on %s":                      "已在 %[2]s 上新增 %[1]s",
+	"Failed to %s drive on %s: %s":        "無法 %s %[2]s 上的磁碟：%[3]s",
+	"Kill all %d active plots on %s?":     "終止 %[2]s 上全部 %[1]d 個進行中的繪圖？",
+	"Queue the failed plots of %s again?": "將 %s 上失敗的繪圖重新排入佇列？",
+	"Clear the archived plots of %s?":     "清除 %s 上的已封存繪圖？",
+	"Killed %d plots on %s":               "已終止 %[2]s 上的 %[1]d 個繪圖",
+	"Queued %d failed plots again on %s":  "已將 %[2]s 上 %[1]d 個失敗的繪圖重新排入佇列",
+	"Cleared %d archived plots on %s":     "已清除 %[2]s 上 %[1]d 個已封存繪圖",
+	"Bulk action on %s failed: %s":        "%s 上的批次操作失敗：%s",
+
+	// Plot states and failures
+	"Queued":              "排隊中",
+	"Checking space":      "檢查空間",
+	"Running":             "執行中",
+	"Waiting to copy":     "等待複製",
+	"Copying":             "複製中",
+	"Verifying":           "驗證中",
+	"Errored":             "錯誤",
+	"Finished":            "完成",
+	"Killed":              "已終止",
+	"External":            "外部",
+	"(suspended)":         "（已暫停）",
+	"Disk full":           "磁碟已滿",
+	"Out of memory":       "記憶體不足",
+	"Plotter missing":     "找不到繪圖程式",
+	"Invalid key":         "金鑰無效",
+	"Copy failed":         "複製失敗",
+	"Verification failed": "驗證失敗",
+	"Other":               "其他",
+
+	// Column headers
+	"#":               "#",
+	"Active":          "進行中",
+	"Available Space": "可用空間",
+	"Avg Phase 1":     "平均階段 1",
+	"Avg Phase 2":     "平均階段 2",
+	"Avg Phase 3":     "平均階段 3",
+	"Avg Phase 4":     "平均階段 4",
+	"Section":         "區段",
+	"Item":            "項目",
+	"Host A":          "主機 A",
+	"Host B":          "主機 B",
+	"Avg Plot Time":   "平均繪圖時間",
+	"By PlotNG":       "由 PlotNG",
+	"Completed":       "已完成",
+	"Count":           "數量",
+	"Dest Dir":        "目標目錄",
+	"Directory":       "目錄",
+	"Duration":        "耗時",
+	"ETA":             "剩餘時間",
+	"Elapsed":         "已用時間",
+	"End Time":        "結束時間",
+	"Failed":          "失敗",
+	"Failure Rate":    "失敗率",
+	"Failure":         "失敗原因",
+	"File":            "檔案",
+	"From":            "來源",
+	"Group":           "群組",
+	"Host":            "主機",
+	"Job":             "工作",
+	"Key":             "金鑰",
+	"Labels":          "標籤",
+	"Modified":        "修改時間",
+	"Name":            "名稱",
+	"Phase":           "階段",
+	"Plot Dir":        "繪圖目錄",
+	"Plot ID":         "繪圖 ID",
+	"Plot Id":         "繪圖 ID",
+	"Plot":            "繪圖",
+	"Plots/Day":       "每日繪圖",
+	"Pre-existing":    "既有",
+	"Progress":        "進度",
+	"Quota":           "配額",
+	"Remaining":       "剩餘",
+	"Scanned":         "掃描時間",
+	"Size":            "大小",
+	"Start Time":      "開始時間",
+	"Started":         "已開始",
+	"State":           "狀態",
+	"Status":          "狀態",
+	"Submitted":       "提交時間",
+	"Temp Dir":        "暫存目錄",
+	"To":              "目的地",
+	"Transfer":        "傳輸",
+	"Write Speed":     "寫入速度",
+	"Written":         "已寫入",
+}
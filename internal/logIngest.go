@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// logQueueSize is the number of plotter log lines waiting to be applied to a plot.  When the queue
+// is full plain lines are dropped, lines the parser found something in always get through.
+const logQueueSize = 1000
+
+// logBatchSize is the most log lines applied to a plot under one lock.
+const logBatchSize = 100
+
+// tailSize is the number of log lines kept on the plot for the UI.
+const tailSize = 20
+
+type logLine struct {
+	text  string
+	time  time.Time
+	event LogEvent
+}
+
+// significant reports whether the line changes the plot, such lines are never dropped.
+func (event *LogEvent) significant() bool {
+	return event.Phase > 0 || len(event.PlotId) > 0 || len(event.Progress) > 0 || len(event.Error) > 0 || event.Audit != PlotAudit{} || event.CopySeconds > 0
+}
+
+// processLogs follows the output of chia until the pipe is closed or ctx is cancelled.  Lines are
+// parsed as they are read and applied to the plot in batches by applyLogs, so a plotter writing
+// faster than the plot can be updated loses plain lines instead of being slowed down by the lock.
+func (ap *ActivePlot) processLogs(ctx context.Context, in io.ReadCloser) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			in.Close() // children of chia can keep the pipe open after chia is killed
+		case <-done:
+		}
+	}()
+
+	lines := make(chan logLine, logQueueSize)
+	finished := make(chan struct{})
+	var dropped uint64
+	go ap.applyLogs(lines, &dropped, finished)
+
+	reader := bufio.NewReader(in)
+	parser := getLogParser(ap.Plotter)
+	for {
+		s, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line := logLine{text: s, time: time.Now(), event: parser.ParseLine(s)}
+		if line.event.significant() {
+			lines <- line
+			continue
+		}
+		select {
+		case lines <- line:
+		default:
+			atomic.AddUint64(&dropped, 1)
+		}
+	}
+	close(lines)
+	<-finished
+}
+
+// applyLogs applies the queued lines to the plot, taking as many as are waiting at once.
+func (ap *ActivePlot) applyLogs(lines <-chan logLine, dropped *uint64, finished chan<- struct{}) {
+	defer close(finished)
+	var logFile *os.File
+	var reported uint64
+	batch := make([]logLine, 0, logBatchSize)
+	for line := range lines {
+		batch = append(batch[:0], line)
+	drain:
+		for len(batch) < logBatchSize {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, line)
+			default:
+				break drain
+			}
+		}
+		total := atomic.LoadUint64(dropped)
+		logFile = ap.applyLogBatch(batch, total-reported, logFile)
+		reported = total
+	}
+	if total := atomic.LoadUint64(dropped); total > reported {
+		logFile = ap.applyLogBatch(nil, total-reported, logFile)
+	}
+	if logFile != nil {
+		logFile.Close()
+	}
+}
+
+// annotate prefixes a log line with the time it was read and the time since the plot started.
+// Lines starting a phase or a table also get the time since the previous such line, which is how
+// long the previous table took.  The caller must hold ap.lock.
+func (ap *ActivePlot) annotate(line logLine) string {
+	prefix := line.time.Format("15:04:05")
+	if !ap.StartTime.IsZero() {
+		prefix += " +" + format.Duration(line.time.Sub(ap.StartTime))
+	}
+	if line.event.Phase > 0 || len(line.event.Progress) > 0 {
+		if !ap.lastStep.IsZero() {
+			prefix += " step " + format.Duration(line.time.Sub(ap.lastStep))
+		}
+		ap.lastStep = line.time
+	}
+	return fmt.Sprintf("[%s] %s", prefix, line.text)
+}
+
+// applyLogBatch updates the plot from batch, noting newly dropped lines in the tail.  It returns
+// the plot log file, which is created once the plot ID is known.
+func (ap *ActivePlot) applyLogBatch(batch []logLine, dropped uint64, logFile *os.File) *os.File {
+	var text []string
+	if dropped > 0 {
+		text = append(text, fmt.Sprintf("... %d log lines dropped, the plotter wrote faster than they could be processed\n", dropped))
+	}
+	var phases []string
+	var newId string
+	var before []string
+	ap.lock.Lock()
+	if len(ap.SavePlotLogDir) > 0 && logFile == nil {
+		before = append(before, ap.Tail...)
+	}
+	ap.DroppedLogLines += dropped
+	ap.changed()
+	for _, line := range batch {
+		event := line.event
+		if event.Phase > 0 {
+			phase := fmt.Sprintf("%d/4", event.Phase)
+			if phase != ap.Phase {
+				phases = append(phases, phase)
+			}
+			ap.Phase = phase
+			switch event.Phase {
+			case 2:
+				ap.Phase1Time = time.Now()
+			case 3:
+				ap.Phase2Time = time.Now()
+			case 4:
+				ap.Phase3Time = time.Now()
+			}
+		}
+		if len(event.PlotId) > 0 {
+			ap.Id = event.PlotId
+			newId = event.PlotId
+		}
+		if len(event.Progress) > 0 {
+			ap.Progress = event.Progress
+		}
+		if len(event.Error) > 0 {
+			ap.LastError = event.Error
+		}
+		if event.CopySeconds > 0 {
+			ap.writeSpeeds.record(ap.TargetDir, expectedPlotSize(ap.PlotSize), time.Duration(event.CopySeconds*float64(time.Second)), line.time)
+		}
+		ap.Audit.merge(event.Audit)
+		ap.checkAudit(event.Audit)
+		text = append(text, ap.annotate(line))
+	}
+	ap.Tail = append(ap.Tail, text...)
+	if len(ap.Tail) > tailSize {
+		ap.Tail = append([]string(nil), ap.Tail[len(ap.Tail)-tailSize:]...)
+	}
+	ap.recentLog = append(ap.recentLog, text...)
+	if len(ap.recentLog) > failureReportLines {
+		ap.recentLog = append([]string(nil), ap.recentLog[len(ap.recentLog)-failureReportLines:]...)
+	}
+	ap.lock.Unlock()
+
+	if len(newId) > 0 && len(ap.SavePlotLogDir) > 0 && logFile == nil {
+		logFilePath := filepath.Join(ap.SavePlotLogDir, fmt.Sprintf("plotng_log_%s.txt", newId))
+		var err error
+		if logFile, err = os.Create(logFilePath); err != nil {
+			log.Printf("Failed to create plot log %s: %s", logFilePath, err)
+			logFile = nil
+		} else {
+			for _, l := range before {
+				logFile.Write([]byte(l))
+			}
+		}
+	}
+	if logFile != nil {
+		for _, l := range text {
+			logFile.Write([]byte(l))
+		}
+	}
+	for _, phase := range phases {
+		ap.events.publish(Event{Type: EventPhaseChanged, Plot: ap, Phase: phase})
+	}
+	return logFile
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LogEvent is the information a LogParser found in one line of plotter output.  Fields are left
+// at their zero value when the line doesn't carry that information.
+type LogEvent struct {
+	Phase    int    // phase which just started, 1 to 4
+	Progress string // overall progress reached eg. "42%"
+	PlotId   string
+	Error    string
+	Audit    PlotAudit // the fields found on this line
+
+	CopySeconds float64 // time the plotter took to copy the final plot to the dest directory
+}
+
+// PlotAudit records the keys and memo a plot was created with, as reported by the plotter, so
+// it can be verified later that plots were made for the intended farmer and pool.
+type PlotAudit struct {
+	FarmerPublicKey string
+	PoolPublicKey   string
+	PoolContract    string
+	Memo            string
+}
+
+// merge copies the fields set in other.
+func (pa *PlotAudit) merge(other PlotAudit) {
+	if len(other.FarmerPublicKey) > 0 {
+		pa.FarmerPublicKey = other.FarmerPublicKey
+	}
+	if len(other.PoolPublicKey) > 0 {
+		pa.PoolPublicKey = other.PoolPublicKey
+	}
+	if len(other.PoolContract) > 0 {
+		pa.PoolContract = other.PoolContract
+	}
+	if len(other.Memo) > 0 {
+		pa.Memo = other.Memo
+	}
+}
+
+// LogParser extracts the plot state from the output of a plotter backend, so a plotter with a
+// different log format only needs a new parser registered with RegisterLogParser.
+type LogParser interface {
+	ParseLine(line string) LogEvent
+}
+
+var (
+	logParsersLock sync.RWMutex
+	logParsers     = map[string]LogParser{}
+)
+
+// RegisterLogParser makes parser available to plots whose Plotter is name.
+func RegisterLogParser(name string, parser LogParser) {
+	logParsersLock.Lock()
+	logParsers[name] = parser
+	logParsersLock.Unlock()
+}
+
+// getLogParser returns the parser registered for plotter, falling back to the chia parser.
+func getLogParser(plotter string) LogParser {
+	logParsersLock.RLock()
+	defer logParsersLock.RUnlock()
+	if len(plotter) == 0 {
+		plotter = "chia"
+	}
+	if parser, ok := logParsers[plotter]; ok {
+		return parser
+	}
+	log.Printf("No log parser for plotter [%s], using chia", plotter)
+	return logParsers["chia"]
+}
+
+func init() {
+	RegisterLogParser("chia", &chiaLogParser{})
+}
+
+var (
+	chiaPhaseRegexp    = regexp.MustCompile(`^\s*Starting phase (\d)/4`)
+	chiaIdRegexp       = regexp.MustCompile(`^\s*ID: ([0-9a-fA-F]+)\s*$`)
+	chiaProgressRegexp = regexp.MustCompile(`^\s*(Computing table \d|Backpropagating on table \d|Compressing tables \d and \d|Write checkpoint tables)`)
+	chiaErrorRegexp    = regexp.MustCompile(`^\s*(Error|Exception|Traceback|Caught plotting error)|\bERROR\b`)
+	chiaAuditRegexp    = regexp.MustCompile(`(Farmer public key|Pool public key|Pool contract address|Memo):\s*(\S+)\s*$`)
+	chiaCopyTimeRegexp = regexp.MustCompile(`^\s*Copy time = ([\d.]+) seconds`)
+)
+
+// chiaLogParser understands the output of "chia plots create".  The phase, ID, progress and copy
+// time markers are anchored at the start of the line so a line which only quotes one, like an
+// error, is never taken for it, and trailing whitespace including the "\r" of Windows line endings
+// is ignored.
+type chiaLogParser struct{}
+
+func (p *chiaLogParser) ParseLine(line string) (event LogEvent) {
+	line = strings.TrimRight(line, "\r\n")
+	if match := chiaPhaseRegexp.FindStringSubmatch(line); match != nil {
+		event.Phase, _ = strconv.Atoi(match[1])
+	}
+	if match := chiaIdRegexp.FindStringSubmatch(line); match != nil {
+		event.PlotId = match[1]
+	}
+	if match := chiaProgressRegexp.FindStringSubmatch(line); match != nil {
+		event.Progress = progressTable[match[1]]
+	}
+	if chiaErrorRegexp.MatchString(line) {
+		event.Error = strings.TrimSpace(line)
+	}
+	if match := chiaCopyTimeRegexp.FindStringSubmatch(line); match != nil {
+		event.CopySeconds, _ = strconv.ParseFloat(match[1], 64)
+	}
+	if match := chiaAuditRegexp.FindStringSubmatch(line); match != nil {
+		switch match[1] {
+		case "Farmer public key":
+			event.Audit.FarmerPublicKey = match[2]
+		case "Pool public key":
+			event.Audit.PoolPublicKey = match[2]
+		case "Pool contract address":
+			event.Audit.PoolContract = match[2]
+		case "Memo":
+			event.Audit.Memo = match[2]
+		}
+	}
+	return
+}
+
+/*
+Progress from Chia docs
+https://github.com/Chia-Network/chia-blockchain/wiki/Beginners-Guide#create-a-plot
+*/
+var progressTable = map[string]string{
+	"Computing table 1":          "1%",
+	"Computing table 2":          "6%",
+	"Computing table 3":          "12%",
+	"Computing table 4":          "20%",
+	"Computing table 5":          "28%",
+	"Computing table 6":          "36%",
+	"Computing table 7":          "42%",
+	"Backpropagating on table 7": "43%",
+	"Backpropagating on table 6": "48%",
+	"Backpropagating on table 5": "51%",
+	"Backpropagating on table 4": "55%",
+	"Backpropagating on table 3": "58%",
+	"Backpropagating on table 2": "61%",
+	"Compressing tables 1 and 2": "66%",
+	"Compressing tables 2 and 3": "73%",
+	"Compressing tables 3 and 4": "79%",
+	"Compressing tables 4 and 5": "85%",
+	"Compressing tables 5 and 6": "92%",
+	"Compressing tables 6 and 7": "98%",
+	"Write checkpoint tables":    "100%",
+}
@@ -0,0 +1,63 @@
+package internal
+
+import "testing"
+
+func TestChiaLogParser(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want LogEvent
+	}{
+		// header
+		{"plot id", "ID: 3d8f2b0a9c6e4f17b5a2d1c0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0", LogEvent{PlotId: "3d8f2b0a9c6e4f17b5a2d1c0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0"}},
+		{"plot id with CRLF", "ID: 3d8f2b0a9c6e4f17\r\n", LogEvent{PlotId: "3d8f2b0a9c6e4f17"}},
+		{"plot size", "Plot size is: 32", LogEvent{}},
+		{"buffer size", "Buffer size is: 3389MiB", LogEvent{}},
+		{"farmer key", "2021-06-07T10:00:00.123 chia.plotting.create_plots       : INFO     Farmer public key: 8f5a1b2c3d4e", LogEvent{Audit: PlotAudit{FarmerPublicKey: "8f5a1b2c3d4e"}}},
+		{"pool key", "2021-06-07T10:00:00.123 chia.plotting.create_plots       : INFO     Pool public key:   a1b2c3d4e5f6", LogEvent{Audit: PlotAudit{PoolPublicKey: "a1b2c3d4e5f6"}}},
+		{"pool contract", "2021-07-07T10:00:00.123 chia.plotting.create_plots       : INFO     Pool contract address: xch1qyqszqgpqyqszqgp", LogEvent{Audit: PlotAudit{PoolContract: "xch1qyqszqgpqyqszqgp"}}},
+		{"memo", "2021-06-07T10:00:00.123 chia.plotting.create_plots       : INFO     Memo: 0123abcd", LogEvent{Audit: PlotAudit{Memo: "0123abcd"}}},
+
+		// phase 1
+		{"phase 1", "Starting phase 1/4: Forward Propagation into tmp files... Mon Jun  7 10:00:00 2021", LogEvent{Phase: 1}},
+		{"table 1", "Computing table 1", LogEvent{Progress: "1%"}},
+		{"f1 complete", "F1 complete, time: 171.542 seconds. CPU (98.95%) Mon Jun  7 10:02:51 2021", LogEvent{}},
+		{"table 2", "Computing table 2", LogEvent{Progress: "6%"}},
+		{"bucket", "\tBucket 0 uniform sort. Ram: 3.250GiB, u_sort min: 0.563GiB, qs min: 0.281GiB.", LogEvent{}},
+		{"table 7", "Computing table 7", LogEvent{Progress: "42%"}},
+		{"phase 1 time", "Time for phase 1 = 9876.543 seconds. CPU (186.48%) Mon Jun  7 12:44:36 2021", LogEvent{}},
+
+		// phase 2
+		{"phase 2", "Starting phase 2/4: Backpropagation into tmp files... Mon Jun  7 12:44:36 2021", LogEvent{Phase: 2}},
+		{"backpropagating 7", "Backpropagating on table 7", LogEvent{Progress: "43%"}},
+		{"scanned", "scanned table 7", LogEvent{}},
+		{"backpropagating 2", "Backpropagating on table 2", LogEvent{Progress: "61%"}},
+
+		// phase 3
+		{"phase 3", `Starting phase 3/4: Compression from tmp files into "/mnt/tmp/plot-k32-2021-06-07-10-00-3d8f.plot.2.tmp" ... Mon Jun  7 13:55:12 2021`, LogEvent{Phase: 3}},
+		{"compressing 1 and 2", "Compressing tables 1 and 2", LogEvent{Progress: "66%"}},
+		{"compressing 6 and 7", "Compressing tables 6 and 7", LogEvent{Progress: "98%"}},
+
+		// phase 4
+		{"phase 4", `Starting phase 4/4: Write Checkpoint tables into "/mnt/tmp/plot-k32-2021-06-07-10-00-3d8f.plot.2.tmp" ... Mon Jun  7 16:20:01 2021`, LogEvent{Phase: 4}},
+		{"c1 and c3", "\tStarting to write C1 and C3 tables", LogEvent{}},
+		{"checkpoint tables", "\tWrite checkpoint tables", LogEvent{Progress: "100%"}},
+		{"total time", "Total time = 23456.789 seconds. CPU (160.11%) Mon Jun  7 16:31:00 2021", LogEvent{}},
+		{"copy time", "Copy time = 612.345 seconds. CPU (5.12%) Mon Jun  7 16:41:12 2021", LogEvent{CopySeconds: 612.345}},
+		{"renamed", `Renamed final file from "/mnt/dst/plot-k32.plot.2.tmp" to "/mnt/dst/plot-k32.plot"`, LogEvent{}},
+
+		// errors and lines which only quote a marker
+		{"exception", "Exception: Not enough space on /mnt/tmp", LogEvent{Error: "Exception: Not enough space on /mnt/tmp"}},
+		{"traceback", "Traceback (most recent call last):", LogEvent{Error: "Traceback (most recent call last):"}},
+		{"caught error", "Caught plotting error: bad allocation", LogEvent{Error: "Caught plotting error: bad allocation"}},
+		{"logged error", "2021-06-07T10:00:00.123 chia.plotting.create_plots       : ERROR    Disk full while Computing table 3", LogEvent{Error: "2021-06-07T10:00:00.123 chia.plotting.create_plots       : ERROR    Disk full while Computing table 3"}},
+		{"quoted phase", "Retrying after Starting phase 2/4 failed", LogEvent{}},
+		{"quoted copy time", "Previous Copy time = 12 seconds", LogEvent{}},
+	}
+	parser := getLogParser("chia")
+	for _, test := range tests {
+		if got := parser.ParseLine(test.line); got != test.want {
+			t.Errorf("%s: ParseLine(%q) = %+v, want %+v", test.name, test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// MaintenanceWindow is a recurring time, eg. for backups or updates, in which no new plots start.
+// Start and End are given as "15:04" in local time and may wrap around midnight, Days lists the
+// days the window starts on as "Mon" to "Sun", all days when empty.  With Suspend the running
+// plots are stopped for the duration of the window too.
+type MaintenanceWindow struct {
+	Name    string
+	Days    []string
+	Start   string
+	End     string
+	Suspend bool
+}
+
+// MaintenanceStatus is the current or next maintenance window of a plotter, sent to the UI.
+type MaintenanceStatus struct {
+	Name   string
+	Active bool
+	Start  time.Time
+	End    time.Time
+}
+
+func (mw *MaintenanceWindow) validate() error {
+	start, err1 := time.Parse("15:04", mw.Start)
+	end, err2 := time.Parse("15:04", mw.End)
+	if err1 != nil || err2 != nil || start.Equal(end) {
+		return fmt.Errorf("maintenance window [%s] needs a Start and End like \"02:00\"", mw.Name)
+	}
+	for _, day := range mw.Days {
+		if _, ok := weekdays[day]; !ok {
+			return fmt.Errorf("maintenance window [%s] has an invalid day [%s], use Mon to Sun", mw.Name, day)
+		}
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday, "Mon": time.Monday, "Tue": time.Tuesday, "Wed": time.Wednesday,
+	"Thu": time.Thursday, "Fri": time.Friday, "Sat": time.Saturday,
+}
+
+// occurrence returns the window starting on the day of day, and false if it doesn't start that day.
+func (mw *MaintenanceWindow) occurrence(day time.Time) (start time.Time, end time.Time, ok bool) {
+	from, err1 := time.Parse("15:04", mw.Start)
+	to, err2 := time.Parse("15:04", mw.End)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	if len(mw.Days) > 0 {
+		found := false
+		for _, name := range mw.Days {
+			if weekdays[name] == day.Weekday() {
+				found = true
+			}
+		}
+		if !found {
+			return
+		}
+	}
+	start = time.Date(day.Year(), day.Month(), day.Day(), from.Hour(), from.Minute(), 0, 0, day.Location())
+	end = time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), 0, 0, day.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+// maintenanceStatus returns the window active at now, or else the next one to start.
+func maintenanceStatus(windows []MaintenanceWindow, now time.Time) (status MaintenanceStatus, window *MaintenanceWindow) {
+	for i := range windows {
+		for offset := -1; offset <= 7; offset++ {
+			start, end, ok := windows[i].occurrence(now.AddDate(0, 0, offset))
+			if !ok {
+				continue
+			}
+			if !now.Before(start) && now.Before(end) {
+				return MaintenanceStatus{Name: windows[i].Name, Active: true, Start: start, End: end}, &windows[i]
+			}
+			if start.After(now) && (status.Start.IsZero() || start.Before(status.Start)) {
+				status = MaintenanceStatus{Name: windows[i].Name, Start: start, End: end}
+			}
+		}
+	}
+	return status, nil
+}
+
+// suspendPlotter suspends or resumes the plotter process.
+func (ap *ActivePlot) suspendPlotter(suspend bool) error {
+	ap.lock.RLock()
+	pid := ap.Pid
+	ap.lock.RUnlock()
+	if pid <= 0 {
+		return fmt.Errorf("plotter not running")
+	}
+	return suspendProcess(pid, suspend)
+}
+
+// checkMaintenance pauses new plots during the maintenance windows, and suspends the running plots
+// for the windows asking for it until the window ends.
+func (server *Server) checkMaintenance(config *Config, now time.Time) {
+	status, window := maintenanceStatus(config.MaintenanceWindows, now)
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.maintenance = status
+	active := window != nil
+	if active == server.inMaintenance {
+		return
+	}
+	server.inMaintenance = active
+	if active {
+		server.schedulerEvent("Maintenance window [%s] started, no new plots until %s", status.Name, status.End.Format("15:04"))
+		if !window.Suspend {
+			return
+		}
+		for _, plot := range server.active {
+			if plot.currentState() != PlotRunning {
+				continue
+			}
+			if err := plot.suspendPlotter(true); err != nil {
+				log.Printf("Plot [%d] failed to suspend: %s", plot.PlotId, err)
+				continue
+			}
+			plot.setSuspended(true)
+			server.suspended = append(server.suspended, plot)
+		}
+		return
+	}
+	server.schedulerEvent("Maintenance window ended, resuming plots")
+	for _, plot := range server.suspended {
+		if err := plot.suspendPlotter(false); err != nil {
+			log.Printf("Plot [%d] failed to resume: %s", plot.PlotId, err)
+		}
+		plot.setSuspended(false)
+	}
+	server.suspended = nil
+}
+
+func (ap *ActivePlot) setSuspended(suspended bool) {
+	ap.lock.Lock()
+	ap.Suspended = suspended
+	ap.changed()
+	ap.lock.Unlock()
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// availableMemory returns the memory available for new processes without swapping, MemAvailable
+// from the Linux /proc/meminfo.
+func availableMemory() (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid MemAvailable: %s", fields[1])
+			}
+			return kb * KB, nil
+		}
+	}
+	return 0, fmt.Errorf("no MemAvailable in /proc/meminfo")
+}
+
+// memoryLow reports whether new plots should be held back because less than MinFreeMemory is
+// available, so a new plot doesn't get the plotter killed by the OOM killer.
+func (server *Server) memoryLow(config *Config) bool {
+	if config.MinFreeMemory <= 0 {
+		server.memoryPaused = false
+		return false
+	}
+	available, err := availableMemory()
+	if err != nil {
+		if !server.memoryPaused {
+			log.Printf("Failed to read the available memory, MinFreeMemory is ignored: %s", err)
+		}
+		return false
+	}
+	low := available < uint64(config.MinFreeMemory)*MB
+	if low != server.memoryPaused {
+		if low {
+			server.schedulerEvent("Pausing new plots, only %d MiB of memory available, MinFreeMemory is %d MiB", available/MB, config.MinFreeMemory)
+		} else {
+			server.schedulerEvent("Resuming new plots, %d MiB of memory available", available/MB)
+		}
+		server.memoryPaused = low
+	}
+	return low
+}
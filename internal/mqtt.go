@@ -0,0 +1,414 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMqttTopic          = "plotng"
+	defaultMqttDiscoveryTopic = "homeassistant"
+	mqttKeepAlive             = 3 * time.Minute // the state is published every minute
+	mqttTimeout               = 10 * time.Second
+)
+
+// mqttConnectErrors are the reasons a broker refuses a connection, by CONNACK return code.
+var mqttConnectErrors = []string{"", "unacceptable protocol version", "client identifier rejected", "server unavailable", "bad user name or password", "not authorized"}
+
+// mqttSlug turns a host name or a directory into a part of a topic or an object ID.
+var mqttSlug = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// mqttClient publishes to an MQTT 3.1.1 broker at QoS 0, which is all the status updates need.
+// It is safe to use from several goroutines.
+type mqttClient struct {
+	conn net.Conn
+	lock sync.Mutex
+}
+
+// mqttBrokerAddress returns the address of the broker and whether it uses TLS, for a broker given
+// as host:port, tcp://host:port, mqtt://host:port or ssl:// / tls:// / mqtts://host:port.
+func mqttBrokerAddress(broker string) (string, bool, error) {
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	u, err := url.Parse(broker)
+	if err != nil || len(u.Hostname()) == 0 {
+		return "", false, fmt.Errorf("invalid MqttBroker %s", broker)
+	}
+	secure := false
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		secure = true
+	default:
+		return "", false, fmt.Errorf("invalid MqttBroker %s, unknown scheme %s", broker, u.Scheme)
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "1883"
+		if secure {
+			port = "8883"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), secure, nil
+}
+
+// mqttConnect connects to the broker, which publishes will on willTopic, retained, if the
+// connection is lost.
+func mqttConnect(config *Config, clientId string, willTopic string, will string) (*mqttClient, error) {
+	addr, secure, err := mqttBrokerAddress(config.MqttBroker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: mqttTimeout}
+	var conn net.Conn
+	if secure {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mqttString(&body, "MQTT")
+	flags := byte(0x02 | 0x04 | 0x20) // clean session, will, retained will
+	if len(config.MqttUsername) > 0 {
+		flags |= 0x80
+		if len(config.MqttPassword) > 0 {
+			flags |= 0x40
+		}
+	}
+	body.Write([]byte{4, flags, byte(mqttKeepAlive / time.Second >> 8), byte(mqttKeepAlive / time.Second & 0xff)})
+	mqttString(&body, clientId)
+	mqttString(&body, willTopic)
+	mqttString(&body, will)
+	if len(config.MqttUsername) > 0 {
+		mqttString(&body, config.MqttUsername)
+		if len(config.MqttPassword) > 0 {
+			mqttString(&body, config.MqttPassword)
+		}
+	}
+	client := &mqttClient{conn: conn}
+	if err := client.send(0x10, body.Bytes()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	connack := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(mqttTimeout))
+	if _, err := io.ReadFull(conn, connack); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if connack[0] != 0x20 {
+		conn.Close()
+		return nil, errors.New("invalid answer from the broker")
+	}
+	if code := int(connack[3]); code != 0 {
+		conn.Close()
+		if code < len(mqttConnectErrors) {
+			return nil, fmt.Errorf("connection refused, %s", mqttConnectErrors[code])
+		}
+		return nil, fmt.Errorf("connection refused, code %d", code)
+	}
+	conn.SetReadDeadline(time.Time{})
+	// The broker sends nothing else at QoS 0, but the connection must be read to see it closed.
+	go io.Copy(ioutil.Discard, conn)
+	return client, nil
+}
+
+func mqttString(buf *bytes.Buffer, s string) {
+	buf.Write([]byte{byte(len(s) >> 8), byte(len(s))})
+	buf.WriteString(s)
+}
+
+// send writes a packet of the given type, with the remaining length encoded before body.
+func (mc *mqttClient) send(packetType byte, body []byte) error {
+	var packet bytes.Buffer
+	packet.WriteByte(packetType)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		packet.WriteByte(b)
+		if length == 0 {
+			break
+		}
+	}
+	packet.Write(body)
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	mc.conn.SetWriteDeadline(time.Now().Add(mqttTimeout))
+	_, err := mc.conn.Write(packet.Bytes())
+	return err
+}
+
+func (mc *mqttClient) publish(topic string, payload []byte, retain bool) error {
+	var body bytes.Buffer
+	mqttString(&body, topic)
+	body.Write(payload)
+	packetType := byte(0x30)
+	if retain {
+		packetType |= 0x01
+	}
+	return mc.send(packetType, body.Bytes())
+}
+
+func (mc *mqttClient) close() {
+	mc.send(0xe0, nil)
+	mc.conn.Close()
+}
+
+// mqttPublisher publishes the state of the plotter every cycle and the events as they happen,
+// with Home Assistant discovery so the sensors show up without configuring them.
+type mqttPublisher struct {
+	settings  string
+	client    *mqttClient
+	topic     string
+	discovery string
+	node      string
+	failed    bool
+	sensors   map[string]bool
+	lock      sync.Mutex
+}
+
+// mqttState is published, retained, to <MqttTopic>/<host>/state every minute.
+type mqttState struct {
+	ActivePlots   int                `json:"active_plots"`
+	Phase1Plots   int                `json:"phase1_plots"`
+	QueuedJobs    int                `json:"queued_jobs"`
+	Finished24h   int                `json:"finished_24h"`
+	Failed24h     int                `json:"failed_24h"`
+	Paused        string             `json:"paused"`
+	PausedReason  string             `json:"paused_reason"`
+	TempFreeGiB   map[string]float64 `json:"temp_free_gib"`
+	TargetFreeGiB map[string]float64 `json:"target_free_gib"`
+}
+
+// mqttEvent is published to <MqttTopic>/<host>/event for every event of the event bus.
+type mqttEvent struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	PlotId int64     `json:"plot_id,omitempty"`
+	Id     string    `json:"id,omitempty"`
+	From   string    `json:"from,omitempty"`
+	To     string    `json:"to,omitempty"`
+	Phase  string    `json:"phase,omitempty"`
+	Dir    string    `json:"dir,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// checkMqtt connects to the broker, or reconnects after a failure or a change of settings, and
+// publishes the state.
+func (server *Server) checkMqtt(config *Config, now time.Time) {
+	settings := strings.Join([]string{config.MqttBroker, config.MqttUsername, config.MqttPassword, config.MqttTopic, config.MqttDiscoveryTopic}, "\n")
+	mp := server.mqtt
+	if mp != nil && mp.settings != settings {
+		mp.disconnect()
+		mp = nil
+	}
+	if len(config.MqttBroker) == 0 {
+		server.lock.Lock()
+		server.mqtt = nil
+		server.lock.Unlock()
+		return
+	}
+	if mp == nil {
+		host, _ := os.Hostname()
+		mp = &mqttPublisher{
+			settings:  settings,
+			topic:     config.MqttTopic,
+			discovery: config.MqttDiscoveryTopic,
+			node:      strings.Trim(mqttSlug.ReplaceAllString(strings.ToLower(host), "_"), "_"),
+		}
+		if len(mp.topic) == 0 {
+			mp.topic = defaultMqttTopic
+		}
+		if len(mp.discovery) == 0 {
+			mp.discovery = defaultMqttDiscoveryTopic
+		}
+		if len(mp.node) == 0 {
+			mp.node = "plotter"
+		}
+		server.lock.Lock()
+		server.mqtt = mp
+		server.lock.Unlock()
+	}
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if mp.client == nil {
+		client, err := mqttConnect(config, "plotng_"+mp.node, mp.statusTopic(), "offline")
+		if err != nil {
+			if !mp.failed {
+				log.Printf("Failed to connect to the MQTT broker %s, retrying every minute: %s", config.MqttBroker, err)
+				mp.failed = true
+			}
+			return
+		}
+		log.Printf("Connected to the MQTT broker %s", config.MqttBroker)
+		mp.client = client
+		mp.failed = false
+		mp.sensors = map[string]bool{}
+		mp.publish(mp.statusTopic(), []byte("online"), true)
+	}
+	state := server.mqttState(config, now)
+	mp.announce(config, state)
+	if data, err := json.Marshal(state); err == nil {
+		mp.publish(mp.stateTopic(), data, true)
+	}
+}
+
+// mqttState gathers the published state, the caller must not hold server.lock.
+func (server *Server) mqttState(config *Config, now time.Time) *mqttState {
+	state := &mqttState{Paused: "OFF", TempFreeGiB: map[string]float64{}, TargetFreeGiB: map[string]float64{}}
+	server.lock.RLock()
+	state.ActivePlots = len(server.active)
+	state.QueuedJobs = len(server.queue)
+	for _, plot := range server.active {
+		if plot.Snapshot().Phase == "1/4" {
+			state.Phase1Plots++
+		}
+	}
+	state.Finished24h, state.Failed24h = server.recentResults(now.Add(-24 * time.Hour))
+	if server.pausedNow() {
+		state.Paused = "ON"
+	}
+	if server.halted != nil {
+		state.PausedReason = server.halted.String()
+	} else if server.inMaintenance {
+		state.PausedReason = fmt.Sprintf("maintenance window %s", server.maintenance.Name)
+	}
+	server.lock.RUnlock()
+	for _, dir := range config.TempDirectory {
+		state.TempFreeGiB[dir] = float64(server.getDiskSpaceAvailable(dir)) / float64(GB)
+	}
+	for _, dir := range config.TargetDirectory {
+		state.TargetFreeGiB[dir] = float64(server.getDiskSpaceAvailable(dir)) / float64(GB)
+	}
+	return state
+}
+
+// publishMqttEvent publishes the events of the event bus.
+func (server *Server) publishMqttEvent(event Event) {
+	server.lock.RLock()
+	mp := server.mqtt
+	server.lock.RUnlock()
+	if mp == nil {
+		return
+	}
+	e := mqttEvent{Type: event.Type, Time: event.Time, Phase: event.Phase, Dir: event.Dir, Error: event.Error}
+	if event.Plot != nil {
+		status := event.Plot.Snapshot()
+		e.PlotId = status.PlotId
+		e.Id = status.Id
+	}
+	if event.Type == EventStateChanged || event.Type == EventPlotFinished {
+		e.From = event.From.String()
+		e.To = event.To.String()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.publish(mp.topic+"/"+mp.node+"/event", data, false)
+}
+
+func (mp *mqttPublisher) statusTopic() string {
+	return mp.topic + "/" + mp.node + "/status"
+}
+
+func (mp *mqttPublisher) stateTopic() string {
+	return mp.topic + "/" + mp.node + "/state"
+}
+
+// publish sends a message if connected, and drops the connection if it fails so the next cycle
+// reconnects.  The caller must hold mp.lock.
+func (mp *mqttPublisher) publish(topic string, payload []byte, retain bool) {
+	if mp.client == nil {
+		return
+	}
+	if err := mp.client.publish(topic, payload, retain); err != nil {
+		log.Printf("Lost the connection to the MQTT broker: %s", err)
+		mp.client.conn.Close()
+		mp.client = nil
+	}
+}
+
+func (mp *mqttPublisher) disconnect() {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if mp.client != nil {
+		mp.publish(mp.statusTopic(), []byte("offline"), true)
+		if mp.client != nil {
+			mp.client.close()
+		}
+		mp.client = nil
+	}
+}
+
+// announce publishes the Home Assistant discovery configuration of the sensors which weren't
+// announced on this connection yet.  The caller must hold mp.lock.
+func (mp *mqttPublisher) announce(config *Config, state *mqttState) {
+	device := map[string]interface{}{
+		"identifiers":  []string{"plotng_" + mp.node},
+		"name":         "PlotNG " + mp.node,
+		"manufacturer": "PlotNG",
+	}
+	sensor := func(component string, object string, name string, template string, unit string) {
+		if mp.sensors[object] {
+			return
+		}
+		discovery := map[string]interface{}{
+			"name":                  fmt.Sprintf("PlotNG %s %s", mp.node, name),
+			"unique_id":             "plotng_" + mp.node + "_" + object,
+			"state_topic":           mp.stateTopic(),
+			"value_template":        template,
+			"availability_topic":    mp.statusTopic(),
+			"payload_available":     "online",
+			"payload_not_available": "offline",
+			"device":                device,
+		}
+		if len(unit) > 0 {
+			discovery["unit_of_measurement"] = unit
+			discovery["state_class"] = "measurement"
+		}
+		if data, err := json.Marshal(discovery); err == nil {
+			mp.publish(fmt.Sprintf("%s/%s/plotng_%s/%s/config", mp.discovery, component, mp.node, object), data, true)
+			mp.sensors[object] = true
+		}
+	}
+	sensor("sensor", "active_plots", "active plots", "{{ value_json.active_plots }}", "plots")
+	sensor("sensor", "phase1_plots", "plots in phase 1", "{{ value_json.phase1_plots }}", "plots")
+	sensor("sensor", "queued_jobs", "queued jobs", "{{ value_json.queued_jobs }}", "jobs")
+	sensor("sensor", "finished_24h", "plots finished in 24h", "{{ value_json.finished_24h }}", "plots")
+	sensor("sensor", "failed_24h", "plots failed in 24h", "{{ value_json.failed_24h }}", "plots")
+	sensor("binary_sensor", "paused", "paused", "{{ value_json.paused }}", "")
+	for _, dir := range config.TempDirectory {
+		slug := strings.Trim(mqttSlug.ReplaceAllString(dir, "_"), "_")
+		sensor("sensor", "temp_free_"+slug, "temp free "+dir, fmt.Sprintf("{{ value_json.temp_free_gib[%q] | round(1) }}", dir), "GiB")
+	}
+	for _, dir := range config.TargetDirectory {
+		slug := strings.Trim(mqttSlug.ReplaceAllString(dir, "_"), "_")
+		sensor("sensor", "target_free_"+slug, "target free "+dir, fmt.Sprintf("{{ value_json.target_free_gib[%q] | round(1) }}", dir), "GiB")
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNodeRpcUrl = "https://localhost:8555"
+	nodeRpcTimeout    = 10 * time.Second
+)
+
+// NodeSyncCheck modes.
+const (
+	nodeSyncDelayPlots = "plots"
+	nodeSyncDelayMoves = "moves"
+)
+
+// nodeSslDir returns the directory holding the certificates of the chia services.
+func nodeSslDir(config *Config) string {
+	dir := config.NodeSslDir
+	if len(dir) == 0 {
+		dir = "~/.chia/mainnet/config/ssl"
+	}
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+	return dir
+}
+
+// nodeSyncing asks the full node of config over its RPC API whether it is still syncing the
+// blockchain.
+func nodeSyncing(config *Config) (bool, error) {
+	ssl := nodeSslDir(config)
+	cert, err := tls.LoadX509KeyPair(filepath.Join(ssl, "full_node", "private_full_node.crt"), filepath.Join(ssl, "full_node", "private_full_node.key"))
+	if err != nil {
+		return false, err
+	}
+	client := &http.Client{
+		Timeout: nodeRpcTimeout,
+		Transport: &http.Transport{
+			// The node's certificate is signed by the private CA of the installation, the client
+			// certificate is what authenticates us.
+			TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true},
+		},
+	}
+	url := config.NodeRpcUrl
+	if len(url) == 0 {
+		url = defaultNodeRpcUrl
+	}
+	resp, err := client.Post(strings.TrimSuffix(url, "/")+"/get_blockchain_state", "application/json", bytes.NewBufferString("{}"))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("node returned %s", resp.Status)
+	}
+	var state struct {
+		Success         bool
+		Error           string
+		BlockchainState struct {
+			Sync struct {
+				Synced   bool
+				SyncMode bool `json:"sync_mode"`
+			}
+		} `json:"blockchain_state"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return false, err
+	}
+	if !state.Success {
+		return false, fmt.Errorf("node error: %s", state.Error)
+	}
+	return !state.BlockchainState.Sync.Synced || state.BlockchainState.Sync.SyncMode, nil
+}
+
+// checkNodeSync delays new plots or the moves to the dest directories while the full node is
+// syncing, so plotting doesn't compete with the initial sync for I/O.
+func (server *Server) checkNodeSync(config *Config) {
+	if len(config.NodeSyncCheck) == 0 {
+		server.nodeSyncing = false
+		server.transfers.hold(false)
+		return
+	}
+	syncing, err := nodeSyncing(config)
+	if err != nil {
+		log.Printf("Failed to check the sync status of the node: %s", err)
+		return
+	}
+	server.transfers.hold(syncing && config.NodeSyncCheck == nodeSyncDelayMoves)
+	if syncing == server.nodeSyncing {
+		return
+	}
+	server.nodeSyncing = syncing
+	held := "new plots"
+	if config.NodeSyncCheck == nodeSyncDelayMoves {
+		held = "moves to the dest directories"
+	}
+	if syncing {
+		server.schedulerEvent("Node is syncing, delaying %s", held)
+	} else {
+		server.schedulerEvent("Node is synced, resuming %s", held)
+	}
+}
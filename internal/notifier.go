@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// notify runs the configured NotifyCommand with the subject and message appended as the last two
+// arguments.  It does nothing when no command is configured.
+func notify(config *Config, subject string, message string) {
+	if config == nil || len(strings.TrimSpace(config.NotifyCommand)) == 0 {
+		return
+	}
+	args := strings.Fields(config.NotifyCommand)
+	args = append(args, subject, message)
+	go func() {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			log.Printf("Failed to send notification [%s]: %s %s", subject, err, strings.TrimSpace(string(out)))
+		}
+	}()
+}
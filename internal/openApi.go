@@ -0,0 +1,385 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion is the version of the HTTP API given in the OpenAPI document, bumped whenever an
+// endpoint changes incompatibly.
+const apiVersion = "1.0.0"
+
+// apiRoute is an endpoint of the HTTP API: the handler serving it and the description of its
+// operations, from which the OpenAPI document is generated.
+type apiRoute struct {
+	// path is matched exactly, or as a prefix up to a {parameter} in its last segment.
+	path       string
+	handler    func(server *Server, resp http.ResponseWriter, req *http.Request)
+	operations []apiOperation
+	// token marks the endpoints which need the DebugToken, their query isn't logged as it may
+	// hold it and they aren't bounded by apiTimeout.
+	token bool
+}
+
+// apiOperation is a method of an apiRoute.
+type apiOperation struct {
+	method  string
+	summary string
+	params  []apiParam
+	// request and response are values of the types sent and returned as JSON, nil without body.
+	request  interface{}
+	response interface{}
+	// contentType of the response when it isn't JSON, described as binary.
+	contentType string
+	// status of a successful response, 200 by default, and errors the other statuses returned.
+	status  int
+	errors  []int
+	headers []string
+}
+
+// apiParam is a query or path parameter of an apiOperation, kind being its OpenAPI type.
+type apiParam struct {
+	name        string
+	kind        string
+	description string
+	required    bool
+}
+
+// apiRoutes are the endpoints of the HTTP API.  The last one, the state polled by the UI, also
+// answers the paths no other route matches.
+var apiRoutes []apiRoute
+
+func init() {
+	pathParam := apiParam{name: "path", kind: "string", description: "the directory", required: true}
+	apiRoutes = []apiRoute{
+		{path: "/openapi.json", handler: (*Server).handleOpenApi, operations: []apiOperation{
+			{method: "GET", summary: "Returns this OpenAPI document", response: map[string]interface{}{}},
+		}},
+		{path: "/jobs", handler: (*Server).handleJobs, operations: []apiOperation{
+			{method: "GET", summary: "Lists the queued jobs in the order they will start", response: []*PlotJob{}},
+			{method: "POST", summary: "Queues a job, fields left out use the values of the configuration", request: PlotJob{}, response: PlotJob{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest}},
+			{method: "PUT", summary: "Changes a queued job and returns the queue", params: []apiParam{
+				{name: "id", kind: "integer", description: "the JobId", required: true},
+				{name: "action", kind: "string", description: "up, down, top, hold, release or cancel", required: true},
+			}, response: []*PlotJob{}, errors: []int{http.StatusBadRequest}},
+		}},
+		{path: "/halt", handler: (*Server).handleHalt, operations: []apiOperation{
+			{method: "GET", summary: "Returns why plotting is halted, null when it isn't", response: &Halt{}},
+			{method: "DELETE", summary: "Resumes plotting", response: &Halt{}},
+		}},
+		{path: "/orphans", handler: (*Server).handleOrphans, operations: []apiOperation{
+			{method: "DELETE", summary: "Deletes an orphaned temp file found by the last scan, or all of them, but never a work directory holding finished plots", params: []apiParam{
+				{name: "path", kind: "string", description: "the orphan to delete, all of them when left out"},
+			}, response: map[string]uint64{"Reclaimed": 0}, errors: []int{http.StatusBadRequest, http.StatusConflict}},
+		}},
+		{path: "/history", handler: (*Server).handleHistory, operations: []apiOperation{
+			{method: "GET", summary: "Returns the archived plots, filtered and paged", params: []apiParam{
+				{name: "from", kind: "string", description: "a date like 2021-06-01 or an RFC 3339 time, on the time the plots ended"},
+				{name: "to", kind: "string", description: "a date like 2021-06-01 or an RFC 3339 time, on the time the plots ended"},
+				{name: "state", kind: "string", description: "the final states to include, separated by commas eg. errored,killed"},
+				{name: "limit", kind: "integer", description: "the maximum number of plots"},
+				{name: "offset", kind: "integer", description: "the number of plots to skip"},
+				{name: "cursor", kind: "integer", description: "the X-Next-Cursor of the previous page, or the PlotId of the last plot known"},
+			}, response: []*PlotStatus{}, errors: []int{http.StatusBadRequest}, headers: []string{"X-Total-Count", "X-Next-Cursor"}},
+		}},
+		{path: "/plots/kill", handler: (*Server).handleKillAll, operations: []apiOperation{
+			{method: "POST", summary: "Kills all active plots, the scheduler keeps starting new ones", response: BulkResult{}},
+		}},
+		{path: "/plots/retry", handler: (*Server).handleRetryFailed, operations: []apiOperation{
+			{method: "POST", summary: "Queues a job for every failed plot of the archive not retried yet", response: BulkResult{}},
+		}},
+		{path: "/archive", handler: (*Server).handleClearArchive, operations: []apiOperation{
+			{method: "DELETE", summary: "Removes the archived plots, filtered like the history", params: []apiParam{
+				{name: "from", kind: "string", description: "a date like 2021-06-01 or an RFC 3339 time, on the time the plots ended"},
+				{name: "to", kind: "string", description: "a date like 2021-06-01 or an RFC 3339 time, on the time the plots ended"},
+				{name: "state", kind: "string", description: "the final states to remove, separated by commas eg. errored,killed"},
+			}, response: BulkResult{}, errors: []int{http.StatusBadRequest}},
+		}},
+		{path: "/distribution", handler: (*Server).handleDistribution, operations: []apiOperation{
+			{method: "GET", summary: "Returns the plot count and size of every dest directory from the last scan", response: []*DestinationSummary{}},
+		}},
+		{path: "/rebalance", handler: (*Server).handleRebalance, operations: []apiOperation{
+			{method: "GET", summary: "Returns the current rebalance", response: &Rebalance{}},
+			{method: "POST", summary: "Plans a rebalance of the dest directories, or starts it without DryRun", request: RebalanceRequest{}, response: &Rebalance{}, errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusServiceUnavailable}},
+			{method: "DELETE", summary: "Cancels the remaining moves of the rebalance", response: &Rebalance{}},
+		}},
+		{path: "/debug/state", handler: (*Server).handleDebugState, operations: []apiOperation{
+			{method: "GET", summary: "Returns the state of the server for a bug report", response: DebugState{}},
+		}},
+		{path: "/config", handler: (*Server).handleConfig, operations: []apiOperation{
+			{method: "GET", summary: "Returns the current configuration without passwords", response: Config{}, errors: []int{http.StatusNotFound}},
+			{method: "PUT", summary: "Validates, saves and applies a new configuration", request: Config{}, status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+		}},
+		{path: "/dirs", handler: (*Server).handleDirs, operations: []apiOperation{
+			{method: "GET", summary: "Lists the disabled directories", response: map[string][]string{"Disabled": nil}},
+			{method: "PUT", summary: "Enables or disables a directory and lists the disabled directories", params: []apiParam{
+				pathParam,
+				{name: "enabled", kind: "boolean", description: "whether the directory is used", required: true},
+			}, response: map[string][]string{"Disabled": nil}, errors: []int{http.StatusBadRequest}},
+		}},
+		{path: "/drives", handler: (*Server).handleDrives, operations: []apiOperation{
+			{method: "GET", summary: "Lists the drives being evacuated and the temp directories being drained, with the plots still using them", response: []DriveStatus{}},
+		}},
+		{path: "/drives/evacuate", handler: (*Server).handleDrives, operations: []apiOperation{
+			{method: "POST", summary: "Stops scheduling on a drive until its plots are done", params: []apiParam{pathParam}, response: DriveStatus{}, errors: []int{http.StatusBadRequest}},
+		}},
+		{path: "/drives/drain", handler: (*Server).handleDrives, operations: []apiOperation{
+			{method: "POST", summary: "Stops scheduling on a temp directory and notifies once its plots are done, until it is enabled again", params: []apiParam{pathParam}, response: DriveStatus{}, errors: []int{http.StatusBadRequest}},
+		}},
+		{path: "/drives/add", handler: (*Server).handleDrives, operations: []apiOperation{
+			{method: "POST", summary: "Validates a drive and adds it to the dest directories, or to the temp directories", params: []apiParam{
+				pathParam,
+				{name: "temp", kind: "boolean", description: "adds the drive as a temp directory"},
+			}, status: http.StatusNoContent, errors: []int{http.StatusBadRequest}},
+		}},
+		{path: "/credits", handler: (*Server).handleCredits, operations: []apiOperation{
+			{method: "GET", summary: "Returns the plot credits, one is taken by every plot started when PlotCredits is set", response: PlotCredits{}},
+			{method: "POST", summary: "Grants plot credits, and sets the hourly refill and the capacity when given", request: CreditGrant{}, response: PlotCredits{}, errors: []int{http.StatusBadRequest}},
+			{method: "DELETE", summary: "Revokes the plot credits left and stops the refill", response: PlotCredits{}},
+		}},
+		{path: "/metrics", handler: (*Server).handleMetrics, operations: []apiOperation{
+			{method: "GET", summary: "Returns the plots and the space of the directories in the Prometheus text format", contentType: "text/plain"},
+		}},
+		{path: "/debug/metrics", handler: (*Server).handleProfiling, token: true, operations: []apiOperation{
+			{method: "GET", summary: "Returns the Go runtime metrics and the plots per state and failure", response: RuntimeMetrics{}, errors: []int{http.StatusForbidden}},
+		}},
+		{path: "/debug/pprof/{profile}", handler: (*Server).handleProfiling, token: true, operations: []apiOperation{
+			{method: "GET", summary: "Returns a net/http/pprof profile", contentType: "application/octet-stream", errors: []int{http.StatusForbidden}},
+		}},
+		{path: "/", handler: (*Server).handleState, operations: []apiOperation{
+			{method: "GET", summary: "Returns the state of the server as a gob encoded Msg, as polled by the UI", params: []apiParam{
+				{name: "since", kind: "integer", description: "the revision last seen, only plots changed after it are sent"},
+				{name: "epoch", kind: "integer", description: "the epoch of the revision, everything is sent when the server restarted since"},
+			}, contentType: "application/x-gob"},
+		}},
+		// last, it serves the paths no other route matches
+		{path: "/{id}", handler: (*Server).handleState, operations: []apiOperation{
+			{method: "DELETE", summary: "Kills the active plot with this Id", status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		}},
+	}
+}
+
+// mutating returns whether method changes anything on the route, only allowed from the host
+// itself or with the ConfigToken.  The endpoints needing the DebugToken only read.
+func (route *apiRoute) mutating(method string) bool {
+	return !route.token && method != "GET" && method != "HEAD" && method != "OPTIONS"
+}
+
+// matches returns whether the route serves path.
+func (route *apiRoute) matches(path string) bool {
+	if i := strings.Index(route.path, "{"); i >= 0 {
+		return strings.HasPrefix(path, route.path[:i])
+	}
+	return path == route.path
+}
+
+// findApiRoute returns the route serving path.
+func findApiRoute(path string) *apiRoute {
+	for i := range apiRoutes {
+		if apiRoutes[i].matches(path) {
+			return &apiRoutes[i]
+		}
+	}
+	return &apiRoutes[len(apiRoutes)-1]
+}
+
+// handleOpenApi returns the OpenAPI document of the HTTP API.
+func (server *Server) handleOpenApi(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(openApiDocument())
+}
+
+// openApiDocument generates the OpenAPI 3.0 document of apiRoutes, with the schemas of the types
+// taken from their Go definitions.
+func openApiDocument() map[string]interface{} {
+	schemas := openApiSchemas{}
+	paths := map[string]interface{}{}
+	for _, route := range apiRoutes {
+		item := map[string]interface{}{}
+		for _, op := range route.operations {
+			item[strings.ToLower(op.method)] = schemas.operation(&route, &op)
+		}
+		paths[route.path] = item
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "PlotNG",
+			"description": "The HTTP API of the PlotNG server.  Timestamps include their UTC offset, the X-Time-Zone header gives the time zone of the server.",
+			"version":     apiVersion,
+		},
+		"servers": []interface{}{map[string]interface{}{"url": apiPrefix}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"debugToken":  map[string]interface{}{"type": "http", "scheme": "bearer", "description": "The DebugToken of the configuration, also accepted as the token parameter"},
+				"configToken": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "The ConfigToken of the configuration, needed by the changes from another host"},
+			},
+		},
+	}
+}
+
+// openApiSchemas are the schemas of the named types, referenced by the operations.
+type openApiSchemas map[string]interface{}
+
+// operation returns the OpenAPI operation object of op.
+func (schemas openApiSchemas) operation(route *apiRoute, op *apiOperation) map[string]interface{} {
+	operation := map[string]interface{}{"summary": op.summary}
+	var params []interface{}
+	if i := strings.Index(route.path, "{"); i >= 0 {
+		name := strings.Trim(route.path[i:], "{}")
+		params = append(params, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+	}
+	for _, param := range op.params {
+		params = append(params, map[string]interface{}{
+			"name":        param.name,
+			"in":          "query",
+			"description": param.description,
+			"required":    param.required,
+			"schema":      map[string]interface{}{"type": param.kind},
+		})
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	if op.request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(op.request))}},
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if op.response != nil {
+		success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(op.response))}}
+	} else if len(op.contentType) > 0 {
+		success["content"] = map[string]interface{}{op.contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}}
+	}
+	if len(op.headers) > 0 {
+		headers := map[string]interface{}{}
+		for _, header := range op.headers {
+			headers[header] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+		success["headers"] = headers
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	for _, code := range op.errors {
+		responses[strconv.Itoa(code)] = map[string]interface{}{"description": http.StatusText(code)}
+	}
+	if route.token {
+		operation["security"] = []interface{}{map[string]interface{}{"debugToken": []string{}}}
+	} else if route.mutating(op.method) {
+		operation["security"] = []interface{}{map[string]interface{}{"configToken": []string{}}}
+		responses[strconv.Itoa(http.StatusForbidden)] = map[string]interface{}{"description": http.StatusText(http.StatusForbidden)}
+	}
+	operation["responses"] = responses
+	return operation
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// of returns the schema of t as encoded by encoding/json.  Named structs are added to schemas and
+// referenced, so recursive types end.
+func (schemas openApiSchemas) of(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemas.of(t.Elem())
+		if _, ref := schema["$ref"]; ref {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": integerFormat(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": integerFormat(t), "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemas.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemas.of(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return schemas.object(t)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = map[string]interface{}{} // placeholder for recursive references
+			schemas[t.Name()] = schemas.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// object returns the schema of the struct t, with the fields of embedded structs inlined.
+func (schemas openApiSchemas) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	schemas.fields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// fields adds the fields of the struct t encoded by encoding/json to properties.
+func (schemas openApiSchemas) fields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Name
+		if tag := field.Tag.Get("json"); len(tag) > 0 {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; len(n) > 0 {
+				name = n
+			}
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && len(field.Tag.Get("json")) == 0 {
+			schemas.fields(fieldType, properties)
+			continue
+		}
+		if len(field.PkgPath) > 0 {
+			continue // unexported
+		}
+		properties[name] = schemas.of(field.Type)
+	}
+}
+
+// integerFormat returns the OpenAPI format of an integer type.
+func integerFormat(t reflect.Type) string {
+	if t.Bits() <= 32 {
+		return "int32"
+	}
+	return "int64"
+}
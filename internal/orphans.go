@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+const (
+	// orphanScanCycles is how many cycles pass between two scans of the temp directories.
+	orphanScanCycles = 10
+	// orphanMinAge protects files of a plot which just started and hasn't printed its ID yet.
+	orphanMinAge = 10 * time.Minute
+)
+
+// OrphanFile is a file or work directory in a temp directory which doesn't belong to any active plot.
+// A work directory holding finished plots, left by a final copy which failed, lists them in Plots:
+// they are to be recovered and it is never reclaimed.
+type OrphanFile struct {
+	TempDir string
+	Path    string
+	Size    uint64
+	ModTime time.Time
+	Plots   []string
+}
+
+// reclaimable reports whether the orphan can be deleted.
+func (orphan *OrphanFile) reclaimable() bool {
+	return len(orphan.Plots) == 0
+}
+
+// finishedPlots returns the paths of the plot files below dir.
+func finishedPlots(fs Filesystem, dir string) (plots []string) {
+	fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(info.Name(), ".plot") {
+			plots = append(plots, path)
+		}
+		return nil
+	})
+	return
+}
+
+func dirSize(fs Filesystem, path string) (size uint64) {
+	fs.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return
+}
+
+// scanOrphans looks for chia temp files and plotng work directories which no active plot owns,
+// the temp files of the plotters PlotNG didn't start aren't orphans either.
+func (server *Server) scanOrphans(config *Config, now time.Time) {
+	server.lock.RLock()
+	var owners []string
+	for _, plot := range server.active {
+		if id := plot.Snapshot().Id; len(id) > 0 {
+			owners = append(owners, id)
+		}
+		owners = append(owners, fmt.Sprintf("plotng-%d", plot.PlotId))
+	}
+	for _, plot := range server.external {
+		if len(plot.Id) > 0 {
+			owners = append(owners, plot.Id)
+		}
+	}
+	server.lock.RUnlock()
+	owned := func(name string) bool {
+		for _, owner := range owners {
+			if strings.Contains(name, owner) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var orphans []*OrphanFile
+	for _, dir := range config.TempDirectory {
+		fileList, err := server.filesystem().ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range fileList {
+			isWorkDir := file.IsDir() && strings.HasPrefix(file.Name(), "plotng-")
+			isTempFile := !file.IsDir() && strings.HasSuffix(file.Name(), ".tmp")
+			if (!isWorkDir && !isTempFile) || owned(file.Name()) || now.Sub(file.ModTime()) < orphanMinAge {
+				continue
+			}
+			orphan := &OrphanFile{
+				TempDir: dir,
+				Path:    filepath.Join(dir, file.Name()),
+				Size:    uint64(file.Size()),
+				ModTime: file.ModTime(),
+			}
+			if isWorkDir {
+				orphan.Size = dirSize(server.filesystem(), orphan.Path)
+				orphan.Plots = finishedPlots(server.filesystem(), orphan.Path)
+			}
+			orphans = append(orphans, orphan)
+		}
+	}
+
+	var total uint64
+	count := 0
+	for _, orphan := range orphans {
+		if !orphan.reclaimable() {
+			log.Printf("Finished plots to recover in %s: %s", orphan.Path, strings.Join(orphan.Plots, ", "))
+			continue
+		}
+		total += orphan.Size
+		count++
+	}
+	if count > 0 {
+		log.Printf("Found %d orphaned temp files using %s", count, format.Space(total))
+	}
+	server.lock.Lock()
+	server.orphans = orphans
+	server.lock.Unlock()
+}
+
+// handleOrphans deletes the orphan given by the "path" query parameter, or all orphans without it.
+// Only paths found by the last scan can be deleted, and never the work directories holding
+// finished plots.
+func (server *Server) handleOrphans(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "DELETE" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := req.URL.Query().Get("path")
+	if len(path) > 0 {
+		if err := server.checkPath(path); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	server.lock.Lock()
+	for _, orphan := range server.orphans {
+		if orphan.Path == path && !orphan.reclaimable() {
+			server.lock.Unlock()
+			http.Error(resp, fmt.Sprintf("%s holds finished plots to recover: %s", path, strings.Join(orphan.Plots, ", ")), http.StatusConflict)
+			return
+		}
+	}
+	var remaining []*OrphanFile
+	var reclaimed uint64
+	for _, orphan := range server.orphans {
+		if (len(path) > 0 && orphan.Path != path) || !orphan.reclaimable() {
+			remaining = append(remaining, orphan)
+			continue
+		}
+		if err := server.filesystem().RemoveAll(orphan.Path); err != nil {
+			log.Printf("Failed to delete orphan: %s", err)
+			remaining = append(remaining, orphan)
+			continue
+		}
+		log.Printf("Orphan: %s deleted", orphan.Path)
+		reclaimed += orphan.Size
+	}
+	server.orphans = remaining
+	server.lock.Unlock()
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(map[string]uint64{"Reclaimed": reclaimed})
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// dirOverlay holds the directories switched off at runtime, e.g. while a drive is replaced.  It is
+// kept next to the configuration file so it survives a restart without touching the config itself.
+// Evacuating holds the drives being emptied for removal, true once no plot uses them any more.
+// Draining holds the temp directories being drained, e.g. to trim their SSD, true once idle.
+type dirOverlay struct {
+	Disabled   map[string]bool
+	Evacuating map[string]bool
+	Draining   map[string]bool
+}
+
+func overlayPath(configPath string) string {
+	return configPath + ".overlay"
+}
+
+func loadOverlay(path string) *dirOverlay {
+	overlay := &dirOverlay{Disabled: map[string]bool{}, Evacuating: map[string]bool{}, Draining: map[string]bool{}}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read overlay %s: %s", path, err)
+		}
+		return overlay
+	}
+	if err := json.Unmarshal(data, overlay); err != nil {
+		log.Printf("Failed to parse overlay %s: %s", path, err)
+	}
+	if overlay.Disabled == nil {
+		overlay.Disabled = map[string]bool{}
+	}
+	if overlay.Evacuating == nil {
+		overlay.Evacuating = map[string]bool{}
+	}
+	if overlay.Draining == nil {
+		overlay.Draining = map[string]bool{}
+	}
+	return overlay
+}
+
+func (overlay *dirOverlay) save(path string) error {
+	data, err := json.MarshalIndent(overlay, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// handleDirs lists the disabled directories on GET, and enables or disables the directory given
+// by the path parameter on PUT.
+func (server *Server) handleDirs(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "PUT":
+		path := req.URL.Query().Get("path")
+		enabled, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+		if len(path) == 0 || err != nil {
+			http.Error(resp, "path and enabled parameters are required", http.StatusBadRequest)
+			return
+		}
+		if err := server.checkPath(path); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.lock.Lock()
+		if enabled {
+			delete(server.overlay.Disabled, path)
+			delete(server.overlay.Evacuating, path)
+			delete(server.overlay.Draining, path)
+			log.Printf("Directory [%s] enabled", path)
+		} else {
+			server.overlay.Disabled[path] = true
+			log.Printf("Directory [%s] disabled", path)
+		}
+		err = server.overlay.save(overlayPath(server.config.ConfigPath))
+		server.lock.Unlock()
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server.lock.RLock()
+	disabled := []string{}
+	for dir := range server.overlay.Disabled {
+		disabled = append(disabled, dir)
+	}
+	server.lock.RUnlock()
+	sort.Strings(disabled)
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(map[string][]string{"Disabled": disabled})
+}
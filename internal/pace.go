@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"math"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// paceSamples is how many of the last finished plots give the plot duration for pacing.
+const paceSamples = 10
+
+// defaultPaceDuration is the plot duration assumed for pacing until a plot has finished.
+const defaultPaceDuration = 8 * time.Hour
+
+// paceState is when pacing started and the concurrency it last picked, to log its changes.
+type paceState struct {
+	since    time.Time
+	parallel int
+	interval time.Duration
+}
+
+// pacedConfig returns the configuration to schedule with for TargetPlotsPerDay: the plots start
+// one at a time, evenly spread over the day, and only as many run as the recent plot duration
+// needs to finish them, so that as few plots as possible share the drives.  It runs one more
+// plot, starting them sooner, when the plots finished over the last day fall behind the target,
+// and one fewer, starting them later, when they are ahead.  NumberOfParallelPlots stays the maximum.  The caller must
+// hold server.lock.
+func (server *Server) pacedConfig(config *Config, now time.Time) *Config {
+	if config.TargetPlotsPerDay <= 0 {
+		server.pace = paceState{}
+		return config
+	}
+	if server.pace.since.IsZero() {
+		server.pace.since = now
+	}
+	duration, finished := server.paceHistory(now)
+	perDay := float64(config.TargetPlotsPerDay)
+	interval := time.Duration(float64(24*time.Hour) / perDay)
+	parallel := int(math.Ceil(perDay * duration.Hours() / 24))
+	// the progress is only known once pacing has run for a plot and part of the day
+	if window := now.Sub(server.pace.since); window > duration {
+		if window > 24*time.Hour {
+			window = 24 * time.Hour
+		}
+		expected := perDay * window.Hours() / 24
+		switch {
+		case float64(finished) < expected-1:
+			parallel++
+			interval = interval * 3 / 4
+		case float64(finished) > expected+1:
+			parallel--
+			interval = interval * 5 / 4
+		}
+	}
+	if parallel > config.NumberOfParallelPlots {
+		parallel = config.NumberOfParallelPlots
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	interval = interval.Round(time.Minute)
+	if parallel != server.pace.parallel || interval != server.pace.interval {
+		server.schedulerEvent("Pacing %d plots/day with plots of %s: %d parallel plots, one every %s, %d finished in the last day",
+			config.TargetPlotsPerDay, format.Duration(duration), parallel, format.Duration(interval), finished)
+		server.pace.parallel, server.pace.interval = parallel, interval
+	}
+	paced := *config
+	paced.NumberOfParallelPlots = parallel
+	paced.DelaysBetweenPlot = int(interval / time.Minute)
+	paced.StaggeringDelay = 0
+	paced.IdleExtraPlots = 0
+	return &paced
+}
+
+// paceHistory returns the mean duration of the last finished plots and the number of plots
+// finished in the last day.  The caller must hold server.lock.
+func (server *Server) paceHistory(now time.Time) (duration time.Duration, finished int) {
+	var total time.Duration
+	samples := 0
+	for i := len(server.archive) - 1; i >= 0; i-- {
+		plot := server.archive[i]
+		if plot.State != PlotFinished {
+			continue
+		}
+		if now.Sub(plot.EndTime) < 24*time.Hour {
+			finished++
+		}
+		if samples < paceSamples {
+			total += plot.EndTime.Sub(plot.StartTime)
+			samples++
+		}
+	}
+	if samples == 0 {
+		return defaultPaceDuration, finished
+	}
+	return total / time.Duration(samples), finished
+}
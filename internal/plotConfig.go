@@ -2,33 +2,117 @@ package internal
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
 
 type Config struct {
-	TargetDirectory        []string
-	TempDirectory          []string
-	NumberOfParallelPlots  int
-	Fingerprint            string
-	FarmerPublicKey        string
-	PoolPublicKey          string
-	Threads                int
-	PlotSize               int
-	Buffers                int
-	DisableBitField        bool
-	StaggeringDelay        int
-	ShowPlotLog            bool
-	DiskSpaceCheck         bool
-	DelaysBetweenPlot      int
-	MaxActivePlotPerTarget int
-	MaxActivePlotPerTemp   int
-	MaxActivePlotPerPhase1 int
-	UseTargetForTmp2       bool
-	BucketSize             int
-	SavePlotLogDir         string
+	TargetDirectory              []string
+	BufferDirectory              []string
+	BufferOffloadPercent         float64
+	TempDirectory                []string
+	NumberOfParallelPlots        int
+	Fingerprint                  string
+	FarmerPublicKey              string
+	PoolPublicKey                string
+	Threads                      int
+	PlotSize                     int
+	Buffers                      int
+	DisableBitField              bool
+	StaggeringDelay              int
+	ShowPlotLog                  bool
+	DiskSpaceCheck               bool
+	DelaysBetweenPlot            int
+	MaxActivePlotPerTarget       int
+	MaxActivePlotPerTemp         int
+	MaxActivePlotPerPhase1       int
+	MinTargetWriteSpeed          float64
+	UseTargetForTmp2             bool
+	BucketSize                   int
+	SavePlotLogDir               string
+	NotifyCommand                string
+	EmailDigest                  string
+	SmtpServer                   string
+	SmtpUsername                 string
+	SmtpPassword                 string
+	EmailFrom                    string
+	EmailTo                      []string
+	MaxCpuTemperature            int
+	MaxNvmeTemperature           int
+	MinFreeMemory                int
+	MaxThreadsPerCore            float64
+	IdleExtraPlots               int
+	IdleMinutes                  int
+	IdleMaxCpu                   float64
+	IdleMaxDiskBusy              float64
+	NodeSyncCheck                string
+	NodeRpcUrl                   string
+	NodeSslDir                   string
+	HaltAfterFailures            int
+	MaintenanceWindows           []MaintenanceWindow
+	SshListen                    string
+	SshAuthorizedKeys            string
+	SshHostKey                   string
+	TelegramBotToken             string
+	TelegramChatIds              []int64
+	MqttBroker                   string
+	MqttUsername                 string
+	MqttPassword                 string
+	MqttTopic                    string
+	MqttDiscoveryTopic           string
+	EventLogFile                 string
+	UpsName                      string
+	OnBatteryAction              string
+	ElectricityPriceUrl          string
+	ElectricityPriceField        string
+	MaxElectricityPrice          float64
+	Labels                       []string
+	Profiles                     []PlotProfile
+	Quotas                       map[string]int
+	MaxConcurrentCopiesPerTarget int
+	TargetGroups                 map[string]string
+	MaxTransferRate              float64
+	MaxTransferRatePerHost       map[string]float64
+	TransferSchedule             []TransferWindow
+	S3Endpoint                   string
+	S3Region                     string
+	S3AccessKey                  string
+	S3SecretKey                  string
+	RemountCommand               string
+	RemountAttempts              int
+	IsolatePlotDirs              bool
+	Plotter                      string
+	PoolContractAddress          string
+	RebalanceTransferRate        float64
+	DebugToken                   string
+	Scheduler                    string
+	RedactLogs                   bool
+	RedactPatterns               []string
+	HistoryRetentionDays         int
+	HistoryRetentionCount        int
+	PlotLogRetentionDays         int
+	PlotSubfolder                string
+	PlotSubfolderNames           map[string]string
+	DestinationKeys              map[string]DestinationKeys
+	TempWriteAmplification       float64
+	TempTbwBudget                map[string]float64
+	SmartDevices                 map[string]string
+	TrimAfterPlots               int
+	TrimCommand                  string
+	ProportionalFill             bool
+	RunAsUser                    string
+	PlotOwner                    string
+	PlotFileMode                 string
+	PlotDirMode                  string
+	AllowedPaths                 []string
+	TargetPlotsPerDay            int
+	PlotCredits                  bool
+	ConfigToken                  string
 }
 
 type PlotConfig struct {
@@ -42,7 +126,11 @@ func (pc *PlotConfig) ProcessConfig() (newConfigLoaded bool) {
 	if fs, err := os.Lstat(pc.ConfigPath); err != nil {
 		log.Printf("Failed to open config file [%s]: %s\n", pc.ConfigPath, err)
 	} else {
-		if pc.LastMod != fs.ModTime() {
+		pc.Lock.RLock()
+		lastMod := pc.LastMod
+		pc.Lock.RUnlock()
+		if lastMod != fs.ModTime() {
+			var loaded *Config
 			if f, err := os.Open(pc.ConfigPath); err != nil {
 				log.Printf("Failed to open config file [%s]: %s\n", pc.ConfigPath, err)
 			} else {
@@ -52,15 +140,219 @@ func (pc *PlotConfig) ProcessConfig() (newConfigLoaded bool) {
 				if err := decoder.Decode(&newConfig); err != nil {
 					log.Printf("Failed to process config file [%s], check your config file for mistake: %s\n", pc.ConfigPath, err)
 				} else {
-					pc.Lock.Lock()
-					pc.CurrentConfig = &newConfig
-					pc.Lock.Unlock()
+					loaded = &newConfig
+				}
+			}
+			pc.Lock.Lock()
+			if pc.LastMod == lastMod { // else SaveConfig has just written the file
+				if loaded != nil {
+					pc.CurrentConfig = loaded
 					log.Printf("New configuration loaded")
 					newConfigLoaded = true
 				}
+				pc.LastMod = fs.ModTime()
 			}
-			pc.LastMod = fs.ModTime()
+			pc.Lock.Unlock()
 		}
 	}
 	return
 }
+
+// Validate checks the settings which can be changed from the UI.
+func (config *Config) Validate() error {
+	if config.NumberOfParallelPlots < 0 {
+		return fmt.Errorf("NumberOfParallelPlots can't be negative")
+	}
+	if config.StaggeringDelay < 0 || config.DelaysBetweenPlot < 0 {
+		return fmt.Errorf("delays can't be negative")
+	}
+	if config.PlotSize != 0 && (config.PlotSize < 25 || config.PlotSize > 35) {
+		return fmt.Errorf("invalid PlotSize: %d", config.PlotSize)
+	}
+	if config.Threads < 0 || config.Buffers < 0 || config.BucketSize < 0 {
+		return fmt.Errorf("Threads, Buffers and BucketSize can't be negative")
+	}
+	if config.MaxActivePlotPerTarget < 0 || config.MaxActivePlotPerTemp < 0 || config.MaxActivePlotPerPhase1 < 0 {
+		return fmt.Errorf("maximum active plots can't be negative")
+	}
+	if config.MinTargetWriteSpeed < 0 {
+		return fmt.Errorf("MinTargetWriteSpeed can't be negative")
+	}
+	if config.MinFreeMemory < 0 {
+		return fmt.Errorf("MinFreeMemory can't be negative")
+	}
+	for _, window := range config.MaintenanceWindows {
+		if err := window.validate(); err != nil {
+			return err
+		}
+	}
+	if len(config.SshListen) > 0 && len(config.SshAuthorizedKeys) == 0 {
+		return fmt.Errorf("SshListen needs SshAuthorizedKeys")
+	}
+	if len(config.TelegramBotToken) > 0 && len(config.TelegramChatIds) == 0 {
+		return fmt.Errorf("TelegramBotToken needs TelegramChatIds")
+	}
+	if len(config.MqttBroker) > 0 {
+		if _, _, err := mqttBrokerAddress(config.MqttBroker); err != nil {
+			return err
+		}
+	}
+	if config.BufferOffloadPercent < 0 || config.BufferOffloadPercent > 100 {
+		return fmt.Errorf("BufferOffloadPercent must be between 0 and 100")
+	}
+	for _, dir := range config.BufferDirectory {
+		if isRemoteTarget(dir) {
+			return fmt.Errorf("buffer directory %s must be local", dir)
+		}
+	}
+	if len(config.BufferDirectory) > 0 && len(config.TargetDirectory) == 0 {
+		return fmt.Errorf("BufferDirectory needs TargetDirectory to offload to")
+	}
+	names := map[string]bool{}
+	for i := range config.Profiles {
+		profile := &config.Profiles[i]
+		if err := profile.validate(); err != nil {
+			return err
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("duplicate profile %s", profile.Name)
+		}
+		names[profile.Name] = true
+		for _, dir := range profile.TargetDirectory {
+			if len(strings.TrimSpace(dir)) == 0 {
+				return fmt.Errorf("empty directory in profile %s", profile.Name)
+			}
+		}
+	}
+	if config.HaltAfterFailures < 0 {
+		return fmt.Errorf("HaltAfterFailures can't be negative")
+	}
+	if config.HistoryRetentionDays < 0 || config.HistoryRetentionCount < 0 || config.PlotLogRetentionDays < 0 {
+		return fmt.Errorf("HistoryRetentionDays, HistoryRetentionCount and PlotLogRetentionDays can't be negative")
+	}
+	if config.MaxThreadsPerCore < 0 {
+		return fmt.Errorf("MaxThreadsPerCore can't be negative")
+	}
+	if config.TargetPlotsPerDay < 0 {
+		return fmt.Errorf("TargetPlotsPerDay can't be negative")
+	}
+	if config.IdleExtraPlots < 0 || config.IdleMinutes < 0 {
+		return fmt.Errorf("IdleExtraPlots and IdleMinutes can't be negative")
+	}
+	switch config.NodeSyncCheck {
+	case "", nodeSyncDelayPlots, nodeSyncDelayMoves:
+	default:
+		return fmt.Errorf("NodeSyncCheck must be \"%s\" or \"%s\"", nodeSyncDelayPlots, nodeSyncDelayMoves)
+	}
+	if config.IdleMaxCpu < 0 || config.IdleMaxCpu > 100 || config.IdleMaxDiskBusy < 0 || config.IdleMaxDiskBusy > 100 {
+		return fmt.Errorf("IdleMaxCpu and IdleMaxDiskBusy must be between 0 and 100")
+	}
+	if _, ok := schedulers[config.Scheduler]; len(config.Scheduler) > 0 && !ok {
+		return fmt.Errorf("unknown Scheduler %s, valid schedulers are %s", config.Scheduler, strings.Join(schedulerNames(), ", "))
+	}
+	if config.TrimAfterPlots < 0 {
+		return fmt.Errorf("TrimAfterPlots can't be negative")
+	}
+	if config.TempWriteAmplification < 0 {
+		return fmt.Errorf("TempWriteAmplification can't be negative")
+	}
+	for dir, budget := range config.TempTbwBudget {
+		if budget < 0 {
+			return fmt.Errorf("TempTbwBudget of %s can't be negative", dir)
+		}
+	}
+	if err := validateDestinationKeys(config); err != nil {
+		return err
+	}
+	if err := validatePlotSubfolder(config); err != nil {
+		return err
+	}
+	if err := validatePlotUsers(config); err != nil {
+		return err
+	}
+	if err := validatePlotModes(config); err != nil {
+		return err
+	}
+	if _, err := compileRedactPatterns(config.RedactPatterns); err != nil {
+		return err
+	}
+	for _, dir := range append(append(append([]string{}, config.TempDirectory...), config.TargetDirectory...), config.BufferDirectory...) {
+		if len(strings.TrimSpace(dir)) == 0 {
+			return fmt.Errorf("empty directory")
+		}
+	}
+	if err := config.checkConfigPaths(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// redacted returns a copy of the configuration without passwords, for sending over the network.
+func (config *Config) redacted() *Config {
+	c := *config
+	c.SmtpPassword = ""
+	c.S3SecretKey = ""
+	c.DebugToken = ""
+	c.ConfigToken = ""
+	c.TelegramBotToken = ""
+	c.MqttPassword = ""
+	return &c
+}
+
+// keepSecret fills an empty secret of a new configuration with the current one, unless the host
+// it is sent to changed: a client which redirected it would receive it.
+func keepSecret(newSecret *string, secret string, name string, newHost string, host string, hostName string) error {
+	if len(*newSecret) > 0 || len(secret) == 0 {
+		return nil
+	}
+	if newHost != host {
+		return fmt.Errorf("%s must be given again when %s changes", name, hostName)
+	}
+	*newSecret = secret
+	return nil
+}
+
+// SaveConfig validates newConfig, writes it to the configuration file and makes it the current
+// configuration straight away.  Passwords left empty keep their current value, as long as the
+// server they are sent to stays the same.  A new file is only readable by its owner as it holds
+// these passwords, an existing one keeps its mode.
+func (pc *PlotConfig) SaveConfig(newConfig *Config) error {
+	if err := newConfig.Validate(); err != nil {
+		return err
+	}
+	pc.Lock.Lock()
+	defer pc.Lock.Unlock()
+	if current := pc.CurrentConfig; current != nil {
+		if err := keepSecret(&newConfig.SmtpPassword, current.SmtpPassword, "SmtpPassword", newConfig.SmtpServer, current.SmtpServer, "SmtpServer"); err != nil {
+			return err
+		}
+		if err := keepSecret(&newConfig.S3SecretKey, current.S3SecretKey, "S3SecretKey", newConfig.S3Endpoint, current.S3Endpoint, "S3Endpoint"); err != nil {
+			return err
+		}
+		if err := keepSecret(&newConfig.MqttPassword, current.MqttPassword, "MqttPassword", newConfig.MqttBroker, current.MqttBroker, "MqttBroker"); err != nil {
+			return err
+		}
+		if len(newConfig.DebugToken) == 0 {
+			newConfig.DebugToken = pc.CurrentConfig.DebugToken
+		}
+		if len(newConfig.ConfigToken) == 0 {
+			newConfig.ConfigToken = pc.CurrentConfig.ConfigToken
+		}
+		if len(newConfig.TelegramBotToken) == 0 {
+			newConfig.TelegramBotToken = pc.CurrentConfig.TelegramBotToken
+		}
+	}
+	data, err := json.MarshalIndent(newConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(pc.ConfigPath, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	if fs, err := os.Lstat(pc.ConfigPath); err == nil {
+		pc.LastMod = fs.ModTime()
+	}
+	pc.CurrentConfig = newConfig
+	log.Printf("New configuration saved")
+	return nil
+}
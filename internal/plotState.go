@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// PlotState is the state of a plot.  A plot moves through the states
+//
+//	Queued -> SpaceCheck -> Running -> [WaitingToCopy -> Copying] -> Verifying -> Finished
+//
+// and can fail (Error) or be cancelled (Killed) on the way.  The values are sent to the UI, new
+// states are added at the end so older clients keep showing the states they know.
+type PlotState int
+
+const (
+	PlotRunning PlotState = iota
+	PlotError
+	PlotFinished
+	PlotKilled
+	PlotWaitingToCopy
+	PlotCopying
+	PlotQueued
+	PlotSpaceCheck
+	PlotVerifying
+)
+
+// plotTransitions lists the states a plot can move to from each state, final states have none.
+var plotTransitions = map[PlotState][]PlotState{
+	PlotQueued:        {PlotSpaceCheck, PlotError, PlotKilled},
+	PlotSpaceCheck:    {PlotRunning, PlotError, PlotKilled},
+	PlotRunning:       {PlotWaitingToCopy, PlotVerifying, PlotError, PlotKilled},
+	PlotWaitingToCopy: {PlotCopying, PlotError, PlotKilled},
+	PlotCopying:       {PlotVerifying, PlotError, PlotKilled},
+	PlotVerifying:     {PlotFinished, PlotError},
+}
+
+// String returns the name of a plot state shown to the user.
+func (state PlotState) String() string {
+	switch state {
+	case PlotQueued:
+		return "Queued"
+	case PlotSpaceCheck:
+		return "Checking space"
+	case PlotRunning:
+		return "Running"
+	case PlotWaitingToCopy:
+		return "Waiting to copy"
+	case PlotCopying:
+		return "Copying"
+	case PlotVerifying:
+		return "Verifying"
+	case PlotError:
+		return "Errored"
+	case PlotFinished:
+		return "Finished"
+	case PlotKilled:
+		return "Killed"
+	}
+	return "Unknown"
+}
+
+// Final reports whether the plot is done, successfully or not.
+func (state PlotState) Final() bool {
+	return state == PlotFinished || state == PlotError || state == PlotKilled
+}
+
+func (state PlotState) canMoveTo(to PlotState) bool {
+	for _, next := range plotTransitions[state] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StateChange records when a plot entered a state.
+type StateChange struct {
+	State PlotState
+	Time  time.Time
+}
+
+// now returns the time of the plot's clock.
+func (ap *ActivePlot) now() time.Time {
+	if ap.clock == nil {
+		return time.Now()
+	}
+	return ap.clock.Now()
+}
+
+// setState moves the plot to a new state, refusing transitions the state machine doesn't allow.
+func (ap *ActivePlot) setState(to PlotState) bool {
+	ap.lock.Lock()
+	from := ap.State
+	if !from.canMoveTo(to) {
+		ap.lock.Unlock()
+		log.Printf("Plot [%d] can't go from %s to %s", ap.PlotId, from, to)
+		return false
+	}
+	now := ap.now()
+	ap.State = to
+	if to.Final() {
+		ap.EndTime = now
+	}
+	ap.StateHistory = append(ap.StateHistory, StateChange{State: to, Time: now})
+	ap.changed()
+	ap.lock.Unlock()
+	ap.events.publish(Event{Type: EventStateChanged, Time: now, Plot: ap, From: from, To: to})
+	if to.Final() {
+		ap.events.publish(Event{Type: EventPlotFinished, Time: now, Plot: ap, From: from, To: to})
+	}
+	return true
+}
+
+// fail moves the plot to PlotError, keeping the reason and its category for the UI.
+func (ap *ActivePlot) fail(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	ap.lock.Lock()
+	ap.LastError = message
+	ap.Failure = classifyFailure(message, ap.exited, ap.exitCode, ap.recentLog)
+	ap.changed()
+	ap.lock.Unlock()
+	log.Printf("Plot [%d] %s", ap.PlotId, message)
+	ap.setState(PlotError)
+}
+
+// archiveFinishedPlot moves plots which reached a final state, killed plots included, from the
+// active plots to the archive so the scheduler can start the next one.
+func (server *Server) archiveFinishedPlot(event Event) {
+	server.lock.Lock()
+	if _, ok := server.active[event.Plot.PlotId]; ok {
+		server.archive = append(server.archive, event.Plot)
+		delete(server.active, event.Plot.PlotId)
+		event.Plot.lock.Lock()
+		event.Plot.changed() // so clients syncing deltas see it move to the archive
+		event.Plot.lock.Unlock()
+	}
+	server.lock.Unlock()
+}
+
+func (server *Server) notifyPlotFailure(event Event) {
+	if event.To != PlotError {
+		return
+	}
+	server.config.Lock.RLock()
+	config := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	plot := event.Plot.Snapshot()
+	notify(config, "Plot failed", fmt.Sprintf("Plot [%s] in %s failed while %s: %s", plot.Id, plot.PlotDir, strings.ToLower(event.From.String()), plot.LastError))
+}
+
+// countTransition counts the plots which entered each state and the failures of each category,
+// for the metrics.
+func (server *Server) countTransition(event Event) {
+	failure := FailureNone
+	if event.To == PlotError {
+		failure = event.Plot.Snapshot().Failure
+	}
+	server.lock.Lock()
+	server.stateCounts[event.To]++
+	if failure != FailureNone {
+		server.failureCounts[failure]++
+	}
+	server.lock.Unlock()
+}
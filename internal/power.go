@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const nutDefaultPort = "3493"
+
+// upsOnBattery queries a NUT (Network UPS Tools) server for the status of the UPS named
+// "ups@host[:port]".  The special name "system" reads the mains power supplies of the Linux kernel instead.
+func upsOnBattery(upsName string) (bool, error) {
+	if upsName == "system" {
+		return systemOnBattery()
+	}
+	ups, host := upsName, "localhost"
+	if idx := strings.Index(upsName, "@"); idx >= 0 {
+		ups, host = upsName[:idx], upsName[idx+1:]
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, nutDefaultPort)
+	}
+	conn, err := net.DialTimeout("tcp", host, 5*time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprintf(conn, "GET VAR %s ups.status\n", ups); err != nil {
+		return false, err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	// VAR <ups> ups.status "OB DISCHRG"
+	if !strings.HasPrefix(reply, "VAR ") {
+		return false, fmt.Errorf("unexpected reply from NUT server: %s", strings.TrimSpace(reply))
+	}
+	parts := strings.SplitN(reply, "\"", 3)
+	if len(parts) < 2 {
+		return false, fmt.Errorf("unexpected reply from NUT server: %s", strings.TrimSpace(reply))
+	}
+	for _, flag := range strings.Fields(parts[1]) {
+		if flag == "OB" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// systemOnBattery reports whether every mains power supply known to the kernel is offline.
+func systemOnBattery() (bool, error) {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	foundMains := false
+	for _, supply := range supplies {
+		supplyType, err := ioutil.ReadFile(filepath.Join(supply, "type"))
+		if err != nil || strings.TrimSpace(string(supplyType)) != "Mains" {
+			continue
+		}
+		foundMains = true
+		if online, err := ioutil.ReadFile(filepath.Join(supply, "online")); err == nil && strings.TrimSpace(string(online)) == "1" {
+			return false, nil
+		}
+	}
+	if !foundMains {
+		return false, fmt.Errorf("no mains power supply found")
+	}
+	return true, nil
+}
+
+// checkPower pauses new plots while running on battery, and optionally stops all active plots.
+func (server *Server) checkPower(config *Config) {
+	if len(config.UpsName) == 0 {
+		server.onBattery = false
+		return
+	}
+	onBattery, err := upsOnBattery(config.UpsName)
+	if err != nil {
+		log.Printf("Failed to check UPS [%s]: %s", config.UpsName, err)
+		return
+	}
+	if onBattery == server.onBattery {
+		return
+	}
+	server.onBattery = onBattery
+	if !onBattery {
+		server.schedulerEvent("Mains power restored, resuming new plots")
+		notify(config, "Power restored", "Mains power restored, resuming new plots")
+		return
+	}
+	if config.OnBatteryAction == "stop" {
+		server.schedulerEvent("Running on battery, stopping all active plots")
+		notify(config, "Running on battery", "Running on battery, stopping all active plots")
+		server.lock.Lock()
+		for _, plot := range server.active {
+			plot.kill()
+		}
+		server.lock.Unlock()
+	} else {
+		server.schedulerEvent("Running on battery, pausing new plots")
+		notify(config, "Running on battery", "Running on battery, pausing new plots")
+	}
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// plotUser is the user the plotter runs as, or who owns the plots, when PlotNG runs as root eg. to
+// create cgroups or mount a tmpfs.
+type plotUser struct {
+	name   string
+	uid    int
+	gid    int
+	groups []uint32 // supplementary groups
+	home   string
+}
+
+// lookupPlotUser finds the user of RunAsUser or PlotOwner, "user" or "user:group" by name or id.
+// Without group, the primary group of the user is used.
+func lookupPlotUser(spec string) (*plotUser, error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("not supported on Windows")
+	}
+	name, groupName := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, groupName = spec[:i], spec[i+1:]
+	}
+	u, err := user.Lookup(name)
+	if _, numeric := strconv.Atoi(name); err != nil && numeric == nil {
+		u, err = user.LookupId(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pu := &plotUser{name: u.Username, home: u.HomeDir}
+	if pu.uid, err = strconv.Atoi(u.Uid); err != nil {
+		return nil, fmt.Errorf("user %s has no numeric id", name)
+	}
+	gid := u.Gid
+	if len(groupName) > 0 {
+		g, err := user.LookupGroup(groupName)
+		if _, numeric := strconv.Atoi(groupName); err != nil && numeric == nil {
+			g, err = user.LookupGroupId(groupName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		gid = g.Gid
+	}
+	if pu.gid, err = strconv.Atoi(gid); err != nil {
+		return nil, fmt.Errorf("group %s has no numeric id", gid)
+	}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if n, err := strconv.Atoi(id); err == nil {
+				pu.groups = append(pu.groups, uint32(n))
+			}
+		}
+	}
+	return pu, nil
+}
+
+// validatePlotUsers checks that the users of RunAsUser and PlotOwner exist.
+func validatePlotUsers(config *Config) error {
+	if len(config.RunAsUser) > 0 {
+		if _, err := lookupPlotUser(config.RunAsUser); err != nil {
+			return fmt.Errorf("RunAsUser %s: %s", config.RunAsUser, err)
+		}
+	}
+	if len(config.PlotOwner) > 0 {
+		if _, err := lookupPlotUser(config.PlotOwner); err != nil {
+			return fmt.Errorf("PlotOwner %s: %s", config.PlotOwner, err)
+		}
+	}
+	return nil
+}
+
+// plotUsers returns the user to run the plotter as and the owner of the plots, nil unless PlotNG
+// runs as root.  The plots belong to RunAsUser unless PlotOwner is set.
+func (config *Config) plotUsers() (runAs *plotUser, owner *plotUser) {
+	if os.Geteuid() != 0 {
+		return nil, nil
+	}
+	var err error
+	if len(config.RunAsUser) > 0 {
+		if runAs, err = lookupPlotUser(config.RunAsUser); err != nil {
+			log.Printf("RunAsUser %s: %s, running the plotter as root", config.RunAsUser, err)
+		}
+	}
+	owner = runAs
+	if len(config.PlotOwner) > 0 {
+		if owner, err = lookupPlotUser(config.PlotOwner); err != nil {
+			log.Printf("PlotOwner %s: %s, the plots are left as they are", config.PlotOwner, err)
+		}
+	}
+	return runAs, owner
+}
+
+// chown gives path to the user, the error is logged as the plot can be used anyway.
+func (pu *plotUser) chown(path string) {
+	if pu == nil {
+		return
+	}
+	if err := os.Chown(path, pu.uid, pu.gid); err != nil {
+		log.Printf("Failed to give %s to %s: %s", path, pu.name, err)
+	}
+}
+
+// parseMode parses the octal mode bits of PlotFileMode or PlotDirMode, 0 when not set.
+func parseMode(mode string) (os.FileMode, error) {
+	if len(mode) == 0 {
+		return 0, nil
+	}
+	bits, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || bits > 0777 || bits == 0 {
+		return 0, fmt.Errorf("%s is not an octal mode like 0644", mode)
+	}
+	return os.FileMode(bits), nil
+}
+
+// validatePlotModes checks PlotFileMode and PlotDirMode.
+func validatePlotModes(config *Config) error {
+	if _, err := parseMode(config.PlotFileMode); err != nil {
+		return fmt.Errorf("PlotFileMode: %s", err)
+	}
+	if _, err := parseMode(config.PlotDirMode); err != nil {
+		return fmt.Errorf("PlotDirMode: %s", err)
+	}
+	return nil
+}
+
+// plotModes returns the mode bits of the final plots and of the subfolders created in the dest
+// directories, 0 to leave them to the umask.
+func (config *Config) plotModes() (fileMode os.FileMode, dirMode os.FileMode) {
+	fileMode, _ = parseMode(config.PlotFileMode)
+	dirMode, _ = parseMode(config.PlotDirMode)
+	return fileMode, dirMode
+}
+
+// chmod sets the mode bits of path unless mode is 0, the error is logged as the plot can be used
+// anyway.
+func chmod(path string, mode os.FileMode) {
+	if mode == 0 {
+		return
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		log.Printf("Failed to change the mode of %s to %04o: %s", path, mode, err)
+	}
+}
+
+// makeSubfolder creates a subfolder of a dest directory with PlotDirMode and gives it to owner,
+// with the folders above it it creates.  Existing folders are left as they are.
+func (ap *ActivePlot) makeSubfolder(dir string, owner *plotUser) error {
+	fs := ap.filesystem()
+	var created []string
+	for missing := dir; ; missing = filepath.Dir(missing) {
+		if _, err := fs.Stat(missing); err == nil || missing == filepath.Dir(missing) {
+			break
+		}
+		created = append(created, missing)
+	}
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, folder := range created {
+		chmod(folder, ap.dirMode)
+		owner.chown(folder)
+	}
+	return nil
+}
+
+// setFinalPlotAccess gives the final plot of a local target to its owner with PlotFileMode, as the
+// plotter or the copy created it as its own user with its umask.
+func (ap *ActivePlot) setFinalPlotAccess() {
+	id := ap.Snapshot().Id
+	if (ap.owner == nil && ap.fileMode == 0) || isRemoteTarget(ap.TargetDir) || len(id) == 0 {
+		return
+	}
+	if name, err := findFinalPlot(ap.filesystem(), ap.finalDir(), id); err == nil {
+		chmod(filepath.Join(ap.finalDir(), name), ap.fileMode)
+		ap.owner.chown(filepath.Join(ap.finalDir(), name))
+	}
+}
@@ -0,0 +1,31 @@
+// +build !windows
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// runAs makes cmd run as the user, with its home directory so chia finds the keys of the user.
+func (pu *plotUser) runAs(cmd *exec.Cmd) {
+	if pu == nil {
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(pu.uid), Gid: uint32(pu.gid), Groups: pu.groups},
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = nil
+	for _, v := range env {
+		if !strings.HasPrefix(v, "HOME=") && !strings.HasPrefix(v, "USER=") && !strings.HasPrefix(v, "LOGNAME=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	cmd.Env = append(cmd.Env, "HOME="+pu.home, "USER="+pu.name, "LOGNAME="+pu.name)
+}
@@ -0,0 +1,9 @@
+// +build windows
+
+package internal
+
+import "os/exec"
+
+// runAs does nothing, RunAsUser is rejected on Windows.
+func (pu *plotUser) runAs(cmd *exec.Cmd) {
+}
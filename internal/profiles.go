@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PlotProfile is a named set of plot settings sharing the temp directories with the other
+// profiles, eg. plots for two pools or two k-sizes.  Plot starts are shared between the profiles
+// in proportion to their Weight, 1 when left out.  Empty settings fall back to the configuration.
+type PlotProfile struct {
+	Name                string
+	Weight              int
+	TargetDirectory     []string
+	Fingerprint         string
+	FarmerPublicKey     string
+	PoolPublicKey       string
+	PoolContractAddress string
+	PlotSize            int
+	Threads             int
+	Labels              []string
+}
+
+func (profile *PlotProfile) weight() int {
+	if profile.Weight <= 0 {
+		return 1
+	}
+	return profile.Weight
+}
+
+func (profile *PlotProfile) validate() error {
+	if len(profile.Name) == 0 {
+		return fmt.Errorf("profile without Name")
+	}
+	if profile.Weight < 0 {
+		return fmt.Errorf("Weight of profile %s can't be negative", profile.Name)
+	}
+	if profile.PlotSize != 0 && (profile.PlotSize < 25 || profile.PlotSize > 35) {
+		return fmt.Errorf("invalid PlotSize of profile %s: %d", profile.Name, profile.PlotSize)
+	}
+	if profile.Threads < 0 {
+		return fmt.Errorf("Threads of profile %s can't be negative", profile.Name)
+	}
+	return nil
+}
+
+// apply returns config with the settings of the profile.
+func (profile *PlotProfile) apply(config *Config) *Config {
+	c := *config
+	if len(profile.TargetDirectory) > 0 {
+		c.TargetDirectory = profile.TargetDirectory
+	}
+	if len(profile.Fingerprint) > 0 || len(profile.FarmerPublicKey) > 0 || len(profile.PoolPublicKey) > 0 {
+		c.Fingerprint = profile.Fingerprint
+		c.FarmerPublicKey = profile.FarmerPublicKey
+		c.PoolPublicKey = profile.PoolPublicKey
+	}
+	if len(profile.PoolContractAddress) > 0 {
+		c.PoolContractAddress = profile.PoolContractAddress
+	}
+	if profile.PlotSize > 0 {
+		c.PlotSize = profile.PlotSize
+	}
+	if profile.Threads > 0 {
+		c.Threads = profile.Threads
+	}
+	if len(profile.Labels) > 0 {
+		c.Labels = profile.Labels
+	}
+	return &c
+}
+
+// profileRotation shares the plot starts between the profiles by smooth weighted round robin:
+// every start adds its weight to the credit of each profile and takes the total weight from the
+// profile which started, the profile with the most credit is asked first.  A profile which can't
+// start, eg. because its dest directories are full, keeps its credit, up to one round, so it
+// catches up without starving the others afterwards.
+type profileRotation struct {
+	schedulers map[string]Scheduler
+	credit     map[string]int
+}
+
+// order returns the profiles in the order they are asked for a plot.
+func (rotation *profileRotation) order(profiles []PlotProfile) []*PlotProfile {
+	var ordered []*PlotProfile
+	for i := range profiles {
+		ordered = append(ordered, &profiles[i])
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rotation.credit[ordered[i].Name]+ordered[i].weight() > rotation.credit[ordered[j].Name]+ordered[j].weight()
+	})
+	return ordered
+}
+
+// started updates the credits after profile started a plot.
+func (rotation *profileRotation) started(profiles []PlotProfile, profile string) {
+	total := 0
+	for i := range profiles {
+		total += profiles[i].weight()
+	}
+	for i := range profiles {
+		name := profiles[i].Name
+		rotation.credit[name] += profiles[i].weight()
+		if name == profile {
+			rotation.credit[name] -= total
+		}
+		if rotation.credit[name] > total {
+			rotation.credit[name] = total
+		} else if rotation.credit[name] < -total {
+			rotation.credit[name] = -total
+		}
+	}
+}
+
+// scheduleProfiles asks the scheduler of each profile for a plot, in weighted round robin order,
+// and starts the first one.  The caller must hold server.lock.
+func (server *Server) scheduleProfiles(config *Config, now time.Time) {
+	if server.profiles == nil {
+		server.profiles = &profileRotation{schedulers: map[string]Scheduler{}, credit: map[string]int{}}
+	}
+	for _, profile := range server.profiles.order(config.Profiles) {
+		scheduler, ok := server.profiles.schedulers[profile.Name]
+		if !ok {
+			scheduler = newScheduler(config.Scheduler)
+			server.profiles.schedulers[profile.Name] = scheduler
+		}
+		profileConfig := profile.apply(config)
+		state := server.schedulerState(profileConfig, now)
+		state.ExtraPlots = server.idleExtraPlots(config, now)
+		decision := scheduler.NextJob(state)
+		if len(decision.Reason) > 0 {
+			server.schedulerEvent("Profile [%s]: %s", profile.Name, decision.Reason)
+		}
+		if decision.Job != nil {
+			server.startDecision(config, decision, now, "") // jobs bring their own settings
+			return
+		}
+		if server.startDecision(profileConfig, decision, now, profile.Name) {
+			server.profiles.started(config.Profiles, profile.Name)
+			return
+		}
+	}
+}
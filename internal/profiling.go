@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RuntimeMetrics are the Go runtime figures of the server, to spot leaks in long running daemons,
+// how many plots entered each state and how many failed for each reason since the start.
+type RuntimeMetrics struct {
+	Uptime       time.Duration
+	Goroutines   int
+	HeapAlloc    uint64
+	HeapInuse    uint64
+	HeapObjects  uint64
+	Sys          uint64
+	TotalAlloc   uint64
+	NumGC        uint32
+	PauseTotalNs uint64
+	LastGC       time.Time
+	PlotStates   map[string]int
+	PlotFailures map[string]int
+}
+
+// debugAuthorized checks the DebugToken, given as a bearer token or as the token parameter.  The
+// profiling endpoints are disabled while no DebugToken is configured.
+func (server *Server) debugAuthorized(req *http.Request) bool {
+	server.config.Lock.RLock()
+	defer server.config.Lock.RUnlock()
+	if server.config.CurrentConfig == nil || len(server.config.CurrentConfig.DebugToken) == 0 {
+		return false
+	}
+	token := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(server.config.CurrentConfig.DebugToken)) == 1
+}
+
+// handleProfiling serves the net/http/pprof profiles under /debug/pprof/ and the runtime metrics
+// on /debug/metrics.
+func (server *Server) handleProfiling(resp http.ResponseWriter, req *http.Request) {
+	if !server.debugAuthorized(req) {
+		http.Error(resp, "Forbidden", http.StatusForbidden)
+		return
+	}
+	switch req.URL.Path {
+	case "/debug/metrics":
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		metrics := RuntimeMetrics{
+			Uptime:       time.Since(server.startTime),
+			Goroutines:   runtime.NumGoroutine(),
+			HeapAlloc:    stats.HeapAlloc,
+			HeapInuse:    stats.HeapInuse,
+			HeapObjects:  stats.HeapObjects,
+			Sys:          stats.Sys,
+			TotalAlloc:   stats.TotalAlloc,
+			NumGC:        stats.NumGC,
+			PauseTotalNs: stats.PauseTotalNs,
+			LastGC:       time.Unix(0, int64(stats.LastGC)),
+			PlotStates:   map[string]int{},
+			PlotFailures: map[string]int{},
+		}
+		server.lock.RLock()
+		for state, count := range server.stateCounts {
+			metrics.PlotStates[state.String()] = count
+		}
+		for failure, count := range server.failureCounts {
+			metrics.PlotFailures[failure.String()] = count
+		}
+		server.lock.RUnlock()
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(metrics)
+	case "/debug/pprof/cmdline":
+		pprof.Cmdline(resp, req)
+	case "/debug/pprof/profile":
+		pprof.Profile(resp, req)
+	case "/debug/pprof/symbol":
+		pprof.Symbol(resp, req)
+	case "/debug/pprof/trace":
+		pprof.Trace(resp, req)
+	default:
+		pprof.Index(resp, req)
+	}
+}
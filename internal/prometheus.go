@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The metrics served on /metrics in the Prometheus text format, which the alerting rules of
+// WriteAlertRules are written against.
+const (
+	metricStartTime      = "plotng_start_time_seconds"
+	metricActivePlots    = "plotng_plots_active"
+	metricQueuedJobs     = "plotng_jobs_queued"
+	metricPlotCredits    = "plotng_plot_credits"
+	metricDroppedEvents  = "plotng_events_dropped_total"
+	metricFinishedPlots  = "plotng_plots_finished_total"
+	metricFailedPlots    = "plotng_plots_failed_total"
+	metricAvailableBytes = "plotng_directory_available_bytes"
+	metricSizeBytes      = "plotng_directory_size_bytes"
+)
+
+// metricsWriter writes metrics in the Prometheus text format, with the HELP and TYPE lines before
+// the first sample of every metric.
+type metricsWriter struct {
+	b    strings.Builder
+	seen map[string]bool
+}
+
+func (mw *metricsWriter) sample(name string, kind string, help string, labels map[string]string, value float64) {
+	if !mw.seen[name] {
+		if mw.seen == nil {
+			mw.seen = map[string]bool{}
+		}
+		mw.seen[name] = true
+		fmt.Fprintf(&mw.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	var pairs []string
+	for label, value := range labels {
+		escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escaped))
+	}
+	sort.Strings(pairs)
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(&mw.b, "%s %s\n", name, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// handleMetrics serves the plots and the space of the directories on GET /metrics for Prometheus.
+// The counters start from 0 when the server starts.
+func (server *Server) handleMetrics(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	mw := &metricsWriter{}
+	mw.sample(metricStartTime, "gauge", "Time the server started, in seconds since the epoch.", nil, float64(server.startTime.Unix()))
+	server.lock.RLock()
+	mw.sample(metricActivePlots, "gauge", "Plots running.", nil, float64(len(server.active)))
+	mw.sample(metricQueuedJobs, "gauge", "Jobs queued.", nil, float64(len(server.queue)))
+	mw.sample(metricDroppedEvents, "counter", "Phase and state changes dropped because the event queue was full.", nil, float64(server.bus.droppedEvents()))
+	if server.credits != nil && server.credits.Enabled {
+		mw.sample(metricPlotCredits, "gauge", "Plot credits left, with PlotCredits set.", nil, server.credits.Credits)
+	}
+	mw.sample(metricFinishedPlots, "counter", "Plots finished since the server started.", nil, float64(server.stateCounts[PlotFinished]))
+	var failures []FailureCategory
+	for failure := range server.failureCounts {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].String() < failures[j].String() })
+	for _, failure := range failures {
+		mw.sample(metricFailedPlots, "counter", "Plots failed since the server started, by failure category.",
+			map[string]string{"category": failure.String()}, float64(server.failureCounts[failure]))
+	}
+	if len(failures) == 0 {
+		mw.sample(metricFailedPlots, "counter", "Plots failed since the server started, by failure category.",
+			map[string]string{"category": FailureOther.String()}, 0)
+	}
+	server.lock.RUnlock()
+
+	server.config.Lock.RLock()
+	var tempDirs, targetDirs []string
+	if config := server.config.CurrentConfig; config != nil {
+		tempDirs = append(tempDirs, config.TempDirectory...)
+		targetDirs = append(targetDirs, config.TargetDirectory...)
+	}
+	server.config.Lock.RUnlock()
+	type dirSpace struct {
+		labels          map[string]string
+		available, size uint64
+	}
+	var spaces []dirSpace
+	fs := server.filesystem()
+	for _, dirs := range []struct {
+		kind string
+		list []string
+	}{{"temp", tempDirs}, {"dest", targetDirs}} {
+		for _, dir := range dirs.list {
+			available, size := fs.Available(dir), fs.Size(dir)
+			if available == math.MaxUint64 || size == math.MaxUint64 {
+				continue // remote targets
+			}
+			spaces = append(spaces, dirSpace{map[string]string{"directory": dir, "kind": dirs.kind}, available, size})
+		}
+	}
+	// the samples of a metric are written together
+	for _, space := range spaces {
+		mw.sample(metricAvailableBytes, "gauge", "Space available in the directory.", space.labels, float64(space.available))
+	}
+	for _, space := range spaces {
+		mw.sample(metricSizeBytes, "gauge", "Size of the filesystem of the directory.", space.labels, float64(space.size))
+	}
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	io.WriteString(resp, mw.b.String())
+}
+
+// AlertRuleOptions are the thresholds of the alerting rules.
+type AlertRuleOptions struct {
+	FailureRate  float64       // share of failed plots over Window
+	Window       time.Duration // of the failure rate
+	TempFree     float64       // percentage of free space below which a temp directory alerts
+	DestPlots    int           // plots of room left in all the dest directories of a host
+	NoPlotsHours int           // hours without a finished plot
+}
+
+// WriteAlertRules writes a Prometheus rule file with alerts on the metrics of /metrics: a high
+// failure rate, a temp directory nearly full, the dest directories nearly full and no plot
+// finished for a while.
+func WriteAlertRules(w io.Writer, options AlertRuleOptions) error {
+	window := promDuration(options.Window)
+	failed := fmt.Sprintf("sum by (instance) (increase(%s[%s]))", metricFailedPlots, window)
+	finished := fmt.Sprintf("sum by (instance) (increase(%s[%s]))", metricFinishedPlots, window)
+	rules := []struct {
+		name, expr, wait, severity, summary, description string
+	}{
+		{
+			name:        "PlotNGFailureRate",
+			expr:        fmt.Sprintf("%s / (%s + %s) > %g", failed, failed, finished, options.FailureRate),
+			wait:        "15m",
+			severity:    "warning",
+			summary:     "Many plots fail on {{ $labels.instance }}",
+			description: fmt.Sprintf("{{ $value | humanizePercentage }} of the plots failed in the last %s.", window),
+		},
+		{
+			name:        "PlotNGTempDirectoryFull",
+			expr:        fmt.Sprintf(`%s{kind="temp"} / %s{kind="temp"} * 100 < %g`, metricAvailableBytes, metricSizeBytes, options.TempFree),
+			wait:        "10m",
+			severity:    "warning",
+			summary:     "Temp directory {{ $labels.directory }} nearly full on {{ $labels.instance }}",
+			description: fmt.Sprintf("Only {{ $value | humanize }}%% of the temp directory is free, below %g%%.", options.TempFree),
+		},
+		{
+			name:        "PlotNGDestDirectoriesFull",
+			expr:        fmt.Sprintf(`sum by (instance) (%s{kind="dest"}) < %d`, metricAvailableBytes, uint64(options.DestPlots)*PLOT_SIZE),
+			wait:        "10m",
+			severity:    "warning",
+			summary:     "Dest directories nearly full on {{ $labels.instance }}",
+			description: fmt.Sprintf("{{ $value | humanize1024 }}B left in the dest directories, less than %d plots.", options.DestPlots),
+		},
+		{
+			name: "PlotNGNoPlotsFinished",
+			expr: fmt.Sprintf("sum by (instance) (increase(%s[%dh])) == 0 and on (instance) time() - %s > %d",
+				metricFinishedPlots, options.NoPlotsHours, metricStartTime, options.NoPlotsHours*3600),
+			wait:        "0m",
+			severity:    "critical",
+			summary:     "No plot finished on {{ $labels.instance }}",
+			description: fmt.Sprintf("No plot finished in the last %d hours.", options.NoPlotsHours),
+		},
+	}
+	var b strings.Builder
+	b.WriteString("# Alerting rules for the metrics PlotNG serves on /metrics, generated by plotng alert-rules.\n")
+	b.WriteString("groups:\n  - name: plotng\n    rules:\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "      - alert: %s\n", rule.name)
+		fmt.Fprintf(&b, "        expr: %s\n", yamlQuote(rule.expr))
+		fmt.Fprintf(&b, "        for: %s\n", rule.wait)
+		fmt.Fprintf(&b, "        labels:\n          severity: %s\n", rule.severity)
+		fmt.Fprintf(&b, "        annotations:\n          summary: %s\n          description: %s\n", yamlQuote(rule.summary), yamlQuote(rule.description))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteAlertmanagerConfig writes an example Alertmanager configuration sending the PlotNG alerts
+// to a webhook, grouped by host.
+func WriteAlertmanagerConfig(w io.Writer, webhook string) error {
+	_, err := fmt.Fprintf(w, `# Example Alertmanager configuration for the PlotNG alerts, generated by plotng alert-rules.
+route:
+  receiver: plotng
+  group_by: [alertname, instance]
+  group_wait: 30s
+  group_interval: 5m
+  repeat_interval: 4h
+receivers:
+  - name: plotng
+    webhook_configs:
+      - url: %s
+        send_resolved: true
+inhibit_rules:
+  # a full temp directory makes plots fail, only its alert is sent
+  - source_matchers: [alertname = "PlotNGTempDirectoryFull"]
+    target_matchers: [alertname = "PlotNGFailureRate"]
+    equal: [instance]
+`, yamlQuote(webhook))
+	return err
+}
+
+// promDuration formats d as a Prometheus duration, in whole minutes.
+func promDuration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// yamlQuote returns s as a double quoted YAML string.
+func yamlQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// plotCustomer returns the key a plot is made for, used to account plots against Quotas.  Plots
+// for a pool contract are keyed by the contract too, eg. "1234567890/xch1...", so that the NFT
+// plots of different contracts of the same keys have their own quotas.
+func plotCustomer(fingerprint, farmerPublicKey, poolPublicKey, poolContract string) string {
+	customer := farmerPublicKey
+	switch {
+	case len(fingerprint) > 0:
+		customer = fingerprint
+	case len(poolPublicKey) > 0:
+		customer = poolPublicKey
+	}
+	if len(poolContract) > 0 {
+		customer += "/" + poolContract
+	}
+	return customer
+}
+
+func (ps *PlotStatus) customer() string {
+	return plotCustomer(ps.Fingerprint, ps.FarmerPublicKey, ps.PoolPublicKey, ps.PoolContract)
+}
+
+// quotaCounts are the plots finished for every customer.  They are kept next to the
+// configuration file, so the quotas neither restart from 0 when the server restarts nor when the
+// janitor prunes the archive.
+type quotaCounts struct {
+	Finished map[string]int
+
+	path string
+}
+
+func quotaPath(configPath string) string {
+	return configPath + ".quotas"
+}
+
+func loadQuotaCounts(path string) *quotaCounts {
+	counts := &quotaCounts{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read quota counts %s: %s", path, err)
+		}
+	} else if err := json.Unmarshal(data, counts); err != nil {
+		log.Printf("Failed to parse quota counts %s: %s", path, err)
+	}
+	if counts.Finished == nil {
+		counts.Finished = map[string]int{}
+	}
+	return counts
+}
+
+func (counts *quotaCounts) save() error {
+	if len(counts.path) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(counts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(counts.path, data, 0644)
+}
+
+// countQuotaPlot counts the finished plots towards the quota of their customer.
+func (server *Server) countQuotaPlot(event Event) {
+	if event.To != PlotFinished {
+		return
+	}
+	status := event.Plot.Snapshot()
+	customer := status.customer()
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.quotaCounts.Finished[customer]++
+	if err := server.quotaCounts.save(); err != nil {
+		log.Printf("Failed to save quota counts: %s", err)
+	}
+}
+
+// quotaFulfilled reports whether the plots finished and in progress for customer already cover
+// its quota.  The plots done but not archived yet are left out, the finished ones are already
+// counted.  Customers without a quota are never fulfilled.
+func (server *Server) quotaFulfilled(config *Config, customer string) bool {
+	quota, ok := config.Quotas[customer]
+	if !ok {
+		return false
+	}
+	count := server.quotaCounts.Finished[customer]
+	for _, plot := range server.active {
+		if plot.customer() == customer && !plot.currentState().Final() {
+			count++
+		}
+	}
+	if count >= quota {
+		server.schedulerEvent("Skipping, quota of %d plots for [%s] fulfilled", quota, customer)
+		return true
+	}
+	return false
+}
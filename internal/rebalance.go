@@ -0,0 +1,372 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RebalanceRequest asks for plots to be moved from the fullest to the emptiest dest directories
+// until their fill levels are within Tolerance percent of each other.
+type RebalanceRequest struct {
+	DryRun          bool
+	Targets         []string
+	MaxMoves        int
+	MaxTransferRate float64
+	Tolerance       float64
+}
+
+// RebalanceMove is a plot file moved from one dest directory to another.
+type RebalanceMove struct {
+	Source           string
+	TargetDir        string
+	Subfolder        string
+	Size             uint64
+	State            string
+	Error            string
+	TransferredBytes uint64
+	TransferRate     uint64
+
+	plot *ActivePlot
+}
+
+// Rebalance is the plan of a rebalance, with the progress of its moves once it runs.
+type Rebalance struct {
+	DryRun    bool
+	Running   bool
+	StartTime time.Time
+	Moves     []*RebalanceMove
+
+	cancel context.CancelFunc
+}
+
+const (
+	MovePlanned   = "planned"
+	MoveRunning   = "moving"
+	MoveDone      = "done"
+	MoveFailed    = "failed"
+	MoveCancelled = "cancelled"
+)
+
+// defaultRebalanceTolerance is the fill level difference in percent below which drives count as balanced.
+const defaultRebalanceTolerance = 2
+
+type driveFill struct {
+	dir   string
+	size  uint64
+	used  uint64
+	plots []plotFile
+}
+
+type plotFile struct {
+	path      string
+	size      uint64
+	modTime   time.Time
+	subfolder string
+}
+
+func (df *driveFill) level() float64 {
+	if df.size == 0 {
+		return 1
+	}
+	return float64(df.used) / float64(df.size)
+}
+
+// diskFill returns the size and used space of a local or ssh dest directory.
+func diskFill(ctx context.Context, fs Filesystem, dir string) (*driveFill, error) {
+	if userHost, port, remoteDir, ok := parseRemoteTarget(dir); ok {
+		args := []string{"-o", "BatchMode=yes"}
+		if len(port) > 0 {
+			args = append(args, "-p", port)
+		}
+		args = append(args, userHost, "df -P -B1 "+shellQuote(remoteDir))
+		out, err := exec.CommandContext(ctx, "ssh", args...).Output()
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		fields := strings.Fields(lines[len(lines)-1])
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("unexpected df output: %s", out)
+		}
+		size, err1 := strconv.ParseUint(fields[1], 10, 64)
+		available, err2 := strconv.ParseUint(fields[3], 10, 64)
+		if err1 != nil || err2 != nil || available > size {
+			return nil, fmt.Errorf("unexpected df output: %s", out)
+		}
+		return &driveFill{dir: dir, size: size, used: size - available}, nil
+	}
+	if isRemoteTarget(dir) {
+		return nil, fmt.Errorf("%s has no fill level", dir)
+	}
+	if err := probeDirectory(fs, dir); err != nil {
+		return nil, err
+	}
+	size := fs.Size(dir)
+	fill := &driveFill{dir: dir, size: size, used: size - fs.Available(dir)}
+	fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(info.Name(), ".plot") {
+			fill.plots = append(fill.plots, plotFile{path: path, size: uint64(info.Size()), subfolder: plotSubfolderOf(dir, path)})
+		}
+		return nil
+	})
+	return fill, nil
+}
+
+// planRebalance moves plots one at a time from the fullest drive with plots to the emptiest one,
+// as long as it narrows the gap between them and there is room for the plot.
+func planRebalance(fills []*driveFill, maxMoves int, tolerance float64) []*RebalanceMove {
+	var moves []*RebalanceMove
+	for maxMoves <= 0 || len(moves) < maxMoves {
+		sort.Slice(fills, func(i, j int) bool { return fills[i].level() < fills[j].level() })
+		empty := fills[0]
+		var full *driveFill
+		for idx := len(fills) - 1; idx > 0; idx-- {
+			if len(fills[idx].plots) > 0 {
+				full = fills[idx]
+				break
+			}
+		}
+		if full == nil || (full.level()-empty.level())*100 <= tolerance {
+			break
+		}
+		plot := full.plots[0]
+		if empty.size-empty.used < plot.size+GB {
+			break
+		}
+		before := full.level() - empty.level()
+		after := math.Abs(float64(full.used-plot.size)/float64(full.size) - float64(empty.used+plot.size)/float64(empty.size))
+		if after >= before {
+			break
+		}
+		full.plots = full.plots[1:]
+		full.used -= plot.size
+		empty.used += plot.size
+		moves = append(moves, &RebalanceMove{
+			Source:    plot.path,
+			TargetDir: empty.dir,
+			Subfolder: plot.subfolder,
+			Size:      plot.size,
+			State:     MovePlanned,
+		})
+	}
+	return moves
+}
+
+// startRebalance plans the moves for request within ctx, and runs them one after the other in the
+// background unless it is a dry run.  The moves outlive ctx, they stop when the server shuts down.
+func (server *Server) startRebalance(ctx context.Context, config *Config, request *RebalanceRequest) (*Rebalance, error) {
+	targets := request.Targets
+	if len(targets) == 0 {
+		targets = config.TargetDirectory
+	}
+	var dirs []string
+	server.lock.RLock()
+	for _, dir := range targets {
+		if !server.overlay.Disabled[dir] && !server.offlineTargets[dir] {
+			dirs = append(dirs, dir)
+		}
+	}
+	server.lock.RUnlock()
+	var fills []*driveFill
+	for _, dir := range dirs {
+		fill, err := diskFill(ctx, server.filesystem(), dir)
+		if err != nil {
+			log.Printf("Rebalance: skipping [%s]: %s", dir, err)
+			continue
+		}
+		fills = append(fills, fill)
+	}
+	// With DestinationKeys plots only move between the drives of the same farm.
+	var farms [][]*driveFill
+	farmIndex := map[DestinationKeys]int{}
+	for _, fill := range fills {
+		c := config.forTarget(fill.dir)
+		keys := DestinationKeys{c.Fingerprint, c.FarmerPublicKey, c.PoolPublicKey, c.PoolContractAddress}
+		if i, ok := farmIndex[keys]; ok {
+			farms[i] = append(farms[i], fill)
+		} else {
+			farmIndex[keys] = len(farms)
+			farms = append(farms, []*driveFill{fill})
+		}
+	}
+	tolerance := request.Tolerance
+	if tolerance <= 0 {
+		tolerance = defaultRebalanceTolerance
+	}
+	rebalance := &Rebalance{
+		DryRun:    request.DryRun,
+		StartTime: time.Now(),
+	}
+	planned := false
+	for _, farm := range farms {
+		if len(farm) < 2 || (request.MaxMoves > 0 && len(rebalance.Moves) >= request.MaxMoves) {
+			continue
+		}
+		maxMoves := request.MaxMoves
+		if maxMoves > 0 {
+			maxMoves -= len(rebalance.Moves)
+		}
+		rebalance.Moves = append(rebalance.Moves, planRebalance(farm, maxMoves, tolerance)...)
+		planned = true
+	}
+	if !planned {
+		return nil, fmt.Errorf("at least two dest directories, for the same keys, are needed to rebalance")
+	}
+	if request.DryRun {
+		return rebalance, nil
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if server.rebalance != nil && server.rebalance.Running {
+		return nil, fmt.Errorf("a rebalance is already running")
+	}
+	rate := request.MaxTransferRate
+	if rate <= 0 {
+		rate = config.RebalanceTransferRate
+	}
+	rebalance.Running = true
+	server.rebalance = rebalance
+	runCtx, cancel := context.WithCancel(server.ctx)
+	rebalance.cancel = cancel
+	go server.runRebalance(runCtx, rebalance, config, rate*float64(MB))
+	return rebalance, nil
+}
+
+func (server *Server) runRebalance(ctx context.Context, rebalance *Rebalance, config *Config, rate float64) {
+	defer rebalance.cancel()
+	log.Printf("Rebalance: moving %d plots", len(rebalance.Moves))
+	for _, move := range rebalance.Moves {
+		server.lock.Lock()
+		cancelled := ctx.Err() != nil
+		if cancelled {
+			move.State = MoveCancelled
+		} else {
+			move.State = MoveRunning
+			move.plot = &ActivePlot{
+				PlotStatus: PlotStatus{
+					Id:        filepath.Base(move.Source),
+					TargetDir: move.TargetDir,
+				},
+				transfers:   server.transfers,
+				writeSpeeds: server.writeSpeeds,
+				copyGroup:   copyGroup(config, move.TargetDir),
+				maxCopies:   config.MaxConcurrentCopiesPerTarget,
+				maxRate:     rate,
+			}
+		}
+		server.lock.Unlock()
+		if cancelled {
+			continue
+		}
+
+		plot := move.plot
+		err := plot.transfers.acquire(ctx, plot.copyGroup, plot.maxCopies)
+		if err == nil {
+			err = plot.transferPlot(ctx, move.Source, move.TargetDir, move.name())
+			plot.transfers.release(plot.copyGroup)
+		}
+
+		server.lock.Lock()
+		if ctx.Err() != nil {
+			log.Printf("Rebalance: cancelled moving %s to %s", move.Source, move.TargetDir)
+			move.State = MoveCancelled
+		} else if err != nil {
+			log.Printf("Rebalance: failed to move %s to %s: %s", move.Source, move.TargetDir, err)
+			move.State = MoveFailed
+			move.Error = err.Error()
+		} else {
+			log.Printf("Rebalance: moved %s to %s", move.Source, move.TargetDir)
+			move.State = MoveDone
+		}
+		server.lock.Unlock()
+	}
+	server.lock.Lock()
+	rebalance.Running = false
+	server.lock.Unlock()
+	log.Printf("Rebalance: finished")
+}
+
+// snapshot copies the rebalance with the progress of the current move.  The caller must hold
+// server.lock.
+func (rebalance *Rebalance) snapshot() *Rebalance {
+	if rebalance == nil {
+		return nil
+	}
+	copied := *rebalance
+	copied.Moves = nil
+	for _, move := range rebalance.Moves {
+		m := *move
+		if m.State == MoveRunning && m.plot != nil {
+			status := m.plot.Snapshot()
+			m.TransferredBytes = status.TransferredBytes
+			m.TransferRate = status.TransferRate
+		} else if m.State == MoveDone {
+			m.TransferredBytes = m.Size
+		}
+		copied.Moves = append(copied.Moves, &m)
+	}
+	return &copied
+}
+
+// handleRebalance returns the current rebalance on GET, plans or starts one from the
+// RebalanceRequest posted as JSON and cancels the remaining moves on DELETE.
+func (server *Server) handleRebalance(resp http.ResponseWriter, req *http.Request) {
+	var rebalance *Rebalance
+	switch req.Method {
+	case "GET":
+		server.lock.RLock()
+		rebalance = server.rebalance.snapshot()
+		server.lock.RUnlock()
+	case "POST":
+		var request RebalanceRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(resp, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		server.config.Lock.RLock()
+		config := server.config.CurrentConfig
+		server.config.Lock.RUnlock()
+		if config == nil {
+			http.Error(resp, "No configuration loaded", http.StatusServiceUnavailable)
+			return
+		}
+		for _, dir := range request.Targets {
+			if err := config.checkPath(dir); err != nil {
+				http.Error(resp, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		started, err := server.startRebalance(req.Context(), config, &request)
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusConflict)
+			return
+		}
+		server.lock.RLock()
+		rebalance = started.snapshot()
+		server.lock.RUnlock()
+	case "DELETE":
+		server.lock.Lock()
+		if server.rebalance != nil && server.rebalance.cancel != nil {
+			server.rebalance.cancel()
+		}
+		rebalance = server.rebalance.snapshot()
+		server.lock.Unlock()
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(rebalance)
+}
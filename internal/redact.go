@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedText replaces whatever is redacted.
+const redactedText = "[redacted]"
+
+// redactIdentityPatterns match pool contract addresses and the public keys and memo chia logs,
+// the plot ID is kept as it is needed to follow a plot through the logs.
+var redactIdentityPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bxch1[02-9ac-hj-np-z]{58}\b`),
+	regexp.MustCompile(`\b(0x)?[0-9a-fA-F]{96,}\b`),
+}
+
+// redactor removes fingerprints, keys, pool contract addresses and anything matching
+// RedactPatterns from log lines sent over the API.  A nil redactor leaves everything as is.
+type redactor struct {
+	identity bool
+	literals []string
+	patterns []*regexp.Regexp
+}
+
+// compileRedactPatterns compiles RedactPatterns, it is used to validate them too.
+func compileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RedactPatterns %s: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// newRedactor returns the redactor for config, or nil when redaction is off.
+func newRedactor(config *Config) *redactor {
+	if config == nil || (!config.RedactLogs && len(config.RedactPatterns) == 0) {
+		return nil
+	}
+	r := &redactor{identity: config.RedactLogs}
+	if patterns, err := compileRedactPatterns(config.RedactPatterns); err == nil {
+		r.patterns = patterns
+	}
+	if r.identity {
+		r.patterns = append(r.patterns, redactIdentityPatterns...)
+		r.addLiterals(config.Fingerprint, config.FarmerPublicKey, config.PoolPublicKey, config.PoolContractAddress)
+		for customer := range config.Quotas {
+			r.addLiterals(customer)
+		}
+	}
+	return r
+}
+
+func (r *redactor) addLiterals(literals ...string) {
+	for _, literal := range literals {
+		if len(literal) > 0 {
+			r.literals = append(r.literals, literal)
+		}
+	}
+}
+
+func (r *redactor) line(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, literal := range r.literals {
+		s = strings.ReplaceAll(s, literal, redactedText)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redactedText)
+	}
+	return s
+}
+
+func (r *redactor) lines(lines []string) []string {
+	if r == nil {
+		return lines
+	}
+	redacted := make([]string, len(lines))
+	for i, l := range lines {
+		redacted[i] = r.line(l)
+	}
+	return redacted
+}
+
+// forPlot returns the redactor for the log of a plot, which also knows the plot's own keys.
+func (r *redactor) forPlot(status *PlotStatus) *redactor {
+	if r == nil || !r.identity {
+		return r
+	}
+	plotRedactor := *r
+	plotRedactor.literals = nil
+	plotRedactor.addLiterals(status.Fingerprint, status.FarmerPublicKey, status.PoolPublicKey, status.PoolContract)
+	plotRedactor.literals = append(plotRedactor.literals, r.literals...)
+	return &plotRedactor
+}
+
+// plotLog redacts the log and last error of a plot snapshot.
+func (r *redactor) plotLog(status *PlotStatus) {
+	if r == nil {
+		return
+	}
+	r = r.forPlot(status)
+	status.Tail = r.lines(status.Tail)
+	status.LastError = r.line(status.LastError)
+}
+
+// plot redacts the log of a plot snapshot, and with RedactLogs its fingerprint and keys too.
+func (r *redactor) plot(status *PlotStatus) {
+	if r == nil {
+		return
+	}
+	r.plotLog(status)
+	if r.identity {
+		r = r.forPlot(status)
+		status.Fingerprint = r.line(status.Fingerprint)
+		status.FarmerPublicKey = r.line(status.FarmerPublicKey)
+		status.PoolPublicKey = r.line(status.PoolPublicKey)
+		status.PoolContract = r.line(status.PoolContract)
+		status.Audit = PlotAudit{
+			FarmerPublicKey: r.line(status.Audit.FarmerPublicKey),
+			PoolPublicKey:   r.line(status.Audit.PoolPublicKey),
+			PoolContract:    r.line(status.Audit.PoolContract),
+			Memo:            r.line(status.Audit.Memo),
+		}
+	}
+}
+
+// config returns config without the fingerprint and keys when RedactLogs is set, for debug bundles.
+func (r *redactor) config(config *Config) *Config {
+	if r == nil || !r.identity || config == nil {
+		return config
+	}
+	c := *config
+	c.Fingerprint = r.line(c.Fingerprint)
+	c.FarmerPublicKey = r.line(c.FarmerPublicKey)
+	c.PoolPublicKey = r.line(c.PoolPublicKey)
+	c.PoolContractAddress = r.line(c.PoolContractAddress)
+	c.Quotas = map[string]int{}
+	for customer, quota := range config.Quotas {
+		c.Quotas[r.line(customer)] += quota
+	}
+	return &c
+}
+
+// redactor returns the redactor for the current configuration.  It takes the config lock, so it
+// must not be called with server.lock held.
+func (server *Server) redactor() *redactor {
+	server.config.Lock.RLock()
+	defer server.config.Lock.RUnlock()
+	return newRedactor(server.config.CurrentConfig)
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// probeDirectory checks that dir is still there and readable, a disconnected drive usually
+// fails the directory listing with an I/O error.
+func probeDirectory(fs Filesystem, dir string) error {
+	stat, err := fs.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	_, err = fs.ReadDir(dir)
+	return err
+}
+
+// runRemountCommand runs RemountCommand with dir appended as the last argument.
+func runRemountCommand(config *Config, dir string) error {
+	args := strings.Fields(config.RemountCommand)
+	args = append(args, dir)
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// checkTargets probes the local target directories.  A target which went offline is remounted with
+// RemountCommand, up to RemountAttempts times, before it is taken out of the rotation until the
+// configuration is reloaded.
+func (server *Server) checkTargets(config *Config) {
+	for _, dir := range config.TargetDirectory {
+		if isRemoteTarget(dir) || server.offlineTargets[dir] {
+			continue
+		}
+		err := probeDirectory(server.filesystem(), dir)
+		if err == nil {
+			delete(server.remountAttempts, dir)
+			continue
+		}
+		log.Printf("Target [%s] is offline: %s", dir, err)
+		if len(strings.TrimSpace(config.RemountCommand)) > 0 && server.remountAttempts[dir] < config.RemountAttempts {
+			server.remountAttempts[dir]++
+			log.Printf("Target [%s] remount attempt %d of %d", dir, server.remountAttempts[dir], config.RemountAttempts)
+			if err := runRemountCommand(config, dir); err != nil {
+				log.Printf("Target [%s] remount failed: %s", dir, err)
+			} else if err := probeDirectory(server.filesystem(), dir); err == nil {
+				log.Printf("Target [%s] is back online", dir)
+				delete(server.remountAttempts, dir)
+				continue
+			}
+			if server.remountAttempts[dir] < config.RemountAttempts {
+				continue
+			}
+		}
+		log.Printf("Target [%s] removed from rotation", dir)
+		server.lock.Lock()
+		server.offlineTargets[dir] = true
+		server.lock.Unlock()
+		server.bus.publish(Event{Type: EventDiskOffline, Dir: dir, Error: err.Error()})
+	}
+}
+
+func (server *Server) notifyTargetOffline(event Event) {
+	server.config.Lock.RLock()
+	config := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	notify(config, "Target offline", fmt.Sprintf("Target [%s] is offline and was removed from rotation: %s", event.Dir, event.Error))
+}
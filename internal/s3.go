@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3PartSize     = 64 * MB
+	s3MaxRetries   = 5
+	s3AbortTimeout = time.Minute
+)
+
+var s3Client = &http.Client{
+	Timeout: 30 * time.Minute,
+}
+
+// parseS3Target splits an object storage target "s3://bucket/prefix".
+func parseS3Target(target string) (bucket string, prefix string, ok bool) {
+	if !strings.HasPrefix(target, "s3://") {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(target, "s3://"), "/", 2)
+	if len(parts[0]) == 0 {
+		return "", "", false
+	}
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+	return parts[0], prefix, true
+}
+
+// s3Credentials holds the connection settings copied from the configuration when the plot started.
+type s3Credentials struct {
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+func s3Escape(s string, keepSlash bool) string {
+	var buf bytes.Buffer
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (keepSlash && b == '/') {
+			buf.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sign adds an AWS Signature Version 4 to req.  The payload is not signed, so parts can be streamed.
+func (creds *s3Credentials) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := now.UTC().Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var canonicalQuery []string
+	for _, k := range keys {
+		canonicalQuery = append(canonicalQuery, s3Escape(k, false)+"="+s3Escape(query.Get(k), false))
+	}
+
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:%s\n", req.URL.Host, amzDate)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, true),
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders,
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", day, creds.Region)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := hmacSHA256([]byte("AWS4"+creds.SecretKey), day)
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKey, scope, signedHeaders, signature))
+}
+
+// do sends a signed request for bucket/key using path style addressing, which works for both AWS and MinIO.
+func (creds *s3Credentials) do(ctx context.Context, method string, bucket string, key string, query url.Values, body io.Reader, length int64) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(creds.Endpoint, "/") + "/" + bucket + "/" + key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	creds.sign(req, time.Now())
+	resp, err := s3Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s %s", method, u.Path, resp.Status, bytes.TrimSpace(data))
+	}
+	return resp, nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// uploadToS3 uploads the plot with a multipart upload, retrying failed parts, and aborts the
+// upload if a part can't be sent or ctx is cancelled.
+func (ap *ActivePlot) uploadToS3(ctx context.Context, src string, bucket string, key string) error {
+	creds := ap.s3
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	ap.lock.Lock()
+	ap.TransferSize = uint64(stat.Size())
+	ap.changed()
+	id := ap.Id
+	ap.lock.Unlock()
+
+	resp, err := creds.do(ctx, "POST", bucket, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	var initiate struct {
+		UploadId string
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiate)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode multipart upload: %w", err)
+	}
+	uploadQuery := url.Values{"uploadId": {initiate.UploadId}}
+
+	var parts []s3CompletedPart
+	host := ""
+	if u, err := url.Parse(creds.Endpoint); err == nil {
+		host = u.Hostname()
+	}
+	for offset, partNumber := int64(0), 1; offset < stat.Size(); offset, partNumber = offset+int64(s3PartSize), partNumber+1 {
+		length := stat.Size() - offset
+		if length > int64(s3PartSize) {
+			length = int64(s3PartSize)
+		}
+		query := url.Values{"uploadId": {initiate.UploadId}, "partNumber": {fmt.Sprintf("%d", partNumber)}}
+		for attempt := 1; ; attempt++ {
+			reader := &shapedReader{ctx: ctx, in: io.NewSectionReader(in, offset, length), tm: ap.transfers, host: host, plot: ap, base: offset}
+			resp, err = creds.do(ctx, "PUT", bucket, key, query, reader, length)
+			if err == nil {
+				parts = append(parts, s3CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+				resp.Body.Close()
+				break
+			}
+			if attempt >= s3MaxRetries || ctx.Err() != nil {
+				// ctx may be cancelled already, the abort gets its own time.
+				abortCtx, cancel := context.WithTimeout(context.Background(), s3AbortTimeout)
+				if _, abortErr := creds.do(abortCtx, "DELETE", bucket, key, uploadQuery, nil, 0); abortErr != nil {
+					log.Printf("Plot [%s] failed to abort upload: %s", id, abortErr)
+				}
+				cancel()
+				return err
+			}
+			log.Printf("Plot [%s] upload of part %d failed, retrying: %s", id, partNumber, err)
+			select {
+			case <-time.After(time.Duration(attempt*attempt) * time.Second):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	complete := struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts}
+	body, err := xml.Marshal(&complete)
+	if err != nil {
+		return err
+	}
+	resp, err = creds.do(ctx, "POST", bucket, key, uploadQuery, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 can report a failed completion with a 200 status and an error document.
+	data, _ := ioutil.ReadAll(resp.Body)
+	if bytes.Contains(data, []byte("<Error>")) {
+		return fmt.Errorf("failed to complete upload: %s", bytes.TrimSpace(data))
+	}
+	return nil
+}
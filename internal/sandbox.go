@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkPath makes sure path stays inside AllowedPaths, once its symlinks are resolved, so a
+// client of the API can't make PlotNG read, write or delete anything else.  Without AllowedPaths
+// every path is allowed, and remote targets are left to their own host.
+func (config *Config) checkPath(path string) error {
+	if len(config.AllowedPaths) == 0 || isRemoteTarget(path) {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("%s is not an absolute path", path)
+	}
+	for _, element := range strings.Split(filepath.ToSlash(path), "/") {
+		if element == ".." {
+			return fmt.Errorf("%s goes up the directory tree", path)
+		}
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for _, root := range config.AllowedPaths {
+		if resolvedRoot, err := resolvePath(root); err == nil && isWithin(resolved, resolvedRoot) {
+			return nil
+		}
+	}
+	if resolved != filepath.Clean(path) {
+		return fmt.Errorf("%s resolves to %s, outside of AllowedPaths", path, resolved)
+	}
+	return fmt.Errorf("%s is outside of AllowedPaths", path)
+}
+
+// checkConfigPaths checks the directories and files of config with checkPath.
+func (config *Config) checkConfigPaths() error {
+	paths := append(append(append([]string{}, config.TempDirectory...), config.TargetDirectory...), config.BufferDirectory...)
+	for _, profile := range config.Profiles {
+		paths = append(paths, profile.TargetDirectory...)
+	}
+	for _, path := range []string{config.SavePlotLogDir, config.EventLogFile} {
+		if len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		if err := config.checkPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSandboxChange refuses a new configuration from the API which widens the sandbox of the
+// current one: the commands PlotNG runs can only be changed in the file, and so can AllowedPaths
+// once set.
+func (config *Config) checkSandboxChange(newConfig *Config) error {
+	if newConfig.NotifyCommand != config.NotifyCommand || newConfig.TrimCommand != config.TrimCommand ||
+		newConfig.RemountCommand != config.RemountCommand {
+		return fmt.Errorf("NotifyCommand, TrimCommand and RemountCommand can only be changed in the configuration file")
+	}
+	if len(config.AllowedPaths) > 0 && strings.Join(newConfig.AllowedPaths, "\n") != strings.Join(config.AllowedPaths, "\n") {
+		return fmt.Errorf("AllowedPaths can only be changed in the configuration file")
+	}
+	return nil
+}
+
+// resolvePath resolves the symlinks of path.  The part which doesn't exist yet, like a plot
+// subfolder, is appended to the resolved part which does.
+func resolvePath(path string) (string, error) {
+	path = filepath.Clean(path)
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) || path == filepath.Dir(path) {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = filepath.Dir(path)
+	}
+}
+
+// isWithin reports whether path is root or below it.
+func isWithin(path string, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkPath checks a path given to the API against the current configuration.
+func (server *Server) checkPath(path string) error {
+	server.config.Lock.RLock()
+	config := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	if config == nil {
+		return nil
+	}
+	return config.checkPath(path)
+}
@@ -0,0 +1,336 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// SchedulerState is what a Scheduler sees of the server when it picks the next plot.  The maps
+// belong to the server and must not be changed.  ExtraPlots is how many plots may run above
+// NumberOfParallelPlots because the machine is idle.  WriteSpeeds holds the measured write speed in
+// bytes/s of the dest directories with recent copies.  Trimming holds the temp directories being
+// trimmed, where no plot may start.
+type SchedulerState struct {
+	Now            time.Time
+	Config         *Config
+	ExtraPlots     int
+	Active         []PlotStatus
+	Queue          []*PlotJob
+	Disabled       map[string]bool
+	Offline        map[string]bool
+	Trimming       map[string]bool
+	SpaceAvailable func(dir string) uint64
+	WriteSpeeds    map[string]float64
+}
+
+// ActiveInTemp returns the number of active plots using dir as temp directory.
+func (state *SchedulerState) ActiveInTemp(dir string) (count int) {
+	for _, plot := range state.Active {
+		if plot.PlotDir == dir {
+			count++
+		}
+	}
+	return
+}
+
+// ActiveInTarget returns the number of active plots using dir as dest directory.
+func (state *SchedulerState) ActiveInTarget(dir string) (count int) {
+	for _, plot := range state.Active {
+		if plot.TargetDir == dir {
+			count++
+		}
+	}
+	return
+}
+
+// SlowTarget reports whether the measured write speed of dir is below MinTargetWriteSpeed while
+// another usable dest directory isn't, so plots can be steered around a drive busy with copies.
+func (state *SchedulerState) SlowTarget(dir string) bool {
+	slow := func(dir string) bool {
+		speed, ok := state.WriteSpeeds[dir]
+		return ok && speed < state.Config.MinTargetWriteSpeed*float64(MB)
+	}
+	if state.Config.MinTargetWriteSpeed <= 0 || !slow(dir) {
+		return false
+	}
+	for _, other := range state.Config.TargetDirectory {
+		if !state.Disabled[other] && !state.Offline[other] && !slow(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveInPhase returns the number of active plots in a plotting phase, 1 to 4.
+func (state *SchedulerState) ActiveInPhase(phase int) (count int) {
+	prefix := fmt.Sprintf("%d/4", phase)
+	for _, plot := range state.Active {
+		if strings.HasPrefix(plot.Phase, prefix) {
+			count++
+		}
+	}
+	return
+}
+
+// SchedulerDecision is the plot a Scheduler wants started: a queued Job, or a plot from TempDir to
+// TargetDir using the configuration.  When it starts nothing, Reason may say why.
+type SchedulerDecision struct {
+	Job       *PlotJob
+	TempDir   string
+	TargetDir string
+	Reason    string
+}
+
+// Scheduler decides which plot to start next, it is asked once per cycle unless plotting is paused.
+// Schedulers can keep state between cycles, a new one is made when the configuration is reloaded.
+type Scheduler interface {
+	NextJob(state *SchedulerState) SchedulerDecision
+}
+
+// schedulers are the schedulers which can be picked with the Scheduler setting.
+var schedulers = map[string]func() Scheduler{
+	"default": func() Scheduler { return &defaultScheduler{} },
+}
+
+func schedulerNames() []string {
+	var names []string
+	for name := range schedulers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newScheduler(name string) Scheduler {
+	if len(name) == 0 {
+		name = "default"
+	}
+	create, ok := schedulers[name]
+	if !ok {
+		log.Printf("Unknown scheduler [%s], using the default scheduler", name)
+		create = schedulers["default"]
+	}
+	return create()
+}
+
+// defaultScheduler starts queued jobs first, in queue order skipping held jobs and those whose
+// directories are disabled, trimmed or offline, then goes round robin through the temp and dest
+// directories, waiting DelaysBetweenPlot between plots and
+// StaggeringDelay after every round of dest directories.  With ProportionalFill the dest
+// directories are picked by weighted round robin instead, see fillTarget.
+type defaultScheduler struct {
+	currentTemp   int
+	currentTarget int
+	delayUntil    time.Time
+	fillCredit    map[string]float64
+}
+
+func (ds *defaultScheduler) NextJob(state *SchedulerState) SchedulerDecision {
+	config := state.Config
+	if len(state.Active) >= config.NumberOfParallelPlots+state.ExtraPlots {
+		return SchedulerDecision{}
+	}
+	for _, job := range state.Queue {
+		if job.Held || state.Trimming[job.TempDir] || state.Disabled[job.TempDir] || state.Disabled[job.TargetDir] || state.Offline[job.TargetDir] {
+			continue
+		}
+		return SchedulerDecision{Job: job}
+	}
+	if len(config.TempDirectory) == 0 || len(config.TargetDirectory) == 0 {
+		return SchedulerDecision{}
+	}
+	if state.Now.Before(ds.delayUntil) {
+		return SchedulerDecision{Reason: fmt.Sprintf("Waiting until %s", format.Time(ds.delayUntil))}
+	}
+
+	if ds.currentTarget >= len(config.TargetDirectory) {
+		ds.currentTarget = 0
+		ds.delayUntil = state.Now.Add(time.Duration(config.StaggeringDelay) * time.Minute)
+		return SchedulerDecision{}
+	}
+	if ds.currentTemp >= len(config.TempDirectory) {
+		ds.currentTemp = 0
+	}
+	if config.MaxActivePlotPerPhase1 > 0 {
+		if sum := state.ActiveInPhase(1); config.MaxActivePlotPerPhase1 <= sum {
+			return SchedulerDecision{Reason: fmt.Sprintf("Skipping, Too many active plots in Phase 1: %d", sum)}
+		}
+	}
+	plotDir := config.TempDirectory[ds.currentTemp]
+	ds.currentTemp++
+	if ds.currentTemp >= len(config.TempDirectory) {
+		ds.currentTemp = 0
+	}
+	if state.Disabled[plotDir] {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], directory is disabled", plotDir)}
+	}
+	if state.Trimming[plotDir] {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], directory is being trimmed", plotDir)}
+	}
+	if count := state.ActiveInTemp(plotDir); config.MaxActivePlotPerTemp > 0 && count >= config.MaxActivePlotPerTemp {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], too many active plots: %d", plotDir, count)}
+	}
+	targetDir := config.TargetDirectory[ds.currentTarget]
+	if config.ProportionalFill {
+		targetDir = ds.fillTarget(state, targetDir)
+	}
+	ds.currentTarget++
+	if state.Offline[targetDir] {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], target is offline", targetDir)}
+	}
+	if state.Disabled[targetDir] {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], directory is disabled", targetDir)}
+	}
+	if state.SlowTarget(targetDir) {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], writing at %s, below MinTargetWriteSpeed", targetDir, format.Rate(uint64(state.WriteSpeeds[targetDir])))}
+	}
+	count := state.ActiveInTarget(targetDir)
+	if config.MaxActivePlotPerTarget > 0 && count >= config.MaxActivePlotPerTarget {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], too many active plots: %d", targetDir, count)}
+	}
+
+	ds.delayUntil = state.Now.Add(time.Duration(config.DelaysBetweenPlot) * time.Minute)
+
+	targetDirSpace := state.SpaceAvailable(targetDir)
+	if config.DiskSpaceCheck && uint64(count+1)*PLOT_SIZE > targetDirSpace {
+		return SchedulerDecision{Reason: fmt.Sprintf("Skipping [%s], Not enough space: %d", targetDir, targetDirSpace/GB)}
+	}
+	return SchedulerDecision{TempDir: plotDir, TargetDir: targetDir}
+}
+
+// fillTarget picks the dest directory of the next plot in proportion to the space left on the
+// usable dest directories, after the plots already writing to them, so that drives of different
+// sizes fill up at about the same time.  Remote dest directories, which have no free space, weigh
+// as much as the average local one.  It returns fallback when no dest directory is usable.
+func (ds *defaultScheduler) fillTarget(state *SchedulerState, fallback string) string {
+	weights := map[string]float64{}
+	var local []string
+	var localSpace float64
+	for _, dir := range state.Config.TargetDirectory {
+		if state.Offline[dir] || state.Disabled[dir] || state.SlowTarget(dir) {
+			continue
+		}
+		weights[dir] = 0
+		if isRemoteTarget(dir) {
+			continue
+		}
+		space, writing := state.SpaceAvailable(dir), uint64(state.ActiveInTarget(dir))*PLOT_SIZE
+		if space > writing {
+			weights[dir] = float64(space - writing)
+		}
+		local = append(local, dir)
+		localSpace += weights[dir]
+	}
+	average := 1.0
+	if len(local) > 0 && localSpace > 0 {
+		average = localSpace / float64(len(local))
+	}
+	if ds.fillCredit == nil {
+		ds.fillCredit = map[string]float64{}
+	}
+	best, total := "", 0.0
+	for _, dir := range state.Config.TargetDirectory {
+		weight, ok := weights[dir]
+		if !ok {
+			continue
+		}
+		if isRemoteTarget(dir) {
+			weight = average
+		}
+		ds.fillCredit[dir] += weight
+		total += weight
+		if len(best) == 0 || ds.fillCredit[dir] > ds.fillCredit[best] {
+			best = dir
+		}
+	}
+	if len(best) == 0 || total == 0 {
+		return fallback
+	}
+	ds.fillCredit[best] -= total
+	return best
+}
+
+// schedulerState collects the state for the scheduler.  The caller must hold server.lock.
+func (server *Server) schedulerState(config *Config, now time.Time) *SchedulerState {
+	state := &SchedulerState{
+		Now:            now,
+		Config:         config,
+		Queue:          append([]*PlotJob(nil), server.queue...),
+		Disabled:       server.overlay.Disabled,
+		Offline:        server.offlineTargets,
+		Trimming:       server.trimming(),
+		SpaceAvailable: server.getDiskSpaceAvailable,
+		WriteSpeeds:    server.currentWriteSpeeds(now),
+	}
+	for _, plot := range server.active {
+		state.Active = append(state.Active, plot.Snapshot())
+	}
+	return state
+}
+
+// schedule asks the scheduler for the next plot and starts it, unless plotting is paused or the
+// quota of the plot is already fulfilled.
+func (server *Server) schedule(config *Config, now time.Time) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	paused := server.plottingPaused(config, now)
+	server.recordPause(paused, now)
+	if paused || !server.threadsLeft(config) {
+		return
+	}
+	config = server.pacedConfig(config, now)
+	if !server.plotCreditAvailable(config, now) {
+		return // startDecision checks the credit of every plot, this skips the scheduler
+	}
+	if len(config.Profiles) > 0 {
+		server.scheduleProfiles(config, now)
+		return
+	}
+	if server.scheduler == nil || server.schedulerName != config.Scheduler {
+		server.scheduler = newScheduler(config.Scheduler)
+		server.schedulerName = config.Scheduler
+	}
+	state := server.schedulerState(config, now)
+	state.ExtraPlots = server.idleExtraPlots(config, now)
+	decision := server.scheduler.NextJob(state)
+	if len(decision.Reason) > 0 {
+		server.schedulerEvent("%s", decision.Reason)
+	}
+	server.startDecision(config, decision, now, "")
+}
+
+// startDecision starts the plot the scheduler decided on for profile, taking its plot credit, and
+// reports whether it started one.  The caller must hold server.lock.
+func (server *Server) startDecision(config *Config, decision SchedulerDecision, now time.Time, profile string) bool {
+	switch {
+	case decision.Job == nil && (len(decision.TempDir) == 0 || len(decision.TargetDir) == 0):
+		return false
+	case !server.plotCreditAvailable(config, now):
+		return false
+	case decision.Job != nil:
+		if !server.startQueuedJob(config, decision.Job) {
+			return false
+		}
+		server.consumePlotCredit(config, now)
+		return true
+	case len(decision.TempDir) > 0 && len(decision.TargetDir) > 0:
+		plot := newActivePlot(config.forTarget(decision.TargetDir), decision.TempDir, server.bufferTarget(config, decision.TargetDir))
+		plot.Profile = profile
+		if server.quotaFulfilled(config, plot.customer()) {
+			return false
+		}
+		server.fitThreads(config, plot)
+		server.startPlot(config, plot)
+		if len(server.active) > config.NumberOfParallelPlots {
+			server.startedExtraPlot(now)
+		}
+		server.consumePlotCredit(config, now)
+		return true
+	}
+	return false
+}
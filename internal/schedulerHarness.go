@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// harnessPhaseTimes are the times a plot of the scheduler harness spends in each phase by default,
+// about those of a k32 plot on an NVMe drive.
+var harnessPhaseTimes = [4]time.Duration{3 * time.Hour, 75 * time.Minute, 150 * time.Minute, 15 * time.Minute}
+
+// SchedulerHarness runs the scheduler of a server against a virtual clock and virtual disks.  Its
+// plots don't run the plotter, they move through the phases after PhaseTimes, or TempPhaseTimes
+// of their temp directory when it has any, and take PLOT_SIZE from their target when they finish,
+// so stagger, quotas and phase limits can be checked deterministically.  They go through the
+// states of real plots, whose events are delivered synchronously.
+type SchedulerHarness struct {
+	Clock          *VirtualClock
+	Disks          *StaticDiskUsage
+	PhaseTimes     [4]time.Duration
+	TempPhaseTimes map[string][4]time.Duration
+	server         *Server
+	config         *Config
+	starting       []*ActivePlot // started by the last cycle
+}
+
+// NewSchedulerHarness returns a harness scheduling plots with config from start, with the given
+// space available in each directory.
+func NewSchedulerHarness(config *Config, start time.Time, space map[string]uint64) *SchedulerHarness {
+	h := &SchedulerHarness{
+		Clock:      NewVirtualClock(start),
+		Disks:      NewStaticDiskUsage(space),
+		PhaseTimes: harnessPhaseTimes,
+		config:     config,
+	}
+	h.server = &Server{
+		active:         map[int64]*ActivePlot{},
+		overlay:        &dirOverlay{Disabled: map[string]bool{}, Evacuating: map[string]bool{}, Draining: map[string]bool{}},
+		offlineTargets: map[string]bool{},
+		credits:        &PlotCredits{},
+		quotaCounts:    &quotaCounts{Finished: map[string]int{}},
+		events:         newLineBuffer(1000),
+		clock:          h.Clock,
+		fs:             &diskUsageOverride{Filesystem: localFilesystem{}, usage: h.Disks},
+		chiaVersion:    chiaVersionRules[len(chiaVersionRules)-1],
+		stateCounts:    map[PlotState]int{},
+		failureCounts:  map[FailureCategory]int{},
+		bus:            newSyncEventBus(),
+		revisions:      &revisionCounter{},
+	}
+	h.server.bus.subscribe(h.server.recordEvent)
+	h.server.bus.subscribe(h.server.countQuotaPlot, EventPlotFinished)
+	h.server.bus.subscribe(h.server.archiveFinishedPlot, EventPlotFinished)
+	h.server.bus.subscribe(h.server.countTransition, EventStateChanged)
+	h.server.plotRunner = h.startPlot
+	return h
+}
+
+// startPlot is called by the server with server.lock held, the plot is run once it is released,
+// as RunPlot runs in its own goroutine.
+func (h *SchedulerHarness) startPlot(plot *ActivePlot) {
+	h.starting = append(h.starting, plot)
+}
+
+// runStarting moves the plots started by the last cycle to PlotRunning in their first phase.
+func (h *SchedulerHarness) runStarting(now time.Time) {
+	for _, plot := range h.starting {
+		plot.lock.Lock()
+		plot.StartTime = now
+		plot.Id = fmt.Sprintf("%064x", plot.PlotId)
+		plot.changed()
+		plot.lock.Unlock()
+		if plot.setState(PlotSpaceCheck) && plot.setState(PlotRunning) {
+			h.enterPhase(plot, 1, now)
+		}
+	}
+	h.starting = nil
+}
+
+// enterPhase moves plot to phase, 1 to 4, as its log would.
+func (h *SchedulerHarness) enterPhase(plot *ActivePlot, phase int, at time.Time) {
+	name := fmt.Sprintf("%d/4", phase)
+	plot.lock.Lock()
+	plot.Phase = name
+	plot.changed()
+	plot.lock.Unlock()
+	plot.events.publish(Event{Type: EventPhaseChanged, Time: at, Plot: plot, Phase: name})
+}
+
+// progress moves the virtual plots through their phases, the finished ones are verified and
+// archived.
+func (h *SchedulerHarness) progress(now time.Time) {
+	h.server.lock.RLock()
+	var plots []*ActivePlot
+	for _, plot := range h.server.active {
+		plots = append(plots, plot)
+	}
+	h.server.lock.RUnlock()
+	sort.Slice(plots, func(i, j int) bool { return plots[i].PlotId < plots[j].PlotId })
+	for _, plot := range plots {
+		plot.lock.RLock()
+		running := plot.State == PlotRunning
+		end := plot.StartTime
+		current := plot.Phase
+		plot.lock.RUnlock()
+		if !running {
+			continue
+		}
+		phaseTimes, ok := h.TempPhaseTimes[plot.PlotDir]
+		if !ok {
+			phaseTimes = h.PhaseTimes
+		}
+		finished := false
+		for phase, duration := range phaseTimes {
+			start := end
+			end = end.Add(duration)
+			if phase > 0 && !now.Before(start) && current < fmt.Sprintf("%d/4", phase+1) {
+				h.enterPhase(plot, phase+1, start)
+			}
+			if now.Before(end) {
+				break
+			}
+			plot.lock.Lock()
+			switch phase {
+			case 0:
+				plot.Phase1Time = end
+			case 1:
+				plot.Phase2Time = end
+			case 2:
+				plot.Phase3Time = end
+			case 3:
+				finished = true
+			}
+			plot.lock.Unlock()
+		}
+		if finished && plot.setState(PlotVerifying) && plot.setState(PlotFinished) {
+			h.Disks.Use(plot.TargetDir, PLOT_SIZE)
+		}
+	}
+}
+
+// Step runs one scheduling cycle at the current time of the clock.
+func (h *SchedulerHarness) Step() {
+	now := h.Clock.Now()
+	h.progress(now)
+	h.server.schedule(h.config, now)
+	h.runStarting(now)
+}
+
+// Run runs a scheduling cycle every cycle for duration, as the server does every minute.
+func (h *SchedulerHarness) Run(duration time.Duration, cycle time.Duration) {
+	end := h.Clock.Now().Add(duration)
+	for h.Clock.Now().Before(end) {
+		h.Step()
+		h.Clock.Advance(cycle)
+	}
+	h.progress(h.Clock.Now())
+}
+
+// Plots returns the active and the finished plots.
+func (h *SchedulerHarness) Plots() (active []*PlotStatus, finished []*PlotStatus) {
+	h.server.lock.RLock()
+	defer h.server.lock.RUnlock()
+	return h.server.plotSnapshots()
+}
+
+// Events returns the scheduler's decisions, with the times of the virtual clock.
+func (h *SchedulerHarness) Events() []string {
+	return h.server.events.Lines()
+}
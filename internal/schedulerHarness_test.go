@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var harnessStart = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestHarness(config *Config) *SchedulerHarness {
+	space := map[string]uint64{}
+	for _, dir := range append(append([]string{}, config.TempDirectory...), config.TargetDirectory...) {
+		space[dir] = 100 * PLOT_SIZE
+	}
+	return NewSchedulerHarness(config, harnessStart, space)
+}
+
+func TestSchedulerHarnessStagger(t *testing.T) {
+	config := &Config{
+		TempDirectory:         []string{"/t1", "/t2"},
+		TargetDirectory:       []string{"/d1", "/d2"},
+		NumberOfParallelPlots: 2,
+		DelaysBetweenPlot:     60,
+	}
+	h := newTestHarness(config)
+	maxActive := 0
+	for i := 0; i < 24*60; i++ {
+		h.Step()
+		active, _ := h.Plots()
+		if len(active) > maxActive {
+			maxActive = len(active)
+		}
+		h.Clock.Advance(time.Minute)
+	}
+	h.progress(h.Clock.Now())
+
+	if maxActive != config.NumberOfParallelPlots {
+		t.Errorf("at most %d plots ran at once, want %d", maxActive, config.NumberOfParallelPlots)
+	}
+	active, finished := h.Plots()
+	var plots []*PlotStatus
+	plots = append(append(plots, finished...), active...)
+	sort.Slice(plots, func(i, j int) bool { return plots[i].StartTime.Before(plots[j].StartTime) })
+	for i := 1; i < len(plots); i++ {
+		if gap := plots[i].StartTime.Sub(plots[i-1].StartTime); gap < time.Duration(config.DelaysBetweenPlot)*time.Minute {
+			t.Errorf("plot %d started %s after the previous one, want at least %d minutes", i, gap, config.DelaysBetweenPlot)
+		}
+	}
+	// 7 hours per plot, 2 at a time an hour apart: 6 plots finish in a day
+	if len(finished) != 6 {
+		t.Errorf("%d plots finished, want 6", len(finished))
+	}
+	want := []PlotState{PlotSpaceCheck, PlotRunning, PlotVerifying, PlotFinished}
+	for _, plot := range finished {
+		var states []PlotState
+		for _, change := range plot.StateHistory {
+			states = append(states, change.State)
+		}
+		if !reflect.DeepEqual(states, want) {
+			t.Errorf("plot %d went through %v, want %v", plot.PlotId, states, want)
+		}
+		if plot.EndTime.Sub(plot.StartTime) < 7*time.Hour {
+			t.Errorf("plot %d took %s, less than its phases", plot.PlotId, plot.EndTime.Sub(plot.StartTime))
+		}
+		if plot.TargetDir != "/d1" && plot.TargetDir != "/d2" {
+			t.Errorf("plot %d went to %s", plot.PlotId, plot.TargetDir)
+		}
+	}
+}
+
+func TestSchedulerHarnessDeterministic(t *testing.T) {
+	// the plot IDs are random, the schedule isn't
+	type run struct {
+		start, end time.Time
+		temp, dest string
+	}
+	schedule := func() []run {
+		config := &Config{
+			TempDirectory:          []string{"/t1", "/t2", "/t3"},
+			TargetDirectory:        []string{"/d1"},
+			NumberOfParallelPlots:  3,
+			StaggeringDelay:        30,
+			MaxActivePlotPerPhase1: 1,
+		}
+		h := newTestHarness(config)
+		h.TempPhaseTimes = map[string][4]time.Duration{"/t3": {time.Hour, time.Hour, time.Hour, time.Hour}}
+		h.Run(48*time.Hour, time.Minute)
+		active, finished := h.Plots()
+		var runs []run
+		for _, plot := range append(finished, active...) {
+			runs = append(runs, run{plot.StartTime, plot.EndTime, plot.PlotDir, plot.TargetDir})
+		}
+		sort.Slice(runs, func(i, j int) bool { return runs[i].start.Before(runs[j].start) })
+		return runs
+	}
+	first, second := schedule(), schedule()
+	if len(first) == 0 {
+		t.Fatal("no plot started")
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("two runs of the same schedule differ:\n%v\n%v", first, second)
+	}
+}
+
+func TestSchedulerHarnessQueuedJobOnDisabledDir(t *testing.T) {
+	config := &Config{
+		TempDirectory:         []string{"/t1"},
+		TargetDirectory:       []string{"/d1"},
+		NumberOfParallelPlots: 1,
+	}
+	h := newTestHarness(config)
+	h.server.overlay.Disabled["/t2"] = true
+	h.server.queue = []*PlotJob{{JobId: 1, TempDir: "/t2", TargetDir: "/d1"}}
+	h.Step()
+	active, _ := h.Plots()
+	if len(active) != 1 || active[0].PlotDir != "/t1" {
+		t.Fatalf("started %d plots, want one in /t1 and the job on the disabled /t2 left queued", len(active))
+	}
+	if len(h.server.queue) != 1 {
+		t.Errorf("the job on a disabled directory was started")
+	}
+}
@@ -2,32 +2,122 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/ricochet2200/go-disk-usage/du"
+	"plotng/internal/format"
 )
 
+// apiTimeout bounds the time an API request may take, profiles are exempt as they run for a
+// requested number of seconds.
+const apiTimeout = 30 * time.Second
+
+// shutdownTimeout is how long API requests in flight get to finish when the server stops.
+const shutdownTimeout = 5 * time.Second
+
+// msgEvents is the number of recent events sent to the UI.
+const msgEvents = 100
+
 type Server struct {
-	config               *PlotConfig
-	active               map[int64]*ActivePlot
-	archive              []*ActivePlot
-	currentTemp          int
-	currentTarget        int
-	targetDelayStartTime time.Time
-	lock                 sync.RWMutex
+	ctx             context.Context
+	plots           sync.WaitGroup
+	config          *PlotConfig
+	appliedConfig   *Config // the configuration the server state was last adjusted to
+	active          map[int64]*ActivePlot
+	archive         []*ActivePlot
+	retried         map[int64]bool // failed plots queued again
+	quotaCounts     *quotaCounts
+	clearedIds      map[string]bool
+	scheduler       Scheduler
+	schedulerName   string
+	profiles        *profileRotation
+	lastDigest      time.Time
+	tempThrottled   bool
+	memoryPaused    bool
+	threadsFull     bool
+	idle            idleMonitor
+	pace            paceState
+	onBattery       bool
+	nodeSyncing     bool
+	price           float64
+	priceTime       time.Time // of the last price fetched
+	priceRetry      time.Time // no fetch before, after a failure
+	priceBackoff    time.Duration
+	queue           []*PlotJob
+	nextJobId       int64
+	transfers       *transferManager
+	writeSpeeds     *writeSpeeds
+	offlineTargets  map[string]bool
+	remountAttempts map[string]int
+	overlay         *dirOverlay
+	credits         *PlotCredits
+	orphans         []*OrphanFile
+	external        []*ExternalPlot
+	distribution    []*DestinationSummary
+	rebalance       *Rebalance
+	offload         *bufferOffload
+	logs            *lineBuffer
+	events          *lineBuffer
+	startTime       time.Time
+	bus             *eventBus
+	revisions       *revisionCounter
+	stateCounts     map[PlotState]int
+	failureCounts   map[FailureCategory]int
+	streak          failureStreak
+	halted          *Halt
+	maintenance     MaintenanceStatus
+	inMaintenance   bool
+	suspended       []*ActivePlot
+	downtime        []Downtime
+	tempUsage       []UsageSample
+	tempWear        map[string]*TempWear
+	trim            tempTrim
+	ssh             *sshServer
+	telegram        *telegramBot
+	mqtt            *mqttPublisher
+	port            int
+	simulation      *simulation
+	clock           Clock
+	fs              Filesystem
+	plotRunner      func(plot *ActivePlot)
+	cycle           int
+	chiaVersion     *chiaVersionRule
+	keychain        *keychain
+	lock            sync.RWMutex
 }
 
-func (server *Server) ProcessLoop(configPath string, port int) {
+// ProcessLoop runs the scheduler and the API until ctx is cancelled, then kills the active plots
+// and waits for them to stop.
+func (server *Server) ProcessLoop(ctx context.Context, configPath string, port int) {
+	server.ctx = ctx
+	server.startTime = time.Now()
+	server.port = port
+	server.logs = newLineBuffer(2000)
+	server.events = newLineBuffer(1000)
+	log.SetOutput(io.MultiWriter(os.Stderr, server.logs))
 	gob.Register(Msg{})
-	gob.Register(ActivePlot{})
+	gob.Register(PlotStatus{})
+	gob.Register(PlotJob{})
+	gob.Register(OrphanFile{})
+	gob.Register(DestinationSummary{})
+	gob.Register(Rebalance{})
+	gob.Register(RebalanceMove{})
+	httpServer := &http.Server{
+		Addr:        fmt.Sprintf(":%d", port),
+		Handler:     server,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
 	go func() {
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", port), server); err != nil {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start webserver: %s", err)
 		}
 	}()
@@ -36,168 +126,322 @@ func (server *Server) ProcessLoop(configPath string, port int) {
 		ConfigPath: configPath,
 	}
 	server.active = map[int64]*ActivePlot{}
+	server.nextJobId = 1
+	server.transfers = newTransferManager()
+	server.writeSpeeds = newWriteSpeeds()
+	if server.simulation != nil {
+		log.Printf("Simulation mode, plotting with a fake plotter at %gx speed", server.simulation.speed)
+		server.chiaVersion = chiaVersionRules[len(chiaVersionRules)-1]
+	} else {
+		server.chiaVersion = detectChiaVersion()
+		server.keychain = &keychain{}
+	}
+	server.offlineTargets = map[string]bool{}
+	server.remountAttempts = map[string]int{}
+	server.overlay = loadOverlay(overlayPath(configPath))
+	server.credits = loadPlotCredits(creditsPath(configPath))
+	server.quotaCounts = loadQuotaCounts(quotaPath(configPath))
+	server.downtime = loadDowntime(downtimePath(configPath), time.Now())
+	server.tempWear = loadTempWear(wearPath(configPath))
+	server.stateCounts = map[PlotState]int{}
+	server.failureCounts = map[FailureCategory]int{}
+	server.bus = newEventBus()
+	server.revisions = &revisionCounter{}
+	server.bus.subscribe(server.recordEvent)
+	server.bus.subscribe(server.countQuotaPlot, EventPlotFinished) // before the plot leaves the active plots
+	server.bus.subscribe(server.archiveFinishedPlot, EventPlotFinished)
+	server.bus.subscribe(server.notifyPlotFailure, EventPlotFinished)
+	server.bus.subscribe(server.writeFailureReport, EventPlotFinished)
+	server.bus.subscribe(server.trackFailures, EventPlotFinished)
+	server.bus.subscribe(server.recordTempWrites, EventPlotFinished)
+	server.bus.subscribe(server.countTrimPlot, EventPlotFinished)
+	server.bus.subscribe(server.notifyTargetOffline, EventDiskOffline)
+	server.bus.subscribe(server.countTransition, EventStateChanged)
+	server.bus.subscribe(server.publishMqttEvent)
+	server.bus.subscribe(server.writeEventLog)
 	server.createPlot(time.Now())
 	ticker := time.NewTicker(time.Minute)
-	for t := range ticker.C {
-		server.createPlot(t)
+	defer ticker.Stop()
+	for {
+		select {
+		case t := <-ticker.C:
+			server.createPlot(t)
+		case <-ctx.Done():
+			server.shutdown(httpServer)
+			return
+		}
+	}
+}
+
+// shutdown stops the API and kills the active plots.
+func (server *Server) shutdown(httpServer *http.Server) {
+	log.Printf("Shutting down, killing active plots")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Failed to stop webserver: %s", err)
 	}
+	server.lock.Lock()
+	for _, plot := range server.active {
+		plot.kill()
+	}
+	server.lock.Unlock()
+	server.plots.Wait()
 }
 
 func (server *Server) createPlot(t time.Time) {
-	if server.config.ProcessConfig() {
-		server.targetDelayStartTime = time.Time{} // reset delay if new config was loaded
+	server.config.ProcessConfig()
+	server.config.Lock.RLock()
+	current := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	if current != server.appliedConfig {
+		server.lock.Lock()
+		server.applyConfig(server.appliedConfig, current)
+		server.lock.Unlock()
+		server.appliedConfig = current
+		server.remountAttempts = map[string]int{}
+	}
+	if current != nil {
+		// these ask the UPS, the node and the price provider, a slow answer mustn't hold up
+		// SaveConfig.  A configuration is replaced, never changed, so current stays valid.
+		server.checkPower(current)
+		server.checkNodeSync(current)
+		server.checkElectricityPrice(current, t)
 	}
 	if server.config.CurrentConfig != nil {
 		server.config.Lock.RLock()
-		if len(server.active) < server.config.CurrentConfig.NumberOfParallelPlots {
-			server.createNewPlot(server.config.CurrentConfig)
+		server.transfers.setConfig(server.config.CurrentConfig)
+		server.checkMaintenance(server.config.CurrentConfig, t)
+		server.checkSsh(server.config.CurrentConfig)
+		server.checkTelegram(server.config.CurrentConfig)
+		server.checkTargets(server.config.CurrentConfig)
+		server.scanExternalPlots()
+		server.checkEvacuations(server.config.CurrentConfig)
+		server.checkOffload(server.config.CurrentConfig)
+		if server.cycle%orphanScanCycles == 0 {
+			server.scanOrphans(server.config.CurrentConfig, t)
 		}
+		if server.cycle%distributionScanCycles == 0 {
+			server.scanDistribution(server.config.CurrentConfig, t)
+		}
+		if server.cycle%janitorCycles == 0 {
+			server.runJanitor(server.config.CurrentConfig, t)
+		}
+		server.checkTrims(server.config.CurrentConfig)
+		server.schedule(server.config.CurrentConfig, t)
+		server.recordTempUsage(server.config.CurrentConfig, t)
+		server.checkTempWear(server.config.CurrentConfig, server.cycle%smartCheckCycles == 0)
+		server.checkMqtt(server.config.CurrentConfig, t)
 		server.config.Lock.RUnlock()
 	}
-	fmt.Printf("%s, %d Active Plots\n", t.Format("2006-01-02 15:04:05"), len(server.active))
+	server.cycle++
+	server.saveDowntime(t)
+	server.checkOverduePlots(t)
+	server.sendDigestIfDue(t)
+	server.lock.RLock()
+	fmt.Printf("%s, %d Active Plots\n", format.Time(t), len(server.active))
 	for _, plot := range server.active {
 		fmt.Print(plot.String(server.config.CurrentConfig.ShowPlotLog))
-		if plot.State == PlotFinished || plot.State == PlotError {
-			server.archive = append(server.archive, plot)
-			delete(server.active, plot.PlotId)
-		}
 	}
+	server.lock.RUnlock()
 	fmt.Println(" ")
 }
 
-func (server *Server) createNewPlot(config *Config) {
-	defer server.lock.Unlock()
-	server.lock.Lock()
-	if len(config.TempDirectory) == 0 || len(config.TargetDirectory) == 0 {
-		return
+// startPlot adds the plot to the active plots and starts it.  The caller must hold server.lock.
+func (server *Server) startPlot(config *Config, plot *ActivePlot) {
+	plot.chiaVersion = server.chiaVersion
+	plot.keychain = server.keychain
+	plot.Subfolder = config.plotSubfolder(&plot.PlotStatus)
+	if len(plot.Plotter) == 0 {
+		plot.Plotter = server.chiaVersion.logParser
 	}
-	if time.Now().Before(server.targetDelayStartTime) {
-		log.Printf("Waiting until %s", server.targetDelayStartTime.Format("2006-01-02 15:04:05"))
-		return
-	}
-
-	if server.currentTarget >= len(config.TargetDirectory) {
-		server.currentTarget = 0
-		server.targetDelayStartTime = time.Now().Add(time.Duration(config.StaggeringDelay) * time.Minute)
-		return
-	}
-	if server.currentTemp >= len(config.TempDirectory) {
-		server.currentTemp = 0
-	}
-	if config.MaxActivePlotPerPhase1 > 0 {
-		getPhase1 := func(plot *ActivePlot) bool {
-			if strings.HasPrefix(plot.Phase, "1/4") {
-				return true
-			}
-			return false
+	if config.MaxConcurrentCopiesPerTarget > 0 || isRemoteTarget(plot.TargetDir) || config.NodeSyncCheck == nodeSyncDelayMoves {
+		plot.transfers = server.transfers
+		plot.s3 = &s3Credentials{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
 		}
-
-		var sum int
-
-		for _, plot := range server.active {
-			if getPhase1(plot) {
-				sum++
-			}
-		}
-
-		if config.MaxActivePlotPerPhase1 <= sum {
-			log.Printf("Skipping, Too many active plots in Phase 1: %d", sum)
-			return
-		}
-	}
-	plotDir := config.TempDirectory[server.currentTemp]
-	server.currentTemp++
-	if server.currentTemp >= len(config.TempDirectory) {
-		server.currentTemp = 0
+		plot.copyGroup = copyGroup(config, plot.TargetDir)
+		plot.maxCopies = config.MaxConcurrentCopiesPerTarget
 	}
-	if config.MaxActivePlotPerTemp > 0 && int(server.countActiveTemp(plotDir)) >= config.MaxActivePlotPerTemp {
-		log.Printf("Skipping [%s], too many active plots: %d", plotDir, int(server.countActiveTemp(plotDir)))
-		return
-	}
-	targetDir := config.TargetDirectory[server.currentTarget]
-	server.currentTarget++
-
-	if config.MaxActivePlotPerTarget > 0 && int(server.countActiveTarget(targetDir)) >= config.MaxActivePlotPerTarget {
-		log.Printf("Skipping [%s], too many active plots: %d", targetDir, int(server.countActiveTarget(targetDir)))
+	plot.events = server.bus
+	plot.clock = server.clock
+	plot.revisions = server.revisions
+	plot.simulation = server.simulation
+	plot.fs = server.fs
+	plot.writeSpeeds = server.writeSpeeds
+	plot.Revision = server.revisions.next()
+	plot.diskSpaceCheck = config.DiskSpaceCheck
+	plot.runAs, plot.owner = config.plotUsers()
+	plot.fileMode, plot.dirMode = config.plotModes()
+	server.active[plot.PlotId] = plot
+	server.bus.publish(Event{Type: EventPlotStarted, Plot: plot})
+	if server.plotRunner != nil {
+		server.plotRunner(plot)
 		return
 	}
+	server.plots.Add(1)
+	go func() {
+		defer server.plots.Done()
+		plot.RunPlot(server.ctx)
+	}()
+}
 
-	server.targetDelayStartTime = time.Now().Add(time.Duration(config.DelaysBetweenPlot) * time.Minute)
-
-	targetDirSpace := server.getDiskSpaceAvailable(targetDir)
-	if config.DiskSpaceCheck && (server.countActiveTarget(targetDir)+1)*PLOT_SIZE > targetDirSpace {
-		log.Printf("Skipping [%s], Not enough space: %d", targetDir, targetDirSpace/GB)
-		return
+// plotSnapshots returns copies of the active and archived plots, safe to encode while the plots
+// keep running.  The caller must hold server.lock.
+func (server *Server) plotSnapshots() (actives []*PlotStatus, archived []*PlotStatus) {
+	for _, plot := range server.active {
+		status := plot.Snapshot()
+		actives = append(actives, &status)
 	}
-
-	t := time.Now()
-	plot := &ActivePlot{
-		PlotId:           t.Unix(),
-		TargetDir:        targetDir,
-		PlotDir:          plotDir,
-		Fingerprint:      config.Fingerprint,
-		FarmerPublicKey:  config.FarmerPublicKey,
-		PoolPublicKey:    config.PoolPublicKey,
-		Threads:          config.Threads,
-		Buffers:          config.Buffers,
-		PlotSize:         config.PlotSize,
-		DisableBitField:  config.DisableBitField,
-		UseTargetForTmp2: config.UseTargetForTmp2,
-		BucketSize:       config.BucketSize,
-		SavePlotLogDir:   config.SavePlotLogDir,
-		Phase:            "NA",
-		Tail:             nil,
-		State:            PlotRunning,
+	for _, plot := range server.archive {
+		status := plot.Snapshot()
+		archived = append(archived, &status)
 	}
-	server.active[plot.PlotId] = plot
-	go plot.RunPlot()
+	return
 }
 
-func (server *Server) countActiveTarget(path string) (count uint64) {
-	for _, plot := range server.active {
-		if plot.TargetDir == path {
-			count++
+// recentResults counts the archived plots which finished and failed since the given time.  The
+// caller must hold server.lock.
+func (server *Server) recentResults(since time.Time) (finished int, failed int) {
+	for _, plot := range server.archive {
+		status := plot.Snapshot()
+		if status.EndTime.Before(since) {
+			continue
+		}
+		if status.State == PlotFinished {
+			finished++
+		} else {
+			failed++
 		}
 	}
 	return
 }
 
-func (server *Server) countActiveTemp(path string) (count uint64) {
-	for _, plot := range server.active {
-		if plot.PlotDir == path {
-			count++
-		}
+// newActivePlot creates a plot for plotDir and targetDir using the plotting parameters from config.
+func newActivePlot(config *Config, plotDir string, targetDir string) *ActivePlot {
+	return &ActivePlot{
+		PlotStatus: PlotStatus{
+			PlotId:           time.Now().UnixNano(),
+			TargetDir:        targetDir,
+			PlotDir:          plotDir,
+			Fingerprint:      config.Fingerprint,
+			FarmerPublicKey:  config.FarmerPublicKey,
+			PoolPublicKey:    config.PoolPublicKey,
+			PoolContract:     config.PoolContractAddress,
+			Threads:          config.Threads,
+			Buffers:          config.Buffers,
+			PlotSize:         config.PlotSize,
+			DisableBitField:  config.DisableBitField,
+			UseTargetForTmp2: config.UseTargetForTmp2,
+			BucketSize:       config.BucketSize,
+			SavePlotLogDir:   config.SavePlotLogDir,
+			Labels:           config.Labels,
+			IsolateWorkDir:   config.IsolatePlotDirs,
+			Plotter:          config.Plotter,
+			Phase:            "NA",
+			Tail:             nil,
+			State:            PlotQueued,
+		},
 	}
-	return
+}
+
+// plottingPaused reports whether external conditions currently prevent starting any new plot.
+func (server *Server) plottingPaused(config *Config, now time.Time) bool {
+	nodeSyncing := server.nodeSyncing && config.NodeSyncCheck == nodeSyncDelayPlots
+	return server.halted != nil || server.inMaintenance || server.onBattery || nodeSyncing || server.temperatureThrottled(config) || server.memoryLow(config) || server.electricityTooExpensive(config, now)
 }
 
 func (server *Server) getDiskSpaceAvailable(path string) uint64 {
-	d := du.NewDiskUsage(path)
-	return d.Available()
+	return server.filesystem().Available(path)
 }
 
 func (server *Server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set(apiVersionHeader, apiVersion)
+	resp.Header().Set(apiVersionsHeader, apiMajors())
+	path, err := apiPath(req.URL.Path)
+	if err != nil {
+		log.Printf("New query: %s -  %s: %s", req.Method, req.URL.Path, err)
+		http.Error(resp, err.Error(), http.StatusNotFound)
+		return
+	}
+	route := findApiRoute(path)
+	if route.mutating(req.Method) && !server.apiAuthorized(req) {
+		log.Printf("Refused query: %s -  %s from %s", req.Method, req.URL.Path, req.RemoteAddr)
+		http.Error(resp, "Forbidden, changes from another host need the ConfigToken", http.StatusForbidden)
+		return
+	}
+	if route.token {
+		log.Printf("New query: %s -  %s", req.Method, req.URL.Path) // without the token
+		route.handler(server, resp, withPath(req, path))
+		return
+	}
 	log.Printf("New query: %s -  %s", req.Method, req.URL.String())
+	ctx, cancel := context.WithTimeout(req.Context(), apiTimeout)
+	defer cancel()
+	req = withPath(req.WithContext(ctx), path)
+	resp.Header().Set("X-Time-Zone", time.Now().Format("MST -07:00"))
+	route.handler(server, resp, req)
+}
+
+// handleState returns the state of the server as a gob encoded Msg on GET, as polled by the UI,
+// and kills the active plot whose Id is the last segment of the path on DELETE.
+func (server *Server) handleState(resp http.ResponseWriter, req *http.Request) {
+	redact := server.redactor()
 	defer server.lock.RUnlock()
 	server.lock.RLock()
 
 	switch req.Method {
 	case "GET":
 		var msg Msg
+		msg.TimeZone = time.Now().Format("MST -07:00")
 		msg.TargetDirs = map[string]uint64{}
+		msg.Offline = server.offlineTargets
+		msg.Disabled = server.overlay.Disabled
+		msg.Evacuating = server.overlay.Evacuating
+		msg.Draining = server.overlay.Draining
+		msg.Trimming = server.trimming()
 		msg.TempDirs = map[string]uint64{}
-		for _, v := range server.active {
-			msg.Actives = append(msg.Actives, v)
+		since, epoch := parseDeltaQuery(req.URL.Query())
+		server.plotDeltas(&msg, since, epoch)
+		for _, plot := range append(msg.Actives, msg.Archived...) {
+			redact.plotLog(plot)
 		}
-		for _, v := range server.archive {
-			msg.Archived = append(msg.Archived, v)
+		msg.Events = redact.lines(server.events.Last(msgEvents))
+		msg.Queued = append(msg.Queued, server.queue...)
+		msg.Orphans = append(msg.Orphans, server.orphans...)
+		msg.External = append(msg.External, server.external...)
+		msg.Distribution = append(msg.Distribution, server.distribution...)
+		msg.Rebalance = server.rebalance.snapshot()
+		msg.Buffers, msg.Offload = server.offloadSnapshot(server.config.CurrentConfig)
+		msg.Downtime = append(msg.Downtime, server.downtime...)
+		msg.TempUsage = append(msg.TempUsage, server.tempUsage...)
+		msg.WriteSpeeds = server.currentWriteSpeeds(time.Now())
+		msg.Threads = server.threadPlan(server.config.CurrentConfig)
+		msg.Maintenance = server.maintenance
+		if server.halted != nil {
+			halt := *server.halted
+			halt.LastError = redact.line(halt.LastError)
+			msg.Halted = &halt
 		}
 		if server.config.CurrentConfig != nil {
+			msg.Quotas = server.config.CurrentConfig.Quotas
+			msg.QuotaFinished = server.quotaCounts.Finished
 			for _, dir := range server.config.CurrentConfig.TargetDirectory {
 				msg.TargetDirs[dir] = server.getDiskSpaceAvailable(dir)
 			}
+			for _, dir := range server.config.CurrentConfig.BufferDirectory {
+				msg.TargetDirs[dir] = server.getDiskSpaceAvailable(dir)
+			}
+			msg.TempWear = map[string]*TempWear{}
 			for _, dir := range server.config.CurrentConfig.TempDirectory {
 				msg.TempDirs[dir] = server.getDiskSpaceAvailable(dir)
+				if wear, ok := server.tempWear[dir]; ok {
+					copied := *wear
+					msg.TempWear[dir] = &copied
+				}
 			}
 		}
 		var buf bytes.Buffer
@@ -210,18 +454,59 @@ func (server *Server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 			log.Printf("Failed to encode message: %s", err)
 		}
 	case "DELETE":
+		id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		if len(id) == 0 {
+			http.Error(resp, "Missing plot Id", http.StatusBadRequest)
+			return
+		}
 		for _, v := range server.active {
-			if v.Id == req.RequestURI {
-				v.process.Kill()
-				v.State = PlotKilled
+			if v.Snapshot().Id == id {
+				v.kill()
+				resp.WriteHeader(http.StatusNoContent)
+				return
 			}
 		}
+		http.Error(resp, fmt.Sprintf("No active plot %s", id), http.StatusNotFound)
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 type Msg struct {
-	Actives    []*ActivePlot
-	Archived   []*ActivePlot
-	TempDirs   map[string]uint64
-	TargetDirs map[string]uint64
+	Actives       []*PlotStatus
+	Archived      []*PlotStatus
+	Queued        []*PlotJob
+	Quotas        map[string]int
+	QuotaFinished map[string]int // plots finished by customer, archived or not
+	Offline       map[string]bool
+	Disabled      map[string]bool
+	Evacuating    map[string]bool
+	Draining      map[string]bool
+	TempWear      map[string]*TempWear
+	Trimming      map[string]bool
+	Orphans       []*OrphanFile
+	External      []*ExternalPlot
+	Distribution  []*DestinationSummary
+	Rebalance     *Rebalance
+	Buffers       map[string]bool
+	Offload       *RebalanceMove
+	TempDirs      map[string]uint64
+	TargetDirs    map[string]uint64
+	WriteSpeeds   map[string]float64
+	TimeZone      string
+	Events        []string
+	Downtime      []Downtime
+	TempUsage     []UsageSample
+	Threads       ThreadPlan
+	Halted        *Halt
+	Maintenance   MaintenanceStatus
+
+	// Revision is the latest change to the plots in Actives and Archived.  When the client asked
+	// for the changes since a revision of the same Epoch, Since is that revision and only the plots
+	// changed after it are sent; ActiveIds and ArchivedCount let the client drop the rest.
+	Revision      int64
+	Epoch         int64
+	Since         int64
+	ActiveIds     []int64
+	ArchivedCount int
 }
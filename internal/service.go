@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ServiceOptions describe the PlotNG server registered with the service manager of the OS by
+// plotng service install, so that it starts at boot.
+type ServiceOptions struct {
+	Name       string // of the service, or the launchd label
+	ConfigPath string
+	Port       int
+	User       string // account the server runs as, the chia keys are in its profile
+	Password   string // of User, Windows only
+}
+
+// InstallService registers the server with the Windows Service Control Manager or launchd, and
+// starts it.  The PATH is kept so the service finds chia like the shell installing it.
+func InstallService(options ServiceOptions) error {
+	if len(options.Name) == 0 {
+		return fmt.Errorf("the service needs a name")
+	}
+	config, err := filepath.Abs(options.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(config); err != nil {
+		return fmt.Errorf("configuration file: %s", err)
+	}
+	options.ConfigPath = config
+	return installService(options)
+}
+
+// UninstallService stops the server and removes it from the service manager.
+func UninstallService(name string) error {
+	return uninstallService(name)
+}
+
+// serviceCommand returns the executable and arguments of the service.
+func serviceCommand(options ServiceOptions) (exe string, args []string, err error) {
+	if exe, err = os.Executable(); err != nil {
+		return "", nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe, []string{"-config", options.ConfigPath, "-port", strconv.Itoa(options.Port)}, nil
+}
+
+// serviceLogPath returns the file the service logs to, next to its configuration file.
+func serviceLogPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "plotng.log")
+}
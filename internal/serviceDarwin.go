@@ -0,0 +1,92 @@
+// +build darwin
+
+package internal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// launchdPlist returns where the job of the service is defined: a daemon started at boot when
+// installed as root, an agent started at login otherwise.
+func launchdPlist(name string) (string, error) {
+	if os.Geteuid() == 0 {
+		return filepath.Join("/Library/LaunchDaemons", name+".plist"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library/LaunchAgents", name+".plist"), nil
+}
+
+func installService(options ServiceOptions) error {
+	exe, args, err := serviceCommand(options)
+	if err != nil {
+		return err
+	}
+	path, err := launchdPlist(options.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists, uninstall the service first", path)
+	}
+	escape := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", escape(options.Name))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{exe}, args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", escape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	fmt.Fprintf(&b, "\t<key>EnvironmentVariables</key>\n\t<dict>\n\t\t<key>PATH</key>\n\t\t<string>%s</string>\n\t</dict>\n", escape(os.Getenv("PATH")))
+	fmt.Fprintf(&b, "\t<key>WorkingDirectory</key>\n\t<string>%s</string>\n", escape(filepath.Dir(options.ConfigPath)))
+	if len(options.User) > 0 {
+		fmt.Fprintf(&b, "\t<key>UserName</key>\n\t<string>%s</string>\n", escape(options.User))
+	}
+	logPath := escape(serviceLogPath(options.ConfigPath))
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", logPath, logPath)
+	// restarted after a crash, not after launchctl stop
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	b.WriteString("</dict>\n</plist>\n")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	if out, err := exec.Command("launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl load %s: %s %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func uninstallService(name string) error {
+	path, err := launchdPlist(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("service %s is not installed: %s", name, err)
+	}
+	if out, err := exec.Command("launchctl", "unload", "-w", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl unload %s: %s %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return os.Remove(path)
+}
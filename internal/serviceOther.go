@@ -0,0 +1,20 @@
+// +build !windows,!darwin
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+func installService(options ServiceOptions) error {
+	exe, args, err := serviceCommand(options)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("not supported on this OS, use its init system, e.g. a systemd unit with ExecStart=%s %s", exe, strings.Join(args, " "))
+}
+
+func uninstallService(name string) error {
+	return fmt.Errorf("not supported on this OS, use its init system")
+}
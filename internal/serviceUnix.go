@@ -0,0 +1,12 @@
+// +build !windows
+
+package internal
+
+import (
+	"context"
+)
+
+// RunDaemon runs the server, launchd and the init systems just start it.
+func RunDaemon(ctx context.Context, configPath string, run func(ctx context.Context)) {
+	run(ctx)
+}
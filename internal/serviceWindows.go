@@ -0,0 +1,137 @@
+// +build windows
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func installService(options ServiceOptions) error {
+	exe, args, err := serviceCommand(options)
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("can't connect to the service manager, run as Administrator: %s", err)
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(options.Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists, uninstall it first", options.Name)
+	}
+	config := mgr.Config{
+		DisplayName: "PlotNG " + options.Name,
+		Description: "PlotNG plot manager, " + options.ConfigPath,
+		StartType:   mgr.StartAutomatic,
+	}
+	if len(options.User) > 0 {
+		config.ServiceStartName = options.User
+		config.Password = options.Password
+	}
+	s, err := m.CreateService(options.Name, exe, config, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	// restarted after a crash, not after a stop
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}, 24*60*60); err != nil {
+		log.Printf("Failed to set the recovery actions of service %s: %s", options.Name, err)
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+options.Name, registry.SET_VALUE)
+	if err == nil {
+		err = key.SetStringsValue("Environment", []string{"PATH=" + os.Getenv("PATH")})
+		key.Close()
+	}
+	if err != nil {
+		log.Printf("Failed to set the PATH of service %s, it may not find chia: %s", options.Name, err)
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("service %s installed but not started: %s", options.Name, err)
+	}
+	return nil
+}
+
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("can't connect to the service manager, run as Administrator: %s", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	if status, err := s.Control(svc.Stop); err == nil {
+		for deadline := time.Now().Add(shutdownTimeout); status.State != svc.Stopped && time.Now().Before(deadline); {
+			time.Sleep(time.Second)
+			if status, err = s.Query(); err != nil {
+				break
+			}
+		}
+	}
+	return s.Delete()
+}
+
+// serviceHandler runs the server while the Service Control Manager lets it.
+type serviceHandler struct {
+	ctx context.Context
+	run func(ctx context.Context)
+}
+
+func (handler *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	ctx, cancel := context.WithCancel(handler.ctx)
+	done := make(chan struct{})
+	go func() {
+		handler.run(ctx)
+		close(done)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(shutdownTimeout / time.Millisecond)}
+				cancel()
+				<-done
+				return false, 0
+			}
+		case <-done:
+			cancel()
+			return false, 1 // restarted by the recovery actions
+		}
+	}
+}
+
+// RunDaemon runs the server, under the Service Control Manager when it was started as a service.
+// A service has no console, so it logs to plotng.log next to the configuration file.
+func RunDaemon(ctx context.Context, configPath string, run func(ctx context.Context)) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		run(ctx)
+		return
+	}
+	if abs, err := filepath.Abs(configPath); err == nil {
+		configPath = abs
+	}
+	if file, err := os.OpenFile(serviceLogPath(configPath), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+		os.Stdout, os.Stderr = file, file
+		log.SetOutput(file)
+	}
+	if err := svc.Run("", &serviceHandler{ctx: ctx, run: run}); err != nil {
+		log.Printf("Service failed: %s", err)
+	}
+}
@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	mathrand "math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// simulatedPlotSize is the size of the plot files written by the fake plotter, small enough to
+// copy quickly when PlotNG moves them.
+const simulatedPlotSize = 1 << 20
+
+// simulation runs plotng itself as a fake plotter instead of chia, so the UI, the schedulers and
+// the notifications can be tried without plotting hardware.
+type simulation struct {
+	speed    float64
+	failures float64
+}
+
+// Simulate makes the server start the fake plotter instead of chia.  speed is the number of
+// simulated seconds per second, failures the percentage of plots which fail.
+func (server *Server) Simulate(speed float64, failures float64) {
+	server.simulation = &simulation{speed: speed, failures: failures}
+}
+
+// command returns the command running the fake plotter with the arguments meant for chia.
+func (sim *simulation) command(ctx context.Context, args []string) *exec.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	simArgs := []string{
+		"simulate-plotter",
+		"-speed", strconv.FormatFloat(sim.speed, 'f', -1, 64),
+		"-failures", strconv.FormatFloat(sim.failures, 'f', -1, 64),
+	}
+	return exec.CommandContext(ctx, exe, append(simArgs, args...)...)
+}
+
+// plotterCommand returns the command creating the plot, chia unless simulating.
+func (ap *ActivePlot) plotterCommand(ctx context.Context, args []string) *exec.Cmd {
+	if ap.simulation != nil {
+		return ap.simulation.command(ctx, args)
+	}
+	return exec.CommandContext(ctx, "chia", args...)
+}
+
+// simulatedStep is a line of the fake plotter's output, written after the given share of the
+// plot time has passed.  Lines starting a phase get the time appended, lines ending a phase the
+// simulated duration of the phase.
+type simulatedStep struct {
+	share      float64
+	line       string
+	phaseStart int
+	phaseEnd   int
+}
+
+// simulatedTimeline follows the output of "chia plots create" for a k32 plot taking about 7
+// hours: 3h for phase 1, 1h15 for phase 2, 2h30 for phase 3 and 15 mins for phase 4.
+func simulatedTimeline() []simulatedStep {
+	var steps []simulatedStep
+	add := func(share float64, format string, args ...interface{}) {
+		steps = append(steps, simulatedStep{share: share, line: fmt.Sprintf(format, args...)})
+	}
+	start := func(phase int, line string) {
+		steps = append(steps, simulatedStep{line: fmt.Sprintf("Starting phase %d/4: %s", phase, line), phaseStart: phase})
+	}
+	end := func(share float64, phase int) {
+		steps = append(steps, simulatedStep{share: share, line: fmt.Sprintf("Time for phase %d =", phase), phaseEnd: phase})
+	}
+	start(1, "Forward Propagation into tmp files...")
+	add(0.01, "Computing table 1")
+	for table := 2; table <= 7; table++ {
+		add(0.067, "Computing table %d", table)
+		add(0, "\tBucket 0 uniform sort. Ram: 3.250GiB, u_sort min: 0.563GiB, qs min: 0.281GiB.")
+	}
+	end(0.02, 1)
+	start(2, "Backpropagation into tmp files...")
+	for table := 7; table >= 2; table-- {
+		add(0.03, "Backpropagating on table %d", table)
+	}
+	end(0, 2)
+	start(3, "Compression from tmp files into final file...")
+	for table := 1; table <= 6; table++ {
+		add(0.06, "Compressing tables %d and %d", table, table+1)
+	}
+	end(0, 3)
+	start(4, "Write Checkpoint tables into final file...")
+	add(0.005, "Write checkpoint tables")
+	end(0.03, 4)
+	return steps
+}
+
+// chiaTime formats t the way chia prints times in its log.
+func chiaTime(t time.Time) string {
+	return t.Format("Mon Jan _2 15:04:05 2006")
+}
+
+// SimulatePlotter is the fake plotter run by plotng in simulation mode.  It takes the arguments of
+// "chia plots create", prints a realistic log at the given speed, keeps a temp file in the temp
+// directory while it runs and writes a small plot file to the final directory.
+func SimulatePlotter(args []string) error {
+	flags := flag.NewFlagSet("simulate-plotter", flag.ContinueOnError)
+	speed := flags.Float64("speed", 60, "simulated seconds per second")
+	failures := flags.Float64("failures", 0, "percentage of plots which fail")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *speed <= 0 {
+		return fmt.Errorf("invalid speed: %g", *speed)
+	}
+	options := map[string]string{"-k": "32", "-t": os.TempDir(), "-d": os.TempDir()}
+	for _, arg := range flags.Args() {
+		if len(arg) > 2 && strings.HasPrefix(arg, "-") {
+			options[arg[:2]] = arg[2:]
+		}
+	}
+	k, err := strconv.Atoi(options["-k"])
+	if err != nil {
+		return fmt.Errorf("invalid k: %s", options["-k"])
+	}
+
+	random := mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+	idBytes := make([]byte, 32)
+	rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+	name := fmt.Sprintf("plot-k%d-%s-%s.plot", k, time.Now().Format("2006-01-02-15-04"), id)
+
+	// Every k doubles the plot time, each plot takes up to 15% more or less than the average.
+	total := 7 * time.Hour
+	for i := 32; i < k; i++ {
+		total *= 2
+	}
+	total = time.Duration(float64(total) * (0.85 + 0.3*random.Float64()) / *speed)
+	steps := simulatedTimeline()
+	failAt := -1
+	if random.Float64()*100 < *failures {
+		failAt = random.Intn(len(steps))
+	}
+
+	fmt.Printf("Starting plotting progress into temporary dirs: %s and %s\n", options["-t"], options["-t"])
+	fmt.Printf("ID: %s\n", id)
+	fmt.Printf("Plot size is: %d\n", k)
+	if len(options["-f"]) > 0 {
+		fmt.Printf("Farmer public key: %s\n", options["-f"])
+	}
+	if len(options["-p"]) > 0 {
+		fmt.Printf("Pool public key: %s\n", options["-p"])
+	}
+	if len(options["-c"]) > 0 {
+		fmt.Printf("Pool contract address: %s\n", options["-c"])
+	}
+	fmt.Printf("Process ID is: %d\n", os.Getpid())
+
+	tempFile := filepath.Join(options["-t"], name+".table1.tmp")
+	if err := ioutil.WriteFile(tempFile, nil, 0644); err != nil {
+		return err
+	}
+	started := time.Now()
+	var phaseStarted time.Time
+	for i, step := range steps {
+		time.Sleep(time.Duration(step.share * float64(total)))
+		if i == failAt {
+			fmt.Println("Caught plotting error: simulated failure")
+			return fmt.Errorf("simulated failure")
+		}
+		switch {
+		case step.phaseStart > 0:
+			phaseStarted = time.Now()
+			fmt.Printf("%s %s\n", step.line, chiaTime(phaseStarted))
+		case step.phaseEnd > 0:
+			fmt.Printf("%s %.3f seconds. CPU (150.00%%) %s\n", step.line, time.Since(phaseStarted).Seconds()**speed, chiaTime(time.Now()))
+		default:
+			fmt.Println(step.line)
+		}
+	}
+	os.Remove(tempFile)
+
+	final := filepath.Join(options["-d"], name)
+	if err := ioutil.WriteFile(final, make([]byte, simulatedPlotSize), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Total time = %.3f seconds. CPU (150.00%%) %s\n", time.Since(started).Seconds()**speed, chiaTime(time.Now()))
+	fmt.Printf("Renamed final file from \"%s.2.tmp\" to \"%s\"\n", final, final)
+	return nil
+}
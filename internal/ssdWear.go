@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// smartCheckCycles is how many cycles pass between two readings of the SMART counters.
+	smartCheckCycles = 60
+	// smartTimeout stops waiting for smartctl on a drive which doesn't answer.
+	smartTimeout = 30 * time.Second
+	// defaultTempWriteAmplification is about how many times its size chia writes to the temp
+	// directories for a plot, 1.4 TB for a k32 plot.  Without bitfield it writes about 1.8 TB.
+	defaultTempWriteAmplification           = 13
+	defaultTempWriteAmplificationNoBitfield = 17
+	// wearWarnPercent is the share of TempTbwBudget at which the drive is reported as approaching
+	// its endurance limit.
+	wearWarnPercent = 80
+)
+
+const (
+	wearAlertNone = iota
+	wearAlertApproaching
+	wearAlertExceeded
+)
+
+// TempWear is the endurance used on the drive of a temp directory.  It is kept next to the
+// configuration file, e.g. config.json.wear, so the estimate survives restarts.
+type TempWear struct {
+	Estimated uint64 // written by the plots, from their size and TempWriteAmplification
+	Smart     uint64 // written to the drive in its life, read from SMART with SmartDevices
+	Budget    uint64 // TempTbwBudget
+	Alerted   int    // the last alert sent, wearAlertApproaching or wearAlertExceeded
+}
+
+// Written returns the bytes written to the drive, from SMART when available.
+func (tw *TempWear) Written() uint64 {
+	if tw.Smart > 0 {
+		return tw.Smart
+	}
+	return tw.Estimated
+}
+
+// Percent returns the share of the budget used, 0 without budget.
+func (tw *TempWear) Percent() float64 {
+	if tw.Budget == 0 {
+		return 0
+	}
+	return float64(tw.Written()) / float64(tw.Budget) * 100
+}
+
+func (tw *TempWear) alertLevel() int {
+	switch percent := tw.Percent(); {
+	case tw.Budget == 0:
+		return wearAlertNone
+	case percent >= 100:
+		return wearAlertExceeded
+	case percent >= wearWarnPercent:
+		return wearAlertApproaching
+	}
+	return wearAlertNone
+}
+
+func wearPath(configPath string) string {
+	return configPath + ".wear"
+}
+
+func loadTempWear(path string) map[string]*TempWear {
+	wear := map[string]*TempWear{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read SSD wear %s: %s", path, err)
+		}
+		return wear
+	}
+	if err := json.Unmarshal(data, &wear); err != nil {
+		log.Printf("Failed to parse SSD wear %s: %s", path, err)
+	}
+	return wear
+}
+
+// saveTempWear writes the wear of the temp directories.  The caller must hold server.lock.
+func (server *Server) saveTempWear() {
+	data, err := json.MarshalIndent(server.tempWear, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(wearPath(server.config.ConfigPath), data, 0644)
+	}
+	if err != nil {
+		log.Printf("Failed to save SSD wear: %s", err)
+	}
+}
+
+// tempWearOf returns the wear of dir, adding it when unknown.  The caller must hold server.lock.
+func (server *Server) tempWearOf(dir string) *TempWear {
+	if server.tempWear == nil {
+		server.tempWear = map[string]*TempWear{}
+	}
+	wear, ok := server.tempWear[dir]
+	if !ok {
+		wear = &TempWear{}
+		server.tempWear[dir] = wear
+	}
+	return wear
+}
+
+// tempWrites estimates how much a plot wrote to its temp directory: all of it for a finished plot,
+// and a quarter for every phase a failed or killed one completed.
+func tempWrites(config *Config, status *PlotStatus) uint64 {
+	amplification := config.TempWriteAmplification
+	if amplification <= 0 {
+		amplification = defaultTempWriteAmplification
+		if status.DisableBitField {
+			amplification = defaultTempWriteAmplificationNoBitfield
+		}
+	}
+	share := 1.0
+	if status.State != PlotFinished {
+		share = 0
+		for _, done := range []time.Time{status.Phase1Time, status.Phase2Time, status.Phase3Time} {
+			if !done.IsZero() {
+				share += 0.25
+			}
+		}
+	}
+	return uint64(float64(expectedPlotSize(status.PlotSize)) * amplification * share)
+}
+
+// recordTempWrites adds the estimated writes of a plot which ended to the wear of its temp directory.
+func (server *Server) recordTempWrites(event Event) {
+	server.config.Lock.RLock()
+	config := server.config.CurrentConfig
+	server.config.Lock.RUnlock()
+	if config == nil {
+		return
+	}
+	status := event.Plot.Snapshot()
+	written := tempWrites(config, &status)
+	if written == 0 {
+		return
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.tempWearOf(status.PlotDir).Estimated += written
+	server.saveTempWear()
+}
+
+// checkTempWear reads the SMART counters of the SmartDevices when readSmart is set, and sends an
+// alert when a temp drive approaches or exceeds its TempTbwBudget.
+func (server *Server) checkTempWear(config *Config, readSmart bool) {
+	smart := map[string]uint64{}
+	if readSmart {
+		for dir, device := range config.SmartDevices {
+			written, err := readSmartWritten(device)
+			if err != nil {
+				log.Printf("Failed to read the SMART data of %s for [%s]: %s", device, dir, err)
+				continue
+			}
+			smart[dir] = written
+		}
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	changed := false
+	for _, dir := range config.TempDirectory {
+		wear := server.tempWearOf(dir)
+		if written, ok := smart[dir]; ok && written != wear.Smart {
+			wear.Smart, changed = written, true
+		}
+		if budget := uint64(config.TempTbwBudget[dir] * 1e12); budget != wear.Budget {
+			wear.Budget, changed = budget, true
+		}
+		level := wear.alertLevel()
+		if level == wear.Alerted {
+			continue
+		}
+		if level > wear.Alerted {
+			message := fmt.Sprintf("[%s] wrote %.1f TB, %.0f%% of its %g TBW budget", dir, float64(wear.Written())/1e12, wear.Percent(), config.TempTbwBudget[dir])
+			subject := "Temp SSD approaching its endurance limit"
+			if level == wearAlertExceeded {
+				subject = "Temp SSD exceeded its endurance limit"
+			}
+			server.schedulerEvent("%s: %s", subject, message)
+			notify(config, subject, message)
+		}
+		wear.Alerted, changed = level, true
+	}
+	if changed {
+		server.saveTempWear()
+	}
+}
+
+// smartctlOutput is the part of "smartctl --json -A" telling how much was written to the drive.
+type smartctlOutput struct {
+	NvmeLog *struct {
+		DataUnitsWritten uint64 `json:"data_units_written"`
+	} `json:"nvme_smart_health_information_log"`
+	AtaAttributes *struct {
+		Table []struct {
+			Id  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	LogicalBlockSize uint64 `json:"logical_block_size"`
+}
+
+// readSmartWritten returns the bytes written to device in its life, from the data units of an
+// NVMe drive or the Total_LBAs_Written attribute of a SATA drive.
+func readSmartWritten(device string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), smartTimeout)
+	defer cancel()
+	// smartctl sets bits of its exit status for warnings about the drive, the output is still valid.
+	out, err := exec.CommandContext(ctx, "smartctl", "--json", "-A", "-i", device).Output()
+	if len(out) == 0 && err != nil {
+		return 0, err
+	}
+	var parsed smartctlOutput
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return 0, err
+	}
+	if parsed.NvmeLog != nil {
+		return parsed.NvmeLog.DataUnitsWritten * 512000, nil
+	}
+	if parsed.AtaAttributes != nil {
+		blockSize := parsed.LogicalBlockSize
+		if blockSize == 0 {
+			blockSize = 512
+		}
+		for _, attribute := range parsed.AtaAttributes.Table {
+			if attribute.Id == 241 {
+				return attribute.Raw.Value * blockSize, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("smartctl reports no bytes written")
+}
@@ -0,0 +1,419 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultSshHostKey is the file name of the ssh host key, next to the configuration file, when
+// SshHostKey isn't set.  It is generated on first use.
+const defaultSshHostKey = "plotng_ssh_host_key"
+
+// sshServer serves the read-only UI to ssh clients, so anyone with an authorized key can watch
+// the plots with "ssh -p 2222 plotng@host", without the PlotNG client or access to the HTTP port.
+type sshServer struct {
+	addr           string
+	hostKey        string
+	authorizedKeys string
+	listener       net.Listener
+}
+
+// checkSsh starts the ssh server, or restarts it when its settings change.
+func (server *Server) checkSsh(config *Config) {
+	hostKey := config.SshHostKey
+	if len(hostKey) == 0 {
+		hostKey = filepath.Join(filepath.Dir(server.config.ConfigPath), defaultSshHostKey)
+	}
+	if server.ssh != nil {
+		if server.ssh.addr == config.SshListen && server.ssh.hostKey == hostKey && server.ssh.authorizedKeys == config.SshAuthorizedKeys {
+			return
+		}
+		if server.ssh.listener != nil {
+			server.ssh.listener.Close()
+			log.Printf("Stopped the ssh server on %s", server.ssh.addr)
+		}
+		server.ssh = nil
+	}
+	if len(config.SshListen) == 0 {
+		return
+	}
+	// A failed start is remembered too, so it is only retried when the settings change.
+	server.ssh = &sshServer{addr: config.SshListen, hostKey: hostKey, authorizedKeys: config.SshAuthorizedKeys}
+	sshConfig, err := server.ssh.serverConfig()
+	if err != nil {
+		log.Printf("Failed to start the ssh server: %s", err)
+		return
+	}
+	listener, err := net.Listen("tcp", config.SshListen)
+	if err != nil {
+		log.Printf("Failed to start the ssh server: %s", err)
+		return
+	}
+	server.ssh.listener = listener
+	log.Printf("Serving the read-only UI over ssh on %s", config.SshListen)
+	go server.ssh.serve(listener, sshConfig, fmt.Sprintf("localhost:%d", server.port))
+}
+
+// serverConfig accepts the keys of SshAuthorizedKeys, read again on every login so keys can be
+// added or revoked without restarting the server.
+func (ss *sshServer) serverConfig() (*ssh.ServerConfig, error) {
+	signer, err := loadSshHostKey(ss.hostKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := readAuthorizedKeys(ss.authorizedKeys); err != nil {
+		return nil, err
+	}
+	authorizedKeys := ss.authorizedKeys
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			keys, err := readAuthorizedKeys(authorizedKeys)
+			if err != nil {
+				return nil, err
+			}
+			if !keys[string(key.Marshal())] {
+				return nil, fmt.Errorf("unknown key for %s", conn.User())
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	return config, nil
+}
+
+// loadSshHostKey reads the host key from path, or generates an ed25519 key there if it doesn't
+// exist yet.
+func loadSshHostKey(path string) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return nil, err
+		}
+		log.Printf("Generated the ssh host key %s", path)
+	} else if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh host key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// readAuthorizedKeys reads a file in the format of ~/.ssh/authorized_keys, the options of the
+// keys are ignored.
+func readAuthorizedKeys(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for len(data) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			break // no more keys, ParseAuthorizedKey skips comments and invalid lines
+		}
+		keys[string(key.Marshal())] = true
+		data = rest
+	}
+	return keys, nil
+}
+
+func (ss *sshServer) serve(listener net.Listener, config *ssh.ServerConfig, host string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go serveSshConn(conn, config, host)
+	}
+}
+
+func serveSshConn(conn net.Conn, config *ssh.ServerConfig, host string) {
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		log.Printf("Refused ssh login from %s: %s", conn.RemoteAddr(), err)
+		return
+	}
+	log.Printf("Ssh session for %s from %s", sshConn.User(), sshConn.RemoteAddr())
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSshSession(channel, requests, host)
+	}
+	log.Printf("Ssh session for %s from %s ended", sshConn.User(), sshConn.RemoteAddr())
+}
+
+// serveSshSession runs the UI once the client asks for a shell with a terminal, commands and
+// subsystems are refused.
+func serveSshSession(channel ssh.Channel, requests <-chan *ssh.Request, host string) {
+	var tty *sshTty
+	term := ""
+	for req := range requests {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term          string
+				Columns, Rows uint32
+				Width, Height uint32
+				Modes         string
+			}
+			if ssh.Unmarshal(req.Payload, &pty) == nil && tty == nil {
+				term = pty.Term
+				tty = newSshTty(channel, int(pty.Columns), int(pty.Rows))
+				ok = true
+			}
+		case "window-change":
+			var size struct {
+				Columns, Rows uint32
+				Width, Height uint32
+			}
+			if ssh.Unmarshal(req.Payload, &size) == nil && tty != nil {
+				tty.resize(int(size.Columns), int(size.Rows))
+				ok = true
+			}
+		case "shell":
+			ok = true
+			if tty == nil {
+				fmt.Fprint(channel, "PlotNG needs a terminal, connect with ssh -t\r\n")
+				go closeSshSession(channel, 1)
+			} else {
+				go runSshUI(channel, tty, term, host)
+			}
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+// runSshUI runs a read-only client of the local server on the terminal of the session.
+func runSshUI(channel ssh.Channel, tty *sshTty, term string, host string) {
+	status := 0
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Ssh UI failed: %v", p)
+			status = 1
+		}
+		closeSshSession(channel, status)
+	}()
+	screen, err := newSshScreen(tty, term)
+	if err == nil {
+		err = screen.Init()
+	}
+	if err != nil {
+		fmt.Fprintf(channel, "Failed to start the UI: %s\r\n", err)
+		status = 1
+		return
+	}
+	screen.EnableMouse()
+
+	client := &Client{
+		http:     &http.Client{Timeout: httpClient.Timeout, Transport: readOnlyTransport{}},
+		readOnly: true,
+	}
+	client.init(host)
+	client.setupUI()
+	client.app.SetScreen(screen)
+	tty.onClose(func() { go client.stop() })
+	go client.processLoop()
+	if err := client.app.Run(); err != nil {
+		log.Printf("Ssh UI failed: %s", err)
+		status = 1
+	}
+}
+
+func closeSshSession(channel ssh.Channel, status int) {
+	exit := struct{ Status uint32 }{uint32(status)}
+	channel.SendRequest("exit-status", false, ssh.Marshal(&exit))
+	channel.Close()
+}
+
+// sshTermLock serializes the creation of the screens, tcell looks the terminal up by $TERM.
+var sshTermLock sync.Mutex
+
+// newSshScreen returns a screen for the terminal type of the ssh client, or xterm if it's unknown.
+func newSshScreen(tty *sshTty, term string) (tcell.Screen, error) {
+	sshTermLock.Lock()
+	defer sshTermLock.Unlock()
+	saved, set := os.LookupEnv("TERM")
+	defer func() {
+		if set {
+			os.Setenv("TERM", saved)
+		} else {
+			os.Unsetenv("TERM")
+		}
+	}()
+	os.Setenv("TERM", term)
+	screen, err := tcell.NewTerminfoScreenFromTty(tty)
+	if err != nil {
+		os.Setenv("TERM", "xterm-256color")
+		screen, err = tcell.NewTerminfoScreenFromTty(tty)
+	}
+	return screen, err
+}
+
+// readOnlyTransport refuses the requests of the ssh sessions which would change the server.
+type readOnlyTransport struct{}
+
+func (readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, errors.New("this session is read-only")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// sshTty is the terminal of an ssh session for tcell.  Its input is read on a goroutine, so Drain
+// can wake up a pending Read when the UI stops.
+type sshTty struct {
+	channel  ssh.Channel
+	input    chan []byte
+	pending  []byte
+	drain    chan struct{}
+	drained  sync.Once
+	lock     sync.Mutex
+	closed   func()
+	eof      bool
+	width    int
+	height   int
+	onResize func()
+}
+
+func newSshTty(channel ssh.Channel, width int, height int) *sshTty {
+	tty := &sshTty{
+		channel: channel,
+		input:   make(chan []byte),
+		drain:   make(chan struct{}),
+		width:   width,
+		height:  height,
+	}
+	go tty.readInput()
+	return tty
+}
+
+// readInput passes the input of the session to Read, and stops the UI when the client
+// disconnects.
+func (tty *sshTty) readInput() {
+	for {
+		buf := make([]byte, 256)
+		n, err := tty.channel.Read(buf)
+		if n > 0 {
+			select {
+			case tty.input <- buf[:n]:
+			case <-tty.drain:
+				return
+			}
+		}
+		if err != nil {
+			tty.lock.Lock()
+			tty.eof = true
+			closed := tty.closed
+			tty.lock.Unlock()
+			if closed != nil {
+				closed()
+			}
+			return
+		}
+	}
+}
+
+// onClose calls closed when the client disconnects, at once if it already has.
+func (tty *sshTty) onClose(closed func()) {
+	tty.lock.Lock()
+	tty.closed = closed
+	eof := tty.eof
+	tty.lock.Unlock()
+	if eof {
+		closed()
+	}
+}
+
+func (tty *sshTty) resize(width int, height int) {
+	tty.lock.Lock()
+	tty.width, tty.height = width, height
+	onResize := tty.onResize
+	tty.lock.Unlock()
+	if onResize != nil {
+		onResize()
+	}
+}
+
+// Start does nothing, the terminal of the ssh client is already in raw mode.
+func (tty *sshTty) Start() error {
+	return nil
+}
+
+func (tty *sshTty) Stop() error {
+	return nil
+}
+
+// Drain wakes up Read for good, the UI of a session is never restarted.
+func (tty *sshTty) Drain() error {
+	tty.drained.Do(func() { close(tty.drain) })
+	return nil
+}
+
+func (tty *sshTty) NotifyResize(cb func()) {
+	tty.lock.Lock()
+	tty.onResize = cb
+	tty.lock.Unlock()
+}
+
+func (tty *sshTty) WindowSize() (int, int, error) {
+	tty.lock.Lock()
+	defer tty.lock.Unlock()
+	return tty.width, tty.height, nil
+}
+
+func (tty *sshTty) Read(b []byte) (int, error) {
+	if len(tty.pending) == 0 {
+		select {
+		case tty.pending = <-tty.input:
+		case <-tty.drain:
+			return 0, nil
+		}
+	}
+	n := copy(b, tty.pending)
+	tty.pending = tty.pending[n:]
+	return n, nil
+}
+
+func (tty *sshTty) Write(b []byte) (int, error) {
+	return tty.channel.Write(b)
+}
+
+// Close does nothing, the session closes the channel when the UI stops.
+func (tty *sshTty) Close() error {
+	return nil
+}
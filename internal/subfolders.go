@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// subfolderPlaceholder matches the placeholders of PlotSubfolder.
+var subfolderPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// soloSubfolder names the plots without a pool contract, and stands in for missing keys.
+const soloSubfolder = "solo"
+
+// subfolderValues returns the values of the placeholders of PlotSubfolder for a plot.
+func subfolderValues(config *Config, status *PlotStatus) map[string]string {
+	orSolo := func(value string) string {
+		if len(value) == 0 {
+			return soloSubfolder
+		}
+		return value
+	}
+	name := orSolo(status.PoolContract)
+	for _, key := range []string{status.PoolContract, status.PoolPublicKey, status.FarmerPublicKey, status.Fingerprint} {
+		if named, ok := config.PlotSubfolderNames[key]; ok && len(key) > 0 {
+			name = named
+			break
+		}
+	}
+	label := ""
+	if len(status.Labels) > 0 {
+		label = status.Labels[0]
+	}
+	return map[string]string{
+		"{name}":        name,
+		"{contract}":    orSolo(status.PoolContract),
+		"{pool}":        orSolo(status.PoolPublicKey),
+		"{farmer}":      orSolo(status.FarmerPublicKey),
+		"{fingerprint}": orSolo(status.Fingerprint),
+		"{k}":           "k" + strconv.Itoa(plotK(status.PlotSize)),
+		"{label}":       orSolo(label),
+		"{profile}":     orSolo(status.Profile),
+	}
+}
+
+// plotSubfolder returns the subdirectory of the dest directory the final plot of status goes to,
+// following PlotSubfolder, with forward slashes.  It is empty without PlotSubfolder.
+func (config *Config) plotSubfolder(status *PlotStatus) string {
+	if len(config.PlotSubfolder) == 0 {
+		return ""
+	}
+	values := subfolderValues(config, status)
+	subfolder := subfolderPlaceholder.ReplaceAllStringFunc(config.PlotSubfolder, func(placeholder string) string {
+		return values[placeholder]
+	})
+	return path.Clean(subfolder)
+}
+
+// validatePlotSubfolder checks that PlotSubfolder only uses known placeholders and stays inside
+// the dest directory, and that the names of PlotSubfolderNames are single directory names.
+func validatePlotSubfolder(config *Config) error {
+	values := subfolderValues(config, &PlotStatus{})
+	for _, placeholder := range subfolderPlaceholder.FindAllString(config.PlotSubfolder, -1) {
+		if _, ok := values[placeholder]; !ok {
+			return fmt.Errorf("unknown placeholder %s in PlotSubfolder", placeholder)
+		}
+	}
+	if strings.Contains(config.PlotSubfolder, `\`) || strings.HasPrefix(config.PlotSubfolder, "/") {
+		return fmt.Errorf("PlotSubfolder must be a relative path with forward slashes")
+	}
+	for _, element := range strings.Split(config.PlotSubfolder, "/") {
+		if element == ".." {
+			return fmt.Errorf("PlotSubfolder can't leave the dest directory")
+		}
+	}
+	for key, name := range config.PlotSubfolderNames {
+		if len(name) == 0 || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("invalid name %q for %s in PlotSubfolderNames", name, key)
+		}
+	}
+	return nil
+}
+
+// plotSubfolderOf returns the subfolder of dir holding the plot file at path, with forward
+// slashes, or an empty string for a plot right in dir.
+func plotSubfolderOf(dir string, file string) string {
+	rel, err := filepath.Rel(dir, filepath.Dir(file))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// name returns the name of the plot moved, in the subfolder it is moved to.
+func (move *RebalanceMove) name() string {
+	return path.Join(move.Subfolder, filepath.Base(move.Source))
+}
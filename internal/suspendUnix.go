@@ -0,0 +1,20 @@
+// +build !windows
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// suspendProcess stops or continues the process with SIGSTOP or SIGCONT.
+func suspendProcess(pid int, suspend bool) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if suspend {
+		return process.Signal(syscall.SIGSTOP)
+	}
+	return process.Signal(syscall.SIGCONT)
+}
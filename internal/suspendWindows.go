@@ -0,0 +1,35 @@
+// +build windows
+
+package internal
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	ntdll            = windows.NewLazySystemDLL("ntdll.dll")
+	ntSuspendProcess = ntdll.NewProc("NtSuspendProcess")
+	ntResumeProcess  = ntdll.NewProc("NtResumeProcess")
+)
+
+// suspendProcess suspends or resumes all the threads of the process, Windows has no SIGSTOP.
+func suspendProcess(pid int, suspend bool) error {
+	handle, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("open process %d: %s", pid, err)
+	}
+	defer windows.CloseHandle(handle)
+	proc := ntResumeProcess
+	if suspend {
+		proc = ntSuspendProcess
+	}
+	if err := proc.Find(); err != nil {
+		return err
+	}
+	if status, _, _ := proc.Call(uintptr(handle)); status != 0 {
+		return fmt.Errorf("%s of process %d failed: %s", proc.Name, pid, windows.NTStatus(status))
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// telegramApiUrl is the Telegram Bot API, the token follows "bot".
+var telegramApiUrl = "https://api.telegram.org/bot"
+
+// telegramPollSeconds is how long a request for new messages waits for one to arrive.
+const telegramPollSeconds = 50
+
+// telegramRetryDelay is the delay before polling again after Telegram couldn't be reached.
+const telegramRetryDelay = 30 * time.Second
+
+var telegramClient = &http.Client{
+	Timeout: (telegramPollSeconds + 10) * time.Second,
+}
+
+// telegramHelp lists the commands of the bot, in the format BotFather expects for /setcommands.
+const telegramHelp = `status - active plots, queue and halts
+pause - stop starting new plots
+resume - start new plots again
+kill - kill an active plot: /kill <plot id>`
+
+// telegramBot answers the commands sent to the bot by the chats of TelegramChatIds.
+type telegramBot struct {
+	token  string
+	cancel context.CancelFunc
+}
+
+type telegramUpdate struct {
+	UpdateId int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			Id int64 `json:"id"`
+		} `json:"chat"`
+		From struct {
+			Username  string `json:"username"`
+			FirstName string `json:"first_name"`
+		} `json:"from"`
+	} `json:"message"`
+}
+
+// checkTelegram starts the bot, or restarts it when the token changes.  The allowed chats are
+// read from the current configuration for every command.
+func (server *Server) checkTelegram(config *Config) {
+	if server.telegram != nil {
+		if server.telegram.token == config.TelegramBotToken {
+			return
+		}
+		server.telegram.cancel()
+		server.telegram = nil
+	}
+	if len(config.TelegramBotToken) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(server.ctx)
+	server.telegram = &telegramBot{token: config.TelegramBotToken, cancel: cancel}
+	go server.runTelegramBot(ctx, config.TelegramBotToken)
+}
+
+func (server *Server) runTelegramBot(ctx context.Context, token string) {
+	log.Printf("Telegram bot started")
+	var offset int64
+	failed := false
+	for ctx.Err() == nil {
+		updates, err := telegramUpdates(ctx, token, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if !failed {
+				log.Printf("Failed to get the Telegram messages, retrying every %s: %s", telegramRetryDelay, err)
+				failed = true
+			}
+			select {
+			case <-time.After(telegramRetryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		failed = false
+		for _, update := range updates {
+			offset = update.UpdateId + 1
+			if update.Message == nil || !strings.HasPrefix(update.Message.Text, "/") {
+				continue
+			}
+			chat := update.Message.Chat.Id
+			if !server.telegramChatAllowed(chat) {
+				log.Printf("Ignored Telegram command from chat %d, which isn't in TelegramChatIds", chat)
+				continue
+			}
+			from := update.Message.From.Username
+			if len(from) == 0 {
+				from = update.Message.From.FirstName
+			}
+			if len(from) == 0 {
+				from = fmt.Sprintf("chat %d", chat)
+			}
+			log.Printf("Telegram command from %s: %s", from, update.Message.Text)
+			reply := server.telegramCommand(update.Message.Text, from)
+			if err := telegramSend(ctx, token, chat, reply); err != nil {
+				log.Printf("Failed to answer on Telegram: %s", err)
+			}
+		}
+	}
+	log.Printf("Telegram bot stopped")
+}
+
+func (server *Server) telegramChatAllowed(chat int64) bool {
+	server.config.Lock.RLock()
+	defer server.config.Lock.RUnlock()
+	if server.config.CurrentConfig == nil {
+		return false
+	}
+	for _, allowed := range server.config.CurrentConfig.TelegramChatIds {
+		if allowed == chat {
+			return true
+		}
+	}
+	return false
+}
+
+// telegramCommand runs a command sent to the bot and returns the answer.
+func (server *Server) telegramCommand(text string, from string) string {
+	args := strings.Fields(text)
+	command := strings.SplitN(args[0], "@", 2)[0] // commands in groups are sent as /status@botname
+	switch command {
+	case "/status":
+		return server.telegramStatus()
+	case "/pause":
+		if !server.pause(fmt.Sprintf("Paused from Telegram by %s", from)) {
+			return "Plotting is already halted, /resume to start new plots again"
+		}
+		return "Paused, no new plots are started until /resume"
+	case "/resume":
+		if !server.resume() {
+			return "Plotting isn't paused"
+		}
+		return "Resumed, new plots are started again"
+	case "/kill":
+		if len(args) != 2 {
+			return "Usage: /kill <plot id>, the plot IDs are listed by /status"
+		}
+		return server.telegramKill(args[1])
+	}
+	return "Commands:\n" + telegramHelp
+}
+
+// telegramStatus summarises the active plots, the queue and why plotting is paused.
+func (server *Server) telegramStatus() string {
+	now := server.now()
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	var buf bytes.Buffer
+	if host, err := os.Hostname(); err == nil {
+		fmt.Fprintf(&buf, "%s\n", host)
+	}
+	if server.halted != nil {
+		fmt.Fprintf(&buf, "HALTED: %s\n", server.halted)
+	} else if server.inMaintenance {
+		fmt.Fprintf(&buf, "In maintenance window %s until %s\n", server.maintenance.Name, format.ShortTime(server.maintenance.End))
+	}
+	finished, failed := server.recentResults(now.Add(-24 * time.Hour))
+	fmt.Fprintf(&buf, "%d active plots, %d queued jobs\n", len(server.active), len(server.queue))
+	fmt.Fprintf(&buf, "Last 24 hours: %d finished, %d failed\n", finished, failed)
+	var actives []PlotStatus
+	for _, plot := range server.active {
+		actives = append(actives, plot.Snapshot())
+	}
+	sort.Slice(actives, func(i, j int) bool { return actives[i].StartTime.Before(actives[j].StartTime) })
+	for _, status := range actives {
+		id := status.Id
+		if len(id) > 10 {
+			id = id[:10]
+		}
+		if len(id) == 0 {
+			id = strconv.FormatInt(status.PlotId, 10)
+		}
+		fmt.Fprintf(&buf, "%s  %s  phase %s  %s\n", id, status.State, status.Phase, format.Duration(now.Sub(status.StartTime)))
+	}
+	return buf.String()
+}
+
+// telegramKill kills the active plot whose plot ID starts with id, as shown by /status.
+func (server *Server) telegramKill(id string) string {
+	var matches []*ActivePlot
+	server.lock.RLock()
+	for _, plot := range server.active {
+		status := plot.Snapshot()
+		if (len(status.Id) > 0 && strings.HasPrefix(status.Id, strings.ToLower(id))) || strconv.FormatInt(status.PlotId, 10) == id {
+			matches = append(matches, plot)
+		}
+	}
+	server.lock.RUnlock()
+	switch len(matches) {
+	case 0:
+		return fmt.Sprintf("No active plot %s", id)
+	case 1:
+		matches[0].kill()
+		if state := matches[0].currentState(); state != PlotKilled {
+			return fmt.Sprintf("Plot %s can't be killed while %s", id, state)
+		}
+		return fmt.Sprintf("Killed plot %s", id)
+	}
+	return fmt.Sprintf("%d active plots start with %s, use more of the plot ID", len(matches), id)
+}
+
+func telegramUpdates(ctx context.Context, token string, offset int64) ([]telegramUpdate, error) {
+	params := url.Values{}
+	params.Set("timeout", strconv.Itoa(telegramPollSeconds))
+	params.Set("offset", strconv.FormatInt(offset, 10))
+	params.Set("allowed_updates", `["message"]`)
+	var result struct {
+		Ok          bool             `json:"ok"`
+		Description string           `json:"description"`
+		Result      []telegramUpdate `json:"result"`
+	}
+	if err := telegramCall(ctx, token, "getUpdates", params, &result); err != nil {
+		return nil, err
+	}
+	if !result.Ok {
+		return nil, fmt.Errorf("%s", result.Description)
+	}
+	return result.Result, nil
+}
+
+func telegramSend(ctx context.Context, token string, chat int64, text string) error {
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chat, 10))
+	params.Set("text", text)
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := telegramCall(ctx, token, "sendMessage", params, &result); err != nil {
+		return err
+	}
+	if !result.Ok {
+		return fmt.Errorf("%s", result.Description)
+	}
+	return nil
+}
+
+// telegramCall posts a request to the Bot API.  The errors leave out the URL, which holds the
+// token.
+func telegramCall(ctx context.Context, token string, method string, params url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", telegramApiUrl+token+"/"+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return errors.New("invalid TelegramBotToken")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := telegramClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package internal
+
+import "time"
+
+// tempUsageWindow is how long the temp disk usage is kept, for the graph of the statistics view.
+const tempUsageWindow = 24 * time.Hour
+
+// tempUsageInterval is the time between two samples of the temp disk usage.
+const tempUsageInterval = 15 * time.Minute
+
+// UsageSample is the share of the temp drives in use at a time, in percent.
+type UsageSample struct {
+	Time    time.Time
+	Percent float64
+}
+
+// recordTempUsage samples the space used on the temp directories, all of them together, at most
+// once every tempUsageInterval, and drops the samples older than tempUsageWindow.
+func (server *Server) recordTempUsage(config *Config, now time.Time) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if n := len(server.tempUsage); n > 0 && now.Sub(server.tempUsage[n-1].Time) < tempUsageInterval {
+		return
+	}
+	var size, available uint64
+	for _, dir := range config.TempDirectory {
+		if dirSize := server.filesystem().Size(dir); dirSize > 0 {
+			size += dirSize
+			available += server.getDiskSpaceAvailable(dir)
+		}
+	}
+	if size == 0 || available > size {
+		return
+	}
+	server.tempUsage = append(server.tempUsage, UsageSample{Time: now, Percent: float64(size-available) / float64(size) * 100})
+	for len(server.tempUsage) > 0 && now.Sub(server.tempUsage[0].Time) > tempUsageWindow {
+		server.tempUsage = server.tempUsage[1:]
+	}
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// temperatureHysteresis is how many degrees a sensor has to drop below its threshold before
+// plotting resumes, so we don't flip between throttled and running every cycle.
+const temperatureHysteresis = 5
+
+var cpuSensorNames = map[string]bool{
+	"coretemp":    true,
+	"k10temp":     true,
+	"zenpower":    true,
+	"cpu_thermal": true,
+}
+
+// readTemperatures returns the highest CPU and NVMe temperatures in degrees Celsius reported by
+// the Linux hwmon interface.  Zero is returned for a sensor type which could not be found.
+func readTemperatures() (cpu int, nvme int) {
+	dirs, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+	for _, dir := range dirs {
+		name, err := ioutil.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		sensor := strings.TrimSpace(string(name))
+		inputs, _ := filepath.Glob(filepath.Join(dir, "temp*_input"))
+		for _, input := range inputs {
+			data, err := ioutil.ReadFile(input)
+			if err != nil {
+				continue
+			}
+			milliDegrees, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				continue
+			}
+			temp := milliDegrees / 1000
+			if cpuSensorNames[sensor] && temp > cpu {
+				cpu = temp
+			} else if sensor == "nvme" && temp > nvme {
+				nvme = temp
+			}
+		}
+	}
+	return
+}
+
+// temperatureThrottled reports whether new plots should be held back because the CPU or NVMe
+// drives are too hot.
+func (server *Server) temperatureThrottled(config *Config) bool {
+	if config.MaxCpuTemperature <= 0 && config.MaxNvmeTemperature <= 0 {
+		server.tempThrottled = false
+		return false
+	}
+	cpu, nvme := readTemperatures()
+	limit := func(max int) int {
+		if server.tempThrottled {
+			return max - temperatureHysteresis
+		}
+		return max
+	}
+	throttled := (config.MaxCpuTemperature > 0 && cpu >= limit(config.MaxCpuTemperature)) ||
+		(config.MaxNvmeTemperature > 0 && nvme >= limit(config.MaxNvmeTemperature))
+	if throttled != server.tempThrottled {
+		if throttled {
+			server.schedulerEvent("Pausing new plots, temperature too high: CPU %d°C, NVMe %d°C", cpu, nvme)
+		} else {
+			server.schedulerEvent("Resuming new plots, temperature back to normal: CPU %d°C, NVMe %d°C", cpu, nvme)
+		}
+		server.tempThrottled = throttled
+	}
+	return throttled
+}
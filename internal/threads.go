@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"runtime"
+)
+
+// defaultPlotThreads is the number of threads chia uses when Threads isn't set, and the fewest
+// threads the thread plan gives a plot.
+const defaultPlotThreads = 2
+
+// ThreadPlan is how the plotting threads are spread over the CPU.  Limit is the number of cores
+// times MaxThreadsPerCore, 0 when unlimited, and Used the threads of the plots still plotting.
+type ThreadPlan struct {
+	Cores int
+	Limit int
+	Used  int
+}
+
+func plotThreads(threads int) int {
+	if threads > 0 {
+		return threads
+	}
+	return defaultPlotThreads
+}
+
+// threadPlan returns the current plan.  Plots waiting for or doing their copy don't use their
+// threads any more.  The caller must hold server.lock.
+func (server *Server) threadPlan(config *Config) ThreadPlan {
+	plan := ThreadPlan{Cores: runtime.NumCPU()}
+	if config != nil && config.MaxThreadsPerCore > 0 {
+		plan.Limit = int(float64(plan.Cores) * config.MaxThreadsPerCore)
+	}
+	for _, plot := range server.active {
+		switch plot.currentState() {
+		case PlotWaitingToCopy, PlotCopying, PlotVerifying:
+			continue
+		}
+		plan.Used += plotThreads(plot.Threads)
+	}
+	return plan
+}
+
+// threadsLeft reports whether the plan has room for another plot.  The caller must hold
+// server.lock.
+func (server *Server) threadsLeft(config *Config) bool {
+	plan := server.threadPlan(config)
+	full := plan.Limit > 0 && plan.Limit-plan.Used < defaultPlotThreads
+	if full != server.threadsFull {
+		if full {
+			server.schedulerEvent("Pausing new plots, %d of %d threads in use", plan.Used, plan.Limit)
+		} else {
+			server.schedulerEvent("Resuming new plots, %d of %d threads in use", plan.Used, plan.Limit)
+		}
+		server.threadsFull = full
+	}
+	return !full
+}
+
+// fitThreads gives plot no more threads than the plan has left.  The caller must hold server.lock.
+func (server *Server) fitThreads(config *Config, plot *ActivePlot) {
+	plan := server.threadPlan(config)
+	if plan.Limit == 0 {
+		return
+	}
+	want := plotThreads(plot.Threads)
+	if free := plan.Limit - plan.Used; free < want && free >= defaultPlotThreads {
+		server.schedulerEvent("Plot %s -> %s gets %d threads instead of %d, %d of %d threads in use", plot.PlotDir, plot.TargetDir, free, want, plan.Used, plan.Limit)
+		plot.Threads = free
+	}
+}
@@ -0,0 +1,396 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transferChunkSize is the amount of data read at once while copying a plot, and so the
+// granularity of the bandwidth limit.
+const transferChunkSize = 256 * KB
+
+// TransferWindow overrides MaxTransferRate between Start and End, given as "15:04" in local time.
+// Windows may wrap around midnight.
+type TransferWindow struct {
+	Start           string
+	End             string
+	MaxTransferRate float64
+}
+
+func (tw *TransferWindow) contains(now time.Time) bool {
+	start, err1 := time.Parse("15:04", tw.Start)
+	end, err2 := time.Parse("15:04", tw.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minutes := now.Hour()*60 + now.Minute()
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+	if startMinutes <= endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes
+	}
+	return minutes >= startMinutes || minutes < endMinutes
+}
+
+// rateLimiter spreads the bytes of every caller over time so their sum stays under a rate.
+type rateLimiter struct {
+	lock sync.Mutex
+	next time.Time
+}
+
+func (rl *rateLimiter) wait(ctx context.Context, n int, bytesPerSecond float64) {
+	if bytesPerSecond <= 0 {
+		return
+	}
+	rl.lock.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	rl.next = rl.next.Add(time.Duration(float64(n) / bytesPerSecond * float64(time.Second)))
+	sleep := rl.next.Sub(now)
+	rl.lock.Unlock()
+	timer := time.NewTimer(sleep)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// transferManager moves finished plots to their targets.  It bounds the number of copies running
+// at the same time on each destination device group, and the bandwidth used by remote transfers.
+// While held, no new copy starts.
+type transferManager struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	held   bool
+	active map[string]int
+	config *Config
+	global rateLimiter
+	hosts  map[string]*rateLimiter
+}
+
+func newTransferManager() *transferManager {
+	tm := &transferManager{
+		active: map[string]int{},
+		hosts:  map[string]*rateLimiter{},
+	}
+	tm.cond = sync.NewCond(&tm.lock)
+	return tm
+}
+
+// setConfig updates the bandwidth limits of the transfers in progress.
+func (tm *transferManager) setConfig(config *Config) {
+	tm.lock.Lock()
+	tm.config = config
+	tm.lock.Unlock()
+}
+
+// hold stops new copies from starting until it is called with false.
+func (tm *transferManager) hold(held bool) {
+	tm.lock.Lock()
+	tm.held = held
+	tm.lock.Unlock()
+	tm.cond.Broadcast()
+}
+
+// acquire waits until fewer than max copies run on group and the transfers aren't held, or ctx
+// is cancelled.
+func (tm *transferManager) acquire(ctx context.Context, group string, max int) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			tm.lock.Lock()
+			tm.cond.Broadcast()
+			tm.lock.Unlock()
+		case <-done:
+		}
+	}()
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	for tm.held || (max > 0 && tm.active[group] >= max) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tm.cond.Wait()
+	}
+	tm.active[group]++
+	return nil
+}
+
+func (tm *transferManager) release(group string) {
+	tm.lock.Lock()
+	tm.active[group]--
+	tm.lock.Unlock()
+	tm.cond.Broadcast()
+}
+
+// limits returns the global and per host bandwidth limits in bytes/s at now, zero means unlimited.
+func (tm *transferManager) limits(host string, now time.Time) (global float64, perHost float64, hostLimiter *rateLimiter) {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	hostLimiter, ok := tm.hosts[host]
+	if !ok {
+		hostLimiter = &rateLimiter{}
+		tm.hosts[host] = hostLimiter
+	}
+	if tm.config == nil {
+		return
+	}
+	global = tm.config.MaxTransferRate
+	for _, window := range tm.config.TransferSchedule {
+		if window.contains(now) {
+			global = window.MaxTransferRate
+			break
+		}
+	}
+	return global * float64(MB), tm.config.MaxTransferRatePerHost[host] * float64(MB), hostLimiter
+}
+
+// shapedReader reads the plot being transferred, holding back reads to respect the bandwidth
+// limits of remote hosts and recording the transfer rate on the plot.
+type shapedReader struct {
+	ctx       context.Context
+	in        io.Reader
+	tm        *transferManager
+	host      string
+	plot      *ActivePlot
+	base      int64
+	lastTime  time.Time
+	lastBytes int64
+	bytes     int64
+	limiter   rateLimiter
+}
+
+func (sr *shapedReader) Read(p []byte) (int, error) {
+	if err := sr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(p) > int(transferChunkSize) {
+		p = p[:transferChunkSize]
+	}
+	n, err := sr.in.Read(p)
+	if n > 0 && len(sr.host) > 0 {
+		global, perHost, hostLimiter := sr.tm.limits(sr.host, time.Now())
+		sr.tm.global.wait(sr.ctx, n, global)
+		hostLimiter.wait(sr.ctx, n, perHost)
+	}
+	if n > 0 && sr.plot.maxRate > 0 {
+		sr.limiter.wait(sr.ctx, n, sr.plot.maxRate)
+	}
+	sr.bytes += int64(n)
+	sr.plot.lock.Lock()
+	sr.plot.TransferredBytes = uint64(sr.base + sr.bytes)
+	sr.plot.changed()
+	if now := time.Now(); now.Sub(sr.lastTime) >= time.Second {
+		if !sr.lastTime.IsZero() {
+			sr.plot.TransferRate = uint64(float64(sr.bytes-sr.lastBytes) / now.Sub(sr.lastTime).Seconds())
+		}
+		sr.lastTime = now
+		sr.lastBytes = sr.bytes
+	}
+	sr.plot.lock.Unlock()
+	return n, err
+}
+
+// copyGroup returns the device group of a target directory, targets without a group are their own group.
+func copyGroup(config *Config, targetDir string) string {
+	if group, ok := config.TargetGroups[targetDir]; ok {
+		return group
+	}
+	return targetDir
+}
+
+// parseRemoteTarget splits a remote target directory "ssh://[user@]host[:port]/path".
+func parseRemoteTarget(target string) (userHost string, port string, dir string, ok bool) {
+	if !strings.HasPrefix(target, "ssh://") {
+		return "", "", "", false
+	}
+	u, err := url.Parse(target)
+	if err != nil || len(u.Hostname()) == 0 {
+		return "", "", "", false
+	}
+	userHost = u.Hostname()
+	if u.User != nil {
+		userHost = u.User.Username() + "@" + userHost
+	}
+	return userHost, u.Port(), u.Path, true
+}
+
+// isRemoteTarget reports whether target is a remote ssh or object storage destination.
+func isRemoteTarget(target string) bool {
+	_, _, _, ok := parseRemoteTarget(target)
+	_, _, isS3 := parseS3Target(target)
+	return ok || isS3
+}
+
+// findFinalPlot returns the name of the finished plot file with the given id in dir.
+func findFinalPlot(fs Filesystem, dir string, id string) (string, error) {
+	fileList, err := fs.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range fileList {
+		if strings.Contains(file.Name(), id) && strings.HasSuffix(file.Name(), ".plot") {
+			return file.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("no plot file for [%s] found in %s", id, dir)
+}
+
+// moveFinalPlot moves the plot which chia left in the temp directory to its target directory,
+// once the transfer manager allows another copy to the target's device.
+func (ap *ActivePlot) moveFinalPlot(ctx context.Context) error {
+	id := ap.Snapshot().Id
+	name, err := findFinalPlot(ap.filesystem(), ap.tempDir(), id)
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(ap.tempDir(), name)
+
+	ap.setState(PlotWaitingToCopy)
+	if err := ap.transfers.acquire(ctx, ap.copyGroup, ap.maxCopies); err != nil {
+		return err
+	}
+	defer ap.transfers.release(ap.copyGroup)
+	ap.setState(PlotCopying)
+	if stat, err := ap.filesystem().Stat(src); err == nil {
+		ap.lock.Lock()
+		ap.TransferSize = uint64(stat.Size())
+		ap.changed()
+		ap.lock.Unlock()
+	}
+	defer func() {
+		ap.lock.Lock()
+		ap.TransferRate = 0
+		ap.changed()
+		ap.lock.Unlock()
+	}()
+	log.Printf("Plot [%s] copying %s to %s", id, src, ap.TargetDir)
+	return ap.transferPlot(ctx, src, ap.TargetDir, path.Join(ap.Subfolder, name))
+}
+
+// transferPlot moves the plot file src to name in targetDir, which is a local directory, an ssh
+// target or an S3 bucket.  A name with slashes puts the plot in a subfolder, created as needed.  The throughput of copies is recorded as the write speed of targetDir, renames aren't.
+func (ap *ActivePlot) transferPlot(ctx context.Context, src string, targetDir string, name string) error {
+	start := time.Now()
+	var size uint64
+	if stat, err := ap.filesystem().Stat(src); err == nil {
+		size = uint64(stat.Size())
+	}
+	if userHost, port, dir, ok := parseRemoteTarget(targetDir); ok {
+		if err := ap.copyToRemote(ctx, src, userHost, port, dir, name); err != nil {
+			return err
+		}
+		ap.writeSpeeds.record(targetDir, size, time.Since(start), time.Now())
+		return ap.filesystem().Remove(src)
+	}
+	if bucket, prefix, ok := parseS3Target(targetDir); ok {
+		if err := ap.uploadToS3(ctx, src, bucket, path.Join(prefix, name)); err != nil {
+			return err
+		}
+		ap.writeSpeeds.record(targetDir, size, time.Since(start), time.Now())
+		return ap.filesystem().Remove(src)
+	}
+
+	dst := filepath.Join(targetDir, filepath.FromSlash(name))
+	if subfolder := filepath.Dir(dst); subfolder != filepath.Clean(targetDir) {
+		if _, err := ap.filesystem().Stat(targetDir); err != nil {
+			return err
+		}
+		if err := ap.makeSubfolder(subfolder, ap.owner); err != nil {
+			return err
+		}
+	}
+	if err := ap.filesystem().Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := ap.copyFile(ctx, src, dst+".tmp"); err != nil {
+		ap.filesystem().Remove(dst + ".tmp")
+		return err
+	}
+	if err := ap.filesystem().Rename(dst+".tmp", dst); err != nil {
+		ap.filesystem().Remove(dst + ".tmp")
+		return err
+	}
+	ap.writeSpeeds.record(targetDir, size, time.Since(start), time.Now())
+	return ap.filesystem().Remove(src)
+}
+
+func (ap *ActivePlot) copyFile(ctx context.Context, src string, dst string) error {
+	in, err := ap.filesystem().Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := ap.filesystem().Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, &shapedReader{ctx: ctx, in: in, tm: ap.transfers, plot: ap}); err != nil {
+		out.Close()
+		return err
+	}
+	if syncer, ok := out.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// shellQuote quotes s for the remote shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// copyToRemote streams the plot over ssh to name in dir, writing to a temporary name first so a
+// harvester never sees a partial plot.  The subfolder of name is created, dir has to exist.
+func (ap *ActivePlot) copyToRemote(ctx context.Context, src string, userHost string, port string, dir string, name string) error {
+	in, err := ap.filesystem().Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	args := []string{"-o", "BatchMode=yes"}
+	if len(port) > 0 {
+		args = append(args, "-p", port)
+	}
+	dst := path.Join(dir, name)
+	chmod := ""
+	if ap.fileMode != 0 {
+		chmod = fmt.Sprintf(" && chmod %04o %s", ap.fileMode, shellQuote(dst+".tmp"))
+	}
+	command := fmt.Sprintf("cat > %s%s && mv %s %s", shellQuote(dst+".tmp"), chmod, shellQuote(dst+".tmp"), shellQuote(dst))
+	if subfolder := path.Dir(name); subfolder != "." {
+		mkdir := "mkdir -p"
+		if ap.dirMode != 0 {
+			mkdir = fmt.Sprintf("mkdir -p -m %04o", ap.dirMode)
+		}
+		command = fmt.Sprintf("test -d %s && %s %s && %s", shellQuote(dir), mkdir, shellQuote(path.Join(dir, subfolder)), command)
+	}
+	args = append(args, userHost, command)
+	host := userHost
+	if idx := strings.Index(host, "@"); idx >= 0 {
+		host = host[idx+1:]
+	}
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdin = &shapedReader{ctx: ctx, in: in, tm: ap.transfers, host: host, plot: ap}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
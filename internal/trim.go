@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"plotng/internal/format"
+)
+
+const (
+	// defaultTrimCommand is run with the temp directory as last argument when TrimCommand is empty.
+	defaultTrimCommand = "fstrim -v"
+	// trimTimeout stops a trim which hangs, so it doesn't hold the temp directory forever.
+	trimTimeout = 30 * time.Minute
+)
+
+// tempTrim runs the trims of the temp directories one at a time.  A temp directory is trimmed
+// once TrimAfterPlots plots finished in it, when none of its plots is in phase 1, and no plot
+// starts in it while it is trimmed.
+type tempTrim struct {
+	finished map[string]int // the plots finished in each temp directory since its last trim
+	running  string         // the temp directory being trimmed
+}
+
+// countTrimPlot counts a finished plot towards the next trim of its temp directory.
+func (server *Server) countTrimPlot(event Event) {
+	if event.To != PlotFinished {
+		return
+	}
+	server.lock.Lock()
+	if server.trim.finished == nil {
+		server.trim.finished = map[string]int{}
+	}
+	server.trim.finished[event.Plot.Snapshot().PlotDir]++
+	server.lock.Unlock()
+}
+
+// trimming returns the temp directory being trimmed, for the scheduler and the UI.  The caller
+// must hold server.lock.
+func (server *Server) trimming() map[string]bool {
+	if len(server.trim.running) == 0 {
+		return nil
+	}
+	return map[string]bool{server.trim.running: true}
+}
+
+// checkTrims starts the trim of the first temp directory due for one, unless a trim is running or
+// one of its plots is in phase 1.
+func (server *Server) checkTrims(config *Config) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if config.TrimAfterPlots <= 0 || len(server.trim.running) > 0 {
+		return
+	}
+	for _, dir := range config.TempDirectory {
+		if server.trim.finished[dir] < config.TrimAfterPlots || server.inPhase1(dir) {
+			continue
+		}
+		server.trim.running = dir
+		server.trim.finished[dir] = 0
+		args := append(strings.Fields(defaultTrimCommand), dir)
+		if fields := strings.Fields(config.TrimCommand); len(fields) > 0 {
+			args = append(fields, dir)
+		}
+		go server.runTrim(server.ctx, dir, args)
+		return
+	}
+}
+
+// inPhase1 reports whether a plot using dir as temp directory is in phase 1.  The caller must
+// hold server.lock.
+func (server *Server) inPhase1(dir string) bool {
+	for _, plot := range server.active {
+		if status := plot.Snapshot(); status.PlotDir == dir && strings.HasPrefix(status.Phase, "1/4") {
+			return true
+		}
+	}
+	return false
+}
+
+// runTrim runs the trim command args on dir and lets plots start in dir again.
+func (server *Server) runTrim(ctx context.Context, dir string, args []string) {
+	server.schedulerEvent("Trimming [%s]", dir)
+	ctx, cancel := context.WithTimeout(ctx, trimTimeout)
+	defer cancel()
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	server.lock.Lock()
+	server.trim.running = ""
+	server.lock.Unlock()
+	if err != nil {
+		server.schedulerEvent("Failed to trim [%s]: %s %s", dir, err, strings.TrimSpace(string(out)))
+		return
+	}
+	server.schedulerEvent("Trimmed [%s] in %s: %s", dir, format.Duration(time.Since(start)), strings.TrimSpace(string(out)))
+}
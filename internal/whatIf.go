@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"plotng/internal/format"
+)
+
+// whatIfWarmUp is the start of a replay left out of its plots/day, while the first plots are still
+// running and nothing finishes yet.
+const whatIfWarmUp = 24 * time.Hour
+
+// whatIfSpace is the space of every directory in a replay, so only the schedule limits it.
+const whatIfSpace = 1024 * 1024 * GB
+
+// WhatIfRequest replays the plot history of a server with other scheduling settings.  Every
+// combination of NumberOfParallelPlots, StaggeringDelay and DelaysBetweenPlot is replayed, an
+// empty list keeps the value of the configuration.  The history is read from HistoryPath, the
+// output of GET /history or the history.json of a debug bundle, or fetched from Host.
+type WhatIfRequest struct {
+	ConfigPath  string
+	HistoryPath string
+	Host        string
+	Parallel    []int
+	Stagger     []int
+	Delay       []int
+	Days        int
+}
+
+// WhatIfResult is the outcome of replaying one combination of settings.
+type WhatIfResult struct {
+	Parallel    int
+	Stagger     int
+	Delay       int
+	PlotsPerDay float64
+	Current     bool
+}
+
+// loadWhatIfHistory returns the archived plots from the history file, or from the server.
+func loadWhatIfHistory(request *WhatIfRequest) ([]*PlotStatus, error) {
+	var data []byte
+	var err error
+	if len(request.HistoryPath) > 0 {
+		if data, err = ioutil.ReadFile(request.HistoryPath); err != nil {
+			return nil, err
+		}
+	} else {
+		resp, err := (&apiHosts{}).do(httpClient, request.Host, "GET", "/history?state=Finished", nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", request.Host, resp.Status)
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	var plots []*PlotStatus
+	if err := json.Unmarshal(data, &plots); err != nil {
+		return nil, err
+	}
+	return plots, nil
+}
+
+// historyPhaseTimes returns the median phase times of the finished plots of every temp directory,
+// and of all of them.
+func historyPhaseTimes(plots []*PlotStatus) (perTemp map[string][4]time.Duration, overall [4]time.Duration, count int) {
+	samples := map[string][4][]time.Duration{}
+	var all [4][]time.Duration
+	for _, plot := range plots {
+		if plot.State != PlotFinished || plot.Phase1Time.IsZero() || plot.Phase2Time.IsZero() || plot.Phase3Time.IsZero() || plot.EndTime.IsZero() {
+			continue
+		}
+		dir := samples[plot.PlotDir]
+		for phase := 0; phase < 4; phase++ {
+			duration := plot.getPhaseTime(phase + 1).Sub(plot.getPhaseTime(phase))
+			dir[phase] = append(dir[phase], duration)
+			all[phase] = append(all[phase], duration)
+		}
+		samples[plot.PlotDir] = dir
+		count++
+	}
+	median := func(list []time.Duration) time.Duration {
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		return list[len(list)/2]
+	}
+	perTemp = map[string][4]time.Duration{}
+	for dir, phases := range samples {
+		var times [4]time.Duration
+		for phase := range phases {
+			times[phase] = median(phases[phase])
+		}
+		perTemp[dir] = times
+	}
+	if count > 0 {
+		for phase := range all {
+			overall[phase] = median(all[phase])
+		}
+	}
+	return
+}
+
+// whatIfConfig returns config with the settings to replay, without the limits which depend on the
+// state of the machine at the time, like temperatures and free memory.
+func whatIfConfig(config *Config, parallel, stagger, delay int) *Config {
+	c := *config
+	c.NumberOfParallelPlots = parallel
+	c.StaggeringDelay = stagger
+	c.DelaysBetweenPlot = delay
+	c.MaxCpuTemperature = 0
+	c.MaxNvmeTemperature = 0
+	c.MinFreeMemory = 0
+	c.MaxElectricityPrice = 0
+	c.IdleExtraPlots = 0
+	c.MaintenanceWindows = nil
+	return &c
+}
+
+// replay runs the scheduler with config for days and returns the plots finished per day after
+// the warm up.
+func replay(config *Config, perTemp map[string][4]time.Duration, overall [4]time.Duration, days int) float64 {
+	space := map[string]uint64{}
+	for _, dir := range append(append(append([]string{}, config.TempDirectory...), config.TargetDirectory...), config.BufferDirectory...) {
+		space[dir] = whatIfSpace
+	}
+	for _, profile := range config.Profiles {
+		for _, dir := range profile.TargetDirectory {
+			space[dir] = whatIfSpace
+		}
+	}
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	h := NewSchedulerHarness(config, start, space)
+	h.PhaseTimes = overall
+	h.TempPhaseTimes = perTemp
+	h.Run(time.Duration(days)*24*time.Hour, time.Minute)
+	_, finished := h.Plots()
+	count := 0
+	for _, plot := range finished {
+		if plot.EndTime.After(start.Add(whatIfWarmUp)) {
+			count++
+		}
+	}
+	return float64(count) / (float64(days) - whatIfWarmUp.Hours()/24)
+}
+
+// WhatIf replays the history with every combination of the requested settings, best first.
+func WhatIf(request *WhatIfRequest) (results []*WhatIfResult, observed float64, err error) {
+	data, err := ioutil.ReadFile(request.ConfigPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", request.ConfigPath, err)
+	}
+	if request.Days < 2 {
+		return nil, 0, fmt.Errorf("replays need at least 2 days, the first day is warm up")
+	}
+	plots, err := loadWhatIfHistory(request)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load the history: %w", err)
+	}
+	perTemp, overall, count := historyPhaseTimes(plots)
+	if count == 0 {
+		return nil, 0, fmt.Errorf("no finished plot with phase times in the history")
+	}
+	var first, last time.Time
+	for _, plot := range plots {
+		if plot.State != PlotFinished {
+			continue
+		}
+		if first.IsZero() || plot.StartTime.Before(first) {
+			first = plot.StartTime
+		}
+		if plot.EndTime.After(last) {
+			last = plot.EndTime
+		}
+	}
+	if days := last.Sub(first).Hours() / 24; days > 0 {
+		observed = float64(count) / days
+	}
+
+	values := func(list []int, current int) []int {
+		if len(list) == 0 {
+			return []int{current}
+		}
+		return list
+	}
+	for _, parallel := range values(request.Parallel, config.NumberOfParallelPlots) {
+		for _, stagger := range values(request.Stagger, config.StaggeringDelay) {
+			for _, delay := range values(request.Delay, config.DelaysBetweenPlot) {
+				results = append(results, &WhatIfResult{
+					Parallel:    parallel,
+					Stagger:     stagger,
+					Delay:       delay,
+					PlotsPerDay: replay(whatIfConfig(&config, parallel, stagger, delay), perTemp, overall, request.Days),
+					Current:     parallel == config.NumberOfParallelPlots && stagger == config.StaggeringDelay && delay == config.DelaysBetweenPlot,
+				})
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].PlotsPerDay > results[j].PlotsPerDay })
+	return results, observed, nil
+}
+
+// WriteWhatIf replays the history and writes the projected plots/day of every combination.
+func WriteWhatIf(w io.Writer, request *WhatIfRequest) error {
+	results, observed, err := WhatIf(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Observed: %s plots/day\n", format.Float(observed, 2))
+	fmt.Fprintf(w, "Replayed %d days with the median phase times of every temp directory, slowdowns from running more plots at once aren't modelled.\n\n", request.Days)
+	fmt.Fprintf(w, "%8s %8s %8s %10s\n", "Parallel", "Stagger", "Delay", "Plots/Day")
+	for _, result := range results {
+		current := ""
+		if result.Current {
+			current = "  (current)"
+		}
+		fmt.Fprintf(w, "%8d %8d %8d %10s%s\n", result.Parallel, result.Stagger, result.Delay, format.Float(result.PlotsPerDay, 2), current)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package widget
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+	"plotng/internal/locale"
+)
+
+// The units of the numeric columns, given with the data-unit tag, which pick how their aggregates
+// are formatted and whether they have a total.
+const (
+	unitBytes   = "bytes"   // sizes, with a total
+	unitRate    = "rate"    // bytes/s, with a total
+	unitPercent = "percent" // without a total
+)
+
+// aggregateColumn returns the lines of the tooltip of a column: the number of visible rows, then
+// the minimum, maximum and mean of a numeric column with its total, the earliest and latest time
+// of a time column or the number of distinct values of any other column.  Zero durations and
+// times, which are unknown, are left out.
+func (st *SortedTable) aggregateColumn(col int) []string {
+	var numbers []float64
+	var earliest, latest time.Time
+	distinct := map[string]bool{}
+	rows := 0
+	kind := ""
+	for _, row := range st.visible {
+		if _, group := row.data.(*groupRow); group || row.data == nil {
+			continue
+		}
+		v := reflect.ValueOf(row.data).Elem()
+		if col >= v.NumField() {
+			continue
+		}
+		rows++
+		f := v.Field(col)
+		switch value := f.Interface().(type) {
+		case time.Duration:
+			kind = "duration"
+			if value > 0 {
+				numbers = append(numbers, float64(value))
+			}
+			continue
+		case time.Time:
+			kind = "time"
+			if value.IsZero() {
+				continue
+			}
+			if earliest.IsZero() || value.Before(earliest) {
+				earliest = value
+			}
+			if value.After(latest) {
+				latest = value
+			}
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+			kind = "number"
+			numbers = append(numbers, float64(f.Int()))
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+			kind = "number"
+			if f.Uint() != math.MaxUint64 { // unknown space
+				numbers = append(numbers, float64(f.Uint()))
+			}
+		case reflect.Float32, reflect.Float64:
+			kind = "number"
+			numbers = append(numbers, f.Float())
+		default:
+			distinct[fmt.Sprint(f.Interface())] = true
+		}
+	}
+	lines := []string{aggregateLine("Rows", fmt.Sprint(rows))}
+	switch {
+	case kind == "time":
+		if !earliest.IsZero() {
+			lines = append(lines, aggregateLine("Earliest", format.Time(earliest)), aggregateLine("Latest", format.Time(latest)))
+		}
+	case len(numbers) > 0:
+		min, max, total := numbers[0], numbers[0], 0.0
+		for _, n := range numbers {
+			min = math.Min(min, n)
+			max = math.Max(max, n)
+			total += n
+		}
+		unit := st.columnUnit[col]
+		formatted := func(n float64, prec int) string {
+			switch {
+			case kind == "duration":
+				return format.Duration(time.Duration(n))
+			case unit == unitBytes:
+				return format.Space(uint64(n))
+			case unit == unitRate:
+				return format.Rate(uint64(n))
+			case unit == unitPercent:
+				return format.Percent(n, prec)
+			}
+			return format.Float(n, prec)
+		}
+		lines = append(lines,
+			aggregateLine("Min", formatted(min, 0)),
+			aggregateLine("Max", formatted(max, 0)),
+			aggregateLine("Mean", formatted(total/float64(len(numbers)), 1)))
+		if kind != "duration" && unit != unitPercent {
+			lines = append(lines, aggregateLine("Total", formatted(total, 0)))
+		}
+	case len(distinct) > 0:
+		lines = append(lines, aggregateLine("Distinct", fmt.Sprint(len(distinct))))
+	}
+	return lines
+}
+
+// aggregateLine returns a line of the tooltip, with the translation of its label.
+func aggregateLine(label string, value string) string {
+	return runewidth.FillRight(locale.T(label), 9) + " " + value
+}
+
+// ToggleAggregates shows the aggregates of the column the rows are sorted by below its header, or
+// hides them again.
+func (st *SortedTable) ToggleAggregates() *SortedTable {
+	if st.tooltip >= 0 {
+		st.tooltip = -1
+	} else {
+		st.tooltip = st.sortColumn
+	}
+	st.tooltipHover = false
+	return st
+}
+
+// HoverHeader shows the aggregates of the column whose header is at the mouse position, and hides
+// them when the mouse leaves the headers.  It reports whether the tooltip changed.
+func (st *SortedTable) HoverHeader(x, y int) bool {
+	hovered := -1
+	columns := st.visibleColumns()
+	for c := 0; c < len(columns) && c < st.table.GetColumnCount(); c++ {
+		cx, cy, width := st.table.GetCell(0, c).GetLastPosition()
+		if y == cy && x >= cx && x < cx+width {
+			hovered = columns[c]
+		}
+	}
+	switch {
+	case hovered >= 0 && hovered != st.tooltip:
+		st.tooltip, st.tooltipHover = hovered, true
+	case hovered < 0 && st.tooltipHover:
+		st.tooltip, st.tooltipHover = -1, false
+	default:
+		return false
+	}
+	return true
+}
+
+// drawTooltip draws the aggregates of the tooltip column in a box below its header, titled with
+// the header.
+func (st *SortedTable) drawTooltip(screen tcell.Screen) {
+	if st.tooltip < 0 || st.tooltip >= len(st.headers) {
+		return
+	}
+	x, y, width, height := st.table.GetInnerRect()
+	columns := st.visibleColumns()
+	for c := 0; c < len(columns) && c < st.table.GetColumnCount(); c++ {
+		if columns[c] == st.tooltip {
+			x, _, _ = st.table.GetCell(0, c).GetLastPosition()
+		}
+	}
+	title := " " + locale.T(st.headers[st.tooltip]) + " "
+	lines := st.aggregateColumn(st.tooltip)
+	boxWidth := runewidth.StringWidth(title)
+	for _, line := range lines {
+		if w := runewidth.StringWidth(line); w > boxWidth {
+			boxWidth = w
+		}
+	}
+	boxWidth += 2
+	boxHeight := len(lines) + 2
+	if left, _, tableWidth, _ := st.table.GetInnerRect(); x+boxWidth > left+tableWidth {
+		x = left + tableWidth - boxWidth
+	}
+	if boxHeight > height-1 {
+		boxHeight = height - 1
+	}
+	if boxHeight < 3 || boxWidth > width {
+		return
+	}
+	box := tview.NewTextView()
+	box.SetBorder(true)
+	box.SetTitle(tview.Escape(title))
+	box.SetText(tview.Escape(strings.Join(lines, "\n")))
+	box.SetRect(x, y+1, boxWidth, boxHeight)
+	box.Draw(screen)
+}
@@ -0,0 +1,103 @@
+package widget
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// heatmapColors are the GitHub-style shades used for the cells, from empty to busiest.
+var heatmapColors = []tcell.Color{
+	tcell.NewRGBColor(0x2d, 0x33, 0x3b),
+	tcell.NewRGBColor(0x0e, 0x44, 0x29),
+	tcell.NewRGBColor(0x00, 0x6d, 0x32),
+	tcell.NewRGBColor(0x26, 0xa6, 0x41),
+	tcell.NewRGBColor(0x39, 0xd3, 0x53),
+}
+
+// Heatmap draws a grid of counts as coloured cells, with a label for every row and a total at
+// the end of each row.  Column labels are printed above the grid every few columns.
+type Heatmap struct {
+	*tview.Box
+	rowLabels []string
+	colLabels []string
+	values    [][]int
+}
+
+func NewHeatmap() *Heatmap {
+	return &Heatmap{
+		Box: tview.NewBox(),
+	}
+}
+
+// SetData replaces the grid.  values is indexed by [row][column] and must match the labels.
+func (hm *Heatmap) SetData(rowLabels []string, colLabels []string, values [][]int) *Heatmap {
+	hm.rowLabels = rowLabels
+	hm.colLabels = colLabels
+	hm.values = values
+	return hm
+}
+
+func (hm *Heatmap) colorFor(value, max int) tcell.Color {
+	if value <= 0 || max <= 0 {
+		return heatmapColors[0]
+	}
+	idx := 1 + (value-1)*(len(heatmapColors)-1)/max
+	if idx >= len(heatmapColors) {
+		idx = len(heatmapColors) - 1
+	}
+	return heatmapColors[idx]
+}
+
+func (hm *Heatmap) Draw(screen tcell.Screen) {
+	hm.Box.DrawForSubclass(screen, hm)
+	x, y, width, height := hm.GetInnerRect()
+
+	labelWidth := 0
+	for _, l := range hm.rowLabels {
+		if len(l) > labelWidth {
+			labelWidth = len(l)
+		}
+	}
+	labelWidth++
+
+	max := 0
+	for _, row := range hm.values {
+		for _, v := range row {
+			if v > max {
+				max = v
+			}
+		}
+	}
+
+	for c, l := range hm.colLabels {
+		if c%3 == 0 {
+			tview.Print(screen, l, x+labelWidth+c*2, y, width-labelWidth-c*2, tview.AlignLeft, tcell.ColorYellow)
+		}
+	}
+
+	for r, label := range hm.rowLabels {
+		if r+1 >= height {
+			break
+		}
+		rowY := y + r + 1
+		tview.Print(screen, label, x, rowY, labelWidth, tview.AlignLeft, tcell.ColorYellow)
+		total := 0
+		for c := range hm.colLabels {
+			value := 0
+			if r < len(hm.values) && c < len(hm.values[r]) {
+				value = hm.values[r][c]
+			}
+			total += value
+			cellX := x + labelWidth + c*2
+			if cellX >= x+width {
+				break
+			}
+			style := tcell.StyleDefault.Foreground(hm.colorFor(value, max))
+			screen.SetContent(cellX, rowY, '■', nil, style)
+		}
+		totalX := x + labelWidth + len(hm.colLabels)*2 + 1
+		tview.Print(screen, fmt.Sprintf("%d", total), totalX, rowY, x+width-totalX, tview.AlignLeft, tcell.ColorDefault)
+	}
+}
@@ -2,36 +2,89 @@ package widget
 
 import (
 	"fmt"
+	"io"
 	"reflect"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"github.com/rivo/tview"
+
+	"plotng/internal/locale"
 )
 
 type SortableRow interface {
 	Strings() []string
 }
 
+// ColoredRow can be implemented by rows which should be drawn in a colour other than the default.
+type ColoredRow interface {
+	TextColor() tcell.Color
+}
+
 type tableRow struct {
 	key  string
 	data SortableRow
 }
 
+// groupKeyPrefix starts the keys of the rows heading the groups, which can't clash with the keys
+// of the rows set by the callers.
+const groupKeyPrefix = "\x00group:"
+
+// groupRow heads the rows of a group, with the number of rows in it.
+type groupRow struct {
+	name      string
+	count     int
+	collapsed bool
+}
+
+func (gr *groupRow) Strings() []string {
+	sign := "▼"
+	if gr.collapsed {
+		sign = "▶"
+	}
+	return []string{fmt.Sprintf("%s %s (%d)", sign, gr.name, gr.count)}
+}
+
+func (gr *groupRow) TextColor() tcell.Color {
+	return tcell.ColorYellow
+}
+
 // SortedTable is a wrapper around tview.Table which provides sortable column headers.  Rows are
 // identified by a key rather than by index.
 type SortedTable struct {
 	table       *tview.Table
 	values      []tableRow
+	visible     []tableRow
 	curRow      int
 	curKey      string
 	sortColumn  int
 	sortReverse bool
 
+	headers     []string
+	columns     []int // indexes of the columns shown, all of them when nil
 	columnAlign map[int]int
+	columnUnit  map[int]string // data-unit of the numeric columns, for their aggregates
 
 	selectionChangedFunc func(key string)
+	filterFunc           func(key string, data SortableRow) bool
+	groupFunc            func(key string, data SortableRow) string
+	collapsed            map[string]bool // groups whose rows are hidden
+
+	tooltip      int  // column whose aggregates are shown, -1 for none
+	tooltipHover bool // shown while the mouse is on the header
+
+	stats DrawStats
+}
+
+// DrawStats are the figures of the last Draw of a table, to find out what makes drawing slow.
+type DrawStats struct {
+	Time   time.Time     // when the table was drawn
+	Rows   int           // rows rebuilt, after filtering
+	Redraw time.Duration // sorting, filtering and rebuilding the cells
+	Draw   time.Duration // drawing the cells on the screen
 }
 
 func (st *SortedTable) SetInputCapture(capture func(event *tcell.EventKey) *tcell.EventKey) {
@@ -40,8 +93,17 @@ func (st *SortedTable) SetInputCapture(capture func(event *tcell.EventKey) *tcel
 
 // Mostly implement tview.Primitive with proxies
 func (st *SortedTable) Draw(screen tcell.Screen) {
+	start := time.Now()
 	st.Redraw()
+	redrawn := time.Now()
 	st.table.Draw(screen)
+	st.drawTooltip(screen)
+	st.stats = DrawStats{Time: start, Rows: len(st.visible), Redraw: redrawn.Sub(start), Draw: time.Since(redrawn)}
+}
+
+// DrawStats returns the figures of the last Draw.
+func (st *SortedTable) DrawStats() DrawStats {
+	return st.stats
 }
 
 func (st *SortedTable) GetRect() (int, int, int, int) {
@@ -92,6 +154,8 @@ func NewSortedTable() *SortedTable {
 	st := &SortedTable{
 		table:       tview.NewTable(),
 		columnAlign: make(map[int]int),
+		columnUnit:  make(map[int]string),
+		tooltip:     -1,
 	}
 	st.table.SetFixed(1, 0)
 	st.table.InsertRow(0)
@@ -129,11 +193,11 @@ func (st *SortedTable) selectionChanged(row, column int) {
 		if st.curRow > 0 {
 			st.table.Select(st.curRow, 0)
 		}
-	} else {
+	} else if row <= len(st.visible) {
 		st.curRow = row
-		if st.curKey != st.values[row-1].key {
-			st.curKey = st.values[row-1].key
-			if st.selectionChangedFunc != nil {
+		if st.curKey != st.visible[row-1].key {
+			st.curKey = st.visible[row-1].key
+			if _, group := st.visible[row-1].data.(*groupRow); !group && st.selectionChangedFunc != nil {
 				st.selectionChangedFunc(st.curKey)
 			}
 		}
@@ -145,6 +209,36 @@ func (st *SortedTable) SetSelectionChangedFunc(handler func(key string)) *Sorted
 	return st
 }
 
+// SetFilterFunc sets a function which decides which rows are shown.  All rows are shown when
+// handler is nil.
+func (st *SortedTable) SetFilterFunc(handler func(key string, data SortableRow) bool) *SortedTable {
+	st.filterFunc = handler
+	return st
+}
+
+// SetGroupFunc sets a function which gives the group of every row.  The rows are then shown
+// under a heading per group, in the order of the group names, and the groups can be collapsed
+// with ToggleGroup.  The rows aren't grouped when handler is nil.
+func (st *SortedTable) SetGroupFunc(handler func(key string, data SortableRow) string) *SortedTable {
+	st.groupFunc = handler
+	return st
+}
+
+// ToggleGroup collapses the group whose heading is selected, or expands it again.  It returns
+// false when no heading is selected.
+func (st *SortedTable) ToggleGroup() bool {
+	key := st.GetSelection()
+	if !strings.HasPrefix(key, groupKeyPrefix) {
+		return false
+	}
+	if st.collapsed == nil {
+		st.collapsed = map[string]bool{}
+	}
+	name := strings.TrimPrefix(key, groupKeyPrefix)
+	st.collapsed[name] = !st.collapsed[name]
+	return true
+}
+
 func (st *SortedTable) SetupFromType(value interface{}) *SortedTable {
 	var headers []string
 	v := reflect.TypeOf(value)
@@ -167,25 +261,65 @@ func (st *SortedTable) SetupFromType(value interface{}) *SortedTable {
 				panic("unexpected align")
 			}
 		}
+		if t, ok = f.Tag.Lookup("data-unit"); ok {
+			st.columnUnit[i] = t
+		}
 	}
 	return st.setHeaders(headers...)
 }
 
 func (st *SortedTable) setHeaders(headers ...string) *SortedTable {
-	for colIndex := len(headers); colIndex < st.table.GetColumnCount(); colIndex++ {
+	st.headers = headers
+	columns := st.visibleColumns()
+	for colIndex := len(columns); colIndex < st.table.GetColumnCount(); colIndex++ {
 		cell := st.table.GetCell(0, colIndex)
 		cell.Text = ""
 		cell.Clicked = nil
 	}
-	for c, h := range headers {
-		cell := tview.NewTableCell(h)
+	for c, col := range columns {
+		cell := tview.NewTableCell(locale.T(headers[col]))
 		cell.NotSelectable = true
-		cell.Clicked = st.setSortColumn(c)
+		cell.Clicked = st.setSortColumn(col)
 		st.table.SetCell(0, c, cell)
 	}
 	return st
 }
 
+// visibleColumns returns the indexes of the columns shown, in order.
+func (st *SortedTable) visibleColumns() []int {
+	if st.columns != nil {
+		return st.columns
+	}
+	columns := make([]int, len(st.headers))
+	for i := range columns {
+		columns[i] = i
+	}
+	return columns
+}
+
+// SetVisibleColumns only shows the columns with the given headers, eg. on a narrow terminal.  The
+// rows can still be sorted by a hidden column.  All the columns are shown again when headers is
+// empty.
+func (st *SortedTable) SetVisibleColumns(headers ...string) *SortedTable {
+	var columns []int
+	for _, header := range headers {
+		for col, h := range st.headers {
+			if h == header {
+				columns = append(columns, col)
+			}
+		}
+	}
+	if len(headers) == 0 {
+		columns = nil
+	}
+	if fmt.Sprint(columns) == fmt.Sprint(st.columns) {
+		return st
+	}
+	st.columns = columns
+	st.table.Clear() // the rows are set again on the next draw
+	return st.setHeaders(st.headers...)
+}
+
 func (st *SortedTable) Clear() *SortedTable {
 	st.values = nil
 	return st
@@ -199,6 +333,16 @@ func (st *SortedTable) Keys() []string {
 	return keys
 }
 
+// RowData returns the data of the row with key, nil when there is none.
+func (st *SortedTable) RowData(key string) SortableRow {
+	for _, row := range st.values {
+		if row.key == key {
+			return row.data
+		}
+	}
+	return nil
+}
+
 func (st *SortedTable) SetRowData(key string, data SortableRow) *SortedTable {
 	found := false
 	for idx, dr := range st.values {
@@ -240,9 +384,31 @@ func (st *SortedTable) setSortColumn(col int) func() bool {
 	}
 }
 
+// SortOrder returns the header of the column the rows are sorted by and whether the order is
+// reversed.
+func (st *SortedTable) SortOrder() (header string, reverse bool) {
+	if st.sortColumn < len(st.headers) {
+		header = st.headers[st.sortColumn]
+	}
+	return header, st.sortReverse
+}
+
+// SetSortOrder sorts the rows by the column with the given header, unknown headers are ignored.
+func (st *SortedTable) SetSortOrder(header string, reverse bool) *SortedTable {
+	for c, h := range st.headers {
+		if len(header) > 0 && h == header {
+			st.sortColumn = c
+			st.sortReverse = reverse
+			break
+		}
+	}
+	return st
+}
+
 func (st *SortedTable) redrawHeaders() {
+	columns := st.visibleColumns()
 	for c := 0; c < st.table.GetColumnCount(); c++ {
-		if c == st.sortColumn {
+		if c < len(columns) && columns[c] == st.sortColumn {
 			if !st.sortReverse {
 				st.table.GetCell(0, c).SetTextColor(tcell.ColorGreen)
 			} else {
@@ -255,14 +421,14 @@ func (st *SortedTable) redrawHeaders() {
 }
 
 func (st *SortedTable) GetSelection() string {
-	if st.curRow > 0 {
-		return st.values[st.curRow-1].key
+	if st.curRow > 0 && st.curRow <= len(st.visible) {
+		return st.visible[st.curRow-1].key
 	}
 	return ""
 }
 
 func (st *SortedTable) Select(key string) *SortedTable {
-	for row, value := range st.values {
+	for row, value := range st.visible {
 		if value.key == key {
 			st.table.Select(row+1, 0)
 			break
@@ -289,6 +455,8 @@ func (st *SortedTable) sortData() {
 			}
 			f1 := v1.Field(st.sortColumn)
 			f2 := v2.Field(st.sortColumn)
+			// Sort on the values rather than on the formatted strings, so eg. durations, which are
+			// int64, sort by length and "100:00:00" comes after "99:00:00".
 			switch f1.Kind() {
 			case reflect.String:
 				return f1.String() < f2.String() != st.sortReverse
@@ -296,6 +464,8 @@ func (st *SortedTable) sortData() {
 				return f1.Int() < f2.Int() != st.sortReverse
 			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
 				return f1.Uint() < f2.Uint() != st.sortReverse
+			case reflect.Float32, reflect.Float64:
+				return f1.Float() < f2.Float() != st.sortReverse
 			}
 			switch c1 := f1.Interface().(type) {
 			case time.Time:
@@ -312,24 +482,77 @@ func (st *SortedTable) SetColumnAlign(col int, align int) *SortedTable {
 	return st
 }
 
+func (st *SortedTable) filterData() {
+	st.visible = st.visible[:0]
+	for _, row := range st.values {
+		if st.filterFunc == nil || st.filterFunc(row.key, row.data) {
+			st.visible = append(st.visible, row)
+		}
+	}
+	if st.groupFunc != nil {
+		st.groupData()
+	}
+}
+
+// groupData puts the visible rows under the heading of their group, keeping their order within
+// the group, and leaves out the rows of the collapsed groups.
+func (st *SortedTable) groupData() {
+	groups := map[string][]tableRow{}
+	var names []string
+	for _, row := range st.visible {
+		name := st.groupFunc(row.key, row.data)
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], row)
+	}
+	sort.Strings(names)
+	visible := make([]tableRow, 0, len(st.visible)+len(names))
+	for _, name := range names {
+		rows := groups[name]
+		collapsed := st.collapsed[name]
+		visible = append(visible, tableRow{groupKeyPrefix + name, &groupRow{name, len(rows), collapsed}})
+		if !collapsed {
+			visible = append(visible, rows...)
+		}
+	}
+	st.visible = visible
+}
+
 func (st *SortedTable) updateData() {
-	for rowIndex, rowData := range st.values {
+	for rowIndex, rowData := range st.visible {
 		strData := rowData.data.Strings()
+		if _, group := rowData.data.(*groupRow); group {
+			// the heading goes in the first column shown, whichever it is
+			heading := strData[0]
+			strData = make([]string, len(st.headers))
+			if columns := st.visibleColumns(); len(columns) > 0 {
+				strData[columns[0]] = heading
+			}
+		}
+		textColor := tview.Styles.PrimaryTextColor
+		if colored, ok := rowData.data.(ColoredRow); ok {
+			textColor = colored.TextColor()
+		}
 		colIndex := 0
-		for ; colIndex < len(strData); colIndex++ {
-			cellText := strData[colIndex]
-			cell := tview.NewTableCell(cellText)
-			if align, ok := st.columnAlign[colIndex]; ok {
+		for _, col := range st.visibleColumns() {
+			if col >= len(strData) {
+				break
+			}
+			cell := tview.NewTableCell(strData[col])
+			cell.SetTextColor(textColor)
+			if align, ok := st.columnAlign[col]; ok {
 				cell.Align = align
 			}
 			st.table.SetCell(rowIndex+1, colIndex, cell)
+			colIndex++
 		}
 		for ; colIndex < st.table.GetColumnCount(); colIndex++ {
 			cell := tview.NewTableCell("")
 			st.table.SetCell(rowIndex+1, colIndex, cell)
 		}
 	}
-	for st.table.GetRowCount() > len(st.values)+1 {
+	for st.table.GetRowCount() > len(st.visible)+1 {
 		st.table.RemoveRow(st.table.GetRowCount() - 1)
 	}
 }
@@ -338,6 +561,46 @@ func (st *SortedTable) Redraw() {
 	st.redrawHeaders()
 	selectedKey := st.GetSelection()
 	st.sortData()
+	st.filterData()
 	st.updateData()
 	st.Select(selectedKey)
 }
+
+// WriteText writes the title and the rows of the table as shown, sorted and filtered, as fixed
+// width text.  With colors, the cells keep their colour with ANSI escape sequences.
+func (st *SortedTable) WriteText(w io.Writer, colors bool) error {
+	st.Redraw()
+	widths := make([]int, st.table.GetColumnCount())
+	for row := 0; row < st.table.GetRowCount(); row++ {
+		for col := range widths {
+			if width := runewidth.StringWidth(st.table.GetCell(row, col).Text); width > widths[col] {
+				widths[col] = width
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(st.table.GetTitle()))
+	b.WriteString("\n")
+	for row := 0; row < st.table.GetRowCount(); row++ {
+		var line strings.Builder
+		for col, width := range widths {
+			cell := st.table.GetCell(row, col)
+			padding := strings.Repeat(" ", width-runewidth.StringWidth(cell.Text))
+			text := cell.Text + padding
+			if cell.Align == tview.AlignRight {
+				text = padding + cell.Text
+			}
+			if r, g, b := cell.Color.RGB(); colors && cell.Color != tview.Styles.PrimaryTextColor && r >= 0 {
+				text = fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", r, g, b, text)
+			}
+			if col > 0 {
+				line.WriteString(" ")
+			}
+			line.WriteString(text)
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
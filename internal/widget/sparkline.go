@@ -0,0 +1,130 @@
+package widget
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// brailleDots are the bits of the dots of a braille character, by column and by row from the top.
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// asciiLevels are the characters of the ASCII graph, from a third of a cell to a full cell.
+var asciiLevels = []rune{'.', ':', '|'}
+
+// Sparkline draws a series of values as a small bar graph filling the box, oldest on the left.
+// With braille characters each cell holds two values and four levels, for terminals without a
+// braille font the ASCII graph holds one value and three levels per cell.  Negative values are
+// missing and left blank.
+type Sparkline struct {
+	*tview.Box
+	values []float64
+	max    float64
+	ascii  bool
+	color  tcell.Color
+}
+
+func NewSparkline() *Sparkline {
+	return &Sparkline{
+		Box:   tview.NewBox(),
+		color: tcell.ColorGreen,
+	}
+}
+
+// SetData replaces the values.  The top of the graph is max, or the largest value when max is 0.
+func (sl *Sparkline) SetData(values []float64, max float64) *Sparkline {
+	sl.values = values
+	sl.max = max
+	return sl
+}
+
+// SetASCII draws the graph with ASCII characters instead of braille.
+func (sl *Sparkline) SetASCII(ascii bool) *Sparkline {
+	sl.ascii = ascii
+	return sl
+}
+
+// value returns the value shown in column col of columns, stretching or squeezing the series to
+// the width of the graph.  The last column always shows the latest value.
+func (sl *Sparkline) value(col, columns int) float64 {
+	if len(sl.values) == 0 || columns == 0 {
+		return -1
+	}
+	index := (col+1)*len(sl.values)/columns - 1
+	if index < 0 {
+		index = 0
+	}
+	return sl.values[index]
+}
+
+// level returns the height of value in steps out of steps, at least one step for any value above 0.
+func (sl *Sparkline) level(value, max float64, steps int) int {
+	if value <= 0 || max <= 0 {
+		return 0
+	}
+	level := int(value/max*float64(steps) + 0.5)
+	if level < 1 {
+		level = 1
+	}
+	if level > steps {
+		level = steps
+	}
+	return level
+}
+
+func (sl *Sparkline) Draw(screen tcell.Screen) {
+	sl.Box.DrawForSubclass(screen, sl)
+	x, y, width, height := sl.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	max := sl.max
+	if max <= 0 {
+		for _, v := range sl.values {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	style := tcell.StyleDefault.Foreground(sl.color).Background(tview.Styles.PrimitiveBackgroundColor)
+
+	if sl.ascii {
+		steps := len(asciiLevels)
+		for col := 0; col < width; col++ {
+			level := sl.level(sl.value(col, width), max, height*steps)
+			for row := 0; row < height; row++ {
+				fill := level - (height-1-row)*steps
+				if fill <= 0 {
+					continue
+				}
+				if fill > steps {
+					fill = steps
+				}
+				screen.SetContent(x+col, y+row, asciiLevels[fill-1], nil, style)
+			}
+		}
+		return
+	}
+
+	for col := 0; col < width; col++ {
+		var levels [2]int
+		for half := range levels {
+			levels[half] = sl.level(sl.value(col*2+half, width*2), max, height*4)
+		}
+		for row := 0; row < height; row++ {
+			var dots rune
+			for half := range levels {
+				for dot := 0; dot < 4; dot++ {
+					if (height-1-row)*4+(3-dot) < levels[half] {
+						dots |= brailleDots[half][dot]
+					}
+				}
+			}
+			if dots != 0 {
+				screen.SetContent(x+col, y+row, 0x2800+dots, nil, style)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"plotng/internal/format"
+)
+
+var fingerprintRegexp = regexp.MustCompile(`Fingerprint:\s*(\d+)`)
+
+// detectFingerprints lists the fingerprints of the keys installed in chia.
+func detectFingerprints() []string {
+	out, err := exec.Command("chia", "keys", "show").Output()
+	if err != nil {
+		return nil
+	}
+	var fingerprints []string
+	for _, match := range fingerprintRegexp.FindAllStringSubmatch(string(out), -1) {
+		fingerprints = append(fingerprints, match[1])
+	}
+	return fingerprints
+}
+
+// detectMounts lists the mount points of block devices, which are the candidates for temp and
+// target directories.  Only Linux is supported, other systems return nothing.
+func detectMounts() []string {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") || fields[1] == "/" || strings.HasPrefix(fields[1], "/boot") {
+			continue
+		}
+		mounts = append(mounts, strings.ReplaceAll(fields[1], `\040`, " "))
+	}
+	return mounts
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// RunSetupWizard asks a few questions and writes an initial configuration file to configPath.
+func RunSetupWizard(configPath string) error {
+	server := &Server{}
+	mounts := detectMounts()
+	fingerprints := append([]string{"(none - use public keys)"}, detectFingerprints()...)
+
+	app := tview.NewApplication()
+	form := tview.NewForm()
+	form.AddDropDown("Fingerprint", fingerprints, 0, nil)
+	form.AddInputField("Farmer Public Key", "", 60, nil, nil)
+	form.AddInputField("Pool Public Key", "", 60, nil, nil)
+	var tempChecks, targetChecks []*tview.Checkbox
+	for _, mount := range mounts {
+		space := format.Space(server.getDiskSpaceAvailable(mount))
+		check := tview.NewCheckbox().SetLabel(fmt.Sprintf("Temp %s (%s free)", mount, space))
+		tempChecks = append(tempChecks, check)
+		form.AddFormItem(check)
+	}
+	form.AddInputField("Other Temp Dirs", "", 60, nil, nil)
+	for _, mount := range mounts {
+		space := format.Space(server.getDiskSpaceAvailable(mount))
+		check := tview.NewCheckbox().SetLabel(fmt.Sprintf("Target %s (%s free)", mount, space))
+		targetChecks = append(targetChecks, check)
+		form.AddFormItem(check)
+	}
+	form.AddInputField("Other Target Dirs", "", 60, nil, nil)
+	form.AddInputField("Parallel Plots", "2", 4, tview.InputFieldInteger, nil)
+	form.AddInputField("Stagger (mins)", "30", 4, tview.InputFieldInteger, nil)
+	form.AddInputField("Threads", "2", 4, tview.InputFieldInteger, nil)
+
+	var saveErr error
+	saved := false
+	form.AddButton("Save", func() {
+		config := &Config{
+			PlotSize:        32,
+			ShowPlotLog:     false,
+			FarmerPublicKey: form.GetFormItemByLabel("Farmer Public Key").(*tview.InputField).GetText(),
+			PoolPublicKey:   form.GetFormItemByLabel("Pool Public Key").(*tview.InputField).GetText(),
+		}
+		if idx, _ := form.GetFormItemByLabel("Fingerprint").(*tview.DropDown).GetCurrentOption(); idx > 0 {
+			config.Fingerprint = fingerprints[idx]
+		}
+		for idx, mount := range mounts {
+			if tempChecks[idx].IsChecked() {
+				config.TempDirectory = append(config.TempDirectory, mount)
+			}
+			if targetChecks[idx].IsChecked() {
+				config.TargetDirectory = append(config.TargetDirectory, mount)
+			}
+		}
+		config.TempDirectory = append(config.TempDirectory, splitList(form.GetFormItemByLabel("Other Temp Dirs").(*tview.InputField).GetText())...)
+		config.TargetDirectory = append(config.TargetDirectory, splitList(form.GetFormItemByLabel("Other Target Dirs").(*tview.InputField).GetText())...)
+		config.NumberOfParallelPlots, _ = strconv.Atoi(form.GetFormItemByLabel("Parallel Plots").(*tview.InputField).GetText())
+		config.StaggeringDelay, _ = strconv.Atoi(form.GetFormItemByLabel("Stagger (mins)").(*tview.InputField).GetText())
+		config.Threads, _ = strconv.Atoi(form.GetFormItemByLabel("Threads").(*tview.InputField).GetText())
+
+		if len(config.TempDirectory) == 0 || len(config.TargetDirectory) == 0 {
+			form.SetTitle(" PlotNG Setup - select at least one temp and one target directory ")
+			return
+		}
+		if len(config.Fingerprint) == 0 && len(config.FarmerPublicKey) == 0 {
+			form.SetTitle(" PlotNG Setup - a fingerprint or a farmer public key is required ")
+			return
+		}
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(configPath, append(data, '\n'), 0644)
+		}
+		saveErr = err
+		saved = err == nil
+		app.Stop()
+	})
+	form.AddButton("Cancel", func() {
+		app.Stop()
+	})
+	title := " PlotNG Setup "
+	if _, err := os.Stat(configPath); err == nil {
+		title = fmt.Sprintf(" PlotNG Setup - %s exists and will be overwritten ", configPath)
+	}
+	form.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+
+	if err := app.SetRoot(form, true).EnableMouse(true).Run(); err != nil {
+		return err
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+	if saved {
+		fmt.Printf("Configuration written to %s\n", configPath)
+	}
+	return nil
+}
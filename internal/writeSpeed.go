@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// writeSpeedWindow is how long a finished copy counts towards the write speed of its destination.
+const writeSpeedWindow = 30 * time.Minute
+
+type writeSample struct {
+	time           time.Time
+	bytesPerSecond float64
+}
+
+// writeSpeeds records the throughput of the recent copies to each dest directory, measured by
+// PlotNG for the copies it makes and taken from the "Copy time" of the plotter otherwise.
+type writeSpeeds struct {
+	lock    sync.Mutex
+	samples map[string][]writeSample
+}
+
+func newWriteSpeeds() *writeSpeeds {
+	return &writeSpeeds{samples: map[string][]writeSample{}}
+}
+
+// record adds a copy of size bytes to dir which took elapsed, finishing at now.
+func (ws *writeSpeeds) record(dir string, size uint64, elapsed time.Duration, now time.Time) {
+	if ws == nil || size == 0 || elapsed <= 0 {
+		return
+	}
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	ws.samples[dir] = append(ws.expire(dir, now), writeSample{time: now, bytesPerSecond: float64(size) / elapsed.Seconds()})
+}
+
+// expire drops the samples of dir older than writeSpeedWindow.  The caller must hold ws.lock.
+func (ws *writeSpeeds) expire(dir string, now time.Time) []writeSample {
+	samples := ws.samples[dir]
+	for len(samples) > 0 && now.Sub(samples[0].time) > writeSpeedWindow {
+		samples = samples[1:]
+	}
+	return samples
+}
+
+// speeds returns the average throughput in bytes/s of the recent copies to every dest directory
+// with any.
+func (ws *writeSpeeds) speeds(now time.Time) map[string]float64 {
+	speeds := map[string]float64{}
+	if ws == nil {
+		return speeds
+	}
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	for dir := range ws.samples {
+		samples := ws.expire(dir, now)
+		ws.samples[dir] = samples
+		if len(samples) == 0 {
+			delete(ws.samples, dir)
+			continue
+		}
+		var sum float64
+		for _, sample := range samples {
+			sum += sample.bytesPerSecond
+		}
+		speeds[dir] = sum / float64(len(samples))
+	}
+	return speeds
+}
+
+// currentWriteSpeeds returns the write speed of the dest directories: the average rate of the
+// copies running to a directory, or the average throughput of its recent copies when none is
+// running.  Directories without any measurement are left out.  The caller must hold server.lock.
+func (server *Server) currentWriteSpeeds(now time.Time) map[string]float64 {
+	speeds := server.writeSpeeds.speeds(now)
+	running := map[string][]float64{}
+	for _, plot := range server.active {
+		if status := plot.Snapshot(); status.State == PlotCopying && status.TransferRate > 0 {
+			running[status.TargetDir] = append(running[status.TargetDir], float64(status.TransferRate))
+		}
+	}
+	for dir, rates := range running {
+		var sum float64
+		for _, rate := range rates {
+			sum += rate
+		}
+		speeds[dir] = sum / float64(len(rates))
+	}
+	return speeds
+}